@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"log/slog"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+
+	"rosetta/redact"
+)
+
+// accessLog writes one JSON line per request. It goes through the same
+// redaction as the standard logger since paths and panics can carry emails
+// or tokens.
+var accessLog = slog.New(slog.NewJSONHandler(redact.Writer(os.Stderr), nil))
+
+type requestIDKey struct{}
+
+// requestID returns the ID logRequests assigned to the request in ctx.
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// statusRecorder remembers the status written through it for logging.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// logRequests logs method, path, status, latency and request ID of every
+// request. The ID is taken from X-Request-ID when a proxy already set one
+// and is echoed back in the response.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get("X-Request-ID")
+		if id == "" || len(id) > 128 {
+			id = ulid.MustNew(ulid.Now(), rand.Reader).String()
+		}
+		w.Header().Set("X-Request-ID", id)
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		accessLog.Info("request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", status,
+			"latency_ms", float64(time.Since(start).Microseconds())/1000,
+			"request_id", id,
+		)
+	})
+}
+
+// recoverPanics turns a panicking handler into a 500 and logs the stack,
+// instead of net/http dropping the connection.
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			// Let net/http abort the response as it means to
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+			accessLog.Error("panic",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"request_id", requestID(r.Context()),
+				"panic", p,
+				"stack", string(debug.Stack()),
+			)
+			// Too late for a status if the handler already wrote one
+			if rec, ok := w.(*statusRecorder); ok && rec.status != 0 {
+				return
+			}
+			httpError(w, "Internal server error", http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
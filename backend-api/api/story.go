@@ -0,0 +1,338 @@
+// Package api defines the request and response bodies of the HTTP API. They
+// are mapped to and from the persisted models explicitly, so storage-only
+// fields never leak into responses.
+package api
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/models"
+)
+
+type StoryRequest struct {
+	Title      string           `json:"title"`
+	Language   string           `json:"language"`
+	Segments   []SegmentRequest `json:"segments"`
+	Characters []Character      `json:"characters"`
+	// AudioTracks left out keeps what the story has; [] removes them
+	AudioTracks     []AudioTrack `json:"audio_tracks,omitempty"`
+	IsPublished     bool         `json:"is_published"`
+	Visibility      string       `json:"visibility"`
+	AgeRating       string       `json:"age_rating"`
+	ContentWarnings []string     `json:"content_warnings"`
+	// Metadata left out keeps what the story has; {} clears it
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+type SegmentRequest struct {
+	ID      primitive.ObjectID `json:"id"`
+	Audio   *Audio             `json:"audio,omitempty"`
+	Image   *Image             `json:"image,omitempty"`
+	Script  *Script            `json:"script,omitempty"`
+	Speaker string             `json:"speaker,omitempty"`
+	Clip    *AudioClip         `json:"clip,omitempty"`
+}
+
+type StoryResponse struct {
+	ID              primitive.ObjectID   `json:"id"`
+	Title           string               `json:"title"`
+	Language        string               `json:"language"`
+	Segments        []SegmentResponse    `json:"segments"`
+	Characters      []Character          `json:"characters"`
+	AudioTracks     []AudioTrackResponse `json:"audio_tracks,omitempty"`
+	IsPublished     bool                 `json:"is_published"`
+	Visibility      string               `json:"visibility"`
+	AgeRating       string               `json:"age_rating,omitempty"`
+	ContentWarnings []string             `json:"content_warnings"`
+	Metadata        map[string]string    `json:"metadata,omitempty"`
+	Version         int64                `json:"version"`
+	ContentHash     string               `json:"content_hash,omitempty"`
+	OwnerID         *primitive.ObjectID  `json:"owner_id,omitempty"`
+	PublishedAt     *time.Time           `json:"published_at,omitempty"`
+	Lock            *Lock                `json:"lock,omitempty"`
+	CreatedAt       time.Time            `json:"created_at"`
+	UpdatedAt       time.Time            `json:"updated_at"`
+}
+
+// Lock is the advisory edit lock, shown only while it is live.
+type Lock struct {
+	Holder     string    `json:"holder"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type SegmentResponse struct {
+	ID      primitive.ObjectID `json:"id"`
+	Audio   *AudioResponse     `json:"audio,omitempty"`
+	Image   *Image             `json:"image,omitempty"`
+	Script  *Script            `json:"script,omitempty"`
+	Speaker string             `json:"speaker,omitempty"`
+	Version int64              `json:"version"`
+	// Translation is the script in the language the reader asked for, when
+	// the segment has been translated into it
+	Translation *Translation `json:"translation,omitempty"`
+	// Clip is set instead of audio for segments playing part of a track
+	Clip *AudioClipResponse `json:"clip,omitempty"`
+}
+
+type Character struct {
+	Name  string `json:"name"`
+	Color string `json:"color,omitempty"`
+	Voice string `json:"voice,omitempty"`
+}
+
+type Audio struct {
+	URL string `json:"url"`
+}
+
+// AudioResponse describes uploaded audio; the metadata is absent for
+// external URLs and uploads completed before it was recorded.
+type AudioResponse struct {
+	URL         string `json:"url"`
+	Size        int64  `json:"size,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	DurationMs  int64  `json:"duration_ms,omitempty"`
+	// OriginalURL is the upload as it was, once URL plays its transcoded copy
+	OriginalURL string `json:"original_url,omitempty"`
+	// Warnings are problems found in the recording that recording it again
+	// fixes: clipping, noisy or quiet
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// AudioTrack is a recording several segments play parts of. Tracks
+// uploaded through the API are sent back with the URL they were given.
+type AudioTrack struct {
+	ID  primitive.ObjectID `json:"id"`
+	URL string             `json:"url"`
+}
+
+type AudioTrackResponse struct {
+	ID          primitive.ObjectID `json:"id"`
+	URL         string             `json:"url"`
+	Size        int64              `json:"size,omitempty"`
+	ContentType string             `json:"content_type,omitempty"`
+	DurationMs  int64              `json:"duration_ms,omitempty"`
+}
+
+// AudioClip is the range of an audio track a segment plays, in milliseconds.
+type AudioClip struct {
+	TrackID primitive.ObjectID `json:"track_id"`
+	StartMs int64              `json:"start_ms"`
+	EndMs   int64              `json:"end_ms"`
+}
+
+// AudioClipResponse carries the URL of the track, so players don't have to
+// look it up. It is absent while the track's upload is pending.
+type AudioClipResponse struct {
+	TrackID primitive.ObjectID `json:"track_id"`
+	URL     string             `json:"url,omitempty"`
+	StartMs int64              `json:"start_ms"`
+	EndMs   int64              `json:"end_ms"`
+}
+
+type Image struct {
+	URL string `json:"url"`
+}
+
+type Script struct {
+	Text string `json:"text"`
+	// Language left out means the story's language
+	Language string `json:"language,omitempty"`
+	// Translations left out keep what the segment has; {} clears them
+	Translations map[string]string `json:"translations,omitempty"`
+}
+
+type Translation struct {
+	Language string `json:"language"`
+	Text     string `json:"text"`
+}
+
+type TranslationRequest struct {
+	Text string `json:"text"`
+}
+
+func (r *StoryRequest) ToModel() models.Story {
+	story := models.Story{
+		Title:           r.Title,
+		Language:        r.Language,
+		IsPublished:     r.IsPublished,
+		Visibility:      r.Visibility,
+		AgeRating:       r.AgeRating,
+		ContentWarnings: r.ContentWarnings,
+		Metadata:        r.Metadata,
+	}
+	if r.Segments != nil {
+		story.Segments = make([]models.Segment, 0, len(r.Segments))
+		for _, segment := range r.Segments {
+			story.Segments = append(story.Segments, segment.ToModel())
+		}
+	}
+	for _, character := range r.Characters {
+		story.Characters = append(story.Characters, models.Character(character))
+	}
+	if r.AudioTracks != nil {
+		story.AudioTracks = make([]models.AudioTrack, 0, len(r.AudioTracks))
+		for _, track := range r.AudioTracks {
+			story.AudioTracks = append(story.AudioTracks, models.AudioTrack{ID: track.ID, Audio: models.Audio{Url: track.URL}})
+		}
+	}
+	return story
+}
+
+func (r *SegmentRequest) ToModel() models.Segment {
+	segment := models.Segment{ID: r.ID, Speaker: r.Speaker}
+	if r.Audio != nil {
+		segment.Audio = &models.Audio{Url: r.Audio.URL}
+	}
+	if r.Image != nil {
+		segment.Image = &models.Image{Url: r.Image.URL}
+	}
+	if r.Script != nil {
+		segment.Script = &models.Script{Text: r.Script.Text, Language: r.Script.Language, Translations: r.Script.Translations}
+	}
+	if r.Clip != nil {
+		segment.Clip = &models.AudioClip{TrackID: r.Clip.TrackID, StartMs: r.Clip.StartMs, EndMs: r.Clip.EndMs}
+	}
+	return segment
+}
+
+// StoryRequestFromModel is the inverse of ToModel, e.g. to patch a stored
+// story as if the client had sent it in full.
+func StoryRequestFromModel(story *models.Story) StoryRequest {
+	request := StoryRequest{
+		Title:           story.Title,
+		Language:        story.Language,
+		Segments:        make([]SegmentRequest, 0, len(story.Segments)),
+		Characters:      make([]Character, 0, len(story.Characters)),
+		IsPublished:     story.IsPublished,
+		Visibility:      story.Visibility,
+		AgeRating:       story.AgeRating,
+		ContentWarnings: story.ContentWarnings,
+		Metadata:        story.Metadata,
+	}
+	for _, segment := range story.Segments {
+		response := SegmentFromModel(&segment, nil)
+		var audio *Audio
+		if response.Audio != nil {
+			audio = &Audio{URL: response.Audio.URL}
+		}
+		var clip *AudioClip
+		if response.Clip != nil {
+			clip = &AudioClip{TrackID: response.Clip.TrackID, StartMs: response.Clip.StartMs, EndMs: response.Clip.EndMs}
+		}
+		request.Segments = append(request.Segments, SegmentRequest{
+			ID:      response.ID,
+			Audio:   audio,
+			Image:   response.Image,
+			Script:  response.Script,
+			Speaker: response.Speaker,
+			Clip:    clip,
+		})
+	}
+	// Tracks still waiting for their upload are kept without being sent
+	for _, track := range story.AudioTracks {
+		if track.Url != "" {
+			request.AudioTracks = append(request.AudioTracks, AudioTrack{ID: track.ID, URL: track.Url})
+		}
+	}
+	for _, character := range story.Characters {
+		request.Characters = append(request.Characters, Character(character))
+	}
+	return request
+}
+
+func StoryFromModel(story *models.Story) StoryResponse {
+	response := StoryResponse{
+		ID:              story.ID,
+		Title:           story.Title,
+		Language:        story.Language,
+		Segments:        make([]SegmentResponse, 0, len(story.Segments)),
+		Characters:      make([]Character, 0, len(story.Characters)),
+		IsPublished:     story.IsPublished,
+		Visibility:      story.Visibility,
+		AgeRating:       story.AgeRating,
+		ContentWarnings: append([]string{}, story.ContentWarnings...),
+		Metadata:        story.Metadata,
+		Version:         story.Version,
+		ContentHash:     story.ContentHash,
+		PublishedAt:     story.PublishedAt,
+		CreatedAt:       story.CreatedAt,
+		UpdatedAt:       story.UpdatedAt,
+	}
+	tracks := make(map[primitive.ObjectID]*models.AudioTrack, len(story.AudioTracks))
+	for i, track := range story.AudioTracks {
+		tracks[track.ID] = &story.AudioTracks[i]
+		// A track with only a pending upload has nothing to play yet
+		if track.Url == "" {
+			continue
+		}
+		response.AudioTracks = append(response.AudioTracks, AudioTrackResponse{
+			ID:          track.ID,
+			URL:         track.Url,
+			Size:        track.Size,
+			ContentType: track.ContentType,
+			DurationMs:  track.DurationMs,
+		})
+	}
+	for i := range story.Segments {
+		response.Segments = append(response.Segments, SegmentFromModel(&story.Segments[i], tracks))
+	}
+	for _, character := range story.Characters {
+		response.Characters = append(response.Characters, Character(character))
+	}
+	if response.Visibility == "" {
+		response.Visibility = models.VisibilityPublic
+	}
+	if !story.OwnerID.IsZero() {
+		ownerID := story.OwnerID
+		response.OwnerID = &ownerID
+	}
+	if story.Lock != nil && story.Lock.ExpiresAt.After(time.Now()) {
+		response.Lock = &Lock{Holder: story.Lock.Holder, AcquiredAt: story.Lock.AcquiredAt, ExpiresAt: story.Lock.ExpiresAt}
+	}
+	return response
+}
+
+func StoriesFromModels(stories []models.Story) []StoryResponse {
+	responses := make([]StoryResponse, 0, len(stories))
+	for i := range stories {
+		responses = append(responses, StoryFromModel(&stories[i]))
+	}
+	return responses
+}
+
+// SegmentFromModel maps a segment for a response. tracks are the audio
+// tracks of its story by ID, for the URL of its clip.
+func SegmentFromModel(segment *models.Segment, tracks map[primitive.ObjectID]*models.AudioTrack) SegmentResponse {
+	response := SegmentResponse{ID: segment.ID, Speaker: segment.Speaker, Version: segment.Version}
+	// A segment with only a pending upload has no playable audio yet
+	if segment.Audio != nil && segment.Audio.Url != "" {
+		response.Audio = &AudioResponse{
+			URL:         segment.Audio.Url,
+			Size:        segment.Audio.Size,
+			ContentType: segment.Audio.ContentType,
+			DurationMs:  segment.Audio.DurationMs,
+			OriginalURL: segment.Audio.OriginalUrl,
+			Warnings:    segment.Audio.Warnings,
+		}
+	}
+	if segment.Image != nil && segment.Image.Url != "" {
+		response.Image = &Image{URL: segment.Image.Url}
+	}
+	if segment.Script != nil {
+		response.Script = &Script{Text: segment.Script.Text, Language: segment.Script.Language, Translations: segment.Script.Translations}
+	}
+	if segment.Clip != nil {
+		response.Clip = &AudioClipResponse{
+			TrackID: segment.Clip.TrackID,
+			StartMs: segment.Clip.StartMs,
+			EndMs:   segment.Clip.EndMs,
+		}
+		if track, ok := tracks[segment.Clip.TrackID]; ok {
+			response.Clip.URL = track.Url
+		}
+	}
+	return response
+}
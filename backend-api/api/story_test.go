@@ -0,0 +1,118 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/models"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+func objectID(t *testing.T, hex string) primitive.ObjectID {
+	t.Helper()
+	id, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// TestStoryFromModelGolden compares serialized responses to the files in
+// testdata; run with -update to rewrite them after an intended change.
+// Storage-only fields are set throughout, so a leak shows up as a diff.
+func TestStoryFromModelGolden(t *testing.T) {
+	created := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	published := created.Add(48 * time.Hour)
+	trackID := objectID(t, "65e1a0000000000000000003")
+	full := models.Story{
+		ID:         objectID(t, "65e1a0000000000000000001"),
+		Title:      "The Fox and the Grapes",
+		Language:   "en",
+		Characters: []models.Character{{Name: "Fox", Color: "#d2691e", Voice: "Matthew"}},
+		AudioTracks: []models.AudioTrack{
+			{ID: trackID, Audio: models.Audio{Url: "https://media.example.com/track.mp3", Key: "stories/1/track.mp3", Size: 4096, ContentType: "audio/mpeg", DurationMs: 60000}},
+			{ID: objectID(t, "65e1a0000000000000000004"), Audio: models.Audio{PendingKey: "stories/1/pending.mp3"}},
+		},
+		Segments: []models.Segment{
+			{
+				ID:      objectID(t, "65e1a0000000000000000002"),
+				Speaker: "Fox",
+				Version: 3,
+				Script:  &models.Script{Text: "What lovely grapes.", Translations: map[string]string{"es": "Qué uvas tan bonitas."}},
+				Audio: &models.Audio{
+					Url: "https://media.example.com/a.mp3", Key: "stories/1/a.mp3", PendingKey: "stories/1/b.mp3",
+					Size: 2048, ContentType: "audio/mpeg", DurationMs: 1500,
+					OriginalUrl: "https://media.example.com/a.wav", OriginalKey: "stories/1/a.wav",
+					Warnings: []string{"quiet"},
+				},
+				Image: &models.Image{Url: "https://media.example.com/a.png", Key: "stories/1/a.png"},
+			},
+			{
+				ID:     objectID(t, "65e1a0000000000000000005"),
+				Script: &models.Script{Text: "Too sour anyway.", Language: "fr"},
+				Clip:   &models.AudioClip{TrackID: trackID, StartMs: 1000, EndMs: 2500},
+				Image:  &models.Image{PendingKey: "stories/1/pending.png"},
+			},
+		},
+		IsPublished:        true,
+		Visibility:         models.VisibilityUnlisted,
+		AgeRating:          "7+",
+		ContentWarnings:    []string{"frightening"},
+		Metadata:           map[string]string{"crm_id": "42"},
+		Version:            7,
+		OwnerID:            objectID(t, "65e1a00000000000000000ff"),
+		PublishedAt:        &published,
+		ContentFingerprint: "fingerprint",
+		ContentHash:        "sha256:abc",
+		Moderation:         &models.Moderation{Status: models.ModerationFlagged, Score: 0.7, Reasons: []string{"spam"}},
+		Lock:               &models.EditLock{Holder: "tab-1", AcquiredAt: created, ExpiresAt: time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)},
+		CreatedAt:          created,
+		UpdatedAt:          published,
+	}
+	minimal := models.Story{
+		ID:        objectID(t, "65e1a0000000000000000010"),
+		Title:     "Draft",
+		Language:  "es",
+		CreatedAt: created,
+		UpdatedAt: created,
+	}
+
+	tests := []struct {
+		golden string
+		story  models.Story
+	}{
+		{"story.golden", full},
+		{"story_minimal.golden", minimal},
+	}
+	for _, test := range tests {
+		t.Run(test.golden, func(t *testing.T) {
+			got, err := json.MarshalIndent(StoryFromModel(&test.story), "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			path := filepath.Join("testdata", test.golden)
+			if *update {
+				if err := os.WriteFile(path, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("StoryFromModel output differs from %s:\n%s", path, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"rosetta/domain"
+	"rosetta/models"
+)
+
+const (
+	apiKeyPrefix       = "rk_"
+	apiKeyHeader       = "X-API-Key"
+	maxAPIKeyName      = 100
+	apiKeyTouchEvery   = time.Hour
+	apiKeyDisplayChars = 8
+)
+
+var errInvalidAPIKey = domain.New(domain.ErrUnauthenticated, "Invalid API key")
+
+type apiKeyRequest struct {
+	Name string `json:"name"`
+}
+
+// apiKeyResponse carries the key itself only when it is created.
+type apiKeyResponse struct {
+	models.APIKey
+	Key string `json:"key,omitempty"`
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func createAPIKey(w http.ResponseWriter, r *http.Request) {
+	var request apiKeyRequest
+	err := decodeRequest(r, &request)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	name := strings.TrimSpace(request.Name)
+	if name == "" || len(name) > maxAPIKeyName {
+		httpError(w, "Name is required and must be at most 100 characters", http.StatusBadRequest)
+		return
+	}
+
+	secret := make([]byte, 32)
+	if _, err = rand.Read(secret); err != nil {
+		writeError(w, err)
+		return
+	}
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	userID, _ := currentUser(r.Context())
+	apiKey := models.APIKey{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    key[:len(apiKeyPrefix)+apiKeyDisplayChars],
+		Hash:      hashAPIKey(key),
+		CreatedAt: time.Now(),
+	}
+	_, err = apiKeysCollection().InsertOne(r.Context(), apiKey)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeResponse(w, r, http.StatusCreated, apiKeyResponse{APIKey: apiKey, Key: key})
+}
+
+func listAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID, _ := currentUser(r.Context())
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := apiKeysCollection().Find(r.Context(), bson.M{"user_id": userID}, opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	keys := []models.APIKey{}
+	if err = cursor.All(r.Context(), &keys); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeResponse(w, r, http.StatusOK, keys)
+}
+
+func deleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, "Invalid API key ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, _ := currentUser(r.Context())
+	res, err := apiKeysCollection().DeleteOne(r.Context(), bson.M{"_id": id, "user_id": userID})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if res.DeletedCount == 0 {
+		writeError(w, domain.New(domain.ErrNotFound, "API key not found"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// userFromAPIKey resolves an API key to its owner. Last use is recorded at
+// most hourly to keep reads cheap for chatty integrations.
+func userFromAPIKey(ctx context.Context, key string) (primitive.ObjectID, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return primitive.NilObjectID, errInvalidAPIKey
+	}
+
+	var apiKey models.APIKey
+	err := apiKeysCollection().FindOne(ctx, bson.M{"hash": hashAPIKey(key)}).Decode(&apiKey)
+	if err == mongo.ErrNoDocuments {
+		return primitive.NilObjectID, errInvalidAPIKey
+	}
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	now := time.Now()
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) > apiKeyTouchEvery {
+		apiKeysCollection().UpdateByID(ctx, apiKey.ID, bson.M{"$set": bson.M{"last_used_at": now}})
+	}
+	return apiKey.UserID, nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"rosetta/domain"
+	"rosetta/models"
+)
+
+type appealRequest struct {
+	Message string `json:"message"`
+}
+
+type appealDecision struct {
+	Outcome string `json:"outcome"`
+	Note    string `json:"note"`
+}
+
+type appealResponse struct {
+	ID        primitive.ObjectID `json:"id"`
+	StoryID   primitive.ObjectID `json:"story_id"`
+	Message   string             `json:"message"`
+	Status    string             `json:"status"`
+	Note      string             `json:"note,omitempty"`
+	CreatedAt time.Time          `json:"created_at"`
+	DecidedAt *time.Time         `json:"decided_at,omitempty"`
+}
+
+func newAppealResponse(appeal *models.Appeal) appealResponse {
+	return appealResponse{
+		ID:        appeal.ID,
+		StoryID:   appeal.StoryID,
+		Message:   appeal.Message,
+		Status:    appeal.Status,
+		Note:      appeal.Note,
+		CreatedAt: appeal.CreatedAt,
+		DecidedAt: appeal.DecidedAt,
+	}
+}
+
+func submitAppeal(w http.ResponseWriter, r *http.Request) {
+	storyID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, "Invalid story ID", http.StatusBadRequest)
+		return
+	}
+
+	var request appealRequest
+	err = decodeRequest(r, &request)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(request.Message) == "" {
+		httpError(w, "Missing message", http.StatusBadRequest)
+		return
+	}
+
+	story, err := findOwnStory(r.Context(), storyID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if story.Moderation == nil {
+		writeError(w, domain.New(domain.ErrConflict, "Story has no moderation action to appeal"))
+		return
+	}
+
+	count, err := appealsCollection().CountDocuments(r.Context(), bson.M{"story_id": storyID, "status": models.AppealPending})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if count > 0 {
+		writeError(w, domain.New(domain.ErrConflict, "An appeal for this story is already pending"))
+		return
+	}
+
+	appeal := models.Appeal{
+		ID:        primitive.NewObjectID(),
+		StoryID:   storyID,
+		Message:   request.Message,
+		Status:    models.AppealPending,
+		CreatedAt: time.Now(),
+	}
+	_, err = appealsCollection().InsertOne(r.Context(), appeal)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeResponse(w, r, http.StatusCreated, newAppealResponse(&appeal))
+}
+
+func listAppeals(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = models.AppealPending
+	}
+
+	cursor, err := appealsCollection().Find(r.Context(), bson.M{"status": status})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var appeals []models.Appeal
+	if err = cursor.All(r.Context(), &appeals); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	responses := make([]appealResponse, 0, len(appeals))
+	for i := range appeals {
+		responses = append(responses, newAppealResponse(&appeals[i]))
+	}
+	writeResponse(w, r, http.StatusOK, responses)
+}
+
+// decideAppeal moves a pending appeal to upheld or overturned. Overturning
+// lifts the moderation action, which puts the story back in feeds.
+func decideAppeal(w http.ResponseWriter, r *http.Request) {
+	appealID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, "Invalid appeal ID", http.StatusBadRequest)
+		return
+	}
+
+	var decision appealDecision
+	err = decodeRequest(r, &decision)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if decision.Outcome != models.AppealUpheld && decision.Outcome != models.AppealOverturned {
+		httpError(w, "Outcome must be upheld or overturned", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	var appeal models.Appeal
+	err = appealsCollection().FindOneAndUpdate(r.Context(),
+		bson.M{"_id": appealID, "status": models.AppealPending},
+		bson.M{"$set": bson.M{"status": decision.Outcome, "note": decision.Note, "decided_at": now}},
+	).Decode(&appeal)
+	if err == mongo.ErrNoDocuments {
+		writeError(w, domain.New(domain.ErrNotFound, "No pending appeal with this ID"))
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	action := models.ModerationActionUphold
+	if decision.Outcome == models.AppealOverturned {
+		action = models.ModerationActionRestore
+		_, err = storiesCollection().UpdateOne(r.Context(), bson.M{"_id": appeal.StoryID}, bson.M{"$unset": bson.M{"moderation": ""}})
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	err = recordModerationAction(r.Context(), appeal.StoryID, action, nil, false)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	appeal.Status = decision.Outcome
+	appeal.Note = decision.Note
+	appeal.DecidedAt = &now
+	writeResponse(w, r, http.StatusOK, newAppealResponse(&appeal))
+}
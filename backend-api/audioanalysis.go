@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/audioquality"
+	"rosetta/audiosplit"
+	"rosetta/domain"
+	"rosetta/models"
+	"rosetta/transcode"
+)
+
+const (
+	queueKindAnalyzeAudio = "analyze_audio"
+	maxAnalysisAttempts   = 5
+)
+
+// scheduleAudioAnalysis queues the analysis of the audio stored under key,
+// which a segment has just been given. Failing to queue is logged: the
+// segment goes without warnings.
+func scheduleAudioAnalysis(ctx context.Context, storyID, segmentID primitive.ObjectID, key string) {
+	err := enqueueJob(ctx, queueKindAnalyzeAudio, segmentAudioPayload{StoryID: storyID, SegmentID: segmentID, Key: key}, 0)
+	if err != nil {
+		log.Printf("failed to schedule analysis of %s: %v", key, err)
+	}
+}
+
+// runAudioAnalysisJob checks a segment's audio for problems the author
+// should record again to fix, and stores them as warnings on the audio for
+// the editor to show. The upload is analyzed even once the segment plays a
+// transcoded copy of it. WAV is read as is; other formats only get
+// analyzed when the transcoder can decode them.
+func runAudioAnalysisJob(ctx context.Context, payload bson.M) error {
+	var job segmentAudioPayload
+	if err := decodePayload(payload, &job); err != nil {
+		return err
+	}
+
+	story, err := findStory(ctx, job.StoryID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	segment := findSegment(&story, job.SegmentID)
+	if segment == nil || !hasAudio(segment, job.Key) {
+		return nil
+	}
+	bucket, head, err := headMedia(ctx, job.Key)
+	if isNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	recording := mediaReader{ctx: ctx, bucket: bucket, key: job.Key}
+	warnings, err := analyzeRecording(ctx, recording, *head.ContentLength)
+	if errors.Is(err, audiosplit.ErrUnsupported) || errors.Is(err, audiosplit.ErrMalformed) || errors.Is(err, transcode.ErrUnreadable) {
+		log.Printf("not analyzing %s: %v", job.Key, err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// Audio starts out without warnings
+	if len(warnings) == 0 {
+		return nil
+	}
+
+	err = updateSegmentIf(ctx, job.StoryID, job.SegmentID, func(segment *models.Segment) error {
+		if !hasAudio(segment, job.Key) {
+			return errAudioReplaced
+		}
+		return nil
+	}, bson.M{"audio.warnings": warnings})
+	if errors.Is(err, errAudioReplaced) || errors.Is(err, domain.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// hasAudio reports whether segment plays the audio stored under key, or a
+// transcoded copy of it.
+func hasAudio(segment *models.Segment, key string) bool {
+	return segment.Audio != nil && (segment.Audio.Key == key || segment.Audio.OriginalKey == key)
+}
+
+// analyzeRecording returns the warnings for the size bytes of recording,
+// decoding it to WAV first if it is in another format. It returns
+// audiosplit.ErrUnsupported for formats it can't decode.
+func analyzeRecording(ctx context.Context, recording io.ReaderAt, size int64) ([]string, error) {
+	wav, err := audiosplit.OpenWAV(recording, size)
+	if err == nil {
+		return audioquality.Analyze(wav)
+	}
+	decoder, ok := audioTranscoder.(transcode.Decoder)
+	if !errors.Is(err, audiosplit.ErrUnsupported) || !ok {
+		return nil, err
+	}
+
+	decoded, err := os.CreateTemp("", "decoded")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(decoded.Name())
+	defer decoded.Close()
+	if err = decoder.Decode(ctx, io.NewSectionReader(recording, 0, size), decoded); err != nil {
+		return nil, err
+	}
+	decodedSize, err := decoded.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	wav, err = audiosplit.OpenWAV(decoded, decodedSize)
+	if err != nil {
+		return nil, err
+	}
+	return audioquality.Analyze(wav)
+}
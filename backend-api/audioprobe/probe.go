@@ -0,0 +1,353 @@
+// Package audioprobe works out how long an audio file plays from its
+// headers, without decoding it. It reads through an io.ReaderAt and only
+// touches a few small ranges, so files can be probed in object storage
+// without downloading them.
+package audioprobe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+var (
+	ErrUnsupported = errors.New("audioprobe: unsupported format")
+	ErrMalformed   = errors.New("audioprobe: malformed file")
+)
+
+// Duration returns the playing time of the size bytes in r. MP3, WAV, MP4
+// (M4A) and Ogg (Vorbis, Opus) are recognised by their content, whatever
+// the file claims to be; other formats return ErrUnsupported.
+func Duration(r io.ReaderAt, size int64) (time.Duration, error) {
+	f := &file{r: io.NewSectionReader(r, 0, size), size: size}
+	magic, err := f.read(0, 12)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, []byte("RIFF")) && bytes.Equal(magic[8:12], []byte("WAVE")):
+		return wavDuration(f)
+	case bytes.Equal(magic[4:8], []byte("ftyp")):
+		return mp4Duration(f)
+	case bytes.HasPrefix(magic, []byte("OggS")):
+		return oggDuration(f)
+	case bytes.HasPrefix(magic, []byte("ID3")) || isFrameSync(magic):
+		return mp3Duration(f)
+	}
+	return 0, ErrUnsupported
+}
+
+type file struct {
+	r    *io.SectionReader
+	size int64
+}
+
+// read returns exactly n bytes at off, or ErrMalformed if the file ends
+// first.
+func (f *file) read(off, n int64) ([]byte, error) {
+	if off < 0 || n < 0 || off+n > f.size {
+		return nil, ErrMalformed
+	}
+	buf := make([]byte, n)
+	read, err := f.r.ReadAt(buf, off)
+	if read == len(buf) {
+		return buf, nil
+	}
+	if err == io.EOF {
+		err = ErrMalformed
+	}
+	return nil, err
+}
+
+func seconds(n, rate float64) time.Duration {
+	return time.Duration(n / rate * float64(time.Second))
+}
+
+// wavDuration divides the size of the data chunk by the byte rate of the
+// fmt chunk.
+func wavDuration(f *file) (time.Duration, error) {
+	var byteRate uint32
+	for off := int64(12); off+8 <= f.size; {
+		header, err := f.read(off, 8)
+		if err != nil {
+			return 0, err
+		}
+		id, size := string(header[:4]), int64(binary.LittleEndian.Uint32(header[4:]))
+		switch id {
+		case "fmt ":
+			fmtChunk, err := f.read(off+8, 16)
+			if err != nil {
+				return 0, err
+			}
+			byteRate = binary.LittleEndian.Uint32(fmtChunk[8:12])
+		case "data":
+			if byteRate == 0 {
+				return 0, ErrMalformed
+			}
+			// Streamed recordings may not know the size up front
+			size = min(size, f.size-off-8)
+			return seconds(float64(size), float64(byteRate)), nil
+		}
+		// Chunks are padded to an even size
+		off += 8 + size + size%2
+	}
+	return 0, ErrMalformed
+}
+
+// mp4Duration reads the duration and time scale from the movie header,
+// moov/mvhd, which may come before or after the media data.
+func mp4Duration(f *file) (time.Duration, error) {
+	moov, moovEnd, err := findBox(f, 0, f.size, "moov")
+	if err != nil {
+		return 0, err
+	}
+	mvhd, _, err := findBox(f, moov, moovEnd, "mvhd")
+	if err != nil {
+		return 0, err
+	}
+
+	version, err := f.read(mvhd, 1)
+	if err != nil {
+		return 0, err
+	}
+	var timescale, duration uint64
+	if version[0] == 1 {
+		b, err := f.read(mvhd+20, 12)
+		if err != nil {
+			return 0, err
+		}
+		timescale, duration = uint64(binary.BigEndian.Uint32(b)), binary.BigEndian.Uint64(b[4:])
+	} else {
+		b, err := f.read(mvhd+12, 8)
+		if err != nil {
+			return 0, err
+		}
+		timescale, duration = uint64(binary.BigEndian.Uint32(b)), uint64(binary.BigEndian.Uint32(b[4:]))
+	}
+	if timescale == 0 {
+		return 0, ErrMalformed
+	}
+	return seconds(float64(duration), float64(timescale)), nil
+}
+
+// findBox returns where the content of the first box of type name between
+// start and end begins and ends.
+func findBox(f *file, start, end int64, name string) (int64, int64, error) {
+	for off := start; off+8 <= end; {
+		header, err := f.read(off, 8)
+		if err != nil {
+			return 0, 0, err
+		}
+		size, headerSize := int64(binary.BigEndian.Uint32(header)), int64(8)
+		switch size {
+		case 0:
+			size = end - off
+		case 1:
+			large, err := f.read(off+8, 8)
+			if err != nil {
+				return 0, 0, err
+			}
+			size, headerSize = int64(binary.BigEndian.Uint64(large)), 16
+		}
+		if size < headerSize || off+size > end {
+			return 0, 0, ErrMalformed
+		}
+		if string(header[4:8]) == name {
+			return off + headerSize, off + size, nil
+		}
+		off += size
+	}
+	return 0, 0, ErrMalformed
+}
+
+// oggTail is how far from the end the last Ogg page is looked for. Pages
+// are at most 65307 bytes.
+const oggTail = 65536
+
+// oggDuration divides the granule position of the last page, a sample
+// count, by the sample rate from the identification header.
+func oggDuration(f *file) (time.Duration, error) {
+	header, err := f.read(0, 27)
+	if err != nil {
+		return 0, err
+	}
+	segments := int64(header[26])
+	packet, err := f.read(27+segments, min(64, f.size-27-segments))
+	if err != nil {
+		return 0, err
+	}
+
+	var rate float64
+	var preSkip int64
+	switch {
+	case len(packet) >= 16 && bytes.HasPrefix(packet, []byte("\x01vorbis")):
+		rate = float64(binary.LittleEndian.Uint32(packet[12:16]))
+	case len(packet) >= 12 && bytes.HasPrefix(packet, []byte("OpusHead")):
+		// Opus granule positions always count 48 kHz samples
+		rate = 48000
+		preSkip = int64(binary.LittleEndian.Uint16(packet[10:12]))
+	default:
+		return 0, ErrUnsupported
+	}
+	if rate == 0 {
+		return 0, ErrMalformed
+	}
+
+	tailStart := max(0, f.size-oggTail)
+	tail, err := f.read(tailStart, f.size-tailStart)
+	if err != nil {
+		return 0, err
+	}
+	last := bytes.LastIndex(tail, []byte("OggS"))
+	if last < 0 || last+14 > len(tail) {
+		return 0, ErrMalformed
+	}
+	granule := int64(binary.LittleEndian.Uint64(tail[last+6 : last+14]))
+	return seconds(float64(max(0, granule-preSkip)), rate), nil
+}
+
+func isFrameSync(b []byte) bool {
+	return len(b) >= 2 && b[0] == 0xFF && b[1]&0xE0 == 0xE0
+}
+
+// mp3Scan is how far past the tags the first MP3 frame is looked for.
+const mp3Scan = 4096
+
+var (
+	// Bitrates in kbit/s by MPEG version 1 or 2 (and 2.5), then layer I, II
+	// or III, then bitrate index
+	mp3Bitrates = [2][3][16]int{
+		{
+			{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448, 0},
+			{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 0},
+			{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+		},
+		{
+			{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256, 0},
+			{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+			{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+		},
+	}
+	mp3SampleRates = map[byte][3]int{
+		3: {44100, 48000, 32000}, // MPEG 1
+		2: {22050, 24000, 16000}, // MPEG 2
+		0: {11025, 12000, 8000},  // MPEG 2.5
+	}
+)
+
+type mp3Frame struct {
+	version     byte // 3 for MPEG 1, 2 for MPEG 2, 0 for MPEG 2.5
+	layer       int  // 1, 2 or 3
+	bitrate     int  // bit/s
+	sampleRate  int
+	mono        bool
+	samples     int // per frame
+	sideInfoLen int
+}
+
+func parseMP3Frame(b []byte) (mp3Frame, bool) {
+	if !isFrameSync(b) || len(b) < 4 {
+		return mp3Frame{}, false
+	}
+	frame := mp3Frame{version: b[1] >> 3 & 3, layer: 4 - int(b[1]>>1&3), mono: b[3]>>6 == 3}
+	rates, ok := mp3SampleRates[frame.version]
+	rateIndex, bitrateIndex := b[2]>>2&3, b[2]>>4
+	if !ok || frame.layer == 4 || rateIndex == 3 {
+		return mp3Frame{}, false
+	}
+	frame.sampleRate = rates[rateIndex]
+
+	mpeg1 := frame.version == 3
+	table := 1
+	if mpeg1 {
+		table = 0
+	}
+	frame.bitrate = mp3Bitrates[table][frame.layer-1][bitrateIndex] * 1000
+	if frame.bitrate == 0 {
+		return mp3Frame{}, false
+	}
+
+	switch {
+	case frame.layer == 1:
+		frame.samples = 384
+	case frame.layer == 3 && !mpeg1:
+		frame.samples = 576
+	default:
+		frame.samples = 1152
+	}
+	switch {
+	case mpeg1 && !frame.mono:
+		frame.sideInfoLen = 32
+	case mpeg1 || !frame.mono:
+		frame.sideInfoLen = 17
+	default:
+		frame.sideInfoLen = 9
+	}
+	return frame, true
+}
+
+// mp3Duration counts frames from a Xing or VBRI header when the encoder
+// wrote one, which variable bitrate files need, and otherwise assumes a
+// constant bitrate.
+func mp3Duration(f *file) (time.Duration, error) {
+	start := int64(0)
+	id3, err := f.read(0, 10)
+	if err != nil {
+		return 0, err
+	}
+	if bytes.HasPrefix(id3, []byte("ID3")) {
+		// The tag size is stored 7 bits per byte
+		size := int64(id3[6])<<21 | int64(id3[7])<<14 | int64(id3[8])<<7 | int64(id3[9])
+		start = 10 + size
+		if id3[5]&0x10 != 0 {
+			start += 10
+		}
+	}
+
+	window, err := f.read(start, min(mp3Scan, f.size-start))
+	if err != nil {
+		return 0, err
+	}
+	var frame mp3Frame
+	offset := -1
+	for i := 0; i+4 <= len(window); i++ {
+		var ok bool
+		if frame, ok = parseMP3Frame(window[i:]); ok {
+			offset = i
+			break
+		}
+	}
+	if offset < 0 {
+		return 0, ErrMalformed
+	}
+
+	header := window[offset:]
+	if frames, ok := vbrFrames(header, frame); ok {
+		return seconds(float64(frames*frame.samples), float64(frame.sampleRate)), nil
+	}
+
+	audio := f.size - start - int64(offset)
+	if tag, err := f.read(f.size-128, 128); err == nil && bytes.HasPrefix(tag, []byte("TAG")) {
+		audio -= 128
+	}
+	return seconds(float64(audio*8), float64(frame.bitrate)), nil
+}
+
+// vbrFrames reads the frame count from the Xing (or Info) or VBRI header in
+// the first frame.
+func vbrFrames(b []byte, frame mp3Frame) (int, bool) {
+	if xing := 4 + frame.sideInfoLen; len(b) >= xing+12 {
+		tag := string(b[xing : xing+4])
+		flags := binary.BigEndian.Uint32(b[xing+4:])
+		if (tag == "Xing" || tag == "Info") && flags&1 != 0 {
+			return int(binary.BigEndian.Uint32(b[xing+8:])), true
+		}
+	}
+	if vbri := 4 + 32; len(b) >= vbri+18 && string(b[vbri:vbri+4]) == "VBRI" {
+		return int(binary.BigEndian.Uint32(b[vbri+14:])), true
+	}
+	return 0, false
+}
@@ -0,0 +1,100 @@
+// Package audioquality finds the problems with a recording that an author
+// can fix by recording again: clipping, background noise and a voice too
+// quiet to hear. It reads 16-bit PCM WAV, like audiosplit; other formats
+// need decoding to WAV first.
+package audioquality
+
+import (
+	"math"
+	"slices"
+	"time"
+
+	"rosetta/audiosplit"
+)
+
+// Warnings, as stored on segment audio.
+const (
+	// Clipping: the recording was too loud and peaks were cut off
+	Clipping = "clipping"
+	// Noisy: the background is loud compared to the voice
+	Noisy = "noisy"
+	// Quiet: the voice is hard to hear at normal volume
+	Quiet = "quiet"
+)
+
+const (
+	window = 50 * time.Millisecond
+	// Speech is taken to be the loud windows and the noise floor the quiet
+	// ones, leaving out a few extremes either way
+	speechQuantile = 0.95
+	noiseQuantile  = 0.1
+	// minWindows for the noise floor to be told apart from pauses at all
+	minWindows = 20
+
+	fullScale = 32767
+	// Clipped peaks are runs of full-scale samples; single ones are loud
+	// but intact
+	minClipRun = 3
+	// maxClippedShare of the samples may be clipped before it is heard
+	maxClippedShare = 0.0001
+	// quietLevel is the speech level below which it is hard to hear, -30 dBFS
+	quietLevel = fullScale * 0.0316
+	// minSNR is the least ratio of speech to noise amplitude, 15 dB
+	minSNR = 5.6
+)
+
+// Analyze returns the warnings for w, if any, in the order of the constants
+// above. It reads the whole recording once.
+func Analyze(w *audiosplit.WAV) ([]string, error) {
+	var (
+		levels          []float64
+		samples         int64
+		clipped         int64
+		run, clippedRun []int64
+	)
+	err := w.Windows(window, func(window []int16) {
+		channels := w.Channels()
+		if run == nil {
+			run, clippedRun = make([]int64, channels), make([]int64, channels)
+		}
+		var sum float64
+		for i, sample := range window {
+			sum += float64(sample) * float64(sample)
+			c := i % channels
+			if sample >= fullScale || sample <= -fullScale {
+				run[c]++
+				// A run counts once it is long enough, then as it grows
+				if run[c] >= minClipRun {
+					clipped += run[c] - clippedRun[c]
+					clippedRun[c] = run[c]
+				}
+			} else {
+				run[c], clippedRun[c] = 0, 0
+			}
+		}
+		samples += int64(len(window))
+		levels = append(levels, math.Sqrt(sum/float64(len(window))))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(levels) == 0 {
+		return nil, nil
+	}
+
+	var warnings []string
+	if float64(clipped) > float64(samples)*maxClippedShare {
+		warnings = append(warnings, Clipping)
+	}
+	slices.Sort(levels)
+	speech := levels[int(float64(len(levels)-1)*speechQuantile)]
+	noise := levels[int(float64(len(levels)-1)*noiseQuantile)]
+	// Digital silence between words is no noise at all
+	if len(levels) >= minWindows && noise > 0 && speech < noise*minSNR {
+		warnings = append(warnings, Noisy)
+	}
+	if speech < quietLevel {
+		warnings = append(warnings, Quiet)
+	}
+	return warnings, nil
+}
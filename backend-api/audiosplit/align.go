@@ -0,0 +1,121 @@
+package audiosplit
+
+import (
+	"math"
+	"time"
+	"unicode/utf8"
+)
+
+// Align works out where the reading of each of parts starts and ends in a
+// recording lasting duration with the given pauses. It returns one more
+// boundary than there are parts: 0, the cuts between parts, then duration.
+//
+// Parts are assumed to take time in proportion to their length. Each cut
+// goes in the middle of a pause, choosing the pauses that stray the least
+// from the proportional estimates overall; with too few pauses, the
+// estimates are used as they are.
+func Align(duration time.Duration, pauses []Pause, parts []string) []time.Duration {
+	boundaries := make([]time.Duration, 0, len(parts)+1)
+	boundaries = append(boundaries, 0)
+	if len(parts) == 0 {
+		return boundaries
+	}
+
+	// Silence before and after the reading is left out of the estimates
+	speechStart, speechEnd := time.Duration(0), duration
+	if len(pauses) > 0 && pauses[0].Start == 0 {
+		speechStart = pauses[0].End
+		pauses = pauses[1:]
+	}
+	if len(pauses) > 0 && pauses[len(pauses)-1].End >= duration {
+		speechEnd = pauses[len(pauses)-1].Start
+		pauses = pauses[:len(pauses)-1]
+	}
+	if speechEnd < speechStart {
+		speechStart, speechEnd = 0, duration
+	}
+
+	total := 0
+	lengths := make([]int, len(parts))
+	for i, part := range parts {
+		lengths[i] = max(utf8.RuneCountInString(part), 1)
+		total += lengths[i]
+	}
+	estimates := make([]float64, len(parts)-1)
+	done := 0
+	for i := range estimates {
+		done += lengths[i]
+		estimates[i] = float64(speechStart) + float64(speechEnd-speechStart)*float64(done)/float64(total)
+	}
+
+	cuts := make([]time.Duration, len(estimates))
+	if picked := pickPauses(pauses, estimates); picked != nil {
+		for i, j := range picked {
+			cuts[i] = pauses[j].Start + (pauses[j].End-pauses[j].Start)/2
+		}
+	} else {
+		for i, estimate := range estimates {
+			cuts[i] = time.Duration(estimate)
+		}
+	}
+
+	boundaries = append(boundaries, cuts...)
+	return append(boundaries, duration)
+}
+
+// pickPauses assigns a pause to every estimate, in order, minimising the sum
+// of the squared distances between the estimates and the middles of their
+// pauses. It returns the index of each estimate's pause, or nil if there
+// are fewer pauses than estimates.
+func pickPauses(pauses []Pause, estimates []float64) []int {
+	k, n := len(estimates), len(pauses)
+	if k == 0 || n < k {
+		return nil
+	}
+	middles := make([]float64, n)
+	for j, pause := range pauses {
+		middles[j] = float64(pause.Start + (pause.End-pause.Start)/2)
+	}
+
+	// cost[j] is the least cost of placing estimates 0..i with estimate i at
+	// pause j; from[i][j] is where estimate i-1 went to get there
+	cost := make([]float64, n)
+	next := make([]float64, n)
+	from := make([][]int32, k)
+	for j := range cost {
+		d := middles[j] - estimates[0]
+		cost[j] = d * d
+	}
+	for i := 1; i < k; i++ {
+		from[i] = make([]int32, n)
+		best, bestAt := math.Inf(1), int32(-1)
+		for j := 0; j < n; j++ {
+			next[j] = math.Inf(1)
+			if bestAt >= 0 {
+				d := middles[j] - estimates[i]
+				next[j] = best + d*d
+				from[i][j] = bestAt
+			}
+			// Pause j is free for estimate i-1 when estimate i comes later
+			if cost[j] < best {
+				best, bestAt = cost[j], int32(j)
+			}
+		}
+		cost, next = next, cost
+	}
+
+	last := 0
+	for j := range cost {
+		if cost[j] < cost[last] {
+			last = j
+		}
+	}
+	picked := make([]int, k)
+	for i := k - 1; i >= 0; i-- {
+		picked[i] = last
+		if i > 0 {
+			last = int(from[i][last])
+		}
+	}
+	return picked
+}
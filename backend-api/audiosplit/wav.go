@@ -0,0 +1,221 @@
+// Package audiosplit cuts a recording of a script read aloud into one piece
+// per part of the script, such as a sentence. Without speech recognition,
+// parts are lined up with the pauses of the reader: boundaries are expected
+// where each part's share of the text puts them, and moved to the nearest
+// pause. Like audioprobe, it reads through an io.ReaderAt, so recordings
+// can be split in object storage without downloading them whole.
+package audiosplit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"slices"
+	"time"
+)
+
+var (
+	ErrUnsupported = errors.New("audiosplit: only 16-bit PCM WAV recordings can be split")
+	ErrMalformed   = errors.New("audiosplit: malformed file")
+)
+
+// WAV is a 16-bit PCM recording.
+type WAV struct {
+	r          io.ReaderAt
+	channels   int
+	sampleRate int
+	blockAlign int
+	dataOff    int64
+	dataSize   int64
+}
+
+// OpenWAV reads the headers of the size bytes in r.
+func OpenWAV(r io.ReaderAt, size int64) (*WAV, error) {
+	header := make([]byte, 12)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		if err == io.EOF {
+			err = ErrUnsupported
+		}
+		return nil, err
+	}
+	if !bytes.Equal(header[:4], []byte("RIFF")) || !bytes.Equal(header[8:], []byte("WAVE")) {
+		return nil, ErrUnsupported
+	}
+
+	w := &WAV{r: r}
+	chunk := make([]byte, 8)
+	for off := int64(12); off+8 <= size; {
+		if _, err := r.ReadAt(chunk, off); err != nil {
+			return nil, err
+		}
+		id, chunkSize := string(chunk[:4]), int64(binary.LittleEndian.Uint32(chunk[4:]))
+		switch id {
+		case "fmt ":
+			if chunkSize < 16 {
+				return nil, ErrMalformed
+			}
+			fmtChunk := make([]byte, 16)
+			if _, err := r.ReadAt(fmtChunk, off+8); err != nil {
+				return nil, err
+			}
+			format := binary.LittleEndian.Uint16(fmtChunk[0:2])
+			bits := binary.LittleEndian.Uint16(fmtChunk[14:16])
+			if format != 1 || bits != 16 {
+				return nil, ErrUnsupported
+			}
+			w.channels = int(binary.LittleEndian.Uint16(fmtChunk[2:4]))
+			w.sampleRate = int(binary.LittleEndian.Uint32(fmtChunk[4:8]))
+			w.blockAlign = w.channels * 2
+		case "data":
+			if w.sampleRate == 0 || w.channels == 0 {
+				return nil, ErrMalformed
+			}
+			w.dataOff = off + 8
+			// Streamed recordings may not know the size up front
+			w.dataSize = min(chunkSize, size-w.dataOff)
+			w.dataSize -= w.dataSize % int64(w.blockAlign)
+			return w, nil
+		}
+		// Chunks are padded to an even size
+		off += 8 + chunkSize + chunkSize%2
+	}
+	return nil, ErrMalformed
+}
+
+func (w *WAV) frames() int64 {
+	return w.dataSize / int64(w.blockAlign)
+}
+
+// Channels is the number of samples in each frame.
+func (w *WAV) Channels() int {
+	return w.channels
+}
+
+func (w *WAV) Duration() time.Duration {
+	return w.frameTime(w.frames())
+}
+
+func (w *WAV) frameTime(frame int64) time.Duration {
+	return time.Duration(frame * int64(time.Second) / int64(w.sampleRate))
+}
+
+func (w *WAV) frameAt(t time.Duration) int64 {
+	return min(max(int64(t)*int64(w.sampleRate)/int64(time.Second), 0), w.frames())
+}
+
+// Cut returns the part of the recording from start to end as a WAV file of
+// its own.
+func (w *WAV) Cut(start, end time.Duration) ([]byte, error) {
+	from, to := w.frameAt(start), w.frameAt(end)
+	size := max(to-from, 0) * int64(w.blockAlign)
+
+	file := make([]byte, 44+size)
+	copy(file[0:], "RIFF")
+	binary.LittleEndian.PutUint32(file[4:], uint32(36+size))
+	copy(file[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(file[16:], 16)
+	binary.LittleEndian.PutUint16(file[20:], 1)
+	binary.LittleEndian.PutUint16(file[22:], uint16(w.channels))
+	binary.LittleEndian.PutUint32(file[24:], uint32(w.sampleRate))
+	binary.LittleEndian.PutUint32(file[28:], uint32(w.sampleRate*w.blockAlign))
+	binary.LittleEndian.PutUint16(file[32:], uint16(w.blockAlign))
+	binary.LittleEndian.PutUint16(file[34:], 16)
+	copy(file[36:], "data")
+	binary.LittleEndian.PutUint32(file[40:], uint32(size))
+
+	if size > 0 {
+		if _, err := w.r.ReadAt(file[44:], w.dataOff+from*int64(w.blockAlign)); err != nil && err != io.EOF {
+			return nil, err
+		}
+	}
+	return file, nil
+}
+
+// Pause is a stretch of the recording where nobody speaks.
+type Pause struct {
+	Start, End time.Duration
+}
+
+const (
+	// pauseWindow is the stretch of audio whose loudness is measured at once
+	pauseWindow = 20 * time.Millisecond
+	// Quiet windows are 20 dB below the loud ones, which are taken to be
+	// speech: the 95th percentile, so a few peaks don't count
+	quietRatio      = 0.1
+	speechQuantile  = 0.95
+	readBufferBytes = 1 << 20
+)
+
+// Windows calls fn with the samples of each stretch of length in turn,
+// channels interleaved; the last stretch may be shorter. The slice is
+// reused between calls. It reads the whole recording once.
+func (w *WAV) Windows(length time.Duration, fn func(samples []int16)) error {
+	windowFrames := max(w.frameAt(length), 1)
+	reader := bufio.NewReaderSize(io.NewSectionReader(w.r, w.dataOff, w.dataSize), readBufferBytes)
+
+	block := make([]byte, windowFrames*int64(w.blockAlign))
+	samples := make([]int16, windowFrames*int64(w.channels))
+	for frame := int64(0); frame < w.frames(); {
+		n := min(windowFrames, w.frames()-frame)
+		data := block[:n*int64(w.blockAlign)]
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return err
+		}
+		window := samples[:n*int64(w.channels)]
+		for i := range window {
+			window[i] = int16(binary.LittleEndian.Uint16(data[2*i:]))
+		}
+		fn(window)
+		frame += n
+	}
+	return nil
+}
+
+// Pauses returns the pauses of at least minLength, in order. It reads the
+// whole recording once.
+func (w *WAV) Pauses(minLength time.Duration) ([]Pause, error) {
+	windowFrames := max(w.frameAt(pauseWindow), 1)
+
+	var levels []float64
+	err := w.Windows(pauseWindow, func(samples []int16) {
+		var sum float64
+		for _, sample := range samples {
+			sum += float64(sample) * float64(sample)
+		}
+		levels = append(levels, sum/float64(len(samples)))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(levels) == 0 {
+		return nil, nil
+	}
+
+	sorted := slices.Clone(levels)
+	slices.Sort(sorted)
+	speech := sorted[int(float64(len(sorted)-1)*speechQuantile)]
+	// Levels are mean squares, so the ratio of amplitudes is squared
+	threshold := speech * quietRatio * quietRatio
+
+	var pauses []Pause
+	start := -1
+	for i := 0; i <= len(levels); i++ {
+		quiet := i < len(levels) && levels[i] <= threshold
+		switch {
+		case quiet && start < 0:
+			start = i
+		case !quiet && start >= 0:
+			pause := Pause{
+				Start: w.frameTime(int64(start) * windowFrames),
+				End:   w.frameTime(min(int64(i)*windowFrames, w.frames())),
+			}
+			if pause.End-pause.Start >= minLength {
+				pauses = append(pauses, pause)
+			}
+			start = -1
+		}
+	}
+	return pauses, nil
+}
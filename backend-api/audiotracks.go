@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/gorilla/mux"
+	"github.com/oklog/ulid/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/api"
+	"rosetta/audioprobe"
+	"rosetta/domain"
+	"rosetta/models"
+	"rosetta/repository"
+	"rosetta/validation"
+)
+
+// newTrackKey names the object of an audio track upload. Tracks have a
+// prefix of their own, so the keys don't parse as segment audio keys.
+func newTrackKey(storyID, trackID primitive.ObjectID) string {
+	return trackMediaPrefix(storyID, trackID) + ulid.MustNew(ulid.Now(), rand.Reader).String()
+}
+
+func trackMediaPrefix(storyID, trackID primitive.ObjectID) string {
+	return storyID.Hex() + "/tracks/" + trackID.Hex() + "/"
+}
+
+// parseTrackKey is the inverse of newTrackKey.
+func parseTrackKey(key string) (primitive.ObjectID, primitive.ObjectID, bool) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 4 || parts[1] != "tracks" {
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+	storyID, err := primitive.ObjectIDFromHex(parts[0])
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+	trackID, err := primitive.ObjectIDFromHex(parts[2])
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+	return storyID, trackID, true
+}
+
+func findAudioTrack(story *models.Story, trackID primitive.ObjectID) *models.AudioTrack {
+	for i := range story.AudioTracks {
+		if story.AudioTracks[i].ID == trackID {
+			return &story.AudioTracks[i]
+		}
+	}
+	return nil
+}
+
+// preserveAudioTracks completes the audio tracks of story from current.
+// Tracks left out entirely are kept, the way metadata is, and so are tracks
+// still waiting for their upload, which clients never see. Sent tracks keep
+// the storage fields of the stored track with the same URL.
+func preserveAudioTracks(story *models.Story, current *models.Story) {
+	if story.AudioTracks == nil {
+		story.AudioTracks = current.AudioTracks
+		return
+	}
+
+	tracks := make([]models.AudioTrack, 0, len(story.AudioTracks))
+	for _, track := range story.AudioTracks {
+		if track.ID.IsZero() {
+			track.ID = primitive.NewObjectID()
+		}
+		if old := findAudioTrack(current, track.ID); old != nil {
+			if track.Url == old.Url {
+				track.Audio = old.Audio
+			}
+			track.PendingKey = old.PendingKey
+		}
+		tracks = append(tracks, track)
+	}
+	for _, old := range current.AudioTracks {
+		if old.Url == "" && old.PendingKey != "" && findAudioTrack(story, old.ID) == nil {
+			tracks = append(tracks, old)
+		}
+	}
+	story.AudioTracks = tracks
+}
+
+// validateClips checks that every clip plays part of an audio track of the
+// story, within the track when its duration is known.
+func validateClips(story *models.Story) error {
+	for i, segment := range story.Segments {
+		if segment.Clip == nil {
+			continue
+		}
+		track := findAudioTrack(story, segment.Clip.TrackID)
+		if track == nil {
+			return domain.New(domain.ErrInvalid, fmt.Sprintf("Segment %d plays a clip of an audio track the story doesn't have", i+1))
+		}
+		if track.DurationMs > 0 && segment.Clip.EndMs > track.DurationMs {
+			return domain.New(domain.ErrInvalid, fmt.Sprintf("Segment %d plays a clip ending after its audio track, which is %d ms long", i+1, track.DurationMs))
+		}
+	}
+	return nil
+}
+
+// updateAudioTracks applies an update made by the server to the stored
+// audio tracks, bumping the story version. fn returns the filter narrowing
+// the guarded update and the update itself; it is called again with the
+// reread story if a concurrent write lands in between.
+func updateAudioTracks(ctx context.Context, storyID primitive.ObjectID, fn func(current *models.Story) (bson.M, bson.M, error)) error {
+	for attempt := 0; attempt < maxSaveAttempts; attempt++ {
+		current, err := findStory(ctx, storyID)
+		if err != nil {
+			return err
+		}
+		filter, update, err := fn(&current)
+		if err != nil {
+			return err
+		}
+
+		filter["_id"] = storyID
+		filter["version"] = repository.VersionFilter(current.Version)
+		set, _ := update["$set"].(bson.M)
+		if set == nil {
+			set = bson.M{}
+			update["$set"] = set
+		}
+		set["updated_at"] = time.Now()
+		set["version"] = current.Version + 1
+
+		res, err := storiesCollection().UpdateOne(ctx, filter, update)
+		if err != nil {
+			return err
+		}
+		if res.MatchedCount == 1 {
+			if updated, err := findStory(ctx, storyID); err == nil {
+				recordRevision(ctx, &updated)
+			}
+			return nil
+		}
+	}
+	return errStoryChanged
+}
+
+// createAudioTrack adds an audio track to a story and returns a presigned
+// URL to upload its recording to, like segment audio uploads. The track
+// becomes playable once the upload is completed.
+func createAudioTrack(w http.ResponseWriter, r *http.Request) {
+	storyID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, "Invalid story ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err = findOwnStory(r.Context(), storyID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	track := models.AudioTrack{ID: primitive.NewObjectID()}
+	track.PendingKey = newTrackKey(storyID, track.ID)
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(writeBucket()),
+		Key:    aws.String(track.PendingKey),
+	}
+	encryptPut(input)
+	req, _ := s3Client.PutObjectRequest(input)
+	presignedURL, err := req.Presign(15 * time.Minute)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	presignedURL = strings.Replace(presignedURL, s3Endpoint, s3PublicHost, 1)
+
+	err = updateAudioTracks(r.Context(), storyID, func(current *models.Story) (bson.M, bson.M, error) {
+		if len(current.AudioTracks) >= validation.MaxAudioTracks {
+			return nil, nil, domain.New(domain.ErrInvalid, "Story already has the most audio tracks allowed")
+		}
+		return bson.M{}, bson.M{"$push": bson.M{"audio_tracks": track}}, nil
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(map[string]interface{}{
+		"track_id":       track.ID,
+		"upload_url":     presignedURL,
+		"upload_headers": uploadHeaders(),
+		"public_url":     mediaURL(track.PendingKey),
+		"key":            track.PendingKey,
+	})
+}
+
+// completeAudioTrackUpload is completeAudioUpload for audio tracks.
+func completeAudioTrackUpload(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	storyID, err := primitive.ObjectIDFromHex(vars["id"])
+	if err != nil {
+		httpError(w, "Invalid story ID", http.StatusBadRequest)
+		return
+	}
+	trackID, err := primitive.ObjectIDFromHex(vars["trackId"])
+	if err != nil {
+		httpError(w, "Invalid track ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err = findOwnStory(r.Context(), storyID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	err = promotePendingTrack(r.Context(), storyID, trackID, "")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	updatedStory, err := findStory(r.Context(), storyID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeResponse(w, r, http.StatusOK, api.StoryFromModel(&updatedStory))
+}
+
+// promotePendingTrack is promotePendingAudio for audio tracks.
+func promotePendingTrack(ctx context.Context, storyID, trackID primitive.ObjectID, key string) error {
+	story, err := findStory(ctx, storyID)
+	if err != nil {
+		return err
+	}
+	track := findAudioTrack(&story, trackID)
+	if track == nil {
+		return domain.New(domain.ErrNotFound, "Audio track not found")
+	}
+	if track.PendingKey == "" {
+		return domain.New(domain.ErrConflict, "No pending audio upload")
+	}
+	pendingKey := track.PendingKey
+	if key != "" && key != pendingKey {
+		return domain.New(domain.ErrConflict, "Upload is no longer pending")
+	}
+
+	bucket, head, err := headMedia(ctx, pendingKey)
+	if isNotFound(err) {
+		return domain.New(domain.ErrConflict, "Audio has not been uploaded yet")
+	}
+	if err != nil {
+		return err
+	}
+
+	audio := models.Audio{
+		Url:         bucketMediaURL(bucket, pendingKey),
+		Key:         pendingKey,
+		Size:        aws.Int64Value(head.ContentLength),
+		ContentType: aws.StringValue(head.ContentType),
+	}
+	// Best effort: clips can't be checked against the track without it
+	duration, err := audioprobe.Duration(mediaReader{ctx: ctx, bucket: bucket, key: pendingKey}, audio.Size)
+	if err != nil {
+		log.Printf("failed to probe duration of %s: %v", pendingKey, err)
+	}
+	audio.DurationMs = duration.Milliseconds()
+
+	err = updateAudioTracks(ctx, storyID, func(*models.Story) (bson.M, bson.M, error) {
+		filter := bson.M{"audio_tracks": bson.M{"$elemMatch": bson.M{"_id": trackID, "pending_key": pendingKey}}}
+		update := bson.M{"$set": bson.M{
+			"audio_tracks.$.url":          audio.Url,
+			"audio_tracks.$.key":          audio.Key,
+			"audio_tracks.$.size":         audio.Size,
+			"audio_tracks.$.content_type": audio.ContentType,
+			"audio_tracks.$.duration_ms":  audio.DurationMs,
+		}, "$unset": bson.M{"audio_tracks.$.pending_key": ""}}
+		return filter, update, nil
+	})
+	if err != nil {
+		return err
+	}
+	return refreshContentHash(ctx, storyID)
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
+
+	"rosetta/domain"
+	"rosetta/models"
+)
+
+const (
+	tokenTTL          = 24 * time.Hour
+	minPasswordLength = 8
+	adminTokenHeader  = "X-Admin-Token"
+)
+
+var jwtSecret []byte
+
+var errInvalidCredentials = domain.New(domain.ErrUnauthenticated, "Invalid email or password")
+
+type credentialsRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type authResponse struct {
+	Token     string             `json:"token"`
+	ExpiresAt time.Time          `json:"expires_at"`
+	UserID    primitive.ObjectID `json:"user_id"`
+}
+
+func register(w http.ResponseWriter, r *http.Request) {
+	var request credentialsRequest
+	err := decodeRequest(r, &request)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	email := strings.ToLower(strings.TrimSpace(request.Email))
+	if !strings.Contains(email, "@") {
+		httpError(w, "Invalid email", http.StatusBadRequest)
+		return
+	}
+	if len(request.Password) < minPasswordLength {
+		httpError(w, fmt.Sprintf("Password must be at least %d characters", minPasswordLength), http.StatusBadRequest)
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	user := models.User{ID: primitive.NewObjectID(), Email: email, PasswordHash: hash, CreatedAt: time.Now()}
+	_, err = usersCollection().InsertOne(r.Context(), user)
+	if mongo.IsDuplicateKeyError(err) {
+		writeError(w, domain.New(domain.ErrConflict, "Email is already registered"))
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeToken(w, r, http.StatusCreated, user.ID)
+}
+
+func login(w http.ResponseWriter, r *http.Request) {
+	var request credentialsRequest
+	err := decodeRequest(r, &request)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var user models.User
+	email := strings.ToLower(strings.TrimSpace(request.Email))
+	err = usersCollection().FindOne(r.Context(), bson.M{"email": email}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		writeError(w, errInvalidCredentials)
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if bcrypt.CompareHashAndPassword(user.PasswordHash, []byte(request.Password)) != nil {
+		writeError(w, errInvalidCredentials)
+		return
+	}
+
+	writeToken(w, r, http.StatusOK, user.ID)
+}
+
+func writeToken(w http.ResponseWriter, r *http.Request, status int, userID primitive.ObjectID) {
+	now := time.Now()
+	expiresAt := now.Add(tokenTTL)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   userID.Hex(),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	}).SignedString(jwtSecret)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeResponse(w, r, status, authResponse{Token: token, ExpiresAt: expiresAt, UserID: userID})
+}
+
+type userKey struct{}
+
+// authenticate resolves the bearer token or API key, if any, to a user on
+// the request context. Requests without either pass through anonymously;
+// routes that need a user are wrapped in requireUser.
+func authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key := r.Header.Get(apiKeyHeader); key != "" {
+			userID, err := userFromAPIKey(r.Context(), key)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userKey{}, userID)))
+			return
+		}
+
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		raw, ok := strings.CutPrefix(header, "Bearer ")
+		var userID primitive.ObjectID
+		var err error
+		if ok {
+			userID, err = parseToken(raw)
+		}
+		if !ok || err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			writeError(w, domain.New(domain.ErrUnauthenticated, "Invalid or expired token"))
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userKey{}, userID)))
+	})
+}
+
+func parseToken(raw string) (primitive.ObjectID, error) {
+	var claims jwt.RegisteredClaims
+	_, err := jwt.ParseWithClaims(raw, &claims, func(*jwt.Token) (interface{}, error) {
+		return jwtSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return primitive.ObjectIDFromHex(claims.Subject)
+}
+
+func requireUser(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := currentUser(r.Context()); !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, domain.New(domain.ErrUnauthenticated, "Authentication required"))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// requireAdmin lets through operators holding ADMIN_TOKEN. Users have no
+// say over the admin routes, whatever they are signed in as.
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if adminToken == "" {
+			writeError(w, domain.New(domain.ErrForbidden, "Admin routes are disabled"))
+			return
+		}
+		if !isAdmin(r) {
+			writeError(w, domain.New(domain.ErrUnauthenticated, "Invalid admin token"))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// isAdmin reports whether r presents the admin token.
+func isAdmin(r *http.Request) bool {
+	return adminToken != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get(adminTokenHeader)), []byte(adminToken)) == 1
+}
+
+func currentUser(ctx context.Context) (primitive.ObjectID, bool) {
+	userID, ok := ctx.Value(userKey{}).(primitive.ObjectID)
+	return userID, ok
+}
+
+// authorizeStory allows changes to a story by its owner only. Stories
+// created before accounts existed have no owner and stay open to any
+// signed-in user.
+func authorizeStory(ctx context.Context, story *models.Story) error {
+	if story.OwnerID.IsZero() {
+		return nil
+	}
+	if userID, ok := currentUser(ctx); !ok || userID != story.OwnerID {
+		return domain.New(domain.ErrForbidden, "Only the owner can change this story")
+	}
+	return nil
+}
+
+// authorizeView allows reading a story to anyone once it is published and
+// not hidden by moderation, including unlisted stories shared by link.
+// Drafts and hidden stories are only shown to those who may change them,
+// and look missing to everyone else.
+func authorizeView(ctx context.Context, story *models.Story) error {
+	if story.IsPublished && (story.Moderation == nil || story.Moderation.Status != models.ModerationHidden) {
+		return nil
+	}
+	if _, ok := currentUser(ctx); ok && authorizeStory(ctx, story) == nil {
+		return nil
+	}
+	return domain.New(domain.ErrNotFound, "Story not found")
+}
+
+// findVisibleStory is findStory for handlers that show the story.
+func findVisibleStory(ctx context.Context, id primitive.ObjectID) (models.Story, error) {
+	story, err := findStory(ctx, id)
+	if err != nil {
+		return story, err
+	}
+	return story, authorizeView(ctx, &story)
+}
+
+// findOwnStory is findStory for handlers that are about to change the story.
+func findOwnStory(ctx context.Context, id primitive.ObjectID) (models.Story, error) {
+	story, err := findStory(ctx, id)
+	if err != nil {
+		return story, err
+	}
+	return story, authorizeStory(ctx, &story)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"rosetta/api"
+	"rosetta/domain"
+	"rosetta/models"
+	"rosetta/textimport"
+	"rosetta/validation"
+)
+
+const (
+	automationDefaultLimit = 20
+	automationMaxLimit     = 100
+)
+
+// automationStory is the flat story shape served to no-code tools such as
+// Zapier, which map fields by name. Its field names are part of the public
+// contract and must not change.
+type automationStory struct {
+	ID           primitive.ObjectID `json:"id"`
+	Title        string             `json:"title"`
+	Language     string             `json:"language"`
+	Text         string             `json:"text"`
+	SegmentCount int                `json:"segment_count"`
+	IsPublished  bool               `json:"is_published"`
+	CreatedAt    time.Time          `json:"created_at"`
+	UpdatedAt    time.Time          `json:"updated_at"`
+	PublishedAt  *time.Time         `json:"published_at,omitempty"`
+}
+
+type automationSegment struct {
+	ID       primitive.ObjectID `json:"id"`
+	StoryID  primitive.ObjectID `json:"story_id"`
+	Position int                `json:"position"`
+	Text     string             `json:"text"`
+	Speaker  string             `json:"speaker,omitempty"`
+}
+
+type automationStoryRequest struct {
+	Title    string `json:"title"`
+	Text     string `json:"text"`
+	Language string `json:"language"`
+	Publish  bool   `json:"publish"`
+}
+
+type automationSegmentRequest struct {
+	Text    string `json:"text"`
+	Speaker string `json:"speaker"`
+}
+
+// registerAutomationRoutes mounts simplified endpoints for automation
+// platforms. They take and return flat objects and are meant to be called
+// with an API key.
+func registerAutomationRoutes(r *mux.Router) {
+	automation := r.PathPrefix("/automation").Subrouter()
+	automation.HandleFunc("/stories", requireUser(createAutomationStory)).Methods("POST")
+	automation.HandleFunc("/stories/{id}/segments", requireUser(appendAutomationSegment)).Methods("POST")
+	automation.HandleFunc("/triggers/published-stories", requireUser(pollPublishedStories)).Methods("GET")
+}
+
+func automationStoryFromModel(story *models.Story) automationStory {
+	texts := make([]string, 0, len(story.Segments))
+	for _, segment := range story.Segments {
+		if segment.Script != nil && segment.Script.Text != "" {
+			texts = append(texts, segment.Script.Text)
+		}
+	}
+	return automationStory{
+		ID:           story.ID,
+		Title:        story.Title,
+		Language:     story.Language,
+		Text:         strings.Join(texts, "\n\n"),
+		SegmentCount: len(story.Segments),
+		IsPublished:  story.IsPublished,
+		CreatedAt:    story.CreatedAt,
+		UpdatedAt:    story.UpdatedAt,
+		PublishedAt:  story.PublishedAt,
+	}
+}
+
+// createAutomationStory creates a story from a title and plain text, one
+// segment per paragraph. The language is detected when not given.
+func createAutomationStory(w http.ResponseWriter, r *http.Request) {
+	var request automationStoryRequest
+	err := decodeRequest(r, &request)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	doc := textimport.Parse(request.Text, textimport.FormatPlain)
+	if request.Language == "" {
+		request.Language = textimport.DetectLanguage(request.Text)
+	}
+	if request.Language == "" {
+		httpError(w, "Could not detect the language, pass it as language", http.StatusBadRequest)
+		return
+	}
+
+	storyRequest := api.StoryRequest{Title: request.Title, Language: request.Language, IsPublished: request.Publish}
+	for _, text := range doc.Segments {
+		storyRequest.Segments = append(storyRequest.Segments, api.SegmentRequest{Script: &api.Script{Text: text}})
+	}
+	if err = validation.Story(&storyRequest); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	story := storyRequest.ToModel()
+	story.OwnerID, _ = currentUser(r.Context())
+	err = assessSpam(r.Context(), clientIP(r), &story)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	err = insertStory(r.Context(), &story)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeResponse(w, r, http.StatusCreated, automationStoryFromModel(&story))
+}
+
+// appendAutomationSegment adds a text segment to the end of a story.
+func appendAutomationSegment(w http.ResponseWriter, r *http.Request) {
+	storyID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, "Invalid story ID", http.StatusBadRequest)
+		return
+	}
+
+	var request automationSegmentRequest
+	err = decodeRequest(r, &request)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	text := strings.TrimSpace(request.Text)
+	if text == "" {
+		writeError(w, validation.Errors{{Field: "text", Message: "is required"}})
+		return
+	}
+
+	if _, err = findOwnStory(r.Context(), storyID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	segment := models.Segment{ID: primitive.NewObjectID(), Script: &models.Script{Text: text}, Speaker: request.Speaker}
+	story, err := modifyStory(r.Context(), storyID, func(story *models.Story) error {
+		if len(story.Segments) >= validation.MaxSegments {
+			return domain.New(domain.ErrInvalid, fmt.Sprintf("A story can have at most %d segments", validation.MaxSegments))
+		}
+		story.Segments = append(story.Segments, segment)
+		return nil
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeResponse(w, r, http.StatusCreated, automationSegment{
+		ID:       segment.ID,
+		StoryID:  story.ID,
+		Position: len(story.Segments) - 1,
+		Text:     text,
+		Speaker:  request.Speaker,
+	})
+}
+
+// pollPublishedStories is a polling trigger: it lists listed stories
+// published after since, newest first, so automation platforms can
+// deduplicate on id.
+func pollPublishedStories(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter := listedFilter(bson.M{"published_at": bson.M{"$ne": nil}})
+	if since := query.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			httpError(w, "Invalid since, expected RFC 3339", http.StatusBadRequest)
+			return
+		}
+		filter["published_at"] = bson.M{"$gt": t}
+	}
+	if lang := query.Get("lang"); lang != "" {
+		filter["language"] = lang
+	}
+
+	limit := automationDefaultLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > automationMaxLimit {
+			httpError(w, fmt.Sprintf("limit must be between 1 and %d", automationMaxLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "published_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(limit))
+	cursor, err := storiesCollection().Find(r.Context(), filter, opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var stories []models.Story
+	if err = cursor.All(r.Context(), &stories); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	results := make([]automationStory, 0, len(stories))
+	for i := range stories {
+		results = append(results, automationStoryFromModel(&stories[i]))
+	}
+	writeResponse(w, r, http.StatusOK, results)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"rosetta/domain"
+	"rosetta/models"
+)
+
+const (
+	maxBackupSize    = 256 << 10
+	maxBackupKeyHint = 200
+)
+
+// draftBackupRequest carries the ciphertext base64-encoded. key_hint is free
+// text for the client, e.g. which passphrase or key version it used.
+type draftBackupRequest struct {
+	Ciphertext []byte `json:"ciphertext"`
+	KeyHint    string `json:"key_hint"`
+}
+
+// putDraftBackup stores the caller's encrypted draft of a story, replacing
+// the previous one.
+func putDraftBackup(w http.ResponseWriter, r *http.Request) {
+	storyID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, "Invalid story ID", http.StatusBadRequest)
+		return
+	}
+
+	// base64 makes the body about a third larger than the ciphertext
+	r.Body = http.MaxBytesReader(w, r.Body, maxBackupSize*4/3+1024)
+	var request draftBackupRequest
+	if err = decodeRequest(r, &request); err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(request.Ciphertext) == 0 || len(request.Ciphertext) > maxBackupSize {
+		httpError(w, "ciphertext is required and must be at most 256 KiB", http.StatusBadRequest)
+		return
+	}
+	if len(request.KeyHint) > maxBackupKeyHint {
+		httpError(w, "key_hint must be at most 200 characters", http.StatusBadRequest)
+		return
+	}
+
+	if _, err = findOwnStory(r.Context(), storyID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	userID, _ := currentUser(r.Context())
+	backup := models.DraftBackup{
+		UserID:     userID,
+		StoryID:    storyID,
+		Ciphertext: request.Ciphertext,
+		KeyHint:    request.KeyHint,
+		UpdatedAt:  time.Now(),
+	}
+	filter := bson.M{"user_id": userID, "story_id": storyID}
+	update := bson.M{"$set": backup}
+	_, err = draftBackupsCollection().UpdateOne(r.Context(), filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeResponse(w, r, http.StatusOK, backup)
+}
+
+func getDraftBackup(w http.ResponseWriter, r *http.Request) {
+	storyID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, "Invalid story ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, _ := currentUser(r.Context())
+	var backup models.DraftBackup
+	err = draftBackupsCollection().FindOne(r.Context(), bson.M{"user_id": userID, "story_id": storyID}).Decode(&backup)
+	if err == mongo.ErrNoDocuments {
+		writeError(w, domain.New(domain.ErrNotFound, "No backup for this story"))
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeResponse(w, r, http.StatusOK, backup)
+}
+
+func deleteDraftBackup(w http.ResponseWriter, r *http.Request) {
+	storyID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, "Invalid story ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, _ := currentUser(r.Context())
+	res, err := draftBackupsCollection().DeleteOne(r.Context(), bson.M{"user_id": userID, "story_id": storyID})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if res.DeletedCount == 0 {
+		writeError(w, domain.New(domain.ErrNotFound, "No backup for this story"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
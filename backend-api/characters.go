@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+
+	"rosetta/domain"
+	"rosetta/models"
+)
+
+var characterColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// validateCharacters checks the story's cast and that every segment speaker
+// refers to one of its characters.
+func validateCharacters(story *models.Story) error {
+	names := make(map[string]bool, len(story.Characters))
+	for _, character := range story.Characters {
+		if character.Name == "" {
+			return domain.New(domain.ErrInvalid, "Character name is required")
+		}
+		if names[character.Name] {
+			return domain.New(domain.ErrInvalid, fmt.Sprintf("Duplicate character %q", character.Name))
+		}
+		if character.Color != "" && !characterColor.MatchString(character.Color) {
+			return domain.New(domain.ErrInvalid, fmt.Sprintf("Character %q color must look like #RRGGBB", character.Name))
+		}
+		names[character.Name] = true
+	}
+
+	for _, segment := range story.Segments {
+		if segment.Speaker != "" && !names[segment.Speaker] {
+			return domain.New(domain.ErrInvalid, fmt.Sprintf("Segment speaker %q is not a character of the story", segment.Speaker))
+		}
+	}
+	return nil
+}
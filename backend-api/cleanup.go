@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"rosetta/domain"
+	"rosetta/models"
+)
+
+const (
+	queueKindMediaCleanup = "media_cleanup"
+	// cleanupDelay lets in-flight uploads and confirms settle before the
+	// objects of a deleted story or segment go
+	cleanupDelay       = time.Minute
+	maxCleanupAttempts = 10
+)
+
+// mediaCleanupPayload is the payload of a media_cleanup job.
+type mediaCleanupPayload struct {
+	StoryID primitive.ObjectID `bson:"story_id"`
+	Prefix  string             `bson:"prefix"`
+}
+
+// scheduleMediaCleanup queues the deletion of the objects under prefix.
+// Failing to queue only leaves orphans behind, so it is logged rather than
+// failing the delete that caused it.
+func scheduleMediaCleanup(ctx context.Context, storyID primitive.ObjectID, prefix string) {
+	err := enqueueJob(ctx, queueKindMediaCleanup, mediaCleanupPayload{StoryID: storyID, Prefix: prefix}, cleanupDelay)
+	if err != nil {
+		log.Printf("failed to schedule media cleanup of %s: %v", prefix, err)
+	}
+}
+
+func storyMediaPrefix(storyID primitive.ObjectID) string {
+	return storyID.Hex() + "/"
+}
+
+func segmentMediaPrefix(storyID, segmentID primitive.ObjectID) string {
+	return storyID.Hex() + "/" + segmentID.Hex() + "/"
+}
+
+// scheduleRemovedSegmentsCleanup queues cleanups for the segments of
+// previous that are missing from story.
+func scheduleRemovedSegmentsCleanup(ctx context.Context, previous, story *models.Story) {
+	kept := make(map[primitive.ObjectID]bool, len(story.Segments))
+	for _, segment := range story.Segments {
+		kept[segment.ID] = true
+	}
+	for _, segment := range previous.Segments {
+		if !kept[segment.ID] {
+			scheduleMediaCleanup(ctx, previous.ID, segmentMediaPrefix(previous.ID, segment.ID))
+		}
+	}
+	for _, track := range previous.AudioTracks {
+		if findAudioTrack(story, track.ID) == nil {
+			scheduleMediaCleanup(ctx, previous.ID, trackMediaPrefix(previous.ID, track.ID))
+		}
+	}
+}
+
+func runMediaCleanupJob(ctx context.Context, payload bson.M) error {
+	var cleanup mediaCleanupPayload
+	if err := decodePayload(payload, &cleanup); err != nil {
+		return err
+	}
+	return deleteMediaPrefix(ctx, cleanup.StoryID, cleanup.Prefix)
+}
+
+// moveMediaCleanups moves the cleanups queued before the job queue existed
+// onto it, keeping their IDs so instances starting together don't move one
+// twice. Those that had been given up on become dead jobs.
+func moveMediaCleanups(ctx context.Context) {
+	cursor, err := mediaCleanupCollection().Find(ctx, bson.M{})
+	if err != nil {
+		log.Printf("failed to move media cleanups to the job queue: %v", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var cleanup models.MediaCleanup
+		if err = cursor.Decode(&cleanup); err != nil {
+			log.Printf("failed to move media cleanups to the job queue: %v", err)
+			return
+		}
+		payload, err := toDocument(mediaCleanupPayload{StoryID: cleanup.StoryID, Prefix: cleanup.Prefix})
+		if err != nil {
+			log.Printf("failed to move media cleanup %s to the job queue: %v", cleanup.ID.Hex(), err)
+			continue
+		}
+		job := models.QueuedJob{
+			ID:            cleanup.ID,
+			Kind:          queueKindMediaCleanup,
+			Payload:       payload,
+			Status:        models.QueuedJobPending,
+			Attempts:      cleanup.Attempts,
+			NextAttemptAt: cleanup.NextAttemptAt,
+			LastError:     cleanup.LastError,
+			CreatedAt:     cleanup.CreatedAt,
+		}
+		if cleanup.GaveUp {
+			job.Status = models.QueuedJobDead
+			job.DeadAt = &cleanup.NextAttemptAt
+		}
+
+		_, err = jobQueueCollection().InsertOne(ctx, job)
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			log.Printf("failed to move media cleanup %s to the job queue: %v", cleanup.ID.Hex(), err)
+			continue
+		}
+		if _, err = mediaCleanupCollection().DeleteOne(ctx, bson.M{"_id": cleanup.ID}); err != nil {
+			log.Printf("failed to remove moved media cleanup %s: %v", cleanup.ID.Hex(), err)
+		}
+	}
+	if err = cursor.Err(); err != nil && ctx.Err() == nil {
+		log.Printf("failed to move media cleanups to the job queue: %v", err)
+	}
+}
+
+// deleteMediaPrefix deletes the objects under prefix that the story, if it
+// still exists, no longer refers to. Merged segments may keep media stored
+// under the prefix of the segment they absorbed.
+func deleteMediaPrefix(ctx context.Context, storyID primitive.ObjectID, prefix string) error {
+	referenced := map[string]bool{}
+	story, err := findStory(ctx, storyID)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return err
+	}
+	for _, segment := range story.Segments {
+		if segment.Audio != nil {
+			referenced[segment.Audio.Key] = true
+			referenced[segment.Audio.PendingKey] = true
+			referenced[segment.Audio.OriginalKey] = true
+		}
+		if segment.Image != nil {
+			referenced[segment.Image.Key] = true
+			referenced[segment.Image.PendingKey] = true
+		}
+	}
+	for _, track := range story.AudioTracks {
+		referenced[track.Key] = true
+		referenced[track.PendingKey] = true
+	}
+
+	// Mid-migration the media may be in either bucket
+	for _, bucket := range mediaBuckets() {
+		if err = deleteUnreferenced(ctx, bucket, prefix, referenced); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deleteUnreferenced(ctx context.Context, bucket, prefix string, referenced map[string]bool) error {
+	var deleteErr error
+	err := s3Client.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		objects := make([]*s3.ObjectIdentifier, 0, len(page.Contents))
+		for _, object := range page.Contents {
+			if !referenced[aws.StringValue(object.Key)] {
+				objects = append(objects, &s3.ObjectIdentifier{Key: object.Key})
+			}
+		}
+		if len(objects) == 0 {
+			return true
+		}
+
+		out, err := s3Client.DeleteObjectsWithContext(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(bucket),
+			Delete: &s3.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err == nil && len(out.Errors) > 0 {
+			err = fmt.Errorf("failed to delete %s: %s", aws.StringValue(out.Errors[0].Key), aws.StringValue(out.Errors[0].Message))
+		}
+		deleteErr = err
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	return deleteErr
+}
@@ -0,0 +1,28 @@
+// Command openapi writes the OpenAPI snapshot of the story API as it is in
+// the code. Run it when releasing a schema version, from backend-api:
+//
+//	go run ./cmd/openapi -version 2 > openapi/snapshots/2.json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"rosetta/openapi"
+)
+
+func main() {
+	version := flag.String("version", "", "schema version to stamp the snapshot with")
+	flag.Parse()
+	if *version == "" {
+		log.Fatal("-version is required")
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(openapi.Generate(*version, openapi.Routes)); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,47 @@
+// Command sdk writes the client packages of every released schema version,
+// the same packages GET /meta/sdks serves, for publishing to npm and as a
+// Swift package. Run it after snapshotting a version, from backend-api:
+//
+//	go run ./cmd/sdk -out dist/sdks
+//
+// Packages land in <out>/<version>/<language>.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+
+	"rosetta/openapi"
+	"rosetta/sdk"
+)
+
+func main() {
+	out := flag.String("out", "dist/sdks", "directory to write the packages to")
+	flag.Parse()
+
+	docs, err := openapi.Snapshots()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, doc := range docs {
+		for _, language := range sdk.Languages {
+			files, err := sdk.Generate(doc, language)
+			if err != nil {
+				log.Fatal(err)
+			}
+			dir := filepath.Join(*out, doc.Info.Version, language)
+			for _, file := range files {
+				path := filepath.Join(dir, filepath.FromSlash(file.Name))
+				if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					log.Fatal(err)
+				}
+				if err = os.WriteFile(path, file.Data, 0o644); err != nil {
+					log.Fatal(err)
+				}
+			}
+			log.Printf("wrote %s", dir)
+		}
+	}
+}
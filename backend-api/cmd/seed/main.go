@@ -0,0 +1,316 @@
+// Command seed fills a database with synthetic stories for soak tests, so
+// index and pagination changes can be tried at production-like scale. Sizes
+// follow log-normal distributions: most stories are short, a few are long.
+// With -media, segments get synthetic audio and images, copied server-side
+// from a small pool of uploaded objects to keep seeding fast.
+//
+//	go run ./cmd/seed -stories 100000 -media
+//
+// It reads DATABASE_URL, DATABASE_NAME and STORIES_COLLECTION, plus the
+// S3_* and AWS_* variables when seeding media.
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	mathrand "math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/oklog/ulid/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"rosetta/models"
+	"rosetta/storage"
+	"rosetta/validation"
+)
+
+type config struct {
+	stories        int
+	segmentsMedian float64
+	wordsMedian    float64
+	audioFraction  float64
+	imageFraction  float64
+	published      float64
+	days           int
+	batch          int
+	workers        int
+	media          bool
+	pool           int
+	append         bool
+	seed           int64
+}
+
+var languages = map[string][]string{
+	"en": strings.Fields("the a fox river night lantern old village child dream quiet bright wind story road morning found walked small under over and then"),
+	"es": strings.Fields("el la zorro río noche farol viejo pueblo niño sueño tranquilo brillante viento cuento camino mañana encontró caminó pequeño bajo sobre y"),
+	"fr": strings.Fields("le la renard rivière nuit lanterne vieux village enfant rêve calme brillant vent conte chemin matin trouva marcha petit sous sur et"),
+}
+
+var speakers = []string{"Narrator", "Amira", "Tomas", "Lea", "Grandmother", "Fox"}
+
+func main() {
+	var cfg config
+	flag.IntVar(&cfg.stories, "stories", 1000, "number of stories to create")
+	flag.Float64Var(&cfg.segmentsMedian, "segments", 12, "median segments per story")
+	flag.Float64Var(&cfg.wordsMedian, "words", 25, "median words per segment script")
+	flag.Float64Var(&cfg.audioFraction, "audio", 0.3, "share of segments with audio")
+	flag.Float64Var(&cfg.imageFraction, "images", 0.1, "share of segments with an image")
+	flag.Float64Var(&cfg.published, "published", 0.6, "share of published stories")
+	flag.IntVar(&cfg.days, "days", 365, "spread creation times over this many days")
+	flag.IntVar(&cfg.batch, "batch", 1000, "stories per insert")
+	flag.IntVar(&cfg.workers, "workers", 16, "concurrent media copies")
+	flag.BoolVar(&cfg.media, "media", false, "create synthetic media objects")
+	flag.IntVar(&cfg.pool, "pool", 20, "distinct synthetic objects per media kind")
+	flag.BoolVar(&cfg.append, "append", false, "add to a non-empty collection")
+	flag.Int64Var(&cfg.seed, "seed", 1, "random seed, for reproducible datasets")
+	flag.Parse()
+
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(os.Getenv("DATABASE_URL")))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Disconnect(ctx)
+
+	collection := client.Database(envOr("DATABASE_NAME", "rosetta")).Collection(envOr("STORIES_COLLECTION", "stories"))
+	if !cfg.append {
+		n, err := collection.EstimatedDocumentCount(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if n > 0 {
+			log.Fatalf("%s already has %d stories, pass -append to add to them", collection.Name(), n)
+		}
+	}
+
+	s := &seeder{cfg: cfg, rng: mathrand.New(mathrand.NewSource(cfg.seed)), stories: collection}
+	if cfg.media {
+		if err = s.setUpMedia(ctx); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err = s.run(ctx); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+type seeder struct {
+	cfg     config
+	rng     *mathrand.Rand
+	stories *mongo.Collection
+
+	backend   storage.Backend
+	publicURL string
+	bucket    string
+	audioPool []string
+	imagePool []string
+	copies    []mediaCopy
+	segments  int
+}
+
+type mediaCopy struct {
+	src, dst string
+}
+
+func (s *seeder) setUpMedia(ctx context.Context) error {
+	sess, err := session.NewSession(&aws.Config{
+		Region:           aws.String(os.Getenv("AWS_REGION")),
+		Credentials:      credentials.NewStaticCredentials(os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"), ""),
+		Endpoint:         aws.String(os.Getenv("S3_ENDPOINT")),
+		S3ForcePathStyle: aws.Bool(true),
+	})
+	if err != nil {
+		return err
+	}
+	s.bucket = os.Getenv("S3_BUCKET")
+	s.publicURL = os.Getenv("S3_PUBLIC_URL")
+	s.backend = storage.NewS3(s3.New(sess), s.bucket)
+
+	// Typical sizes: a narrated segment is a few hundred kB of MP3, an
+	// illustration somewhat less
+	for i := 0; i < s.cfg.pool; i++ {
+		key := fmt.Sprintf("seed-pool/audio/%d.mp3", i)
+		if err = s.upload(ctx, key, s.logNormal(400<<10, 0.6), "audio/mpeg"); err != nil {
+			return err
+		}
+		s.audioPool = append(s.audioPool, key)
+
+		key = fmt.Sprintf("seed-pool/image/%d.jpg", i)
+		if err = s.upload(ctx, key, s.logNormal(150<<10, 0.5), "image/jpeg"); err != nil {
+			return err
+		}
+		s.imagePool = append(s.imagePool, key)
+	}
+	return nil
+}
+
+func (s *seeder) upload(ctx context.Context, key string, size int, contentType string) error {
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		return err
+	}
+	return s.backend.Put(ctx, key, bytes.NewReader(data), contentType)
+}
+
+func (s *seeder) run(ctx context.Context) error {
+	start := time.Now()
+	for done := 0; done < s.cfg.stories; {
+		n := min(s.cfg.batch, s.cfg.stories-done)
+		docs := make([]interface{}, 0, n)
+		for i := 0; i < n; i++ {
+			docs = append(docs, s.story())
+		}
+
+		if err := s.copyMedia(ctx); err != nil {
+			return err
+		}
+		if _, err := s.stories.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false)); err != nil {
+			return err
+		}
+
+		done += n
+		log.Printf("%d/%d stories, %d segments (%s)", done, s.cfg.stories, s.segments, time.Since(start).Round(time.Second))
+	}
+	return nil
+}
+
+// copyMedia gives every queued segment its own copy of a pool object, the
+// way real uploads each get their own key.
+func (s *seeder) copyMedia(ctx context.Context) error {
+	jobs := make(chan mediaCopy)
+	errs := make(chan error, s.cfg.workers)
+	var wg sync.WaitGroup
+	for i := 0; i < s.cfg.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				if err := s.backend.Copy(ctx, job.src, job.dst); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+
+	var err error
+feed:
+	for _, job := range s.copies {
+		select {
+		case jobs <- job:
+		case err = <-errs:
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	s.copies = s.copies[:0]
+	if err != nil {
+		return err
+	}
+	select {
+	case err = <-errs:
+		return err
+	default:
+		return nil
+	}
+}
+
+func (s *seeder) story() models.Story {
+	lang := []string{"en", "es", "fr"}[s.rng.Intn(3)]
+	created := time.Now().Add(-time.Duration(s.rng.Int63n(int64(s.cfg.days) * int64(24*time.Hour))))
+	updated := created.Add(time.Duration(s.rng.Int63n(int64(time.Since(created)) + 1)))
+
+	story := models.Story{
+		ID:        primitive.NewObjectIDFromTimestamp(created),
+		Title:     s.sentence(lang, 2+s.rng.Intn(6)),
+		Language:  lang,
+		CreatedAt: created,
+		UpdatedAt: updated,
+		Version:   1,
+	}
+	if s.rng.Float64() < s.cfg.published {
+		story.IsPublished = true
+		story.PublishedAt = &updated
+	}
+	if s.rng.Float64() < 0.1 {
+		story.Visibility = models.VisibilityUnlisted
+	}
+	if s.rng.Float64() < 0.3 {
+		story.AgeRating = models.AgeRatings[s.rng.Intn(len(models.AgeRatings))]
+	}
+
+	cast := speakers[:s.rng.Intn(4)]
+	for _, name := range cast {
+		story.Characters = append(story.Characters, models.Character{Name: name})
+	}
+
+	count := min(max(s.logNormal(s.cfg.segmentsMedian, 0.8), 1), validation.MaxSegments)
+	story.Segments = make([]models.Segment, 0, count)
+	for i := 0; i < count; i++ {
+		segment := models.Segment{ID: primitive.NewObjectID(), Version: 1}
+		text := s.sentence(lang, max(s.logNormal(s.cfg.wordsMedian, 0.7), 1))
+		if len(text) > validation.MaxScriptLength {
+			text = strings.TrimSpace(text[:strings.LastIndex(text[:validation.MaxScriptLength], " ")])
+		}
+		segment.Script = &models.Script{Text: text}
+		if len(cast) > 0 {
+			segment.Speaker = cast[s.rng.Intn(len(cast))]
+		}
+		if s.backend != nil && s.rng.Float64() < s.cfg.audioFraction {
+			key := fmt.Sprintf("%s/%s/audio/%s", story.ID.Hex(), segment.ID.Hex(), ulid.MustNew(ulid.Timestamp(created), rand.Reader))
+			s.copies = append(s.copies, mediaCopy{src: s.audioPool[s.rng.Intn(len(s.audioPool))], dst: key})
+			segment.Audio = &models.Audio{Url: s.mediaURL(key), Key: key}
+		}
+		if s.backend != nil && s.rng.Float64() < s.cfg.imageFraction {
+			key := fmt.Sprintf("%s/%s/image/%s.jpg", story.ID.Hex(), segment.ID.Hex(), ulid.MustNew(ulid.Timestamp(created), rand.Reader))
+			s.copies = append(s.copies, mediaCopy{src: s.imagePool[s.rng.Intn(len(s.imagePool))], dst: key})
+			segment.Image = &models.Image{Url: s.mediaURL(key), Key: key}
+		}
+		story.Segments = append(story.Segments, segment)
+	}
+	s.segments += count
+	return story
+}
+
+func (s *seeder) mediaURL(key string) string {
+	return fmt.Sprintf("%s/%s/%s", s.publicURL, s.bucket, key)
+}
+
+func (s *seeder) sentence(lang string, words int) string {
+	vocabulary := languages[lang]
+	parts := make([]string, words)
+	for i := range parts {
+		parts[i] = vocabulary[s.rng.Intn(len(vocabulary))]
+	}
+	first := []rune(parts[0])
+	parts[0] = strings.ToUpper(string(first[0])) + string(first[1:])
+	return strings.Join(parts, " ")
+}
+
+// logNormal draws a positive size with the given median; sigma controls
+// the length of the tail.
+func (s *seeder) logNormal(median, sigma float64) int {
+	return int(math.Round(median * math.Exp(s.rng.NormFloat64()*sigma)))
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	contentTypeJSON    = "application/json"
+	contentTypeMsgpack = "application/msgpack"
+)
+
+// negotiateContentType picks JSON, HAL or MessagePack from an Accept header,
+// honouring q-values and falling back to JSON.
+func negotiateContentType(accept string) string {
+	best, bestQ := contentTypeJSON, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+
+		switch mediaType {
+		case contentTypeMsgpack, "application/x-msgpack":
+			mediaType = contentTypeMsgpack
+		case contentTypeHAL:
+		case contentTypeJSON, "*/*", "application/*":
+			mediaType = contentTypeJSON
+		default:
+			continue
+		}
+
+		if q > bestQ {
+			best, bestQ = mediaType, q
+		}
+	}
+	return best
+}
+
+func isMsgpack(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == contentTypeMsgpack || mediaType == "application/x-msgpack"
+}
+
+// decodeRequest decodes the request body as MessagePack when the client says
+// so in Content-Type, and as JSON otherwise.
+func decodeRequest(r *http.Request, v interface{}) error {
+	if isMsgpack(r.Header.Get("Content-Type")) {
+		decoder := msgpack.NewDecoder(r.Body)
+		decoder.SetCustomStructTag("json")
+		return decoder.Decode(v)
+	}
+	return json.NewDecoder(r.Body).Decode(v)
+}
+
+// writeResponse encodes v in the format negotiated from the Accept header.
+func writeResponse(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	contentType := negotiateContentType(r.Header.Get("Accept"))
+	if contentType == contentTypeHAL {
+		doc, err := halDocument(r, v)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		v = doc
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(status)
+
+	if contentType == contentTypeMsgpack {
+		encoder := msgpack.NewEncoder(w)
+		encoder.SetCustomStructTag("json")
+		encoder.Encode(v)
+		return
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false) // Media URLs contain query strings
+	encoder.Encode(v)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"rosetta/config"
+)
+
+// Database and collection names come from the config, so they can be
+// overridden per environment, e.g. to point tests at a randomly named
+// database.
+var (
+	databaseName                    string
+	storiesCollectionName           string
+	deletedStoriesCollectionName    string
+	featuredStoriesCollectionName   string
+	blocklistCollectionName         string
+	moderationActionsCollectionName string
+	appealsCollectionName           string
+	auditLogCollectionName          string
+	storyRevisionsCollectionName    string
+	usersCollectionName             string
+	apiKeysCollectionName           string
+	mediaCleanupCollectionName      string
+	jobsCollectionName              string
+	draftBackupsCollectionName      string
+	storyPlaysCollectionName        string
+	jobQueueCollectionName          string
+)
+
+func setCollectionNames(database string, names config.Collections) {
+	databaseName = database
+	storiesCollectionName = names.Stories
+	deletedStoriesCollectionName = names.DeletedStories
+	featuredStoriesCollectionName = names.FeaturedStories
+	blocklistCollectionName = names.Blocklist
+	moderationActionsCollectionName = names.ModerationActions
+	appealsCollectionName = names.Appeals
+	auditLogCollectionName = names.AuditLog
+	storyRevisionsCollectionName = names.StoryRevisions
+	usersCollectionName = names.Users
+	apiKeysCollectionName = names.APIKeys
+	mediaCleanupCollectionName = names.MediaCleanup
+	jobsCollectionName = names.Jobs
+	draftBackupsCollectionName = names.DraftBackups
+	storyPlaysCollectionName = names.StoryPlays
+	jobQueueCollectionName = names.JobQueue
+}
+
+func storiesCollection() *mongo.Collection {
+	return client.Database(databaseName).Collection(storiesCollectionName)
+}
+
+func deletedStoriesCollection() *mongo.Collection {
+	return client.Database(databaseName).Collection(deletedStoriesCollectionName)
+}
+
+func featuredStoriesCollection() *mongo.Collection {
+	return client.Database(databaseName).Collection(featuredStoriesCollectionName)
+}
+
+func blocklistCollection() *mongo.Collection {
+	return client.Database(databaseName).Collection(blocklistCollectionName)
+}
+
+func moderationActionsCollection() *mongo.Collection {
+	return client.Database(databaseName).Collection(moderationActionsCollectionName)
+}
+
+func appealsCollection() *mongo.Collection {
+	return client.Database(databaseName).Collection(appealsCollectionName)
+}
+
+func auditLogCollection() *mongo.Collection {
+	return client.Database(databaseName).Collection(auditLogCollectionName)
+}
+
+func storyRevisionsCollection() *mongo.Collection {
+	return client.Database(databaseName).Collection(storyRevisionsCollectionName)
+}
+
+func usersCollection() *mongo.Collection {
+	return client.Database(databaseName).Collection(usersCollectionName)
+}
+
+func apiKeysCollection() *mongo.Collection {
+	return client.Database(databaseName).Collection(apiKeysCollectionName)
+}
+
+func mediaCleanupCollection() *mongo.Collection {
+	return client.Database(databaseName).Collection(mediaCleanupCollectionName)
+}
+
+func jobsCollection() *mongo.Collection {
+	return client.Database(databaseName).Collection(jobsCollectionName)
+}
+
+func draftBackupsCollection() *mongo.Collection {
+	return client.Database(databaseName).Collection(draftBackupsCollectionName)
+}
+
+func storyPlaysCollection() *mongo.Collection {
+	return client.Database(databaseName).Collection(storyPlaysCollectionName)
+}
+
+func jobQueueCollection() *mongo.Collection {
+	return client.Database(databaseName).Collection(jobQueueCollectionName)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"rosetta/domain"
+	"rosetta/models"
+)
+
+var errPreconditionFailed = domain.New(domain.ErrPreconditionFailed, "Story was modified since If-Unmodified-Since")
+
+// unmodifiedSince returns the If-Unmodified-Since time, or the zero time if the
+// header is absent. An unparseable date is ignored, as RFC 9110 requires.
+func unmodifiedSince(r *http.Request) time.Time {
+	t, err := http.ParseTime(r.Header.Get("If-Unmodified-Since"))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// modifiedAfter reports whether the story changed after t. HTTP dates only
+// have second precision, so updated_at is truncated before comparing.
+func modifiedAfter(story *models.Story, t time.Time) bool {
+	return !t.IsZero() && story.UpdatedAt.Truncate(time.Second).After(t)
+}
+
+// baseVersion returns the story version named by If-Match, or 0 if only
+// If-Unmodified-Since is given. Writes must carry one of the two so two
+// editors can't silently overwrite each other, and an If-Unmodified-Since
+// standing alone must parse, as ignoring it would leave the write
+// unguarded; ok is false once the missing or invalid precondition has been
+// reported.
+func baseVersion(w http.ResponseWriter, r *http.Request) (version int64, ok bool) {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		since := r.Header.Get("If-Unmodified-Since")
+		if since == "" {
+			httpError(w, "If-Match with the story's ETag is required", http.StatusPreconditionRequired)
+			return 0, false
+		}
+		if _, err := http.ParseTime(since); err != nil {
+			httpError(w, "Invalid If-Unmodified-Since, expected an HTTP date", http.StatusBadRequest)
+			return 0, false
+		}
+		return 0, true
+	}
+	if version, ok = versionFromETag(ifMatch); !ok {
+		httpError(w, "Invalid If-Match", http.StatusBadRequest)
+	}
+	return version, ok
+}
+
+func setLastModified(w http.ResponseWriter, story *models.Story) {
+	if !story.UpdatedAt.IsZero() {
+		w.Header().Set("Last-Modified", story.UpdatedAt.UTC().Format(http.TimeFormat))
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBaseVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		version int64
+		status  int
+	}{
+		{"no precondition", nil, 0, http.StatusPreconditionRequired},
+		{"if-match", map[string]string{"If-Match": `"v3"`}, 3, 0},
+		{"invalid if-match", map[string]string{"If-Match": "nope"}, 0, http.StatusBadRequest},
+		{"if-unmodified-since", map[string]string{"If-Unmodified-Since": "Wed, 21 Oct 2015 07:28:00 GMT"}, 0, 0},
+		{"invalid if-unmodified-since", map[string]string{"If-Unmodified-Since": "yesterday"}, 0, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/stories/x", nil)
+			for k, v := range test.headers {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			version, ok := baseVersion(w, r)
+			if ok != (test.status == 0) {
+				t.Fatalf("ok = %v, status %d", ok, w.Code)
+			}
+			if !ok && w.Code != test.status {
+				t.Errorf("status = %d, want %d", w.Code, test.status)
+			}
+			if ok && version != test.version {
+				t.Errorf("version = %d, want %d", version, test.version)
+			}
+		})
+	}
+}
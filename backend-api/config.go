@@ -0,0 +1,29 @@
+package main
+
+import (
+	"rosetta/config"
+)
+
+// inboundEmailToken authenticates the inbound email webhook; empty
+// disables it.
+var inboundEmailToken string
+
+// adminToken authenticates operators on the admin routes; empty disables
+// them.
+var adminToken string
+
+// applyConfig hands the loaded settings to the parts of the API that use
+// them.
+func applyConfig(cfg *config.Config) {
+	setCollectionNames(cfg.DatabaseName, cfg.Collections)
+	s3Bucket = cfg.S3Bucket
+	s3Endpoint = cfg.S3Endpoint
+	s3PublicHost = cfg.S3PublicURL
+	s3MigrationBucket = cfg.S3MigrationBucket
+	mediaEncryption = cfg.S3ServerSideEncryption
+	mediaKMSKeyID = cfg.S3SSEKMSKeyID
+	scriptFilterMode = cfg.ScriptFilterMode
+	jwtSecret = []byte(cfg.JWTSecret)
+	inboundEmailToken = cfg.InboundEmailToken
+	adminToken = cfg.AdminToken
+}
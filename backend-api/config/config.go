@@ -0,0 +1,337 @@
+// Package config loads the API's settings from the environment and an
+// optional .env file, applying defaults and validating everything up front
+// so a misconfigured deployment fails at startup with every problem listed.
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds every setting of the API. The environment variable behind
+// each field is named in its comment.
+type Config struct {
+	Port            string        // PORT, default 8080
+	GRPCPort        string        // GRPC_PORT, default 9090, empty disables the gRPC API
+	ShutdownTimeout time.Duration // SHUTDOWN_TIMEOUT, default 15s
+	DrainDelay      time.Duration // DRAIN_DELAY, time readiness fails before shutdown, default none
+	DevMode         bool          // DEV_MODE, serves the API console at /dev/console; never in production
+
+	DatabaseURL    string        // DATABASE_URL, required
+	DatabaseName   string        // DATABASE_NAME, default rosetta
+	ConnectTimeout time.Duration // DATABASE_CONNECT_TIMEOUT, default 10s
+	Collections    Collections
+
+	AWSRegion          string // AWS_REGION, required
+	AWSAccessKeyID     string // AWS_ACCESS_KEY_ID, required
+	AWSSecretAccessKey string // AWS_SECRET_ACCESS_KEY, required
+
+	S3Bucket               string // S3_BUCKET, required
+	S3Endpoint             string // S3_ENDPOINT, empty for AWS
+	S3PublicURL            string // S3_PUBLIC_URL, required
+	S3EventsQueueURL       string // S3_EVENTS_QUEUE_URL, optional
+	S3ServerSideEncryption string // S3_SERVER_SIDE_ENCRYPTION: empty, AES256 or aws:kms
+	S3SSEKMSKeyID          string // S3_SSE_KMS_KEY_ID, only with aws:kms
+	S3MigrationBucket      string // S3_MIGRATION_BUCKET, bucket media is being moved to, optional
+
+	JWTSecret         string // JWT_SECRET, required
+	InboundEmailToken string // INBOUND_EMAIL_TOKEN, empty disables inbound email
+	AdminToken        string // ADMIN_TOKEN, sent as X-Admin-Token to the admin routes, empty disables them
+
+	ScriptFilterMode         string // SCRIPT_FILTER_MODE: off (default), mask or reject
+	PublicRateLimitPerMinute int    // PUBLIC_RATE_LIMIT_PER_MINUTE, default 60
+	AuthRateLimitPerMinute   int    // AUTH_RATE_LIMIT_PER_MINUTE, default 10
+	WriteRateLimitPerMinute  int    // WRITE_RATE_LIMIT_PER_MINUTE, default 120
+	UploadRateLimitPerMinute int    // UPLOAD_RATE_LIMIT_PER_MINUTE, default 30
+	QueueWorkers             int    // QUEUE_WORKERS, queued background jobs run at once per instance, default 4
+
+	CORSAllowedOrigins []string // CORS_ALLOWED_ORIGINS, comma-separated origins or *, empty disables CORS
+	CORSAllowedMethods []string // CORS_ALLOWED_METHODS, default GET, POST, PUT, PATCH, DELETE
+	CORSAllowedHeaders []string // CORS_ALLOWED_HEADERS, default the request headers the API reads
+
+	OTLPEndpoint     string  // OTEL_EXPORTER_OTLP_ENDPOINT, OTLP/HTTP collector URL, empty disables tracing
+	ServiceName      string  // OTEL_SERVICE_NAME, default rosetta-api
+	TraceSampleRatio float64 // OTEL_TRACES_SAMPLER_ARG, share of new traces recorded, default 1
+
+	TTSProvider string // TTS_PROVIDER: off (default) or polly, generates segment audio from scripts
+	TTSEndpoint string // TTS_ENDPOINT, empty for AWS
+	TTSEngine   string // TTS_ENGINE: standard (default) or neural
+	TTSVoice    string // TTS_VOICE, voice for every language, default one picked by the story's language
+
+	Transcoder       string // TRANSCODER: off (default) or ffmpeg, re-encodes uploaded audio as AAC
+	FFmpegPath       string // FFMPEG_PATH, default ffmpeg from PATH
+	TranscodeBitrate string // TRANSCODE_BITRATE, default 128k
+}
+
+// Collections names the MongoDB collections, each overridable by its
+// <NAME>_COLLECTION variable, e.g. to point tests at scratch collections.
+type Collections struct {
+	Stories           string
+	DeletedStories    string
+	FeaturedStories   string
+	Blocklist         string
+	ModerationActions string
+	Appeals           string
+	AuditLog          string
+	StoryRevisions    string
+	Users             string
+	APIKeys           string
+	MediaCleanup      string
+	Jobs              string
+	DraftBackups      string
+	StoryPlays        string
+	JobQueue          string
+}
+
+// Error lists every missing and invalid setting.
+type Error struct {
+	Missing []string
+	Invalid []string
+}
+
+func (e *Error) Error() string {
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, "missing "+strings.Join(e.Missing, ", "))
+	}
+	parts = append(parts, e.Invalid...)
+	return "config: " + strings.Join(parts, "; ")
+}
+
+// Load reads the settings from the environment. Variables not set there are
+// looked up in the file named by CONFIG_FILE, or .env in the working
+// directory if it exists.
+func Load() (*Config, error) {
+	file := os.Getenv("CONFIG_FILE")
+	fileValues, err := readEnvFile(file)
+	if os.IsNotExist(err) && file == "" {
+		err = nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+
+	return load(func(key string) string {
+		if v, ok := os.LookupEnv(key); ok {
+			return v
+		}
+		return fileValues[key]
+	})
+}
+
+func load(lookup func(string) string) (*Config, error) {
+	l := &loader{lookup: lookup, errs: &Error{}}
+	cfg := &Config{
+		Port:            l.string("PORT", "8080"),
+		GRPCPort:        l.string("GRPC_PORT", "9090"),
+		ShutdownTimeout: l.duration("SHUTDOWN_TIMEOUT", 15*time.Second),
+		DrainDelay:      l.duration("DRAIN_DELAY", 0),
+		DevMode:         l.bool("DEV_MODE", false),
+
+		DatabaseURL:    l.url("DATABASE_URL", true, "mongodb", "mongodb+srv"),
+		DatabaseName:   l.string("DATABASE_NAME", "rosetta"),
+		ConnectTimeout: l.duration("DATABASE_CONNECT_TIMEOUT", 10*time.Second),
+		Collections: Collections{
+			Stories:           l.string("STORIES_COLLECTION", "stories"),
+			DeletedStories:    l.string("DELETED_STORIES_COLLECTION", "deleted_stories"),
+			FeaturedStories:   l.string("FEATURED_STORIES_COLLECTION", "featured_stories"),
+			Blocklist:         l.string("BLOCKLIST_COLLECTION", "blocklist"),
+			ModerationActions: l.string("MODERATION_ACTIONS_COLLECTION", "moderation_actions"),
+			Appeals:           l.string("APPEALS_COLLECTION", "appeals"),
+			AuditLog:          l.string("AUDIT_LOG_COLLECTION", "audit_log"),
+			StoryRevisions:    l.string("STORY_REVISIONS_COLLECTION", "story_revisions"),
+			Users:             l.string("USERS_COLLECTION", "users"),
+			APIKeys:           l.string("API_KEYS_COLLECTION", "api_keys"),
+			MediaCleanup:      l.string("MEDIA_CLEANUP_COLLECTION", "media_cleanup"),
+			Jobs:              l.string("JOBS_COLLECTION", "jobs"),
+			DraftBackups:      l.string("DRAFT_BACKUPS_COLLECTION", "draft_backups"),
+			StoryPlays:        l.string("STORY_PLAYS_COLLECTION", "story_plays"),
+			JobQueue:          l.string("JOB_QUEUE_COLLECTION", "job_queue"),
+		},
+
+		AWSRegion:          l.required("AWS_REGION"),
+		AWSAccessKeyID:     l.required("AWS_ACCESS_KEY_ID"),
+		AWSSecretAccessKey: l.required("AWS_SECRET_ACCESS_KEY"),
+
+		S3Bucket:               l.required("S3_BUCKET"),
+		S3Endpoint:             l.url("S3_ENDPOINT", false, "http", "https"),
+		S3PublicURL:            l.url("S3_PUBLIC_URL", true, "http", "https"),
+		S3EventsQueueURL:       l.url("S3_EVENTS_QUEUE_URL", false, "http", "https"),
+		S3ServerSideEncryption: l.oneOf("S3_SERVER_SIDE_ENCRYPTION", "", "AES256", "aws:kms"),
+		S3SSEKMSKeyID:          l.string("S3_SSE_KMS_KEY_ID", ""),
+		S3MigrationBucket:      l.string("S3_MIGRATION_BUCKET", ""),
+
+		JWTSecret:         l.required("JWT_SECRET"),
+		InboundEmailToken: l.string("INBOUND_EMAIL_TOKEN", ""),
+		AdminToken:        l.string("ADMIN_TOKEN", ""),
+
+		ScriptFilterMode:         l.oneOf("SCRIPT_FILTER_MODE", "off", "off", "mask", "reject"),
+		PublicRateLimitPerMinute: l.positiveInt("PUBLIC_RATE_LIMIT_PER_MINUTE", 60),
+		AuthRateLimitPerMinute:   l.positiveInt("AUTH_RATE_LIMIT_PER_MINUTE", 10),
+		WriteRateLimitPerMinute:  l.positiveInt("WRITE_RATE_LIMIT_PER_MINUTE", 120),
+		UploadRateLimitPerMinute: l.positiveInt("UPLOAD_RATE_LIMIT_PER_MINUTE", 30),
+		QueueWorkers:             l.positiveInt("QUEUE_WORKERS", 4),
+
+		CORSAllowedOrigins: l.list("CORS_ALLOWED_ORIGINS"),
+		CORSAllowedMethods: l.list("CORS_ALLOWED_METHODS", "GET", "POST", "PUT", "PATCH", "DELETE"),
+		CORSAllowedHeaders: l.list("CORS_ALLOWED_HEADERS",
+			"Authorization", "Content-Type", "If-Match", "If-None-Match", "If-Unmodified-Since", "X-Request-ID"),
+
+		OTLPEndpoint:     l.url("OTEL_EXPORTER_OTLP_ENDPOINT", false, "http", "https"),
+		ServiceName:      l.string("OTEL_SERVICE_NAME", "rosetta-api"),
+		TraceSampleRatio: l.fraction("OTEL_TRACES_SAMPLER_ARG", 1),
+
+		TTSProvider: l.oneOf("TTS_PROVIDER", "off", "off", "polly"),
+		TTSEndpoint: l.url("TTS_ENDPOINT", false, "http", "https"),
+		TTSEngine:   l.oneOf("TTS_ENGINE", "standard", "standard", "neural"),
+		TTSVoice:    l.string("TTS_VOICE", ""),
+
+		Transcoder:       l.oneOf("TRANSCODER", "off", "off", "ffmpeg"),
+		FFmpegPath:       l.string("FFMPEG_PATH", ""),
+		TranscodeBitrate: l.string("TRANSCODE_BITRATE", "128k"),
+	}
+
+	if cfg.S3SSEKMSKeyID != "" && cfg.S3ServerSideEncryption != "aws:kms" {
+		l.invalid("S3_SSE_KMS_KEY_ID requires S3_SERVER_SIDE_ENCRYPTION=aws:kms")
+	}
+	if cfg.S3MigrationBucket != "" && cfg.S3MigrationBucket == cfg.S3Bucket {
+		l.invalid("S3_MIGRATION_BUCKET must differ from S3_BUCKET")
+	}
+	for _, origin := range cfg.CORSAllowedOrigins {
+		if u, err := url.Parse(origin); origin != "*" && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "") {
+			l.invalid(fmt.Sprintf("CORS_ALLOWED_ORIGINS entry %q is not * or an origin such as https://example.com", origin))
+		}
+	}
+	if _, err := strconv.ParseUint(cfg.Port, 10, 16); err != nil {
+		l.invalid(fmt.Sprintf("PORT %q is not a port number", cfg.Port))
+	}
+	if _, err := strconv.ParseUint(cfg.GRPCPort, 10, 16); cfg.GRPCPort != "" && err != nil {
+		l.invalid(fmt.Sprintf("GRPC_PORT %q is not a port number", cfg.GRPCPort))
+	}
+
+	if len(l.errs.Missing) > 0 || len(l.errs.Invalid) > 0 {
+		return nil, l.errs
+	}
+	return cfg, nil
+}
+
+type loader struct {
+	lookup func(string) string
+	errs   *Error
+}
+
+func (l *loader) invalid(message string) {
+	l.errs.Invalid = append(l.errs.Invalid, message)
+}
+
+func (l *loader) string(key, fallback string) string {
+	if v := strings.TrimSpace(l.lookup(key)); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func (l *loader) required(key string) string {
+	v := l.string(key, "")
+	if v == "" {
+		l.errs.Missing = append(l.errs.Missing, key)
+	}
+	return v
+}
+
+func (l *loader) duration(key string, fallback time.Duration) time.Duration {
+	v := l.string(key, "")
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		l.invalid(fmt.Sprintf("%s %q is not a positive duration such as 30s", key, v))
+		return fallback
+	}
+	return d
+}
+
+func (l *loader) bool(key string, fallback bool) bool {
+	v := l.string(key, "")
+	if v == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		l.invalid(fmt.Sprintf("%s %q is not true or false", key, v))
+		return fallback
+	}
+	return b
+}
+
+func (l *loader) positiveInt(key string, fallback int) int {
+	v := l.string(key, "")
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		l.invalid(fmt.Sprintf("%s %q is not a positive integer", key, v))
+		return fallback
+	}
+	return n
+}
+
+// list splits a comma-separated value, dropping empty entries.
+func (l *loader) list(key string, fallback ...string) []string {
+	v := l.string(key, "")
+	if v == "" {
+		return fallback
+	}
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (l *loader) fraction(key string, fallback float64) float64 {
+	v := l.string(key, "")
+	if v == "" {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 || f > 1 {
+		l.invalid(fmt.Sprintf("%s %q is not a number between 0 and 1", key, v))
+		return fallback
+	}
+	return f
+}
+
+func (l *loader) oneOf(key, fallback string, allowed ...string) string {
+	v := l.string(key, fallback)
+	if v != fallback && !slices.Contains(allowed, v) {
+		l.invalid(fmt.Sprintf("%s %q must be one of %s", key, v, strings.Join(allowed, ", ")))
+	}
+	return v
+}
+
+func (l *loader) url(key string, required bool, schemes ...string) string {
+	var v string
+	if required {
+		v = l.required(key)
+	} else {
+		v = l.string(key, "")
+	}
+	if v == "" {
+		return v
+	}
+	u, err := url.Parse(v)
+	if err != nil || !slices.Contains(schemes, u.Scheme) || u.Host == "" {
+		l.invalid(fmt.Sprintf("%s must be a %s URL", key, strings.Join(schemes, " or ")))
+	}
+	return v
+}
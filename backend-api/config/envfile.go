@@ -0,0 +1,49 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// readEnvFile parses a .env file of KEY=VALUE lines. Blank lines, comments
+// and an "export " prefix are allowed, and values may be quoted.
+func readEnvFile(name string) (map[string]string, error) {
+	if name == "" {
+		name = ".env"
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", name, n)
+		}
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			if value[0] == '"' {
+				if value, err = strconv.Unquote(value); err != nil {
+					return nil, fmt.Errorf("%s:%d: %v", name, n, err)
+				}
+			} else {
+				value = value[1 : len(value)-1]
+			}
+		}
+		values[key] = value
+	}
+	return values, scanner.Err()
+}
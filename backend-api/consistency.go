@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/models"
+	"rosetta/repository"
+)
+
+type consistencyIssue struct {
+	Kind     string             `json:"kind"`
+	StoryID  primitive.ObjectID `json:"story_id"`
+	Detail   string             `json:"detail"`
+	Repaired bool               `json:"repaired"`
+}
+
+type consistencyReport struct {
+	StoriesScanned int                `json:"stories_scanned"`
+	Issues         []consistencyIssue `json:"issues"`
+}
+
+// runConsistencyCheck starts a job scanning every story, and repairing
+// what it can with repair=true. The report is the job's result.
+func runConsistencyCheck(w http.ResponseWriter, r *http.Request) {
+	repair := r.URL.Query().Get("repair") == "true"
+
+	startJob(w, r, "consistency_check", func(ctx context.Context, run *jobRun) (interface{}, error) {
+		report := consistencyReport{Issues: []consistencyIssue{}}
+		if err := checkStories(ctx, repair, &report); err != nil {
+			return nil, err
+		}
+		if err := checkFeaturedStories(ctx, repair, &report); err != nil {
+			return nil, err
+		}
+		return report, nil
+	})
+}
+
+func checkStories(ctx context.Context, repair bool, report *consistencyReport) error {
+	collection := storiesCollection()
+	cursor, err := collection.Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var story models.Story
+		if err := cursor.Decode(&story); err != nil {
+			return err
+		}
+		report.StoriesScanned++
+
+		var issues []consistencyIssue
+		set := bson.M{}
+
+		if story.Segments == nil {
+			issues = append(issues, consistencyIssue{Kind: "null_segments", Detail: "segments is null"})
+			set["segments"] = []models.Segment{}
+		}
+
+		seen := map[primitive.ObjectID]bool{}
+		segmentsFixed := false
+		for i, segment := range story.Segments {
+			switch {
+			case segment.ID.IsZero():
+				issues = append(issues, consistencyIssue{Kind: "segment_without_id", Detail: "segment has no ID"})
+			case seen[segment.ID]:
+				issues = append(issues, consistencyIssue{Kind: "duplicate_segment_id", Detail: "segment ID " + segment.ID.Hex() + " is repeated"})
+			default:
+				seen[segment.ID] = true
+				continue
+			}
+			story.Segments[i].ID = primitive.NewObjectID()
+			segmentsFixed = true
+		}
+		if segmentsFixed {
+			set["segments"] = story.Segments
+		}
+
+		if story.CreatedAt.IsZero() {
+			issues = append(issues, consistencyIssue{Kind: "missing_created_at", Detail: "created_at is not set"})
+			set["created_at"] = story.ID.Timestamp()
+		}
+		if story.UpdatedAt.IsZero() {
+			issues = append(issues, consistencyIssue{Kind: "missing_updated_at", Detail: "updated_at is not set"})
+			set["updated_at"] = time.Now()
+		}
+
+		repaired := false
+		if repair && len(set) > 0 {
+			// Only touch the story if nobody changed it while we were looking
+			res, err := collection.UpdateOne(ctx,
+				bson.M{"_id": story.ID, "version": repository.VersionFilter(story.Version)},
+				bson.M{"$set": set})
+			if err != nil {
+				return err
+			}
+			repaired = res.ModifiedCount == 1
+		}
+
+		for _, issue := range issues {
+			issue.StoryID = story.ID
+			issue.Repaired = repaired
+			report.Issues = append(report.Issues, issue)
+		}
+	}
+	return cursor.Err()
+}
+
+// checkFeaturedStories finds editorial overrides pointing at stories that were
+// deleted or unpublished since they were scheduled.
+func checkFeaturedStories(ctx context.Context, repair bool, report *consistencyReport) error {
+	featuredCollection := featuredStoriesCollection()
+	// Stale daily picks are picked again on the next request
+	cursor, err := featuredCollection.Find(ctx, bson.M{"picked": bson.M{"$ne": true}})
+	if err != nil {
+		return err
+	}
+
+	var featured []models.FeaturedStory
+	if err = cursor.All(ctx, &featured); err != nil {
+		return err
+	}
+
+	stories := storiesCollection()
+	for _, f := range featured {
+		count, err := stories.CountDocuments(ctx, listedFilter(bson.M{"_id": f.StoryID}))
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+
+		issue := consistencyIssue{
+			Kind:    "dangling_featured_story",
+			StoryID: f.StoryID,
+			Detail:  "featured on " + f.Date + " (" + f.Language + ") but missing, unpublished or unlisted",
+		}
+		if repair {
+			if _, err = featuredCollection.DeleteOne(ctx, bson.M{"_id": f.ID}); err != nil {
+				return err
+			}
+			issue.Repaired = true
+		}
+		report.Issues = append(report.Issues, issue)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+// Package contentfilter finds profanity and personal data in user text and
+// masks it.
+package contentfilter
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	ModeOff    = "off"
+	ModeMask   = "mask"
+	ModeReject = "reject"
+)
+
+const (
+	KindProfanity = "profanity"
+	KindEmail     = "email"
+	KindPhone     = "phone"
+)
+
+// Built-in word lists, keyed by primary language subtag. Deployments extend
+// them with a custom blocklist.
+var defaultWords = map[string][]string{
+	"en": {"fuck", "fucking", "shit", "bitch", "bastard", "asshole", "cunt", "dick"},
+	"es": {"mierda", "puta", "cabrón", "joder", "coño", "gilipollas"},
+	"fr": {"merde", "putain", "connard", "salope", "enculé"},
+	"de": {"scheiße", "scheisse", "arschloch", "fotze", "wichser", "hure"},
+}
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	phonePattern = regexp.MustCompile(`\+?\d[\d\s().\-]{7,}\d`)
+)
+
+// Match is a flagged span of text, as byte offsets.
+type Match struct {
+	Kind  string `json:"kind"`
+	Text  string `json:"text"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// Check returns the profanity and personal data found in text. Words are
+// compared case-insensitively against the list for lang plus extra.
+func Check(text, lang string, extra []string) []Match {
+	blocked := map[string]bool{}
+	for _, w := range defaultWords[primaryLanguage(lang)] {
+		blocked[w] = true
+	}
+	for _, w := range extra {
+		blocked[strings.ToLower(w)] = true
+	}
+
+	var matches []Match
+	for _, loc := range emailPattern.FindAllStringIndex(text, -1) {
+		matches = append(matches, Match{Kind: KindEmail, Text: text[loc[0]:loc[1]], Start: loc[0], End: loc[1]})
+	}
+	for _, loc := range phonePattern.FindAllStringIndex(text, -1) {
+		if !overlaps(matches, loc[0], loc[1]) {
+			matches = append(matches, Match{Kind: KindPhone, Text: text[loc[0]:loc[1]], Start: loc[0], End: loc[1]})
+		}
+	}
+	for _, word := range words(text) {
+		if blocked[strings.ToLower(word.Text)] && !overlaps(matches, word.Start, word.End) {
+			word.Kind = KindProfanity
+			matches = append(matches, word)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Start < matches[j].Start })
+	return matches
+}
+
+// Mask replaces every match with asterisks, one per character.
+func Mask(text string, matches []Match) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(text[last:m.Start])
+		b.WriteString(strings.Repeat("*", len([]rune(m.Text))))
+		last = m.End
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// words splits text into runs of letters, digits and apostrophes, which works
+// for any script that separates words with spaces or punctuation.
+func words(text string) []Match {
+	var result []Match
+	start := -1
+	for i, r := range text {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\'' || unicode.Is(unicode.Mn, r)
+		switch {
+		case inWord && start < 0:
+			start = i
+		case !inWord && start >= 0:
+			result = append(result, Match{Text: text[start:i], Start: start, End: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		result = append(result, Match{Text: text[start:], Start: start, End: len(text)})
+	}
+	return result
+}
+
+func overlaps(matches []Match, start, end int) bool {
+	for _, m := range matches {
+		if start < m.End && m.Start < end {
+			return true
+		}
+	}
+	return false
+}
+
+func primaryLanguage(lang string) string {
+	lang = strings.ToLower(lang)
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	return lang
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/domain"
+	"rosetta/models"
+	"rosetta/repository"
+)
+
+const contentHashPrefix = "sha256:"
+
+// hashedSegment is the canonical form of a segment inside a content hash.
+// Field order is fixed by the struct, so the JSON encoding is stable.
+type hashedSegment struct {
+	Speaker string `json:"speaker"`
+	Script  string `json:"script"`
+	Audio   string `json:"audio"`
+	Image   string `json:"image"`
+	// Left out when empty, so hashes of untranslated stories don't change
+	ScriptLanguage string            `json:"script_language,omitempty"`
+	Translations   map[string]string `json:"translations,omitempty"`
+	Clip           *hashedClip       `json:"clip,omitempty"`
+}
+
+// hashedClip identifies the track by its checksum, as segment audio is.
+type hashedClip struct {
+	Track   string `json:"track"`
+	StartMs int64  `json:"start_ms"`
+	EndMs   int64  `json:"end_ms"`
+}
+
+type hashedStory struct {
+	Title    string          `json:"title"`
+	Language string          `json:"language"`
+	Segments []hashedSegment `json:"segments"`
+}
+
+// contentHash digests what a reader of the story sees: title, language,
+// scripts with their speakers and a checksum for every media object. Media
+// stored in our bucket is identified by its ETag, anything else by its URL.
+func contentHash(ctx context.Context, story *models.Story) (string, error) {
+	trackChecksums := map[primitive.ObjectID]string{}
+	for _, track := range story.AudioTracks {
+		if track.Url == "" {
+			continue
+		}
+		checksum, err := mediaChecksum(ctx, track.Url)
+		if err != nil {
+			return "", err
+		}
+		trackChecksums[track.ID] = checksum
+	}
+
+	doc := hashedStory{Title: story.Title, Language: story.Language, Segments: []hashedSegment{}}
+	for _, segment := range story.Segments {
+		hashed := hashedSegment{Speaker: segment.Speaker}
+		if segment.Script != nil {
+			hashed.Script = segment.Script.Text
+			hashed.ScriptLanguage = segment.Script.Language
+			hashed.Translations = segment.Script.Translations
+		}
+		if segment.Audio != nil && segment.Audio.Url != "" {
+			checksum, err := mediaChecksum(ctx, segment.Audio.Url)
+			if err != nil {
+				return "", err
+			}
+			hashed.Audio = checksum
+		}
+		if segment.Image != nil && segment.Image.Url != "" {
+			checksum, err := mediaChecksum(ctx, segment.Image.Url)
+			if err != nil {
+				return "", err
+			}
+			hashed.Image = checksum
+		}
+		if segment.Clip != nil {
+			if checksum, ok := trackChecksums[segment.Clip.TrackID]; ok {
+				hashed.Clip = &hashedClip{Track: checksum, StartMs: segment.Clip.StartMs, EndMs: segment.Clip.EndMs}
+			}
+		}
+		doc.Segments = append(doc.Segments, hashed)
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return contentHashPrefix + hex.EncodeToString(sum[:]), nil
+}
+
+func mediaChecksum(ctx context.Context, url string) (string, error) {
+	bucket, key, ok := mediaLocation(url)
+	if !ok {
+		return url, nil
+	}
+
+	head, err := s3Client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if isNotFound(err) {
+		return "", domain.New(domain.ErrInvalid, "Media not found: "+url)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.Trim(aws.StringValue(head.ETag), `"`), nil
+}
+
+// stampContentHash sets the content hash on published stories and clears it
+// on drafts, which are still expected to change.
+func stampContentHash(ctx context.Context, story *models.Story) error {
+	if !story.IsPublished {
+		story.ContentHash = ""
+		return nil
+	}
+	hash, err := contentHash(ctx, story)
+	if err != nil {
+		return err
+	}
+	story.ContentHash = hash
+	return nil
+}
+
+// refreshContentHash recomputes the hash after a change that bypasses
+// saveStory, such as confirming an audio upload. If the story moved on in the
+// meantime, whoever changed it has already stamped a fresh hash.
+func refreshContentHash(ctx context.Context, storyID primitive.ObjectID) error {
+	story, err := findStory(ctx, storyID)
+	if err != nil {
+		return err
+	}
+	if !story.IsPublished {
+		return nil
+	}
+	hash, err := contentHash(ctx, &story)
+	if err != nil {
+		return err
+	}
+	_, err = storiesCollection().UpdateOne(ctx,
+		bson.M{"_id": storyID, "version": repository.VersionFilter(story.Version)},
+		bson.M{"$set": bson.M{"content_hash": hash}},
+	)
+	return err
+}
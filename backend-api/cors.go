@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"rosetta/config"
+)
+
+// corsExposedHeaders are the response headers browser clients need to read.
+const corsExposedHeaders = "ETag, Last-Modified, Link, Location, Retry-After, Warning, X-Request-ID, X-Total-Count, Accept-Patch"
+
+// corsMaxAge is how long, in seconds, browsers may cache a preflight answer.
+const corsMaxAge = "600"
+
+// allowCORS lets browser frontends on the configured origins call the API.
+// It sits in front of the router so preflight requests are answered for
+// every route, whatever methods the route itself accepts. Requests from
+// other origins are served without CORS headers and the browser blocks
+// them.
+func allowCORS(cfg *config.Config, next http.Handler) http.Handler {
+	if len(cfg.CORSAllowedOrigins) == 0 {
+		return next
+	}
+	anyOrigin := slices.Contains(cfg.CORSAllowedOrigins, "*")
+	methods := strings.Join(cfg.CORSAllowedMethods, ", ")
+	headers := strings.Join(cfg.CORSAllowedHeaders, ", ")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if !anyOrigin {
+			w.Header().Add("Vary", "Origin")
+		}
+		if origin == "" || !(anyOrigin || slices.Contains(cfg.CORSAllowedOrigins, origin)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if anyOrigin {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+			w.Header().Set("Access-Control-Allow-Methods", methods)
+			w.Header().Set("Access-Control-Allow-Headers", headers)
+			w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Access-Control-Expose-Headers", corsExposedHeaders)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	_ "embed"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"rosetta/api"
+	"rosetta/models"
+	"rosetta/openapi"
+)
+
+//go:embed devconsole.html
+var devConsolePage []byte
+
+// consoleRoutes are documented for the console only, on top of the routes
+// of the released schema.
+var consoleRoutes = []openapi.Route{
+	{Method: "POST", Path: "/stories/{id}/segments/split", Status: http.StatusOK, Request: splitRequest{}, Response: api.StoryResponse{}},
+	{Method: "POST", Path: "/stories/{id}/segments/merge", Status: http.StatusOK, Request: mergeRequest{}, Response: api.StoryResponse{}},
+	{Method: "POST", Path: "/stories/{storyId}/segments/{segmentId}/audio/generate", Status: http.StatusAccepted, Response: models.Job{}},
+	{Method: "POST", Path: "/stories/{storyId}/segments/{segmentId}/audio/trim", Status: http.StatusAccepted, Request: trimRequest{}, Response: models.Job{}},
+	{Method: "POST", Path: "/stories/{id}/audio-tracks", Status: http.StatusCreated},
+	{Method: "POST", Path: "/stories/{id}/audio-tracks/{trackId}/complete", Status: http.StatusOK, Response: api.StoryResponse{}},
+	{Method: "POST", Path: "/stories/{id}/audio-tracks/{trackId}/split", Status: http.StatusAccepted, Request: splitRequest{}, Response: models.Job{}},
+	{Method: "PUT", Path: "/stories/{storyId}/segments/{segmentId}/translations/{lang}", Status: http.StatusOK, Request: api.TranslationRequest{}, Response: api.StoryResponse{}},
+	{Method: "DELETE", Path: "/stories/{storyId}/segments/{segmentId}/translations/{lang}", Status: http.StatusOK, Response: api.StoryResponse{}},
+}
+
+// registerDevConsole mounts the API console, for trying requests by hand
+// against a development server. The console signs in like any client and
+// sends its requests as that user.
+func registerDevConsole(r *mux.Router) {
+	r.HandleFunc("/dev/console", getDevConsole).Methods("GET")
+	r.HandleFunc("/dev/openapi.json", requireUser(getDevSchema)).Methods("GET")
+}
+
+func getDevConsole(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(devConsolePage)
+}
+
+// getDevSchema describes the routes as they are in the code now, with an
+// example body for each request.
+func getDevSchema(w http.ResponseWriter, r *http.Request) {
+	doc := openapi.Generate("dev", append(append([]openapi.Route{}, openapi.Routes...), consoleRoutes...))
+	for path, methods := range doc.Paths {
+		for method, op := range methods {
+			example := consoleExample(strings.ToUpper(method), path)
+			if op.RequestBody == nil || example == nil {
+				continue
+			}
+			for contentType, media := range op.RequestBody.Content {
+				media.Example = example
+				op.RequestBody.Content[contentType] = media
+			}
+		}
+	}
+	writeResponse(w, r, http.StatusOK, doc)
+}
+
+// consoleExample returns an example request body for an operation.
+func consoleExample(method, path string) interface{} {
+	switch method + " " + path {
+	case "POST /stories", "PUT /stories/{id}":
+		return api.StoryRequest{
+			Title:      "The Fox and the Grapes",
+			Language:   "en",
+			Visibility: models.VisibilityPublic,
+			AgeRating:  "all",
+			Characters: []api.Character{{Name: "Fox", Color: "#d2691e"}},
+			Segments: []api.SegmentRequest{
+				{Speaker: "Narrator", Script: &api.Script{Text: "A hungry fox saw some fine bunches of grapes."}},
+				{Speaker: "Fox", Script: &api.Script{Text: "They are sour anyway.", Translations: map[string]string{"sv": "De är ändå sura."}}},
+			},
+			ContentWarnings: []string{},
+			Metadata:        map[string]string{"source": "console"},
+		}
+	case "PATCH /stories/{id}":
+		return map[string]interface{}{"title": "The Fox and the Sour Grapes"}
+	case "POST /stories/{id}/segments/split", "POST /stories/{id}/audio-tracks/{trackId}/split":
+		return map[string]interface{}{"text": "The fox jumped. He missed. He walked away.", "language": "en"}
+	case "POST /stories/{storyId}/segments/{segmentId}/audio/trim":
+		return map[string]interface{}{"start_ms": 1200, "end_ms": 5400}
+	case "PUT /stories/{storyId}/segments/{segmentId}/translations/{lang}":
+		return api.TranslationRequest{Text: "En hungrig räv såg några fina druvklasar."}
+	case "POST /stories/{id}/segments/merge":
+		return map[string]interface{}{"first_id": "<segment id>", "second_id": "<next segment id>"}
+	}
+	return nil
+}
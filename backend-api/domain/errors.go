@@ -0,0 +1,33 @@
+// Package domain defines the errors storage helpers return to handlers. The
+// HTTP layer maps each kind to a status code in one place.
+package domain
+
+import "errors"
+
+var (
+	ErrInvalid            = errors.New("invalid")
+	ErrNotFound           = errors.New("not found")
+	ErrConflict           = errors.New("conflict")
+	ErrPreconditionFailed = errors.New("precondition failed")
+	ErrQuotaExceeded      = errors.New("quota exceeded")
+	ErrForbidden          = errors.New("forbidden")
+	ErrUnauthenticated    = errors.New("unauthenticated")
+)
+
+// Error pairs one of the sentinel kinds with a message safe to show clients.
+type Error struct {
+	Kind    error
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+func New(kind error, message string) error {
+	return &Error{Kind: kind, Message: message}
+}
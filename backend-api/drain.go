@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// drainer coordinates taking an instance out of rotation ahead of a
+// deploy: readiness fails first so load balancers stop routing to it, then
+// in-flight requests finish and background workers stop.
+type drainer struct {
+	draining atomic.Bool
+	inFlight atomic.Int64
+
+	workers     sync.WaitGroup
+	workersCtx  context.Context
+	stopWorkers context.CancelFunc
+	stopOnce    sync.Once
+}
+
+var drain = &drainer{workersCtx: context.Background()}
+
+// start ties the background workers to parent: they stop when it is done or
+// when the instance is drained.
+func (d *drainer) start(parent context.Context) {
+	d.workersCtx, d.stopWorkers = context.WithCancel(parent)
+}
+
+// startWorker runs fn in the background, passing it the workers' context.
+func (d *drainer) startWorker(fn func(context.Context)) {
+	d.workers.Add(1)
+	go func() {
+		defer d.workers.Done()
+		fn(d.workersCtx)
+	}()
+}
+
+// stop fails readiness and stops the background workers, waiting for them
+// to return. It is safe to call more than once.
+func (d *drainer) stop() {
+	d.draining.Store(true)
+	d.stopOnce.Do(func() {
+		if d.stopWorkers != nil {
+			d.stopWorkers()
+		}
+	})
+	d.workers.Wait()
+}
+
+// track counts the requests being served.
+func (d *drainer) track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.inFlight.Add(1)
+		defer d.inFlight.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// waitIdle waits until at most allowed requests are in flight, reporting
+// false if ctx ends first.
+func (d *drainer) waitIdle(ctx context.Context, allowed int64) bool {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for d.inFlight.Load() > allowed {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+	return true
+}
+
+type drainStatus struct {
+	Draining bool  `json:"draining"`
+	InFlight int64 `json:"in_flight"`
+	Idle     bool  `json:"idle"`
+}
+
+// drainInstance takes the instance out of rotation: readiness fails,
+// background workers stop and the call waits up to timeout (a Go duration,
+// default 30s) for the other in-flight requests to finish. The server keeps
+// serving whatever still reaches it until it is sent SIGTERM. Like the
+// other admin routes, it takes the admin token.
+func drainInstance(w http.ResponseWriter, r *http.Request) {
+	timeout := 30 * time.Second
+	if v := r.URL.Query().Get("timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			httpError(w, "Invalid timeout", http.StatusBadRequest)
+			return
+		}
+		timeout = d
+	}
+
+	drain.stop()
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	// This request is in flight too
+	idle := drain.waitIdle(ctx, 1)
+
+	status := http.StatusOK
+	if !idle {
+		status = http.StatusAccepted
+	}
+	writeResponse(w, r, status, drainStatus{Draining: true, InFlight: drain.inFlight.Load() - 1, Idle: idle})
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"rosetta/domain"
+	"rosetta/redact"
+	"rosetta/validation"
+)
+
+// Stable error codes clients can switch on. Messages may change, codes don't.
+const (
+	codeInvalid            = "invalid_request"
+	codeNotFound           = "not_found"
+	codeConflict           = "conflict"
+	codePreconditionFailed = "precondition_failed"
+	codePreconditionNeeded = "precondition_required"
+	codeQuotaExceeded      = "rate_limited"
+	codeForbidden          = "forbidden"
+	codeUnauthenticated    = "unauthenticated"
+	codeMethodNotAllowed   = "method_not_allowed"
+	codeTooLarge           = "payload_too_large"
+	codeUnsupportedMedia   = "unsupported_media_type"
+	codeValidation         = "validation_failed"
+	codeInternal           = "internal_error"
+)
+
+type errorBody struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+type errorEnvelope struct {
+	Error errorBody `json:"error"`
+}
+
+func statusForError(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrInvalid):
+		return http.StatusBadRequest
+	case errors.Is(err, domain.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, domain.ErrPreconditionFailed):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, domain.ErrQuotaExceeded):
+		return http.StatusTooManyRequests
+	case errors.Is(err, domain.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, domain.ErrUnauthenticated):
+		return http.StatusUnauthorized
+	}
+	return http.StatusInternalServerError
+}
+
+func codeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return codeInvalid
+	case http.StatusNotFound:
+		return codeNotFound
+	case http.StatusConflict:
+		return codeConflict
+	case http.StatusPreconditionFailed:
+		return codePreconditionFailed
+	case http.StatusPreconditionRequired:
+		return codePreconditionNeeded
+	case http.StatusTooManyRequests:
+		return codeQuotaExceeded
+	case http.StatusForbidden:
+		return codeForbidden
+	case http.StatusUnauthorized:
+		return codeUnauthenticated
+	case http.StatusMethodNotAllowed:
+		return codeMethodNotAllowed
+	case http.StatusRequestEntityTooLarge:
+		return codeTooLarge
+	case http.StatusUnsupportedMediaType:
+		return codeUnsupportedMedia
+	}
+	return codeInternal
+}
+
+// writeError maps err to a status and error code. Only domain errors carry
+// messages meant for clients; anything else is logged and reported as an
+// internal error, so storage and AWS details stay out of responses.
+func writeError(w http.ResponseWriter, err error) {
+	var invalid validation.Errors
+	if errors.As(err, &invalid) {
+		writeErrorBody(w, http.StatusUnprocessableEntity, errorBody{Code: codeValidation, Message: "Request validation failed", Details: invalid})
+		return
+	}
+
+	status := statusForError(err)
+	if status == http.StatusInternalServerError {
+		log.Printf("internal error: %v", err)
+		writeErrorBody(w, status, errorBody{Code: codeInternal, Message: "Internal server error"})
+		return
+	}
+	writeErrorBody(w, status, errorBody{Code: codeForStatus(status), Message: redact.String(err.Error())})
+}
+
+// httpError is http.Error with the JSON error envelope, for request problems
+// detected in handlers.
+func httpError(w http.ResponseWriter, message string, status int) {
+	writeErrorBody(w, status, errorBody{Code: codeForStatus(status), Message: message})
+}
+
+func writeErrorBody(w http.ResponseWriter, status int, body errorBody) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorEnvelope{Error: body})
+}
+
+func notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	httpError(w, "Not found", http.StatusNotFound)
+}
+
+func methodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+}
@@ -0,0 +1,21 @@
+//go:build !chaos
+
+package main
+
+import (
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// Fault injection is compiled in only with the chaos build tag; see
+// faults_chaos.go. These are its no-op stand-ins.
+
+func injectFaults(next http.Handler) http.Handler {
+	return next
+}
+
+func addMongoFaults(monitor *event.CommandMonitor) {}
+
+func addS3Faults(handlers *request.Handlers) {}
@@ -0,0 +1,191 @@
+//go:build chaos
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// Builds with the chaos tag let a request ask for faults in the Mongo and
+// S3 calls made on its behalf, so client retries and circuit breakers can
+// be exercised against a real server. Never ship such a build to
+// production. The header lists target.param=value pairs:
+//
+//	X-Inject-Fault: mongo.latency=300ms; s3.error_rate=0.5
+//
+// latency delays every call and error_rate fails that share of calls, so
+// 1 fails all of them. Each call fails on its own, the rest of the request
+// carries on. Only calls made with the request context are affected.
+const faultHeader = "X-Inject-Fault"
+
+type faultSpec struct {
+	Latency   time.Duration
+	ErrorRate float64
+}
+
+type faults struct {
+	Mongo faultSpec
+	S3    faultSpec
+	// failMongo is set from a Mongo command's started event until it
+	// finishes, while the command is to fail
+	failMongo atomic.Bool
+}
+
+type faultsKey struct{}
+
+// faultContext is the context of a request with faults. The driver offers
+// no hook to fail a command, but right after its started event it gives up
+// on a command whose context deadline has passed, without sending or
+// retrying it. So while failMongo is set the deadline reads as passed, and
+// only that command fails. Mongo calls the request makes concurrently may
+// fail in its place.
+type faultContext struct {
+	context.Context
+	faults *faults
+}
+
+func (c faultContext) Deadline() (time.Time, bool) {
+	if c.faults.failMongo.Load() {
+		return time.Time{}, true
+	}
+	return c.Context.Deadline()
+}
+
+func (c faultContext) Value(key interface{}) interface{} {
+	if key == (faultsKey{}) {
+		return c.faults
+	}
+	return c.Context.Value(key)
+}
+
+func init() {
+	log.Printf("fault injection enabled via %s", faultHeader)
+}
+
+func injectFaults(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get(faultHeader)
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		f, err := parseFaults(header)
+		if err != nil {
+			httpError(w, fmt.Sprintf("Invalid %s: %v", faultHeader, err), http.StatusBadRequest)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(faultContext{Context: r.Context(), faults: f}))
+	})
+}
+
+func parseFaults(header string) (*faults, error) {
+	f := &faults{}
+	for _, part := range strings.Split(header, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		target, param, ok2 := strings.Cut(name, ".")
+		if !ok || !ok2 {
+			return f, fmt.Errorf("%q is not target.param=value", part)
+		}
+
+		var spec *faultSpec
+		switch target {
+		case "mongo":
+			spec = &f.Mongo
+		case "s3":
+			spec = &f.S3
+		default:
+			return f, fmt.Errorf("unknown target %q", target)
+		}
+
+		var err error
+		switch param {
+		case "latency":
+			spec.Latency, err = time.ParseDuration(value)
+		case "error_rate":
+			spec.ErrorRate, err = strconv.ParseFloat(value, 64)
+			if err == nil && (spec.ErrorRate < 0 || spec.ErrorRate > 1) {
+				err = fmt.Errorf("error_rate must be between 0 and 1")
+			}
+		default:
+			err = fmt.Errorf("unknown parameter %q", param)
+		}
+		if err != nil {
+			return f, err
+		}
+	}
+	return f, nil
+}
+
+func faultsFrom(ctx context.Context) *faults {
+	f, _ := ctx.Value(faultsKey{}).(*faults)
+	return f
+}
+
+// delay waits out the injected latency and reports whether the call should
+// then fail.
+func (s faultSpec) delay(ctx context.Context) bool {
+	if s.Latency > 0 {
+		select {
+		case <-time.After(s.Latency):
+		case <-ctx.Done():
+		}
+	}
+	return s.ErrorRate > 0 && rand.Float64() < s.ErrorRate
+}
+
+// addMongoFaults hooks into monitor, after any existing handlers. See
+// faultContext for how the command is failed.
+func addMongoFaults(monitor *event.CommandMonitor) {
+	started, succeeded, failed := monitor.Started, monitor.Succeeded, monitor.Failed
+	monitor.Started = func(ctx context.Context, e *event.CommandStartedEvent) {
+		if started != nil {
+			started(ctx, e)
+		}
+		if f := faultsFrom(ctx); f != nil && f.Mongo.delay(ctx) {
+			f.failMongo.Store(true)
+		}
+	}
+	monitor.Succeeded = func(ctx context.Context, e *event.CommandSucceededEvent) {
+		if f := faultsFrom(ctx); f != nil {
+			f.failMongo.Store(false)
+		}
+		if succeeded != nil {
+			succeeded(ctx, e)
+		}
+	}
+	monitor.Failed = func(ctx context.Context, e *event.CommandFailedEvent) {
+		if f := faultsFrom(ctx); f != nil {
+			f.failMongo.Store(false)
+		}
+		if failed != nil {
+			failed(ctx, e)
+		}
+	}
+}
+
+func addS3Faults(handlers *request.Handlers) {
+	// Sign runs before every attempt and an error there skips the send
+	handlers.Sign.PushBack(func(r *request.Request) {
+		if f := faultsFrom(r.Context()); f != nil && f.S3.delay(r.Context()) {
+			r.Error = awserr.NewRequestFailure(awserr.New("InjectedFault", "injected S3 fault", nil), http.StatusServiceUnavailable, "")
+		}
+	})
+}
@@ -0,0 +1,50 @@
+//go:build chaos
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// TestMongoErrorRate checks an injected error rate fails about that share
+// of Mongo commands, each on its own: the request context stays usable and
+// a failed command doesn't fail the ones after it.
+func TestMongoErrorRate(t *testing.T) {
+	monitor := &event.CommandMonitor{}
+	addMongoFaults(monitor)
+
+	f, err := parseFaults("mongo.error_rate=0.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := faultContext{Context: context.Background(), faults: f}
+
+	const n = 2000
+	failures := 0
+	for i := 0; i < n; i++ {
+		// The driver checks the deadline right after the started event
+		monitor.Started(ctx, &event.CommandStartedEvent{})
+		deadline, ok := ctx.Deadline()
+		if ok && time.Now().After(deadline) {
+			failures++
+			monitor.Failed(ctx, &event.CommandFailedEvent{})
+		} else {
+			monitor.Succeeded(ctx, &event.CommandSucceededEvent{})
+		}
+		if ctx.Err() != nil {
+			t.Fatalf("request context ended after %d commands: %v", i+1, ctx.Err())
+		}
+		if _, ok := ctx.Deadline(); ok {
+			t.Fatalf("command %d left the fault armed", i+1)
+		}
+	}
+
+	// 0.3 of 2000 is 600, with a standard deviation of about 20
+	if failures < 500 || failures > 700 {
+		t.Errorf("%d of %d commands failed, want about %d", failures, n, n*3/10)
+	}
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"rosetta/api"
+	"rosetta/domain"
+	"rosetta/models"
+)
+
+const dailyDateLayout = "2006-01-02"
+
+const (
+	feedDefaultLimit = 20
+	feedMaxLimit     = 50
+)
+
+// feedStory is the light story shape of the feed: enough to render a card
+// without downloading the segments.
+type feedStory struct {
+	ID            primitive.ObjectID `bson:"_id" json:"id"`
+	Title         string             `bson:"title" json:"title"`
+	Language      string             `bson:"language" json:"language"`
+	AgeRating     string             `bson:"age_rating,omitempty" json:"age_rating,omitempty"`
+	CoverImageURL string             `bson:"cover_image_url,omitempty" json:"cover_image_url,omitempty"`
+	SegmentCount  int                `bson:"segment_count" json:"segment_count"`
+	PublishedAt   time.Time          `bson:"published_at" json:"published_at"`
+}
+
+// feedProjection computes the card fields in the database. The cover is the
+// first segment image.
+var feedProjection = bson.M{
+	"title":           1,
+	"language":        1,
+	"age_rating":      1,
+	"published_at":    1,
+	"cover_image_url": bson.M{"$arrayElemAt": bson.A{"$segments.image.url", 0}},
+	"segment_count":   bson.M{"$size": bson.M{"$ifNull": bson.A{"$segments", bson.A{}}}},
+}
+
+// Feed cursors point at the last story of a page. Paging by position would
+// skip or repeat stories as new ones get published.
+func encodeFeedCursor(story *feedStory) string {
+	raw := strconv.FormatInt(story.PublishedAt.UnixMilli(), 10) + "." + story.ID.Hex()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeFeedCursor(cursor string) (time.Time, primitive.ObjectID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, primitive.NilObjectID, err
+	}
+	ms, hex, _ := strings.Cut(string(raw), ".")
+	n, err := strconv.ParseInt(ms, 10, 64)
+	if err != nil {
+		return time.Time{}, primitive.NilObjectID, err
+	}
+	id, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		return time.Time{}, primitive.NilObjectID, err
+	}
+	return time.UnixMilli(n), id, nil
+}
+
+// getFeed lists listed stories, most recently published first. The next
+// page is linked with a cursor in the Link header.
+func getFeed(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit := feedDefaultLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > feedMaxLimit {
+			httpError(w, fmt.Sprintf("limit must be between 1 and %d", feedMaxLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	filter := listedFilter(bson.M{"published_at": bson.M{"$ne": nil}})
+	if lang := query.Get("lang"); lang != "" {
+		filter["language"] = lang
+	}
+	if err := contentFilter(filter, query); err != nil {
+		writeError(w, err)
+		return
+	}
+	if cursor := query.Get("cursor"); cursor != "" {
+		publishedAt, id, err := decodeFeedCursor(cursor)
+		if err != nil {
+			httpError(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		filter["$or"] = bson.A{
+			bson.M{"published_at": bson.M{"$lt": publishedAt}},
+			bson.M{"published_at": publishedAt, "_id": bson.M{"$lt": id}},
+		}
+	}
+
+	opts := options.Find().
+		SetProjection(feedProjection).
+		SetSort(bson.D{{Key: "published_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(limit))
+	cursor, err := storiesCollection().Find(r.Context(), filter, opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	stories := []feedStory{}
+	if err = cursor.All(r.Context(), &stories); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// A full page may be followed by more
+	if len(stories) == limit {
+		next := r.URL.Query()
+		next.Set("cursor", encodeFeedCursor(&stories[len(stories)-1]))
+		w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, next.Encode()))
+	}
+	setPublicCache(w)
+	writeResponse(w, r, http.StatusOK, stories)
+}
+
+func getDailyStory(w http.ResponseWriter, r *http.Request) {
+	lang := r.URL.Query().Get("lang")
+	if lang == "" {
+		httpError(w, "Missing lang parameter", http.StatusBadRequest)
+		return
+	}
+
+	filter := listedFilter(bson.M{"language": lang})
+	if err := contentFilter(filter, r.URL.Query()); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	now := time.Now().UTC()
+	date := now.Format(dailyDateLayout)
+
+	storyID, err := dailyStoryID(r.Context(), lang, date, dailyFilterKey(r.URL.Query()), filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	story, err := findStory(r.Context(), storyID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// The feed is also served publicly, where edit locks don't belong
+	story.Lock = nil
+
+	// The pick only changes at midnight UTC, so let clients cache until then
+	expires := now.Truncate(24 * time.Hour).Add(24 * time.Hour)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(expires.Sub(now).Seconds())))
+	w.Header().Set("Expires", expires.Format(http.TimeFormat))
+	writeResponse(w, r, http.StatusOK, api.StoryFromModel(&story))
+}
+
+// dailyFilterKey identifies the content filter of a daily story request, so
+// that each filter keeps a pick of its own for the day.
+func dailyFilterKey(query url.Values) string {
+	key := url.Values{}
+	if max := query.Get("max_age_rating"); max != "" {
+		key.Set("max_age_rating", max)
+	}
+	if exclude := query.Get("exclude_warnings"); exclude != "" {
+		warnings := strings.Split(exclude, ",")
+		slices.Sort(warnings)
+		key.Set("exclude_warnings", strings.Join(slices.Compact(warnings), ","))
+	}
+	return key.Encode()
+}
+
+// dailyStoryID returns the editorial override for the day if one exists and
+// matches filter, otherwise the story picked for the day. The pick is stored
+// by the day's first request, so stories published later in the day don't
+// change it; another is only picked if it stops matching.
+func dailyStoryID(ctx context.Context, lang, date, filterKey string, filter bson.M) (primitive.ObjectID, error) {
+	override, ok, err := findFeaturedStory(ctx, bson.M{"date": date, "language": lang, "picked": bson.M{"$ne": true}}, filter)
+	if err != nil || ok {
+		return override.StoryID, err
+	}
+
+	pickQuery := bson.M{"date": date, "language": lang, "picked": true, "filter": filterKey}
+	picked, ok, err := findFeaturedStory(ctx, pickQuery, filter)
+	if err != nil || ok {
+		return picked.StoryID, err
+	}
+
+	storyID, err := pickDailyStory(ctx, lang, date, filter)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	collection := featuredStoriesCollection()
+	var stored models.FeaturedStory
+	if !picked.ID.IsZero() {
+		// The story picked earlier was unpublished or changed since. Only
+		// one request replaces it; all of them serve what got stored.
+		_, err = collection.UpdateOne(ctx, bson.M{"_id": picked.ID, "story_id": picked.StoryID}, bson.M{"$set": bson.M{"story_id": storyID}})
+		if err != nil {
+			return primitive.NilObjectID, err
+		}
+		if err = collection.FindOne(ctx, bson.M{"_id": picked.ID}).Decode(&stored); err != nil {
+			return primitive.NilObjectID, err
+		}
+		return stored.StoryID, nil
+	}
+
+	// Concurrent first requests all get whichever pick was stored first
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err = collection.FindOneAndUpdate(ctx, pickQuery, bson.M{"$setOnInsert": bson.M{"story_id": storyID}}, opts).Decode(&stored)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return stored.StoryID, nil
+}
+
+// findFeaturedStory finds the featured story matching query and reports
+// whether its story matches filter.
+func findFeaturedStory(ctx context.Context, query, filter bson.M) (models.FeaturedStory, bool, error) {
+	var featured models.FeaturedStory
+	err := featuredStoriesCollection().FindOne(ctx, query).Decode(&featured)
+	if err == mongo.ErrNoDocuments {
+		return featured, false, nil
+	}
+	if err != nil {
+		return featured, false, err
+	}
+
+	storyFilter := bson.M{"_id": featured.StoryID}
+	for k, v := range filter {
+		storyFilter[k] = v
+	}
+	count, err := storiesCollection().CountDocuments(ctx, storyFilter)
+	if err != nil {
+		return featured, false, err
+	}
+	return featured, count > 0, nil
+}
+
+// pickDailyStory picks one of the stories matching filter by hashing the
+// language and date into their ID order, without loading them all.
+func pickDailyStory(ctx context.Context, lang, date string, filter bson.M) (primitive.ObjectID, error) {
+	collection := storiesCollection()
+	count, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	if count == 0 {
+		return primitive.NilObjectID, domain.New(domain.ErrNotFound, "No published stories for language")
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(lang + "/" + date))
+	opts := options.FindOne().
+		SetProjection(bson.M{"_id": 1}).
+		SetSort(bson.M{"_id": 1}).
+		SetSkip(int64(h.Sum64() % uint64(count)))
+
+	var story models.Story
+	err = collection.FindOne(ctx, filter, opts).Decode(&story)
+	if err == mongo.ErrNoDocuments {
+		// One of the counted stories went away in between
+		return primitive.NilObjectID, domain.New(domain.ErrConflict, "Stories changed while picking, try again")
+	}
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return story.ID, nil
+}
+
+func setDailyStoryOverride(w http.ResponseWriter, r *http.Request) {
+	var featured models.FeaturedStory
+	err := json.NewDecoder(r.Body).Decode(&featured)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if featured.Language == "" {
+		httpError(w, "Missing language", http.StatusBadRequest)
+		return
+	}
+	if _, err = time.Parse(dailyDateLayout, featured.Date); err != nil {
+		httpError(w, "Invalid date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	var story models.Story
+	collection := storiesCollection()
+	// A story in another language would never match the day's requests
+	err = collection.FindOne(r.Context(), listedFilter(bson.M{"_id": featured.StoryID, "language": featured.Language})).Decode(&story)
+	if err == mongo.ErrNoDocuments {
+		httpError(w, "Story not found, not published, unlisted or not in "+featured.Language, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	filter := bson.M{"date": featured.Date, "language": featured.Language, "picked": bson.M{"$ne": true}}
+	update := bson.M{"$set": bson.M{"story_id": featured.StoryID}}
+	_, err = featuredStoriesCollection().UpdateOne(r.Context(), filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
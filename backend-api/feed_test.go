@@ -0,0 +1,21 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestDailyFilterKey(t *testing.T) {
+	a, _ := url.ParseQuery("lang=sv&exclude_warnings=violence,drugs&max_age_rating=13%2B")
+	b, _ := url.ParseQuery("max_age_rating=13%2B&exclude_warnings=drugs,violence,drugs&lang=sv")
+	if dailyFilterKey(a) != dailyFilterKey(b) {
+		t.Errorf("same filters keyed %q and %q", dailyFilterKey(a), dailyFilterKey(b))
+	}
+	if key := dailyFilterKey(url.Values{"lang": {"sv"}}); key != "" {
+		t.Errorf("unfiltered request keyed %q, want empty", key)
+	}
+	c, _ := url.ParseQuery("max_age_rating=7%2B")
+	if dailyFilterKey(a) == dailyFilterKey(c) {
+		t.Errorf("different filters both keyed %q", dailyFilterKey(a))
+	}
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"rosetta/api"
+	"rosetta/domain"
+	"rosetta/models"
+	"rosetta/repository"
+	"rosetta/rosettapb"
+	"rosetta/validation"
+)
+
+const grpcDefaultPageSize = 20
+
+// storyService is the gRPC API for internal services. It goes through the
+// same validation, authorization and storage helpers as the HTTP handlers.
+type storyService struct {
+	rosettapb.UnimplementedStoryServiceServer
+}
+
+// serveGRPC starts the gRPC API on addr in the background.
+func serveGRPC(addr string) (*grpc.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(logRPCs, recoverRPCs, authenticateRPCs))
+	rosettapb.RegisterStoryServiceServer(server, storyService{})
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			log.Printf("gRPC server: %v", err)
+		}
+	}()
+	return server, nil
+}
+
+// stopGRPC lets in-flight calls finish for up to timeout, then cuts them off.
+func stopGRPC(server *grpc.Server, timeout time.Duration) {
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(timeout):
+		server.Stop()
+	}
+}
+
+// authenticateRPCs resolves the API key or bearer token in the call
+// metadata like authenticate does for HTTP.
+func authenticateRPCs(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if keys := md.Get(strings.ToLower(apiKeyHeader)); len(keys) > 0 {
+		userID, err := userFromAPIKey(ctx, keys[0])
+		if err != nil {
+			return nil, rpcError(err)
+		}
+		return handler(context.WithValue(ctx, userKey{}, userID), req)
+	}
+
+	headers := md.Get("authorization")
+	if len(headers) == 0 {
+		return handler(ctx, req)
+	}
+	raw, ok := strings.CutPrefix(headers[0], "Bearer ")
+	var userID primitive.ObjectID
+	var err error
+	if ok {
+		userID, err = parseToken(raw)
+	}
+	if !ok || err != nil {
+		return nil, status.Error(codes.Unauthenticated, "Invalid or expired token")
+	}
+	return handler(context.WithValue(ctx, userKey{}, userID), req)
+}
+
+func logRPCs(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	accessLog.Info("rpc",
+		"method", info.FullMethod,
+		"code", status.Code(err).String(),
+		"latency_ms", float64(time.Since(start).Microseconds())/1000,
+	)
+	return resp, err
+}
+
+func recoverRPCs(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			accessLog.Error("panic", "method", info.FullMethod, "panic", p, "stack", string(debug.Stack()))
+			resp, err = nil, status.Error(codes.Internal, "Internal server error")
+		}
+	}()
+	return handler(ctx, req)
+}
+
+func requireRPCUser(ctx context.Context) error {
+	if _, ok := currentUser(ctx); !ok {
+		return status.Error(codes.Unauthenticated, "Authentication required")
+	}
+	return nil
+}
+
+// rpcError is writeError for gRPC: domain errors keep their message,
+// anything else is logged and reported as internal.
+func rpcError(err error) error {
+	var conflict *mergeConflictError
+	if errors.As(err, &conflict) {
+		return status.Error(codes.Aborted, conflict.Error())
+	}
+
+	var code codes.Code
+	switch {
+	case errors.Is(err, domain.ErrInvalid):
+		code = codes.InvalidArgument
+	case errors.Is(err, domain.ErrNotFound):
+		code = codes.NotFound
+	case errors.Is(err, domain.ErrConflict):
+		code = codes.Aborted
+	case errors.Is(err, domain.ErrPreconditionFailed):
+		code = codes.FailedPrecondition
+	case errors.Is(err, domain.ErrQuotaExceeded):
+		code = codes.ResourceExhausted
+	case errors.Is(err, domain.ErrForbidden):
+		code = codes.PermissionDenied
+	case errors.Is(err, domain.ErrUnauthenticated):
+		code = codes.Unauthenticated
+	default:
+		log.Printf("internal error: %v", err)
+		return status.Error(codes.Internal, "Internal server error")
+	}
+	return status.Error(code, err.Error())
+}
+
+func parseRPCStoryID(id string) (primitive.ObjectID, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return primitive.NilObjectID, status.Error(codes.InvalidArgument, "Invalid story ID")
+	}
+	return objectID, nil
+}
+
+func (storyService) CreateStory(ctx context.Context, req *rosettapb.CreateStoryRequest) (*rosettapb.Story, error) {
+	if err := requireRPCUser(ctx); err != nil {
+		return nil, err
+	}
+	request := storyRequestFromPB(req.GetStory())
+	if err := validation.Story(&request); err != nil {
+		return nil, rpcError(err)
+	}
+
+	story := request.ToModel()
+	story.OwnerID, _ = currentUser(ctx)
+	if err := assessSpam(ctx, rpcClientIP(ctx), &story); err != nil {
+		return nil, rpcError(err)
+	}
+	if err := insertStory(ctx, &story); err != nil {
+		return nil, rpcError(err)
+	}
+	return storyToPB(&story), nil
+}
+
+func (storyService) GetStory(ctx context.Context, req *rosettapb.GetStoryRequest) (*rosettapb.Story, error) {
+	id, err := parseRPCStoryID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	story, err := findVisibleStory(ctx, id)
+	if err != nil {
+		return nil, rpcError(err)
+	}
+	return storyToPB(&story), nil
+}
+
+// ListStories pages through stories in ID order; the page token is the last
+// ID of the previous page.
+func (storyService) ListStories(ctx context.Context, req *rosettapb.ListStoriesRequest) (*rosettapb.ListStoriesResponse, error) {
+	pageSize := int(req.GetPageSize())
+	if pageSize == 0 {
+		pageSize = grpcDefaultPageSize
+	}
+	if pageSize < 0 || pageSize > maxListLimit {
+		return nil, status.Errorf(codes.InvalidArgument, "page_size must be between 1 and %d", maxListLimit)
+	}
+
+	list := repository.StoryQuery{Limit: pageSize, Listed: true}
+	list.Owner, _ = currentUser(ctx)
+	if token := req.GetPageToken(); token != "" {
+		after, err := primitive.ObjectIDFromHex(token)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "Invalid page token")
+		}
+		list.AfterID = after
+	}
+	if req.GetUpdatedSince() != nil {
+		list.UpdatedSince = req.GetUpdatedSince().AsTime()
+	}
+
+	stories, _, err := storyRepo.List(ctx, list)
+	if err != nil {
+		return nil, rpcError(err)
+	}
+
+	resp := &rosettapb.ListStoriesResponse{Stories: make([]*rosettapb.Story, 0, len(stories))}
+	for i := range stories {
+		resp.Stories = append(resp.Stories, storyToPB(&stories[i]))
+	}
+	if len(stories) == pageSize {
+		resp.NextPageToken = stories[len(stories)-1].ID.Hex()
+	}
+	return resp, nil
+}
+
+func (storyService) UpdateStory(ctx context.Context, req *rosettapb.UpdateStoryRequest) (*rosettapb.Story, error) {
+	if err := requireRPCUser(ctx); err != nil {
+		return nil, err
+	}
+	id, err := parseRPCStoryID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	request := storyRequestFromPB(req.GetStory())
+	if err = validation.Story(&request); err != nil {
+		return nil, rpcError(err)
+	}
+	if _, err = findOwnStory(ctx, id); err != nil {
+		return nil, rpcError(err)
+	}
+
+	story := request.ToModel()
+	if err = updateStoryContent(ctx, id, &story, time.Time{}, req.GetBaseVersion()); err != nil {
+		return nil, rpcError(err)
+	}
+
+	updated, err := findStory(ctx, id)
+	if err != nil {
+		return nil, rpcError(err)
+	}
+	return storyToPB(&updated), nil
+}
+
+func (storyService) DeleteStory(ctx context.Context, req *rosettapb.DeleteStoryRequest) (*rosettapb.DeleteStoryResponse, error) {
+	if err := requireRPCUser(ctx); err != nil {
+		return nil, err
+	}
+	id, err := parseRPCStoryID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	story, err := findOwnStory(ctx, id)
+	if err != nil {
+		return nil, rpcError(err)
+	}
+
+	deleted, err := removeStory(ctx, id, story.OwnerID, time.Time{})
+	if err != nil {
+		return nil, rpcError(err)
+	}
+	if !deleted {
+		return nil, status.Error(codes.NotFound, "Story not found")
+	}
+	return &rosettapb.DeleteStoryResponse{}, nil
+}
+
+func rpcClientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	addr := p.Addr.String()
+	if i := strings.LastIndexByte(addr, ':'); i >= 0 {
+		addr = addr[:i]
+	}
+	return addr
+}
+
+// storyRequestFromPB maps the protobuf input onto the HTTP request body, so
+// both APIs validate and convert stories the same way.
+func storyRequestFromPB(in *rosettapb.StoryInput) api.StoryRequest {
+	request := api.StoryRequest{
+		Title:           in.GetTitle(),
+		Language:        in.GetLanguage(),
+		IsPublished:     in.GetIsPublished(),
+		Visibility:      in.GetVisibility(),
+		AgeRating:       in.GetAgeRating(),
+		ContentWarnings: in.GetContentWarnings(),
+	}
+	for _, segment := range in.GetSegments() {
+		// Unknown IDs are treated as new segments, as over HTTP
+		id, _ := primitive.ObjectIDFromHex(segment.GetId())
+		s := api.SegmentRequest{ID: id, Speaker: segment.GetSpeaker()}
+		if segment.GetAudioUrl() != "" {
+			s.Audio = &api.Audio{URL: segment.GetAudioUrl()}
+		}
+		if segment.GetImageUrl() != "" {
+			s.Image = &api.Image{URL: segment.GetImageUrl()}
+		}
+		if segment.GetScript() != "" {
+			s.Script = &api.Script{Text: segment.GetScript()}
+		}
+		request.Segments = append(request.Segments, s)
+	}
+	for _, character := range in.GetCharacters() {
+		request.Characters = append(request.Characters, api.Character{
+			Name:  character.GetName(),
+			Color: character.GetColor(),
+			Voice: character.GetVoice(),
+		})
+	}
+	return request
+}
+
+func storyToPB(story *models.Story) *rosettapb.Story {
+	response := api.StoryFromModel(story)
+	out := &rosettapb.Story{
+		Id:              response.ID.Hex(),
+		Title:           response.Title,
+		Language:        response.Language,
+		IsPublished:     response.IsPublished,
+		Visibility:      response.Visibility,
+		AgeRating:       response.AgeRating,
+		ContentWarnings: response.ContentWarnings,
+		Version:         response.Version,
+		ContentHash:     response.ContentHash,
+		CreatedAt:       timestamppb.New(response.CreatedAt),
+		UpdatedAt:       timestamppb.New(response.UpdatedAt),
+	}
+	if response.OwnerID != nil {
+		out.OwnerId = response.OwnerID.Hex()
+	}
+	if response.PublishedAt != nil {
+		out.PublishedAt = timestamppb.New(*response.PublishedAt)
+	}
+	for _, segment := range response.Segments {
+		s := &rosettapb.Segment{Id: segment.ID.Hex(), Speaker: segment.Speaker, Version: segment.Version}
+		if segment.Audio != nil {
+			s.AudioUrl = segment.Audio.URL
+		}
+		if segment.Image != nil {
+			s.ImageUrl = segment.Image.URL
+		}
+		if segment.Script != nil {
+			s.Script = segment.Script.Text
+		}
+		out.Segments = append(out.Segments, s)
+	}
+	for _, character := range response.Characters {
+		out.Characters = append(out.Characters, &rosettapb.Character{
+			Name:  character.Name,
+			Color: character.Color,
+			Voice: character.Voice,
+		})
+	}
+	return out
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"rosetta/api"
+)
+
+const contentTypeHAL = "application/hal+json"
+
+type halLink struct {
+	Href      string `json:"href"`
+	Templated bool   `json:"templated,omitempty"`
+}
+
+func storyLinks(story *api.StoryResponse) map[string]halLink {
+	self := fmt.Sprintf("/stories/%s", story.ID.Hex())
+	return map[string]halLink{
+		"self":         {Href: self},
+		"audio_upload": {Href: self + "/segments/{segmentId}/audio", Templated: true},
+	}
+}
+
+func segmentLinks(story *api.StoryResponse, segment *api.SegmentResponse) map[string]halLink {
+	return map[string]halLink{
+		"audio_upload": {Href: fmt.Sprintf("/stories/%s/segments/%s/audio", story.ID.Hex(), segment.ID.Hex())},
+	}
+}
+
+// halDocument decorates the JSON form of v with HAL _links. Arrays are wrapped
+// in an _embedded items collection.
+func halDocument(r *http.Request, v interface{}) (interface{}, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	links := map[string]halLink{"self": {Href: r.URL.RequestURI()}}
+	var story *api.StoryResponse
+	switch v := v.(type) {
+	case api.StoryResponse:
+		story = &v
+	case storyDelta:
+		story = &v.StoryResponse
+	case syncChanges:
+		links["next"] = halLink{Href: "/sync?token=" + v.Token}
+	}
+
+	var doc map[string]interface{}
+	if err = json.Unmarshal(raw, &doc); err != nil {
+		var items []interface{}
+		if err = json.Unmarshal(raw, &items); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"_links":    links,
+			"_embedded": map[string]interface{}{"items": items},
+		}, nil
+	}
+
+	if story != nil {
+		for name, link := range storyLinks(story) {
+			links[name] = link
+		}
+		if segments, ok := doc["segments"].([]interface{}); ok {
+			for i, segment := range segments {
+				if m, ok := segment.(map[string]interface{}); ok && i < len(story.Segments) {
+					m["_links"] = segmentLinks(story, &story.Segments[i])
+				}
+			}
+		}
+	}
+
+	doc["_links"] = links
+	return doc, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"rosetta/redact"
+)
+
+// dependencyTimeout bounds each readiness probe, so a hung dependency
+// fails the check instead of hanging it.
+const dependencyTimeout = 2 * time.Second
+
+const (
+	statusOK          = "ok"
+	statusUnavailable = "unavailable"
+)
+
+type dependencyStatus struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type readinessStatus struct {
+	Status       string                      `json:"status"`
+	Draining     bool                        `json:"draining"`
+	Dependencies map[string]dependencyStatus `json:"dependencies"`
+}
+
+// liveCheck reports that the process is up and serving. It checks no
+// dependencies, so an outage elsewhere doesn't get the process restarted.
+func liveCheck(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+// readyCheck reports whether the instance can serve traffic: it is not
+// draining, MongoDB answers a ping and the media bucket is reachable. Each
+// dependency's status is listed, and any failure makes it a 503.
+func readyCheck(w http.ResponseWriter, r *http.Request) {
+	probes := map[string]func(context.Context) error{
+		"mongo": func(ctx context.Context) error {
+			return client.Ping(ctx, nil)
+		},
+		"s3": func(ctx context.Context) error {
+			_, err := s3Client.HeadBucketWithContext(ctx, &s3.HeadBucketInput{Bucket: aws.String(s3Bucket)})
+			return err
+		},
+	}
+
+	status := readinessStatus{Status: statusOK, Draining: drain.draining.Load(), Dependencies: map[string]dependencyStatus{}}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, probe := range probes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(r.Context(), dependencyTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := probe(ctx)
+			result := dependencyStatus{Status: statusOK, LatencyMS: float64(time.Since(start).Microseconds()) / 1000}
+			if err != nil {
+				result.Status = statusUnavailable
+				result.Error = redact.String(err.Error())
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			status.Dependencies[name] = result
+			if err != nil {
+				status.Status = statusUnavailable
+			}
+		}()
+	}
+	wg.Wait()
+
+	code := http.StatusOK
+	if status.Draining {
+		status.Status = statusUnavailable
+	}
+	if status.Status != statusOK {
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeResponse(w, r, code, status)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/gorilla/mux"
+	"github.com/oklog/ulid/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/api"
+	"rosetta/domain"
+	"rosetta/models"
+)
+
+// imageTypes maps the accepted image content types to file extensions.
+var imageTypes = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/webp": "webp",
+}
+
+type imageUploadRequest struct {
+	ContentType string `json:"content_type"`
+}
+
+func newImageKey(storyID, segmentID primitive.ObjectID, ext string) string {
+	return fmt.Sprintf("%s/%s/image/%s.%s", storyID.Hex(), segmentID.Hex(), ulid.MustNew(ulid.Now(), rand.Reader), ext)
+}
+
+// generateImageUploadURL works like its audio counterpart. The content type
+// is signed into the URL, so S3 rejects uploads declaring any other type.
+func generateImageUploadURL(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	storyID, err := primitive.ObjectIDFromHex(vars["storyId"])
+	if err != nil {
+		httpError(w, "Invalid story ID", http.StatusBadRequest)
+		return
+	}
+	segmentID, err := primitive.ObjectIDFromHex(vars["segmentId"])
+	if err != nil {
+		httpError(w, "Invalid segment ID", http.StatusBadRequest)
+		return
+	}
+
+	var request imageUploadRequest
+	err = decodeRequest(r, &request)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ext, ok := imageTypes[request.ContentType]
+	if !ok {
+		httpError(w, "content_type must be image/jpeg, image/png or image/webp", http.StatusBadRequest)
+		return
+	}
+
+	story, err := findOwnStory(r.Context(), storyID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if findSegment(&story, segmentID) == nil {
+		writeError(w, domain.New(domain.ErrNotFound, "Segment not found"))
+		return
+	}
+
+	objectName := newImageKey(storyID, segmentID, ext)
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(writeBucket()),
+		Key:         aws.String(objectName),
+		ContentType: aws.String(request.ContentType),
+	}
+	encryptPut(input)
+	req, _ := s3Client.PutObjectRequest(input)
+	presignedURL, err := req.Presign(15 * time.Minute)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	presignedURL = strings.Replace(presignedURL, s3Endpoint, s3PublicHost, 1)
+
+	err = updateSegment(r.Context(), storyID, segmentID, bson.M{"image.pending_key": objectName})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	headers := uploadHeaders()
+	headers["Content-Type"] = request.ContentType
+
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(map[string]interface{}{
+		"upload_url":     presignedURL,
+		"upload_headers": headers,
+		"public_url":     mediaURL(objectName),
+		"key":            objectName,
+	})
+}
+
+func confirmImageUpload(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	storyID, err := primitive.ObjectIDFromHex(vars["storyId"])
+	if err != nil {
+		httpError(w, "Invalid story ID", http.StatusBadRequest)
+		return
+	}
+	segmentID, err := primitive.ObjectIDFromHex(vars["segmentId"])
+	if err != nil {
+		httpError(w, "Invalid segment ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err = findOwnStory(r.Context(), storyID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	err = promotePendingImage(r.Context(), storyID, segmentID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	updatedStory, err := findStory(r.Context(), storyID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeResponse(w, r, http.StatusOK, api.StoryFromModel(&updatedStory))
+}
+
+// promotePendingImage makes a segment's pending image upload its current
+// image once the object exists with an accepted content type.
+func promotePendingImage(ctx context.Context, storyID, segmentID primitive.ObjectID) error {
+	story, err := findStory(ctx, storyID)
+	if err != nil {
+		return err
+	}
+	segment := findSegment(&story, segmentID)
+	if segment == nil {
+		return domain.New(domain.ErrNotFound, "Segment not found")
+	}
+	if segment.Image == nil || segment.Image.PendingKey == "" {
+		return domain.New(domain.ErrConflict, "No pending image upload")
+	}
+	pendingKey := segment.Image.PendingKey
+
+	bucket, head, err := headMedia(ctx, pendingKey)
+	if isNotFound(err) {
+		return domain.New(domain.ErrConflict, "Image has not been uploaded yet")
+	}
+	if err != nil {
+		return err
+	}
+	if _, ok := imageTypes[aws.StringValue(head.ContentType)]; !ok {
+		return domain.New(domain.ErrInvalid, "Uploaded image has an unsupported content type")
+	}
+
+	image := models.Image{Url: bucketMediaURL(bucket, pendingKey), Key: pendingKey}
+	err = updateSegment(ctx, storyID, segmentID, bson.M{"image": image})
+	if err != nil {
+		return err
+	}
+	err = refreshContentHash(ctx, storyID)
+	if err != nil {
+		return err
+	}
+
+	// Best effort: a leftover object is harmless and shows up in media reports
+	if previousKey := segment.Image.Key; previousKey != "" && previousKey != pendingKey {
+		if err = deleteMedia(ctx, previousKey); err != nil {
+			log.Printf("failed to delete replaced image %s: %v", previousKey, err)
+		}
+	}
+	return nil
+}
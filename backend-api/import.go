@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/api"
+	"rosetta/domain"
+	"rosetta/models"
+	"rosetta/textimport"
+	"rosetta/validation"
+)
+
+const (
+	maxImportSize     = 1 << 20
+	maxDocxImportSize = 20 << 20
+	maxDerivedTitle   = 60
+)
+
+// importTextStory creates a draft story from a plain text or Markdown body,
+// one segment per paragraph or heading. The title and language come from
+// the title and lang query parameters, or are derived from the text.
+func importTextStory(w http.ResponseWriter, r *http.Request) {
+	format := textimport.FormatPlain
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		switch {
+		case err != nil:
+			httpError(w, "Invalid Content-Type", http.StatusBadRequest)
+			return
+		case mediaType == "text/markdown":
+			format = textimport.FormatMarkdown
+		case mediaType != "text/plain":
+			httpError(w, "Content-Type must be text/plain or text/markdown", http.StatusUnsupportedMediaType)
+			return
+		}
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportSize))
+	if err != nil {
+		httpError(w, "Import must be at most 1 MB", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	createImportedStory(w, r, textimport.Parse(string(body), format), clientIP(r))
+}
+
+// importDocxStory is importTextStory for Word documents, including Google
+// Docs exported as .docx. Inline images are stored as segment images.
+func importDocxStory(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxDocxImportSize))
+	if err != nil {
+		httpError(w, "Document must be at most 20 MB", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	doc, err := textimport.ParseDocx(body, validation.MaxSegments)
+	switch {
+	case errors.Is(err, textimport.ErrNotDocx):
+		httpError(w, "Body must be a .docx document", http.StatusBadRequest)
+		return
+	case errors.Is(err, textimport.ErrTooManySegments):
+		writeError(w, domain.New(domain.ErrInvalid, fmt.Sprintf("A story can have at most %d segments", validation.MaxSegments)))
+		return
+	case errors.Is(err, textimport.ErrTooLarge):
+		httpError(w, "Document has too many paragraphs or images", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	createImportedStory(w, r, doc, clientIP(r))
+}
+
+// createImportedStory stores the media of doc and creates the draft story.
+// The story is validated first, so nothing is uploaded for an import that
+// fails. clientKey identifies the source for spam checks.
+func createImportedStory(w http.ResponseWriter, r *http.Request, doc textimport.Document, clientKey string) {
+	if len(doc.Segments) == 0 {
+		httpError(w, "Nothing to import", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	title := query.Get("title")
+	if title == "" {
+		title = doc.Title
+	}
+	if title == "" {
+		title = deriveTitle(doc.Segments[0])
+	}
+	lang := query.Get("lang")
+	if lang == "" {
+		lang = textimport.DetectLanguage(strings.Join(doc.Segments, "\n"))
+	}
+	if lang == "" {
+		httpError(w, "Could not detect the language, pass it as lang", http.StatusBadRequest)
+		return
+	}
+
+	story := models.Story{ID: primitive.NewObjectID(), Title: title, Language: lang, Segments: make([]models.Segment, 0, len(doc.Segments))}
+	for _, text := range doc.Segments {
+		segment := models.Segment{ID: primitive.NewObjectID()}
+		if text != "" {
+			segment.Script = &models.Script{Text: text}
+		}
+		story.Segments = append(story.Segments, segment)
+	}
+	request := api.StoryRequestFromModel(&story)
+	if err := validation.Story(&request); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	for i := range story.Segments {
+		segment := &story.Segments[i]
+		if image, ok := doc.Images[i]; ok {
+			key := newImageKey(story.ID, segment.ID, imageTypes[image.ContentType])
+			if err := storeImportedMedia(r.Context(), key, image); err != nil {
+				writeError(w, err)
+				return
+			}
+			segment.Image = &models.Image{Url: mediaURL(key), Key: key}
+		}
+		if audio, ok := doc.Audio[i]; ok {
+			key := newAudioKey(story.ID, segment.ID)
+			if err := storeImportedMedia(r.Context(), key, audio); err != nil {
+				writeError(w, err)
+				return
+			}
+			segment.Audio = &models.Audio{Url: mediaURL(key), Key: key}
+		}
+	}
+	story.OwnerID, _ = currentUser(r.Context())
+
+	err := assessSpam(r.Context(), clientKey, &story)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	err = insertStory(r.Context(), &story)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	for _, segment := range story.Segments {
+		if segment.Audio != nil {
+			scheduleAudioProcessing(r.Context(), story.ID, segment.ID, segment.Audio.Key)
+		}
+	}
+
+	writeResponse(w, r, http.StatusCreated, api.StoryFromModel(&story))
+}
+
+func storeImportedMedia(ctx context.Context, key string, media textimport.Media) error {
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(writeBucket()),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(media.Data),
+		ContentType: aws.String(media.ContentType),
+	}
+	encryptPut(input)
+	_, err := s3Client.PutObjectWithContext(ctx, input)
+	return err
+}
+
+// deriveTitle cuts text at a word boundary to make a title.
+func deriveTitle(text string) string {
+	runes := []rune(text)
+	if len(runes) <= maxDerivedTitle {
+		return text
+	}
+	cut := string(runes[:maxDerivedTitle])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return cut + "…"
+}
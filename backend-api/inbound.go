@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"rosetta/models"
+	"rosetta/textimport"
+)
+
+const (
+	maxInboundEmailSize = 30 << 20
+	maxAudioAttachment  = 20 << 20
+)
+
+// Attachment types kept as segment audio. Voice memo apps mostly send m4a.
+var audioTypes = map[string]bool{
+	"audio/mpeg":  true,
+	"audio/mp4":   true,
+	"audio/x-m4a": true,
+	"audio/aac":   true,
+	"audio/ogg":   true,
+	"audio/wav":   true,
+	"audio/x-wav": true,
+	"audio/webm":  true,
+}
+
+// receiveInboundEmail is the webhook for inbound email parsing in the
+// SendGrid Inbound Parse format: multipart form fields from, subject, text,
+// attachments, dkim, SPF and envelope, with files attachment1 to
+// attachmentN. The provider is authenticated by the INBOUND_EMAIL_TOKEN
+// query parameter.
+//
+// The email becomes a draft story owned by the user with the sender's
+// address: subject as title, body paragraphs as segments and audio
+// attachments as segment audio, in order. The sender's address only counts
+// when the provider vouches for its domain, see senderVerified. Mail from
+// unknown or unverified senders is accepted and dropped so the provider
+// doesn't retry it.
+func receiveInboundEmail(w http.ResponseWriter, r *http.Request) {
+	token := inboundEmailToken
+	if token == "" || subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("token")), []byte(token)) != 1 {
+		httpError(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxInboundEmailSize)
+	if err := r.ParseMultipartForm(maxAudioAttachment); err != nil {
+		httpError(w, "Invalid email payload", http.StatusBadRequest)
+		return
+	}
+
+	from, err := mail.ParseAddress(r.FormValue("from"))
+	if err != nil {
+		httpError(w, "Invalid sender", http.StatusBadRequest)
+		return
+	}
+
+	if !senderVerified(r, from.Address) {
+		log.Printf("dropping inbound email claiming to be from %s: sender not verified", from.Address)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var user models.User
+	err = usersCollection().FindOne(r.Context(), bson.M{"email": strings.ToLower(from.Address)}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	doc := textimport.Parse(r.FormValue("text"), textimport.FormatPlain)
+	doc.Title = strings.TrimSpace(r.FormValue("subject"))
+	doc.Audio = map[int]textimport.Media{}
+	count, _ := strconv.Atoi(r.FormValue("attachments"))
+	next := 0
+	for i := 1; i <= count; i++ {
+		file, header, err := r.FormFile(fmt.Sprintf("attachment%d", i))
+		if err != nil {
+			continue
+		}
+		contentType, _, _ := mime.ParseMediaType(header.Header.Get("Content-Type"))
+		if !audioTypes[contentType] || header.Size > maxAudioAttachment {
+			file.Close()
+			continue
+		}
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Recordings beyond the text get segments of their own
+		if next >= len(doc.Segments) {
+			doc.Segments = append(doc.Segments, "")
+		}
+		doc.Audio[next] = textimport.Media{Data: data, ContentType: contentType}
+		next++
+	}
+
+	ctx := context.WithValue(r.Context(), userKey{}, user.ID)
+	createImportedStory(w, r.WithContext(ctx), doc, "email:"+user.ID.Hex())
+}
+
+// senderVerified reports whether the provider checked that mail claiming to
+// be from address came from its domain: a passing DKIM signature of that
+// domain, or an SPF pass for an envelope sender of that domain. From
+// headers are easily forged, and they pick whose account the story goes
+// to.
+func senderVerified(r *http.Request, address string) bool {
+	_, domain, ok := strings.Cut(address, "@")
+	if !ok {
+		return false
+	}
+	domain = strings.ToLower(domain)
+
+	// dkim is like {@example.com : pass, @other.org : fail}
+	results := strings.Trim(strings.TrimSpace(r.FormValue("dkim")), "{}")
+	for _, result := range strings.Split(results, ",") {
+		signer, verdict, ok := strings.Cut(result, ":")
+		if ok && strings.EqualFold(strings.TrimPrefix(strings.TrimSpace(signer), "@"), domain) &&
+			strings.EqualFold(strings.TrimSpace(verdict), "pass") {
+			return true
+		}
+	}
+
+	if !strings.EqualFold(strings.TrimSpace(r.FormValue("SPF")), "pass") {
+		return false
+	}
+	var envelope struct {
+		From string `json:"from"`
+	}
+	if err := json.Unmarshal([]byte(r.FormValue("envelope")), &envelope); err != nil {
+		return false
+	}
+	_, envelopeDomain, ok := strings.Cut(envelope.From, "@")
+	return ok && strings.EqualFold(envelopeDomain, domain)
+}
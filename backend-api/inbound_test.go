@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSenderVerified(t *testing.T) {
+	tests := []struct {
+		name   string
+		form   url.Values
+		sender string
+		want   bool
+	}{
+		{"dkim pass", url.Values{"dkim": {"{@example.com : pass}"}}, "ann@example.com", true},
+		{"dkim pass among others", url.Values{"dkim": {"{@mailer.net : pass, @Example.com : pass}"}}, "ann@example.com", true},
+		{"dkim fail", url.Values{"dkim": {"{@example.com : fail}"}}, "ann@example.com", false},
+		{"dkim of another domain", url.Values{"dkim": {"{@attacker.net : pass}"}}, "ann@example.com", false},
+		{"spf pass with aligned envelope", url.Values{"SPF": {"pass"}, "envelope": {`{"from":"bounce@example.com"}`}}, "ann@example.com", true},
+		{"spf pass for another envelope", url.Values{"SPF": {"pass"}, "envelope": {`{"from":"x@attacker.net"}`}}, "ann@example.com", false},
+		{"spf softfail", url.Values{"SPF": {"softfail"}, "envelope": {`{"from":"ann@example.com"}`}}, "ann@example.com", false},
+		{"nothing", url.Values{}, "ann@example.com", false},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("POST", "/inbound/email", strings.NewReader(test.form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if got := senderVerified(r, test.sender); got != test.want {
+			t.Errorf("%s: senderVerified = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
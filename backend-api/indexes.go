@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"rosetta/repository"
+)
+
+const revisionRetentionSeconds = 30 * 24 * 60 * 60
+
+const jobRetentionSeconds = 7 * 24 * 60 * 60
+
+const playRetentionSeconds = 400 * 24 * 60 * 60
+
+func ensureIndexes(ctx context.Context) error {
+	stories := storiesCollection()
+	_, err := stories.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "updated_at", Value: 1}}},
+		{Keys: bson.D{{Key: "content_fingerprint", Value: 1}}},
+		{Keys: bson.D{{Key: "moderation.status", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "published_at", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "metadata.$**", Value: 1}}},
+		repository.SearchIndex(),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = moderationActionsCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "created_at", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Revisions only serve as merge bases, so old ones are let go
+	_, err = storyRevisionsCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "story_id", Value: 1}, {Key: "version", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "created_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(revisionRetentionSeconds)},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = usersCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "email", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = apiKeysCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = draftBackupsCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "story_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Plays are aggregated per day, so each count is a single upsert target
+	_, err = storyPlaysCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "story_id", Value: 1}, {Key: "day", Value: 1}, {Key: "source", Value: 1}, {Key: "origin", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "day", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(playRetentionSeconds)},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Finished jobs are only kept for their callers to collect the result
+	_, err = jobsCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "kind", Value: 1}, {Key: "status", Value: 1}}},
+		{Keys: bson.D{{Key: "finished_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(jobRetentionSeconds)},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = jobQueueCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}},
+		{Keys: bson.D{{Key: "last_error", Value: 1}}, Options: options.Index().SetSparse(true)},
+	})
+	if err != nil {
+		return err
+	}
+
+	// One override per day and language, and one pick per content filter
+	_, err = featuredStoriesCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "date", Value: 1}, {Key: "language", Value: 1}, {Key: "picked", Value: 1}, {Key: "filter", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	deleted := deletedStoriesCollection()
+	_, err = deleted.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "owner_id", Value: 1}, {Key: "deleted_at", Value: 1}},
+	})
+	return err
+}
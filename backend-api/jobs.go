@@ -0,0 +1,443 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"rosetta/domain"
+	"rosetta/models"
+)
+
+const (
+	// A running job that has not been heard from for this long is assumed
+	// to have died with its instance
+	jobStaleAfter        = time.Minute
+	jobHeartbeatInterval = 20 * time.Second
+	jobFlushInterval     = 2 * time.Second
+	// Retry-After suggested to clients polling a running job
+	jobPollSeconds = 2
+
+	callbackAttempts = 3
+	callbackTimeout  = 10 * time.Second
+	// Bounds how long a finishing job may keep retrying its callback
+	callbackDeadline = 30 * time.Second
+)
+
+// callbackClient only connects to public addresses, wherever callback URLs
+// or their redirects point, so users can't have the server call into its
+// own network. It dials directly rather than through any proxy, which
+// would do the connecting for it.
+var callbackClient = &http.Client{
+	Timeout: callbackTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: callbackTimeout,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				ip, err := netip.ParseAddr(host)
+				if err != nil || !isPublicAddr(ip) {
+					return fmt.Errorf("callback to non-public address %s refused", host)
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: callbackTimeout,
+	},
+}
+
+// sharedAddressSpace is carrier-grade NAT, private to the provider.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// isPublicAddr reports whether ip is reachable on the internet rather than
+// loopback, link-local, private or otherwise special.
+func isPublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip)
+}
+
+// jobFunc does the work of a job. It may report progress through run and
+// returns the result, which is stored as JSON.
+type jobFunc func(ctx context.Context, run *jobRun) (interface{}, error)
+
+// startJob is how handlers hand off work that may outlast an HTTP timeout:
+// it records a job of kind, runs fn in the background and answers 202 with
+// the job, its URL in Location and a Retry-After for polling. With a
+// callback_url query parameter the finished job is also POSTed there. Work
+// that must survive a restart goes through queueJob instead.
+func startJob(w http.ResponseWriter, r *http.Request, kind string, fn jobFunc) {
+	job, ok := newJob(w, r, kind, models.JobRunning)
+	if !ok {
+		return
+	}
+
+	// The job outlives the request but not a drain or shutdown
+	run := &jobRun{job: job}
+	drain.startWorker(func(ctx context.Context) {
+		run.run(ctx, fn)
+	})
+
+	writeJobAccepted(w, r, job)
+}
+
+// queueJob is startJob for work run from the durable queue, which retries
+// it on another instance if this one goes away. The job is recorded as
+// queued and payload is enqueued as a job of kind, with the job's ID added
+// as job_id; its handler in queueHandlers comes from trackedHandler.
+func queueJob(w http.ResponseWriter, r *http.Request, kind string, payload interface{}) {
+	doc, err := toDocument(payload)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	job, ok := newJob(w, r, kind, models.JobQueued)
+	if !ok {
+		return
+	}
+	doc["job_id"] = job.ID
+	if err = enqueueJob(r.Context(), kind, doc, 0); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJobAccepted(w, r, job)
+}
+
+// newJob records a job of kind in status, with the request's callback_url.
+// ok is false once a bad callback URL or a failure has been reported.
+func newJob(w http.ResponseWriter, r *http.Request, kind, status string) (job models.Job, ok bool) {
+	callback := r.URL.Query().Get("callback_url")
+	if callback != "" {
+		u, err := url.Parse(callback)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			httpError(w, "callback_url must be an absolute http or https URL", http.StatusBadRequest)
+			return job, false
+		}
+		// Names are checked when the callback connects, as they resolve then
+		if ip, err := netip.ParseAddr(strings.Trim(u.Hostname(), "[]")); err == nil && !isPublicAddr(ip) {
+			httpError(w, "callback_url must point to a public address", http.StatusBadRequest)
+			return job, false
+		}
+	}
+
+	now := time.Now()
+	owner, _ := currentUser(r.Context())
+	job = models.Job{
+		ID:          primitive.NewObjectID(),
+		Kind:        kind,
+		Status:      status,
+		CallbackURL: callback,
+		OwnerID:     owner,
+		Admin:       isAdmin(r),
+		StartedAt:   now,
+		UpdatedAt:   now,
+	}
+	if _, err := jobsCollection().InsertOne(r.Context(), job); err != nil {
+		writeError(w, err)
+		return job, false
+	}
+	return job, true
+}
+
+func writeJobAccepted(w http.ResponseWriter, r *http.Request, job models.Job) {
+	w.Header().Set("Location", jobURL(job.ID))
+	w.Header().Set("Retry-After", strconv.Itoa(jobPollSeconds))
+	writeResponse(w, r, http.StatusAccepted, job)
+}
+
+// trackedJobFunc does the work of a job started with queueJob, given the
+// payload it was queued with.
+type trackedJobFunc func(ctx context.Context, run *jobRun, payload bson.M) (interface{}, error)
+
+// trackedPayload is the part of a queueJob payload naming the job clients
+// poll.
+type trackedPayload struct {
+	JobID primitive.ObjectID `bson:"job_id"`
+}
+
+// trackedHandler runs fn from the queue, keeping the job clients poll up
+// to date: running while fn runs, queued again while a failure is retried
+// and finished once fn succeeds, fails on an error about the request, such
+// as a conflict, or has run out of attempts.
+func trackedHandler(fn trackedJobFunc, maxAttempts int) queueHandler {
+	return queueHandler{
+		maxAttempts: maxAttempts,
+		run: func(ctx context.Context, payload bson.M) error {
+			run, err := loadTrackedJob(ctx, payload)
+			if err != nil || run == nil {
+				return err
+			}
+			run.job.Status, run.job.Error, run.job.FinishedAt = models.JobRunning, "", nil
+			run.flush(ctx, true)
+
+			heartbeatCtx, stopHeartbeat := context.WithCancel(ctx)
+			go run.heartbeat(heartbeatCtx)
+			result, err := fn(ctx, run, payload)
+			stopHeartbeat()
+
+			if ctx.Err() != nil || (err != nil && statusForError(err) == http.StatusInternalServerError) {
+				// The final status must land, whatever became of ctx
+				queuedCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				run.job.Status = models.JobQueued
+				run.flush(queuedCtx, true)
+				if err == nil {
+					err = ctx.Err()
+				}
+				return err
+			}
+			run.finish(result, err)
+			return nil
+		},
+		giveUp: func(ctx context.Context, payload bson.M, err error) {
+			run, loadErr := loadTrackedJob(ctx, payload)
+			if loadErr != nil {
+				log.Printf("failed to load job given up on: %v", loadErr)
+			}
+			if run != nil {
+				run.finish(nil, err)
+			}
+		},
+	}
+}
+
+// loadTrackedJob returns the job a queued payload belongs to, or nil if it
+// has completed already, e.g. when its instance died right after. Failed
+// jobs run again when an operator retries them.
+func loadTrackedJob(ctx context.Context, payload bson.M) (*jobRun, error) {
+	var tracked trackedPayload
+	if err := decodePayload(payload, &tracked); err != nil {
+		return nil, err
+	}
+	var job models.Job
+	err := jobsCollection().FindOne(ctx, bson.M{"_id": tracked.JobID}).Decode(&job)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status == models.JobCompleted {
+		return nil, nil
+	}
+	return &jobRun{job: job}, nil
+}
+
+func jobURL(id primitive.ObjectID) string {
+	return "/jobs/" + id.Hex()
+}
+
+// jobRunning reports whether a job of kind is running anywhere, for kinds
+// that must not overlap.
+func jobRunning(ctx context.Context, kind string) (bool, error) {
+	running, err := jobsCollection().CountDocuments(ctx, bson.M{
+		"kind":       kind,
+		"status":     models.JobRunning,
+		"updated_at": bson.M{"$gt": time.Now().Add(-jobStaleAfter)},
+	})
+	return running > 0, err
+}
+
+// getJob answers a poll of a job by the user or admin who started it.
+func getJob(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+
+	var job models.Job
+	err = jobsCollection().FindOne(r.Context(), bson.M{"_id": id}).Decode(&job)
+	if err != nil && err != mongo.ErrNoDocuments {
+		writeError(w, err)
+		return
+	}
+	// Other people's jobs are as good as missing
+	if err == mongo.ErrNoDocuments || !canPollJob(r, &job) {
+		writeError(w, domain.New(domain.ErrNotFound, "Job not found"))
+		return
+	}
+	if job.Status == models.JobRunning && time.Since(job.UpdatedAt) > jobStaleAfter {
+		job.Status = models.JobInterrupted
+	}
+	if job.Status == models.JobRunning {
+		w.Header().Set("Retry-After", strconv.Itoa(jobPollSeconds))
+	}
+
+	writeResponse(w, r, http.StatusOK, job)
+}
+
+// canPollJob reports whether r may see job: admins see every job, users
+// the jobs they started.
+func canPollJob(r *http.Request, job *models.Job) bool {
+	if isAdmin(r) {
+		return true
+	}
+	userID, ok := currentUser(r.Context())
+	return ok && !job.OwnerID.IsZero() && job.OwnerID == userID
+}
+
+// jobRun is the state of a job on the instance running it.
+type jobRun struct {
+	job       models.Job
+	progress  interface{}
+	lastFlush time.Time
+}
+
+// report sets the job's progress, which is saved at most every
+// jobFlushInterval. Values it points into may keep changing; each save
+// takes a snapshot.
+func (j *jobRun) report(ctx context.Context, progress interface{}) {
+	j.progress = progress
+	j.flush(ctx, false)
+}
+
+func (j *jobRun) run(ctx context.Context, fn jobFunc) {
+	heartbeatCtx, stopHeartbeat := context.WithCancel(ctx)
+	go j.heartbeat(heartbeatCtx)
+
+	result, err := fn(ctx, j)
+	stopHeartbeat()
+
+	if ctx.Err() != nil {
+		j.job.Status = models.JobInterrupted
+	}
+	j.finish(result, err)
+}
+
+// finish records the outcome of the job, unless it was interrupted, saves
+// it and notifies the callback URL.
+func (j *jobRun) finish(result interface{}, err error) {
+	now := time.Now()
+	j.job.FinishedAt = &now
+	switch {
+	case j.job.Status == models.JobInterrupted:
+	case err != nil:
+		j.job.Status = models.JobFailed
+		j.job.Error = err.Error()
+	default:
+		j.job.Status = models.JobCompleted
+		if j.job.Result, err = json.Marshal(result); err != nil {
+			j.job.Status = models.JobFailed
+			j.job.Error = err.Error()
+		}
+	}
+	if err != nil {
+		log.Printf("%s job %s: %v", j.job.Kind, j.job.ID.Hex(), err)
+	}
+
+	// The job context may be cancelled, but the final status must land
+	finishCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	j.flush(finishCtx, true)
+	if j.job.CallbackURL != "" {
+		callbackCtx, cancel := context.WithTimeout(context.Background(), callbackDeadline)
+		defer cancel()
+		notifyJobCallback(callbackCtx, &j.job)
+	}
+}
+
+// heartbeat keeps a job that reports no progress from looking stale.
+func (j *jobRun) heartbeat(ctx context.Context) {
+	ticker := time.NewTicker(jobHeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := jobsCollection().UpdateByID(ctx, j.job.ID, bson.M{"$set": bson.M{"updated_at": time.Now()}})
+			if err != nil && ctx.Err() == nil {
+				log.Printf("%s job %s: failed to save heartbeat: %v", j.job.Kind, j.job.ID.Hex(), err)
+			}
+		}
+	}
+}
+
+// flush saves progress at most every jobFlushInterval, unless forced.
+func (j *jobRun) flush(ctx context.Context, force bool) {
+	now := time.Now()
+	if !force && now.Sub(j.lastFlush) < jobFlushInterval {
+		return
+	}
+	j.lastFlush = now
+	j.job.UpdatedAt = now
+
+	if j.progress != nil {
+		progress, err := json.Marshal(j.progress)
+		if err != nil {
+			log.Printf("%s job %s: failed to encode progress: %v", j.job.Kind, j.job.ID.Hex(), err)
+		}
+		j.job.Progress = progress
+	}
+
+	_, err := jobsCollection().UpdateByID(ctx, j.job.ID, bson.M{"$set": bson.M{
+		"status":      j.job.Status,
+		"progress":    j.job.Progress,
+		"result":      j.job.Result,
+		"error":       j.job.Error,
+		"updated_at":  j.job.UpdatedAt,
+		"finished_at": j.job.FinishedAt,
+	}})
+	if err != nil {
+		log.Printf("%s job %s: failed to save progress: %v", j.job.Kind, j.job.ID.Hex(), err)
+	}
+}
+
+// notifyJobCallback POSTs the finished job to its callback URL, retrying a
+// few times. Clients that miss it can still poll.
+func notifyJobCallback(ctx context.Context, job *models.Job) {
+	body, err := json.Marshal(job)
+	if err != nil {
+		log.Printf("%s job %s: failed to encode callback: %v", job.Kind, job.ID.Hex(), err)
+		return
+	}
+
+	for attempt := 1; attempt <= callbackAttempts; attempt++ {
+		err = postJobCallback(ctx, job.CallbackURL, body)
+		if err == nil {
+			return
+		}
+		if attempt == callbackAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			attempt = callbackAttempts
+		case <-time.After(time.Duration(attempt) * time.Second):
+		}
+	}
+	log.Printf("%s job %s: callback failed: %v", job.Kind, job.ID.Hex(), err)
+}
+
+func postJobCallback(ctx context.Context, callbackURL string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := callbackClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("callback answered %s", resp.Status)
+	}
+	return nil
+}
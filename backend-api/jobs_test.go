@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/models"
+)
+
+func TestIsPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr   string
+		public bool
+	}{
+		{"8.8.8.8", true},
+		{"2606:4700::1111", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.0.0.1", false},
+		{"172.16.5.4", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::ffff:127.0.0.1", false},
+		{"224.0.0.1", false},
+	}
+	for _, test := range tests {
+		if got := isPublicAddr(netip.MustParseAddr(test.addr)); got != test.public {
+			t.Errorf("isPublicAddr(%s) = %v, want %v", test.addr, got, test.public)
+		}
+	}
+}
+
+func TestCallbackRefusesLoopback(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	if err := postJobCallback(context.Background(), server.URL, []byte("{}")); err == nil {
+		t.Error("callback to a loopback address succeeded")
+	}
+	if called {
+		t.Error("callback reached a loopback address")
+	}
+}
+
+func TestCanPollJob(t *testing.T) {
+	savedToken := adminToken
+	adminToken = "secret"
+	t.Cleanup(func() { adminToken = savedToken })
+
+	owner := primitive.NewObjectID()
+	userJob := models.Job{OwnerID: owner}
+	adminJob := models.Job{Admin: true}
+	tests := []struct {
+		name  string
+		user  primitive.ObjectID
+		token string
+		job   *models.Job
+		want  bool
+	}{
+		{"owner", owner, "", &userJob, true},
+		{"other user", primitive.NewObjectID(), "", &userJob, false},
+		{"anonymous", primitive.NilObjectID, "", &userJob, false},
+		{"admin job by user", owner, "", &adminJob, false},
+		{"admin job by anonymous", primitive.NilObjectID, "", &adminJob, false},
+		{"admin job by admin", primitive.NilObjectID, "secret", &adminJob, true},
+		{"wrong token", primitive.NilObjectID, "guess", &adminJob, false},
+		{"user job by admin", primitive.NilObjectID, "secret", &userJob, true},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/jobs/x", nil)
+		if test.token != "" {
+			r.Header.Set(adminTokenHeader, test.token)
+		}
+		if !test.user.IsZero() {
+			r = r.WithContext(context.WithValue(r.Context(), userKey{}, test.user))
+		}
+		if got := canPollJob(r, test.job); got != test.want {
+			t.Errorf("%s: canPollJob = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
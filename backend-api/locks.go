@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/api"
+	"rosetta/domain"
+	"rosetta/models"
+)
+
+// Editors renew their lock with a heartbeat well within this window.
+const lockTTL = 2 * time.Minute
+
+// editorETag extends the story ETag with the live lock, which changes
+// without a version bump.
+func editorETag(story *models.Story) string {
+	etag := storyETag(story)
+	if story.Lock == nil || !story.Lock.ExpiresAt.After(time.Now()) {
+		return etag
+	}
+	return fmt.Sprintf(`"v%d-l%d"`, story.Version, story.Lock.ExpiresAt.UnixMilli())
+}
+
+type lockRequest struct {
+	Holder string `json:"holder"`
+	Force  bool   `json:"force"`
+}
+
+// lockStory acquires or renews the advisory edit lock. A lock held by
+// someone else can only be taken with force, which is written to the audit
+// log.
+func lockStory(w http.ResponseWriter, r *http.Request) {
+	storyID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, "Invalid story ID", http.StatusBadRequest)
+		return
+	}
+
+	var request lockRequest
+	err = decodeRequest(r, &request)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	request.Holder = strings.TrimSpace(request.Holder)
+	if request.Holder == "" {
+		httpError(w, "Missing holder", http.StatusBadRequest)
+		return
+	}
+
+	if _, err = findOwnStory(r.Context(), storyID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	err = acquireLock(r.Context(), storyID, request.Holder, request.Force)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	story, err := findStory(r.Context(), storyID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeResponse(w, r, http.StatusOK, api.StoryFromModel(&story))
+}
+
+// acquireLock takes the lock for holder, retrying when the lock is
+// released, lapses or changes hands while it is being taken.
+func acquireLock(ctx context.Context, storyID primitive.ObjectID, holder string, force bool) error {
+	for attempt := 0; attempt < maxSaveAttempts; attempt++ {
+		acquired, err := tryAcquireLock(ctx, storyID, holder, force)
+		if err != nil || acquired {
+			return err
+		}
+	}
+	return errStoryChanged
+}
+
+// tryAcquireLock is one attempt of acquireLock. It returns false without
+// an error if the lock changed under it.
+func tryAcquireLock(ctx context.Context, storyID primitive.ObjectID, holder string, force bool) (bool, error) {
+	now := time.Now()
+	lock := models.EditLock{Holder: holder, AcquiredAt: now, ExpiresAt: now.Add(lockTTL)}
+
+	// Renewing keeps the original acquisition time
+	renewed, err := storiesCollection().UpdateOne(ctx,
+		bson.M{"_id": storyID, "lock.holder": holder, "lock.expires_at": bson.M{"$gt": now}},
+		bson.M{"$set": bson.M{"lock.expires_at": lock.ExpiresAt}},
+	)
+	if err != nil {
+		return false, err
+	}
+	if renewed.MatchedCount == 1 {
+		return true, nil
+	}
+
+	free := bson.M{"_id": storyID, "$or": bson.A{
+		bson.M{"lock": nil},
+		bson.M{"lock.expires_at": bson.M{"$lte": now}},
+		bson.M{"lock.holder": holder},
+	}}
+	res, err := storiesCollection().UpdateOne(ctx, free, bson.M{"$set": bson.M{"lock": lock}})
+	if err != nil {
+		return false, err
+	}
+	if res.MatchedCount == 1 {
+		return true, nil
+	}
+
+	story, err := findStory(ctx, storyID)
+	if err != nil {
+		return false, err
+	}
+	// Released or lapsed since the update above
+	previous := story.Lock
+	if previous == nil || !previous.ExpiresAt.After(now) {
+		return false, nil
+	}
+	if !force {
+		return false, domain.New(domain.ErrConflict, "Story is being edited by "+previous.Holder)
+	}
+
+	// Only take over the lock that was seen, so the audit names its holder
+	taken, err := storiesCollection().UpdateOne(ctx,
+		bson.M{"_id": storyID, "lock.holder": previous.Holder, "lock.expires_at": previous.ExpiresAt},
+		bson.M{"$set": bson.M{"lock": lock}},
+	)
+	if err != nil || taken.MatchedCount == 0 {
+		return false, err
+	}
+	userID, _ := currentUser(ctx)
+	_, err = auditLogCollection().InsertOne(ctx, models.AuditEntry{
+		ID:        primitive.NewObjectID(),
+		StoryID:   storyID,
+		Action:    models.AuditLockTakeover,
+		Actor:     userID.Hex(),
+		Holder:    holder,
+		Previous:  previous.Holder,
+		CreatedAt: now,
+	})
+	return err == nil, err
+}
+
+// unlockStory releases the lock if the caller still holds it. Releasing a
+// lock that lapsed or moved on is not an error.
+func unlockStory(w http.ResponseWriter, r *http.Request) {
+	storyID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, "Invalid story ID", http.StatusBadRequest)
+		return
+	}
+	holder := r.URL.Query().Get("holder")
+	if holder == "" {
+		httpError(w, "Missing holder", http.StatusBadRequest)
+		return
+	}
+
+	if _, err = findOwnStory(r.Context(), storyID); err != nil {
+		writeError(w, err)
+		return
+	}
+	_, err = storiesCollection().UpdateOne(r.Context(),
+		bson.M{"_id": storyID, "lock.holder": holder},
+		bson.M{"$unset": bson.M{"lock": ""}},
+	)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -2,63 +2,105 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/polly"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sts"
 	"github.com/gorilla/mux"
+	"github.com/oklog/ulid/v2"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc"
 
+	"rosetta/api"
+	"rosetta/config"
+	"rosetta/domain"
 	"rosetta/models"
+	"rosetta/redact"
+	"rosetta/repository"
+	"rosetta/transcode"
+	"rosetta/tts"
+	"rosetta/validation"
 )
 
+// maxListLimit caps a page of GET /stories
+const maxListLimit = 100
+
 var client *mongo.Client
 var s3Client *s3.S3
+var stsClient *sts.STS
 var s3Bucket string
 var s3Endpoint string
 var s3PublicHost string
 
+// storyRepo stores stories for the handlers. Some story access still goes
+// to the collection directly, as the repository has no method for it:
+// updates of single fields that Update leaves alone (edit locks,
+// moderation, segment changes, content hashes), queries by publication or
+// with projections (feeds, the daily story, automation, the moderation
+// queue), and maintenance that scans the whole collection (rebuild,
+// consistency checks, the media migration and indexes).
+var storyRepo repository.StoryRepository = repository.NewMongo(storiesCollection)
+
 func main() {
-	// Load environment variables
-	databaseURL := os.Getenv("DATABASE_URL")
-	awsRegion := os.Getenv("AWS_REGION")
-	awsAccessKeyID := os.Getenv("AWS_ACCESS_KEY_ID")
-	awsSecretAccessKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
-	s3Bucket = os.Getenv("S3_BUCKET")
-	s3Endpoint = os.Getenv("S3_ENDPOINT")
-	s3PublicHost = os.Getenv("S3_PUBLIC_URL")
+	log.SetOutput(redact.Writer(os.Stderr))
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	applyConfig(cfg)
+	if err = loadSchemas(); err != nil {
+		log.Fatal(err)
+	}
+	shutdownTracing, err := setupTracing(context.Background(), cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Cancelled on SIGINT or SIGTERM to start a graceful shutdown
+	appCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// Connect to MongoDB
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ConnectTimeout)
 	defer cancel()
 
-	var err error
-	client, err = mongo.Connect(ctx, options.Client().ApplyURI(databaseURL))
+	monitor := queryStats.Monitor()
+	addMongoFaults(monitor)
+	addMongoMetrics(monitor)
+	addMongoTracing(monitor)
+	clientOptions := options.Client().ApplyURI(cfg.DatabaseURL).SetMonitor(monitor)
+	client, err = mongo.Connect(ctx, clientOptions)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	defer func() {
-		if err = client.Disconnect(ctx); err != nil {
-			log.Fatal(err)
-		}
-	}()
+	if err = ensureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
 
 	// Initialize AWS session
 	sess, err := session.NewSession(&aws.Config{
-		Region:           aws.String(awsRegion),
-		Credentials:      credentials.NewStaticCredentials(awsAccessKeyID, awsSecretAccessKey, ""),
+		Region:           aws.String(cfg.AWSRegion),
+		Credentials:      credentials.NewStaticCredentials(cfg.AWSAccessKeyID, cfg.AWSSecretAccessKey, ""),
 		Endpoint:         aws.String(s3Endpoint),
 		S3ForcePathStyle: aws.Bool(true), // Required for LocalStack
 	})
@@ -68,56 +110,346 @@ func main() {
 
 	// Initialize S3 client
 	s3Client = s3.New(sess)
+	addS3Faults(&s3Client.Handlers)
+	addS3Metrics(&s3Client.Handlers)
+	addS3Tracing(&s3Client.Handlers)
+	stsClient = sts.New(sess)
+	if cfg.TTSProvider == "polly" {
+		speechSynthesizer = &tts.Polly{
+			Client: polly.New(sess, &aws.Config{Endpoint: aws.String(cfg.TTSEndpoint)}),
+			Engine: cfg.TTSEngine,
+			Voice:  cfg.TTSVoice,
+		}
+	}
+	if cfg.Transcoder == "ffmpeg" {
+		audioTranscoder = transcode.FFmpeg{Path: cfg.FFmpegPath, Bitrate: cfg.TranscodeBitrate}
+	}
 
-	// Create bucket if it doesn't exist
-	_, err = s3Client.CreateBucket(&s3.CreateBucketInput{
-		Bucket: aws.String(s3Bucket),
-	})
-	if err != nil {
-		log.Fatal(err)
+	// Background workers stop on shutdown or when the instance is drained
+	drain.start(appCtx)
+
+	// Optional: confirm uploads from the bucket's event notifications too
+	if cfg.S3EventsQueueURL != "" {
+		queue := sqs.New(sess)
+		drain.startWorker(func(ctx context.Context) {
+			consumeUploadEvents(ctx, queue, cfg.S3EventsQueueURL)
+		})
+	}
+
+	// Run queued background jobs, such as deleting the media of deleted
+	// stories and segments
+	drain.startWorker(moveMediaCleanups)
+	for i := 0; i < cfg.QueueWorkers; i++ {
+		drain.startWorker(runQueueWorker)
+	}
+	drain.startWorker(runPlayFlush)
+
+	// Create buckets if they don't exist
+	for _, bucket := range mediaBuckets() {
+		_, err = s3Client.CreateBucket(&s3.CreateBucketInput{
+			Bucket: aws.String(bucket),
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Move media over to the new bucket while a migration is configured
+	if s3MigrationBucket != "" {
+		drain.startWorker(runMediaMigration)
 	}
 
 	// Create a new router
 	r := mux.NewRouter()
+	r.Use(labelRoute)
+	r.Use(drain.track)
+	r.Use(authenticate)
+	r.Use(injectFaults)
+	r.Use(warnRemovedFields)
+	r.NotFoundHandler = http.HandlerFunc(notFoundHandler)
+	r.MethodNotAllowedHandler = http.HandlerFunc(methodNotAllowedHandler)
 
 	// Define routes
-	r.HandleFunc("/stories", createStory).Methods("POST")
-	r.HandleFunc("/stories/{id}", deleteStory).Methods("DELETE")
-	r.HandleFunc("/stories/{id}", updateStory).Methods("PUT")
-	r.HandleFunc("/stories/{storyId}/segments/{segmentId}/audio", generateAudioUploadURL).Methods("POST")
+	limits := newRouteLimits(cfg)
+	r.HandleFunc("/auth/register", throttle(limits.auth, register)).Methods("POST")
+	r.HandleFunc("/auth/login", throttle(limits.auth, login)).Methods("POST")
+	r.HandleFunc("/auth/api-keys", requireUser(createAPIKey)).Methods("POST")
+	r.HandleFunc("/auth/api-keys", requireUser(listAPIKeys)).Methods("GET")
+	r.HandleFunc("/auth/api-keys/{id}", requireUser(deleteAPIKey)).Methods("DELETE")
+	r.HandleFunc("/stories", requireUser(throttle(limits.writes, createStory))).Methods("POST")
+	r.HandleFunc("/stories", listStories).Methods("GET")
+	r.HandleFunc("/stories/search", searchStories).Methods("GET")
+	r.HandleFunc("/stories/import/text", requireUser(throttle(limits.writes, importTextStory))).Methods("POST")
+	r.HandleFunc("/stories/import/docx", requireUser(throttle(limits.writes, importDocxStory))).Methods("POST")
+	r.HandleFunc("/inbound/email", receiveInboundEmail).Methods("POST")
+	r.HandleFunc("/stories/{id}", requireUser(throttle(limits.writes, deleteStory))).Methods("DELETE")
+	r.HandleFunc("/stories/{id}", requireUser(throttle(limits.writes, updateStory))).Methods("PUT")
+	r.HandleFunc("/stories/{id}", requireUser(throttle(limits.writes, patchStory))).Methods("PATCH")
+	r.HandleFunc("/stories/{storyId}/segments/{segmentId}/audio", requireUser(throttle(limits.uploads, generateAudioUploadURL))).Methods("POST")
+	r.HandleFunc("/stories/{storyId}/segments/{segmentId}/audio/complete", requireUser(completeAudioUpload)).Methods("POST")
+	// The original name of audio/complete
+	r.HandleFunc("/stories/{storyId}/segments/{segmentId}/audio/confirm", requireUser(completeAudioUpload)).Methods("POST")
+	r.HandleFunc("/stories/{storyId}/segments/{segmentId}/audio/credentials", requireUser(throttle(limits.uploads, generateAudioUploadCredentials))).Methods("POST")
+	r.HandleFunc("/stories/{storyId}/segments/{segmentId}/audio/generate", requireUser(throttle(limits.uploads, generateSegmentAudio))).Methods("POST")
+	r.HandleFunc("/stories/{storyId}/segments/{segmentId}/audio/trim", requireUser(throttle(limits.uploads, trimSegmentAudio))).Methods("POST")
+	r.HandleFunc("/stories/{storyId}/segments/{segmentId}/image", requireUser(throttle(limits.uploads, generateImageUploadURL))).Methods("POST")
+	r.HandleFunc("/stories/{storyId}/segments/{segmentId}/image/confirm", requireUser(confirmImageUpload)).Methods("POST")
+	r.HandleFunc("/stories/{id}/audio-tracks", requireUser(throttle(limits.uploads, createAudioTrack))).Methods("POST")
+	r.HandleFunc("/stories/{id}/audio-tracks/{trackId}/complete", requireUser(completeAudioTrackUpload)).Methods("POST")
+	r.HandleFunc("/stories/{id}/audio-tracks/{trackId}/split", requireUser(throttle(limits.writes, splitRecording))).Methods("POST")
+	r.HandleFunc("/stories/{storyId}/segments/{segmentId}/translations/{lang}", requireUser(throttle(limits.writes, putTranslation))).Methods("PUT")
+	r.HandleFunc("/stories/{storyId}/segments/{segmentId}/translations/{lang}", requireUser(throttle(limits.writes, deleteTranslation))).Methods("DELETE")
 	r.HandleFunc("/stories/{id}", getStory).Methods("GET")
-	r.HandleFunc("/health", healthCheck).Methods("GET")
+	r.HandleFunc("/stories/{id}/media/report", getMediaReport).Methods("GET")
+	r.HandleFunc("/stories/{id}/stats", requireUser(getStoryStats)).Methods("GET")
+	r.HandleFunc("/stories/{id}/publish", requireUser(throttle(limits.writes, publishStory))).Methods("POST")
+	r.HandleFunc("/stories/{id}/unpublish", requireUser(throttle(limits.writes, unpublishStory))).Methods("POST")
+	r.HandleFunc("/stories/{id}/segments/split", requireUser(throttle(limits.writes, splitIntoSegments))).Methods("POST")
+	r.HandleFunc("/stories/{id}/segments/merge", requireUser(throttle(limits.writes, mergeSegments))).Methods("POST")
+	r.HandleFunc("/stories/{id}/lock", requireUser(lockStory)).Methods("POST")
+	r.HandleFunc("/stories/{id}/lock", requireUser(unlockStory)).Methods("DELETE")
+	r.HandleFunc("/stories/{id}/presence", requireUser(heartbeatPresence)).Methods("POST")
+	r.HandleFunc("/stories/{id}/presence", requireUser(getPresence)).Methods("GET")
+	r.HandleFunc("/stories/{id}/presence", requireUser(leavePresence)).Methods("DELETE")
+	r.HandleFunc("/stories/{id}/backup", requireUser(putDraftBackup)).Methods("PUT")
+	r.HandleFunc("/stories/{id}/backup", requireUser(getDraftBackup)).Methods("GET")
+	r.HandleFunc("/stories/{id}/backup", requireUser(deleteDraftBackup)).Methods("DELETE")
+	r.HandleFunc("/meta/schema-version", getSchemaVersion).Methods("GET")
+	r.HandleFunc("/meta/changelog", getChangelog).Methods("GET")
+	r.HandleFunc("/meta/sdks", listSDKs).Methods("GET")
+	r.HandleFunc("/meta/sdks/{version}/{language}", getSDK).Methods("GET")
+	r.HandleFunc("/feed", getFeed).Methods("GET")
+	r.HandleFunc("/feed/daily", getDailyStory).Methods("GET")
+	r.HandleFunc("/feed/daily", requireAdmin(setDailyStoryOverride)).Methods("PUT")
+	r.HandleFunc("/sync", requireUser(getSyncChanges)).Methods("GET")
+	r.HandleFunc("/sync", requireUser(throttle(limits.writes, applySyncMutations))).Methods("POST")
+	r.HandleFunc("/admin/consistency-check", requireAdmin(runConsistencyCheck)).Methods("POST")
+	r.HandleFunc("/admin/blocklist", requireAdmin(listBlockedWords)).Methods("GET")
+	r.HandleFunc("/admin/blocklist", requireAdmin(addBlockedWord)).Methods("POST")
+	r.HandleFunc("/admin/blocklist/{id}", requireAdmin(deleteBlockedWord)).Methods("DELETE")
+	r.HandleFunc("/admin/moderation/queue", requireAdmin(getModerationQueue)).Methods("GET")
+	r.HandleFunc("/admin/moderation/{id}/clear", requireAdmin(clearModerationFlag)).Methods("POST")
+	r.HandleFunc("/admin/moderation/report", requireAdmin(getModerationReport)).Methods("GET")
+	r.HandleFunc("/stories/{id}/appeals", requireUser(submitAppeal)).Methods("POST")
+	r.HandleFunc("/admin/appeals", requireAdmin(listAppeals)).Methods("GET")
+	r.HandleFunc("/admin/appeals/{id}/decision", requireAdmin(decideAppeal)).Methods("POST")
+	r.HandleFunc("/admin/media/reencrypt", requireAdmin(reencryptMedia)).Methods("POST")
+	r.HandleFunc("/admin/query-insights", requireAdmin(getQueryInsights)).Methods("GET")
+	r.HandleFunc("/admin/query-insights", requireAdmin(resetQueryInsights)).Methods("DELETE")
+	r.HandleFunc("/admin/rebuild", requireAdmin(startRebuild)).Methods("POST")
+	// Jobs used to be polled here, before all of them were under /jobs
+	r.HandleFunc("/admin/rebuild/{id}", requireAdmin(getJob)).Methods("GET")
+	r.HandleFunc("/jobs/{id}", getJob).Methods("GET")
+	r.HandleFunc("/admin/jobs", requireAdmin(listQueuedJobs)).Methods("GET")
+	r.HandleFunc("/admin/jobs/{id}/retry", requireAdmin(retryQueuedJob)).Methods("POST")
+	r.HandleFunc("/admin/jobs/{id}", requireAdmin(deleteQueuedJob)).Methods("DELETE")
+	r.HandleFunc("/admin/storage-migration", requireAdmin(getMigrationStatus)).Methods("GET")
+	// Scrapers send the admin token, as the port is public
+	r.HandleFunc("/metrics", requireAdmin(metricsRegistry.Handler().ServeHTTP)).Methods("GET")
+	r.HandleFunc("/health", liveCheck).Methods("GET")
+	r.HandleFunc("/health/live", liveCheck).Methods("GET")
+	r.HandleFunc("/health/ready", readyCheck).Methods("GET")
+	r.HandleFunc("/ready", readyCheck).Methods("GET")
+	r.HandleFunc("/admin/drain", requireAdmin(drainInstance)).Methods("POST")
+	registerPublicRoutes(r, cfg.PublicRateLimitPerMinute)
+	registerAutomationRoutes(r)
+	if cfg.DevMode {
+		log.Print("DEV_MODE is on: serving the API console at /dev/console")
+		registerDevConsole(r)
+	}
+
+	// Internal services talk gRPC on a port of their own
+	var grpcServer *grpc.Server
+	if cfg.GRPCPort != "" {
+		grpcServer, err = serveGRPC(":" + cfg.GRPCPort)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("gRPC server is running on port " + cfg.GRPCPort)
+	}
 
 	// Start the server
-	http.Handle("/", r)
-	fmt.Println("Server is running on port 8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	server := &http.Server{Addr: ":" + cfg.Port, Handler: logRequests(instrumentRequests(traceRequests(recoverPanics(allowCORS(cfg, r)))))}
+	fmt.Println("Server is running on port " + cfg.Port)
+	if err = serve(appCtx, server, cfg.DrainDelay, cfg.ShutdownTimeout); err != nil {
+		log.Print(err)
+	}
+	if grpcServer != nil {
+		stopGRPC(grpcServer, cfg.ShutdownTimeout)
+	}
+
+	// Let background work wind down before closing what it uses
+	stop()
+	drain.stop()
+	closeCtx, cancelClose := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancelClose()
+	if err = client.Disconnect(closeCtx); err != nil {
+		log.Printf("failed to disconnect from MongoDB: %v", err)
+	}
+	if sess.Config.HTTPClient != nil {
+		sess.Config.HTTPClient.CloseIdleConnections()
+	}
+	if err = shutdownTracing(closeCtx); err != nil {
+		log.Printf("failed to flush traces: %v", err)
+	}
+	log.Print("shutdown complete")
 }
 
 func createStory(w http.ResponseWriter, r *http.Request) {
-	var story models.Story
-	err := json.NewDecoder(r.Body).Decode(&story)
+	var request api.StoryRequest
+	err := decodeRequest(r, &request)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err = validation.Story(&request); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	story := request.ToModel()
+	story.OwnerID, _ = currentUser(r.Context())
+	err = assessSpam(r.Context(), clientIP(r), &story)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	err = insertStory(r.Context(), &story)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 
+	writeResponse(w, r, http.StatusCreated, api.StoryFromModel(&story))
+}
+
+func listStories(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var list repository.StoryQuery
+	if since := query.Get("updated_since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			httpError(w, "Invalid updated_since, expected RFC 3339", http.StatusBadRequest)
+			return
+		}
+		list.UpdatedSince = t
+	}
+
+	metadata, err := metadataQuery(query)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	list.Metadata = metadata
+	list.Language = query.Get("lang")
+	// Everyone sees the listed stories, and signed-in users their own too
+	list.Listed = true
+	list.Owner, _ = currentUser(r.Context())
+
+	switch sort := query.Get("sort"); sort {
+	case repository.SortID, repository.SortUpdatedAt, repository.SortUpdatedAtReverse,
+		repository.SortTitle, repository.SortTitleReverse:
+		list.Sort = sort
+	default:
+		httpError(w, "Invalid sort", http.StatusBadRequest)
+		return
+	}
+	if list.Locale, err = titleLocale(query); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Paging is opt-in so existing clients keep getting the full list
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxListLimit {
+			httpError(w, fmt.Sprintf("limit must be between 1 and %d", maxListLimit), http.StatusBadRequest)
+			return
+		}
+		list.Limit = n
+	}
+	if v := query.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			httpError(w, "offset must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		list.Offset = n
+	}
+
+	stories, total, err := storyRepo.List(r.Context(), list)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+	if list.Limit > 0 && int64(list.Offset+list.Limit) < total {
+		next := r.URL.Query()
+		next.Set("offset", strconv.Itoa(list.Offset+list.Limit))
+		w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, next.Encode()))
+	}
+	writeResponse(w, r, http.StatusOK, api.StoriesFromModels(stories))
+}
+
+func findStory(ctx context.Context, id primitive.ObjectID) (models.Story, error) {
+	return storyRepo.Get(ctx, id)
+}
+
+func insertStory(ctx context.Context, story *models.Story) error {
+	// With nothing to preserve, this only gives new tracks their IDs
+	preserveAudioTracks(story, &models.Story{})
+	normalizeScripts(story)
+	if err := validateStory(story); err != nil {
+		return err
+	}
+	if err := checkPublishable(story, &models.Story{}); err != nil {
+		return err
+	}
+	if err := filterScripts(ctx, story); err != nil {
+		return err
+	}
+	if err := stampContentHash(ctx, story); err != nil {
+		return err
+	}
+
 	// Initialize Segments to an empty array if it's nil
 	if story.Segments == nil {
 		story.Segments = []models.Segment{}
 	}
 
-	story.ID = primitive.NewObjectID()
+	// Callers may pick the ID up front, e.g. to store media under it
+	if story.ID.IsZero() {
+		story.ID = primitive.NewObjectID()
+	}
 	story.CreatedAt = time.Now()
-	collection := client.Database("rosetta").Collection("stories")
-	_, err = collection.InsertOne(context.Background(), story)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	story.UpdatedAt = story.CreatedAt
+	story.PublishedAt = nil
+	if story.IsPublished {
+		story.PublishedAt = &story.CreatedAt
+	}
+	story.Version = 1
+	for i := range story.Segments {
+		if story.Segments[i].ID.IsZero() {
+			story.Segments[i].ID = primitive.NewObjectID()
+		}
+		story.Segments[i].Version = story.Version
+	}
+	if err := storyRepo.Create(ctx, story); err != nil {
+		return err
 	}
+	recordRevision(ctx, story)
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(story)
+	if story.Moderation != nil {
+		action := models.ModerationActionFlag
+		if story.Moderation.Status == models.ModerationHidden {
+			action = models.ModerationActionHide
+		}
+		return recordModerationAction(ctx, story.ID, action, story.Moderation.Reasons, true)
+	}
+	return nil
 }
 
 func deleteStory(w http.ResponseWriter, r *http.Request) {
@@ -126,123 +458,229 @@ func deleteStory(w http.ResponseWriter, r *http.Request) {
 
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		http.Error(w, "Invalid story ID", http.StatusBadRequest)
+		httpError(w, "Invalid story ID", http.StatusBadRequest)
 		return
 	}
 
-	collection := client.Database("rosetta").Collection("stories")
-	_, err = collection.DeleteOne(context.Background(), bson.M{"_id": objectID})
+	story, err := findOwnStory(r.Context(), objectID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
+		return
+	}
+
+	var before time.Time
+	if since := unmodifiedSince(r); !since.IsZero() {
+		before = since.Add(time.Second)
+	}
+
+	deleted, err := removeStory(r.Context(), objectID, story.OwnerID, before)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if !deleted {
+		// Tell a failed If-Unmodified-Since guard apart from a missing story
+		_, err = findStory(r.Context(), objectID)
+		if err == nil {
+			err = errPreconditionFailed
+		}
+		writeError(w, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// removeStory deletes the story, only if it was last updated before a
+// non-zero before, records the deletion for the owner's sync clients and
+// cleans up what belonged to it. It reports false if nothing was deleted.
+func removeStory(ctx context.Context, id, ownerID primitive.ObjectID, before time.Time) (bool, error) {
+	deleted, err := storyRepo.Delete(ctx, id, before)
+	if err != nil || !deleted {
+		return false, err
+	}
+
+	if err = recordDeletion(ctx, id, ownerID); err != nil {
+		return true, err
+	}
+	scheduleMediaCleanup(ctx, id, storyMediaPrefix(id))
+
+	// Backups of a deleted story have nothing left to restore into
+	if _, err = draftBackupsCollection().DeleteMany(ctx, bson.M{"story_id": id}); err != nil {
+		log.Printf("failed to delete draft backups of %s: %v", id.Hex(), err)
+	}
+	if _, err = storyPlaysCollection().DeleteMany(ctx, bson.M{"story_id": id}); err != nil {
+		log.Printf("failed to delete play counts of %s: %v", id.Hex(), err)
+	}
+	return true, nil
+}
+
 func updateStory(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		http.Error(w, "Invalid story ID", http.StatusBadRequest)
+		httpError(w, "Invalid story ID", http.StatusBadRequest)
 		return
 	}
 
-	var story models.Story
-	err = json.NewDecoder(r.Body).Decode(&story)
+	var request api.StoryRequest
+	err = decodeRequest(r, &request)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err = validation.Story(&request); err != nil {
+		writeError(w, err)
 		return
 	}
 
-	// Ensure segments have IDs
-	for i, segment := range story.Segments {
-		if segment.ID.IsZero() {
-			story.Segments[i].ID = primitive.NewObjectID()
-		}
+	if _, err = findOwnStory(r.Context(), objectID); err != nil {
+		writeError(w, err)
+		return
 	}
 
-	collection := client.Database("rosetta").Collection("stories")
-	update := bson.M{
-		"$set": bson.M{
-			"title":        story.Title,
-			"segments":     story.Segments,
-			"is_published": story.IsPublished,
-		},
+	story := request.ToModel()
+
+	// If-Match names the version the edit started from; changes made since
+	// are merged in
+	base, ok := baseVersion(w, r)
+	if !ok {
+		return
 	}
 
-	_, err = collection.UpdateOne(context.Background(), bson.M{"_id": objectID}, update)
+	err = updateStoryContent(r.Context(), objectID, &story, unmodifiedSince(r), base)
+	if writeVersionConflict(w, err) {
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
-	var updatedStory models.Story
-	err = collection.FindOne(context.Background(), bson.M{"_id": objectID}).Decode(&updatedStory)
+	updatedStory, err := findStory(r.Context(), objectID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(updatedStory)
+	setLastModified(w, &updatedStory)
+	w.Header().Set("ETag", storyETag(&updatedStory))
+	writeResponse(w, r, http.StatusOK, api.StoryFromModel(&updatedStory))
+}
+
+// newAudioKey returns a fresh object key for a segment's audio. Every upload
+// gets its own key so the current audio stays intact until the new object is
+// confirmed. The ULID is drawn from crypto/rand so keys can't be guessed.
+func newAudioKey(storyID, segmentID primitive.ObjectID) string {
+	return fmt.Sprintf("%s/%s/audio/%s", storyID.Hex(), segmentID.Hex(), ulid.MustNew(ulid.Now(), rand.Reader))
 }
 
 func generateAudioUploadURL(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	storyID := vars["storyId"]
-	segmentID := vars["segmentId"]
 
-	objectName := fmt.Sprintf("%s/%s/audio", storyID, segmentID)
+	storyID, err := primitive.ObjectIDFromHex(vars["storyId"])
+	if err != nil {
+		httpError(w, "Invalid story ID", http.StatusBadRequest)
+		return
+	}
+	segmentID, err := primitive.ObjectIDFromHex(vars["segmentId"])
+	if err != nil {
+		httpError(w, "Invalid segment ID", http.StatusBadRequest)
+		return
+	}
+
+	story, err := findOwnStory(r.Context(), storyID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if findSegment(&story, segmentID) == nil {
+		writeError(w, domain.New(domain.ErrNotFound, "Segment not found"))
+		return
+	}
+
+	objectName := newAudioKey(storyID, segmentID)
 
 	// Generate a pre-signed URL for PUT operation
-	req, _ := s3Client.PutObjectRequest(&s3.PutObjectInput{
-		Bucket: aws.String(s3Bucket),
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(writeBucket()),
 		Key:    aws.String(objectName),
-	})
+	}
+	encryptPut(input)
+	req, _ := s3Client.PutObjectRequest(input)
 	presignedURL, err := req.Presign(15 * time.Minute)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	presignedURL = strings.Replace(presignedURL, s3Endpoint, s3PublicHost, 1)
 
-	publicURL := fmt.Sprintf("%s/%s/%s", s3PublicHost, s3Bucket, objectName)
+	err = updateSegment(r.Context(), storyID, segmentID, bson.M{"audio.pending_key": objectName})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	publicURL := mediaURL(objectName)
 
 	w.WriteHeader(http.StatusOK)
 	encoder := json.NewEncoder(w)
 	encoder.SetEscapeHTML(false) // Disable HTML escaping
-	encoder.Encode(map[string]string{
-		"upload_url": presignedURL,
-		"public_url": publicURL,
+	encoder.Encode(map[string]interface{}{
+		"upload_url":     presignedURL,
+		"upload_headers": uploadHeaders(),
+		"public_url":     publicURL,
+		"key":            objectName,
 	})
 }
 
+func findSegment(story *models.Story, segmentID primitive.ObjectID) *models.Segment {
+	for i := range story.Segments {
+		if story.Segments[i].ID == segmentID {
+			return &story.Segments[i]
+		}
+	}
+	return nil
+}
+
 func getStory(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		http.Error(w, "Invalid story ID", http.StatusBadRequest)
+		httpError(w, "Invalid story ID", http.StatusBadRequest)
 		return
 	}
 
-	var story models.Story
-	collection := client.Database("rosetta").Collection("stories")
-	err = collection.FindOne(context.Background(), bson.M{"_id": objectID}).Decode(&story)
+	story, err := findVisibleStory(r.Context(), objectID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(story)
-}
+	etag := editorETag(&story)
+	w.Header().Set("ETag", etag)
+	w.Header().Add("Vary", "Accept-Language")
+	setLastModified(w, &story)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
 
-func healthCheck(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("OK"))
+	if since := r.URL.Query().Get("since_version"); since != "" {
+		sinceVersion, err := strconv.ParseInt(since, 10, 64)
+		if err != nil {
+			httpError(w, "Invalid since_version", http.StatusBadRequest)
+			return
+		}
+
+		writeResponse(w, r, http.StatusOK, newStoryDelta(&story, sinceVersion))
+		return
+	}
+
+	writeTranslatedStory(w, r, &story)
 }
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/api"
+	"rosetta/audioprobe"
+	"rosetta/domain"
+	"rosetta/models"
+	"rosetta/redact"
+)
+
+type mediaReportItem struct {
+	SegmentID   *primitive.ObjectID `json:"segment_id,omitempty"`
+	TrackID     *primitive.ObjectID `json:"track_id,omitempty"`
+	Kind        string              `json:"kind"`
+	URL         string              `json:"url"`
+	Key         string              `json:"key,omitempty"`
+	External    bool                `json:"external"`
+	Exists      bool                `json:"exists"`
+	Size        int64               `json:"size"`
+	ContentType string              `json:"content_type,omitempty"`
+	Error       string              `json:"error,omitempty"`
+}
+
+type mediaReport struct {
+	StoryID   primitive.ObjectID `json:"story_id"`
+	Items     []mediaReportItem  `json:"items"`
+	TotalSize int64              `json:"total_size"`
+	Dangling  int                `json:"dangling"`
+}
+
+// objectKeyFromURL recovers the bucket key from a public media URL. It returns
+// false for URLs that don't point into our buckets.
+func objectKeyFromURL(url string) (string, bool) {
+	_, key, ok := mediaLocation(url)
+	return key, ok
+}
+
+// mediaLocation is objectKeyFromURL that also tells which bucket the URL
+// points into while a migration is running.
+func mediaLocation(url string) (bucket, key string, ok bool) {
+	for _, bucket := range mediaBuckets() {
+		prefix := s3PublicHost + "/" + bucket + "/"
+		if strings.HasPrefix(url, prefix) {
+			return bucket, strings.TrimPrefix(url, prefix), true
+		}
+	}
+	return "", "", false
+}
+
+// mediaURL is the public URL of new media, which is written to the migration
+// target when there is one.
+func mediaURL(key string) string {
+	return bucketMediaURL(writeBucket(), key)
+}
+
+func bucketMediaURL(bucket, key string) string {
+	return fmt.Sprintf("%s/%s/%s", s3PublicHost, bucket, key)
+}
+
+// mediaReader reads ranges of a media object, so probing a file doesn't
+// download all of it.
+type mediaReader struct {
+	ctx    context.Context
+	bucket string
+	key    string
+}
+
+func (m mediaReader) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	out, err := s3Client.GetObjectWithContext(m.ctx, &s3.GetObjectInput{
+		Bucket: aws.String(m.bucket),
+		Key:    aws.String(m.key),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", off, off+int64(len(p))-1)),
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer out.Body.Close()
+
+	n, err := io.ReadFull(out.Body, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func isNotFound(err error) bool {
+	if reqErr, ok := err.(awserr.RequestFailure); ok {
+		return reqErr.StatusCode() == http.StatusNotFound
+	}
+	return false
+}
+
+func inspectMedia(ctx context.Context, item *mediaReportItem) {
+	if item.Key == "" {
+		key, ok := objectKeyFromURL(item.URL)
+		if !ok {
+			item.External = true
+			return
+		}
+		item.Key = key
+	}
+	key := item.Key
+
+	_, head, err := headMedia(ctx, key)
+	if isNotFound(err) {
+		return
+	}
+	if err != nil {
+		item.Error = redact.String(err.Error())
+		return
+	}
+
+	item.Exists = true
+	item.Size = aws.Int64Value(head.ContentLength)
+	item.ContentType = aws.StringValue(head.ContentType)
+}
+
+func getMediaReport(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		httpError(w, "Invalid story ID", http.StatusBadRequest)
+		return
+	}
+
+	story, err := findVisibleStory(r.Context(), objectID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	report := mediaReport{StoryID: story.ID, Items: []mediaReportItem{}}
+	for _, segment := range story.Segments {
+		if segment.Audio != nil && segment.Audio.Key != "" {
+			report.Items = append(report.Items, mediaReportItem{SegmentID: &segment.ID, Kind: "audio", URL: segment.Audio.Url, Key: segment.Audio.Key})
+		} else if segment.Audio != nil && segment.Audio.Url != "" {
+			report.Items = append(report.Items, mediaReportItem{SegmentID: &segment.ID, Kind: "audio", URL: segment.Audio.Url})
+		}
+		if segment.Audio != nil && segment.Audio.OriginalKey != "" {
+			report.Items = append(report.Items, mediaReportItem{SegmentID: &segment.ID, Kind: "audio_original", URL: segment.Audio.OriginalUrl, Key: segment.Audio.OriginalKey})
+		}
+		if segment.Image != nil && segment.Image.Url != "" {
+			report.Items = append(report.Items, mediaReportItem{SegmentID: &segment.ID, Kind: "image", URL: segment.Image.Url, Key: segment.Image.Key})
+		}
+	}
+	for _, track := range story.AudioTracks {
+		if track.Url != "" {
+			report.Items = append(report.Items, mediaReportItem{TrackID: &track.ID, Kind: "audio_track", URL: track.Url, Key: track.Key})
+		}
+	}
+
+	for i := range report.Items {
+		item := &report.Items[i]
+		inspectMedia(r.Context(), item)
+		report.TotalSize += item.Size
+		if !item.External && !item.Exists && item.Error == "" {
+			report.Dangling++
+		}
+	}
+
+	writeResponse(w, r, http.StatusOK, report)
+}
+
+// completeAudioUpload is called by clients once their upload to the
+// presigned URL finished. It promotes the segment's pending upload to its
+// current audio, with the object's size, content type and duration, then
+// removes the replaced object.
+func completeAudioUpload(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	storyID, err := primitive.ObjectIDFromHex(vars["storyId"])
+	if err != nil {
+		httpError(w, "Invalid story ID", http.StatusBadRequest)
+		return
+	}
+	segmentID, err := primitive.ObjectIDFromHex(vars["segmentId"])
+	if err != nil {
+		httpError(w, "Invalid segment ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err = findOwnStory(r.Context(), storyID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	err = promotePendingAudio(r.Context(), storyID, segmentID, "")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	updatedStory, err := findStory(r.Context(), storyID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeResponse(w, r, http.StatusOK, api.StoryFromModel(&updatedStory))
+}
+
+// promotePendingAudio makes a segment's pending upload its current audio once
+// the object exists. With a non-empty key, it only does so if that key is
+// still the pending one, so a late notice about a superseded upload is
+// refused.
+func promotePendingAudio(ctx context.Context, storyID, segmentID primitive.ObjectID, key string) error {
+	story, err := findStory(ctx, storyID)
+	if err != nil {
+		return err
+	}
+	segment := findSegment(&story, segmentID)
+	if segment == nil {
+		return domain.New(domain.ErrNotFound, "Segment not found")
+	}
+	if segment.Audio == nil || segment.Audio.PendingKey == "" {
+		return domain.New(domain.ErrConflict, "No pending audio upload")
+	}
+	pendingKey := segment.Audio.PendingKey
+	if key != "" && key != pendingKey {
+		return domain.New(domain.ErrConflict, "Upload is no longer pending")
+	}
+
+	bucket, head, err := headMedia(ctx, pendingKey)
+	if isNotFound(err) {
+		return domain.New(domain.ErrConflict, "Audio has not been uploaded yet")
+	}
+	if err != nil {
+		return err
+	}
+
+	audio := models.Audio{
+		Url:         bucketMediaURL(bucket, pendingKey),
+		Key:         pendingKey,
+		Size:        aws.Int64Value(head.ContentLength),
+		ContentType: aws.StringValue(head.ContentType),
+	}
+	// Best effort: audio plays fine without a known duration
+	duration, err := audioprobe.Duration(mediaReader{ctx: ctx, bucket: bucket, key: pendingKey}, audio.Size)
+	if err != nil {
+		log.Printf("failed to probe duration of %s: %v", pendingKey, err)
+	}
+	audio.DurationMs = duration.Milliseconds()
+
+	// Audio of its own replaces any clip the segment played
+	err = updateSegment(ctx, storyID, segmentID, bson.M{"audio": audio, "clip": nil})
+	if err != nil {
+		return err
+	}
+	err = refreshContentHash(ctx, storyID)
+	if err != nil {
+		return err
+	}
+	scheduleAudioProcessing(ctx, storyID, segmentID, pendingKey)
+
+	// Best effort: a leftover object is harmless and shows up in media reports
+	for _, previousKey := range []string{segment.Audio.Key, segment.Audio.OriginalKey} {
+		if previousKey == "" || previousKey == pendingKey {
+			continue
+		}
+		if err = deleteMedia(ctx, previousKey); err != nil {
+			log.Printf("failed to delete replaced audio %s: %v", previousKey, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"rosetta/domain"
+	"rosetta/models"
+)
+
+// Conflict reasons in merge reports
+const (
+	conflictBothModified    = "both_modified"
+	conflictModifiedDeleted = "modified_deleted"
+	conflictDeletedModified = "deleted_modified"
+	conflictBothReordered   = "both_reordered"
+)
+
+type mergeConflict struct {
+	Field     string              `json:"field,omitempty"`
+	SegmentID *primitive.ObjectID `json:"segment_id,omitempty"`
+	Reason    string              `json:"reason"`
+}
+
+// mergeConflictError is returned when an update made against an older
+// version overlaps with what changed since. It carries the full report.
+type mergeConflictError struct {
+	BaseVersion    int64           `json:"base_version"`
+	CurrentVersion int64           `json:"current_version"`
+	Conflicts      []mergeConflict `json:"conflicts"`
+}
+
+func (e *mergeConflictError) Error() string {
+	return "Update conflicts with changes made since version " + strconv.FormatInt(e.BaseVersion, 10)
+}
+
+func (e *mergeConflictError) Is(target error) bool {
+	return target == domain.ErrConflict
+}
+
+// staleVersionError is returned when a patch was made against a version
+// the story has moved on from. Patches aren't merged, so the client reloads
+// the current version and applies its change again.
+type staleVersionError struct {
+	BaseVersion    int64 `json:"base_version"`
+	CurrentVersion int64 `json:"current_version"`
+}
+
+func (e *staleVersionError) Error() string {
+	return "Story is at version " + strconv.FormatInt(e.CurrentVersion, 10) + ", not " + strconv.FormatInt(e.BaseVersion, 10)
+}
+
+func (e *staleVersionError) Is(target error) bool {
+	return target == domain.ErrConflict
+}
+
+// writeVersionConflict writes the conflict report with the current version,
+// or reports false if err is some other error.
+func writeVersionConflict(w http.ResponseWriter, err error) bool {
+	var conflict *mergeConflictError
+	var stale *staleVersionError
+	switch {
+	case errors.As(err, &conflict):
+		writeErrorBody(w, http.StatusConflict, errorBody{Code: codeConflict, Message: conflict.Error(), Details: conflict})
+	case errors.As(err, &stale):
+		writeErrorBody(w, http.StatusConflict, errorBody{Code: codeConflict, Message: stale.Error(), Details: stale})
+	default:
+		return false
+	}
+	return true
+}
+
+// versionFromETag parses the version out of a story ETag, including the
+// lock-aware form editors see.
+func versionFromETag(etag string) (int64, bool) {
+	etag = strings.Trim(strings.TrimPrefix(etag, "W/"), `"`)
+	if !strings.HasPrefix(etag, "v") {
+		return 0, false
+	}
+	etag = strings.TrimPrefix(etag, "v")
+	if i := strings.IndexByte(etag, '-'); i >= 0 {
+		etag = etag[:i]
+	}
+	version, err := strconv.ParseInt(etag, 10, 64)
+	return version, err == nil
+}
+
+func recordRevision(ctx context.Context, story *models.Story) {
+	_, err := storyRevisionsCollection().InsertOne(ctx, models.StoryRevision{
+		ID:        primitive.NewObjectID(),
+		StoryID:   story.ID,
+		Version:   story.Version,
+		Story:     *story,
+		CreatedAt: time.Now(),
+	})
+	// Best effort: without the revision, updates based on it get a 412
+	if err != nil {
+		log.Printf("failed to record revision %d of story %s: %v", story.Version, story.ID.Hex(), err)
+	}
+}
+
+func findRevision(ctx context.Context, storyID primitive.ObjectID, version int64) (models.Story, error) {
+	var revision models.StoryRevision
+	err := storyRevisionsCollection().FindOne(ctx, bson.M{"story_id": storyID, "version": version}).Decode(&revision)
+	if err == mongo.ErrNoDocuments {
+		return models.Story{}, domain.New(domain.ErrPreconditionFailed, "Base version is no longer available, reload the story")
+	}
+	return revision.Story, err
+}
+
+// mergeStory three-way merges ours, an update made against base, with
+// theirs, the story as stored now. Story fields and segments merge
+// independently: a change on one side wins, identical changes agree, and
+// different changes to the same field or segment are conflicts.
+func mergeStory(base, theirs, ours *models.Story) (models.Story, []mergeConflict) {
+	merged := *theirs
+	var conflicts []mergeConflict
+
+	// Tracks and metadata left out of an update are kept, which is no change
+	oursTracks := trackURLs(ours.AudioTracks)
+	if ours.AudioTracks == nil {
+		oursTracks = trackURLs(base.AudioTracks)
+	}
+	oursMetadata := ours.Metadata
+	if oursMetadata == nil {
+		oursMetadata = base.Metadata
+	}
+
+	fields := []struct {
+		name               string
+		base, theirs, ours interface{}
+		set                func()
+	}{
+		{"title", base.Title, theirs.Title, ours.Title, func() { merged.Title = ours.Title }},
+		{"language", base.Language, theirs.Language, ours.Language, func() { merged.Language = ours.Language }},
+		{"characters", base.Characters, theirs.Characters, ours.Characters, func() { merged.Characters = ours.Characters }},
+		{"is_published", base.IsPublished, theirs.IsPublished, ours.IsPublished, func() { merged.IsPublished = ours.IsPublished }},
+		{"visibility", base.Visibility, theirs.Visibility, ours.Visibility, func() { merged.Visibility = ours.Visibility }},
+		{"age_rating", base.AgeRating, theirs.AgeRating, ours.AgeRating, func() { merged.AgeRating = ours.AgeRating }},
+		{"content_warnings", base.ContentWarnings, theirs.ContentWarnings, ours.ContentWarnings, func() { merged.ContentWarnings = ours.ContentWarnings }},
+		{"metadata", metadataOrNil(base.Metadata), metadataOrNil(theirs.Metadata), metadataOrNil(oursMetadata), func() { merged.Metadata = ours.Metadata }},
+		{"audio_tracks", trackURLs(base.AudioTracks), trackURLs(theirs.AudioTracks), oursTracks, func() { merged.AudioTracks = ours.AudioTracks }},
+	}
+	for _, f := range fields {
+		switch {
+		case reflect.DeepEqual(f.ours, f.base), reflect.DeepEqual(f.ours, f.theirs):
+		case reflect.DeepEqual(f.theirs, f.base):
+			f.set()
+		default:
+			conflicts = append(conflicts, mergeConflict{Field: f.name, Reason: conflictBothModified})
+		}
+	}
+
+	segments, segmentConflicts := mergeSegmentLists(base.Segments, theirs.Segments, ours.Segments)
+	merged.Segments = segments
+	return merged, append(conflicts, segmentConflicts...)
+}
+
+func mergeSegmentLists(base, theirs, ours []models.Segment) ([]models.Segment, []mergeConflict) {
+	baseByID := segmentsByID(base)
+	theirsByID := segmentsByID(theirs)
+	oursByID := segmentsByID(ours)
+
+	var conflicts []mergeConflict
+	conflict := func(id primitive.ObjectID, reason string) {
+		conflicts = append(conflicts, mergeConflict{SegmentID: &id, Reason: reason})
+	}
+
+	// Resolve the content of every segment either side kept or added
+	resolved := map[primitive.ObjectID]models.Segment{}
+	for _, b := range base {
+		id := b.ID
+		t, inTheirs := theirsByID[id]
+		o, inOurs := oursByID[id]
+		switch {
+		case inTheirs && inOurs:
+			switch {
+			case sameSegmentContent(o, b), sameSegmentContent(o, t):
+				resolved[id] = t
+			case sameSegmentContent(t, b):
+				resolved[id] = o
+			default:
+				conflict(id, conflictBothModified)
+			}
+		case inTheirs:
+			if !sameSegmentContent(t, b) {
+				conflict(id, conflictDeletedModified)
+			}
+		case inOurs:
+			if !sameSegmentContent(o, b) {
+				conflict(id, conflictModifiedDeleted)
+			}
+		}
+	}
+	for id, t := range theirsByID {
+		if _, ok := baseByID[id]; !ok {
+			resolved[id] = t
+		}
+	}
+	for id, o := range oursByID {
+		if _, ok := baseByID[id]; !ok {
+			resolved[id] = o
+		}
+	}
+
+	// Follow the order of whichever side rearranged the common segments
+	order := theirs
+	other := ours
+	theirsMoved := !sameOrder(base, theirs)
+	oursMoved := !sameOrder(base, ours)
+	if oursMoved && theirsMoved && !sameOrder(theirs, ours) {
+		conflicts = append(conflicts, mergeConflict{Field: "segments", Reason: conflictBothReordered})
+	} else if oursMoved {
+		order, other = ours, theirs
+	}
+	if len(conflicts) > 0 {
+		return nil, conflicts
+	}
+
+	merged := make([]models.Segment, 0, len(order)+len(other))
+	placed := map[primitive.ObjectID]bool{}
+	for _, segment := range order {
+		if segment.ID.IsZero() {
+			merged = append(merged, segment)
+			continue
+		}
+		if resolvedSegment, ok := resolved[segment.ID]; ok {
+			merged = append(merged, resolvedSegment)
+			placed[segment.ID] = true
+		}
+	}
+
+	// Segments the other side added go after the segment preceding them there
+	at := 0
+	for _, segment := range other {
+		_, inBase := baseByID[segment.ID]
+		if segment.ID.IsZero() || (!inBase && !placed[segment.ID]) {
+			merged = insertSegment(merged, at, segment)
+			at++
+			continue
+		}
+		if placed[segment.ID] {
+			at = segmentIndex(merged, segment.ID) + 1
+		}
+	}
+	return merged, nil
+}
+
+func segmentsByID(segments []models.Segment) map[primitive.ObjectID]models.Segment {
+	byID := make(map[primitive.ObjectID]models.Segment, len(segments))
+	for _, segment := range segments {
+		if !segment.ID.IsZero() {
+			byID[segment.ID] = segment
+		}
+	}
+	return byID
+}
+
+// sameSegmentContent compares what clients edit, leaving out server-managed
+// fields such as storage keys and versions.
+func sameSegmentContent(a, b models.Segment) bool {
+	return a.Speaker == b.Speaker &&
+		reflect.DeepEqual(a.Script, b.Script) &&
+		reflect.DeepEqual(a.Clip, b.Clip) &&
+		audioURL(a.Audio) == audioURL(b.Audio) &&
+		imageURL(a.Image) == imageURL(b.Image)
+}
+
+func audioURL(audio *models.Audio) string {
+	if audio == nil {
+		return ""
+	}
+	return audio.Url
+}
+
+// trackURLs is what clients edit of audio tracks. Tracks awaiting their
+// upload are left out, as clients never see them.
+func trackURLs(tracks []models.AudioTrack) []string {
+	urls := []string{}
+	for _, track := range tracks {
+		if track.Url != "" {
+			urls = append(urls, track.ID.Hex()+" "+track.Url)
+		}
+	}
+	return urls
+}
+
+// metadataOrNil makes empty metadata compare equal however it is stored.
+func metadataOrNil(metadata map[string]string) map[string]string {
+	if len(metadata) == 0 {
+		return nil
+	}
+	return metadata
+}
+
+func imageURL(image *models.Image) string {
+	if image == nil {
+		return ""
+	}
+	return image.Url
+}
+
+// sameOrder reports whether the segments a and b have in common appear in
+// the same relative order. A segment repeated in b but not in a puts them
+// out of order.
+func sameOrder(a, b []models.Segment) bool {
+	inB := segmentsByID(b)
+	var common []primitive.ObjectID
+	for _, segment := range a {
+		if _, ok := inB[segment.ID]; ok && !segment.ID.IsZero() {
+			common = append(common, segment.ID)
+		}
+	}
+	inA := segmentsByID(a)
+	i := 0
+	for _, segment := range b {
+		if _, ok := inA[segment.ID]; !ok || segment.ID.IsZero() {
+			continue
+		}
+		if i >= len(common) || common[i] != segment.ID {
+			return false
+		}
+		i++
+	}
+	return true
+}
+
+func segmentIndex(segments []models.Segment, id primitive.ObjectID) int {
+	for i := range segments {
+		if segments[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func insertSegment(segments []models.Segment, at int, segment models.Segment) []models.Segment {
+	segments = append(segments, models.Segment{})
+	copy(segments[at+1:], segments[at:])
+	segments[at] = segment
+	return segments
+}
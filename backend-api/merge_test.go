@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/models"
+)
+
+func segmentList(ids ...primitive.ObjectID) []models.Segment {
+	segments := make([]models.Segment, len(ids))
+	for i, id := range ids {
+		segments[i] = models.Segment{ID: id, Script: &models.Script{Text: id.Hex()}}
+	}
+	return segments
+}
+
+func TestSameOrder(t *testing.T) {
+	a, b, c := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	tests := []struct {
+		name   string
+		x, y   []models.Segment
+		expect bool
+	}{
+		{"equal", segmentList(a, b, c), segmentList(a, b, c), true},
+		{"added and removed", segmentList(a, b), segmentList(a, c, b), true},
+		{"swapped", segmentList(a, b), segmentList(b, a), false},
+		{"repeated", segmentList(a, b), segmentList(a, b, b), false},
+	}
+	for _, test := range tests {
+		if got := sameOrder(test.x, test.y); got != test.expect {
+			t.Errorf("%s: sameOrder = %v, want %v", test.name, got, test.expect)
+		}
+	}
+}
+
+func TestMergeStoryRepeatedSegment(t *testing.T) {
+	a, b := primitive.NewObjectID(), primitive.NewObjectID()
+	base := models.Story{Title: "Fox", Segments: segmentList(a, b)}
+	theirs := models.Story{Title: "Fox", Segments: segmentList(a, b)}
+	ours := models.Story{Title: "Fox", Segments: segmentList(a, b, b)}
+
+	// Must not panic; duplicates are rejected by validation before merging
+	mergeStory(&base, &theirs, &ours)
+}
+
+func TestMergeStoryMetadata(t *testing.T) {
+	base := models.Story{Title: "Fox", Metadata: map[string]string{"source": "a"}}
+	theirs := models.Story{Title: "Fox and Grapes", Metadata: map[string]string{"source": "a"}}
+	ours := models.Story{Title: "Fox", Metadata: map[string]string{"source": "b"}}
+
+	merged, conflicts := mergeStory(&base, &theirs, &ours)
+	if len(conflicts) > 0 {
+		t.Fatalf("unexpected conflicts %v", conflicts)
+	}
+	if merged.Title != "Fox and Grapes" || merged.Metadata["source"] != "b" {
+		t.Errorf("merged title %q and metadata %v, want both changes", merged.Title, merged.Metadata)
+	}
+
+	theirs.Metadata = map[string]string{"source": "c"}
+	if _, conflicts = mergeStory(&base, &theirs, &ours); len(conflicts) != 1 || conflicts[0].Field != "metadata" {
+		t.Errorf("conflicts %v, want one on metadata", conflicts)
+	}
+}
+
+func TestMergeStoryKeepsMetadataLeftOut(t *testing.T) {
+	base := models.Story{Title: "Fox", Metadata: map[string]string{"source": "a"}}
+	theirs := models.Story{Title: "Fox", Metadata: map[string]string{"source": "c"}}
+	ours := models.Story{Title: "Fox and Grapes"}
+
+	merged, conflicts := mergeStory(&base, &theirs, &ours)
+	if len(conflicts) > 0 {
+		t.Fatalf("unexpected conflicts %v", conflicts)
+	}
+	if merged.Metadata["source"] != "c" {
+		t.Errorf("merged metadata %v, want theirs kept", merged.Metadata)
+	}
+}
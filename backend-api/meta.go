@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"rosetta/openapi"
+	"rosetta/sdk"
+)
+
+// The released schema versions and, for each schema of the latest, the
+// fields older versions had that it no longer does.
+var (
+	schemaVersions []*openapi.Document
+	removedFields  map[string]map[string]string
+)
+
+// loadSchemas reads the schema snapshots and warns when the code has moved
+// on from the latest one without a new snapshot.
+func loadSchemas() error {
+	docs, err := openapi.Snapshots()
+	if err != nil {
+		return err
+	}
+	if len(docs) == 0 {
+		return fmt.Errorf("no schema snapshots")
+	}
+	schemaVersions = docs
+	removedFields = openapi.RemovedFields(docs)
+
+	latest := docs[len(docs)-1]
+	if changes := openapi.Diff(latest, openapi.Generate(latest.Info.Version, openapi.Routes)); len(changes) > 0 {
+		log.Printf("API differs from schema version %s in %d places; snapshot a new version with cmd/openapi", latest.Info.Version, len(changes))
+	}
+	return nil
+}
+
+func latestSchema() *openapi.Document {
+	return schemaVersions[len(schemaVersions)-1]
+}
+
+type schemaVersionResponse struct {
+	Version  string   `json:"version"`
+	Versions []string `json:"versions"`
+}
+
+func getSchemaVersion(w http.ResponseWriter, r *http.Request) {
+	response := schemaVersionResponse{Version: latestSchema().Info.Version}
+	for _, doc := range schemaVersions {
+		response.Versions = append(response.Versions, doc.Info.Version)
+	}
+	writeResponse(w, r, http.StatusOK, response)
+}
+
+func getChangelog(w http.ResponseWriter, r *http.Request) {
+	writeResponse(w, r, http.StatusOK, openapi.Changelog(schemaVersions))
+}
+
+type sdkResponse struct {
+	Version        string `json:"version"`
+	Language       string `json:"language"`
+	PackageVersion string `json:"package_version"`
+	URL            string `json:"url"`
+}
+
+// listSDKs lists the generated client packages, newest schema version first.
+func listSDKs(w http.ResponseWriter, r *http.Request) {
+	response := []sdkResponse{}
+	for i := len(schemaVersions) - 1; i >= 0; i-- {
+		doc := schemaVersions[i]
+		for _, language := range sdk.Languages {
+			response = append(response, sdkResponse{
+				Version:        doc.Info.Version,
+				Language:       language,
+				PackageVersion: sdk.PackageVersion(doc),
+				URL:            "/meta/sdks/" + doc.Info.Version + "/" + language,
+			})
+		}
+	}
+	writeResponse(w, r, http.StatusOK, response)
+}
+
+// getSDK serves the client package of a schema version as a zip archive.
+func getSDK(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	var doc *openapi.Document
+	for _, version := range schemaVersions {
+		if version.Info.Version == vars["version"] {
+			doc = version
+		}
+	}
+	if doc == nil {
+		httpError(w, "Unknown schema version", http.StatusNotFound)
+		return
+	}
+
+	files, err := sdk.Generate(doc, vars["language"])
+	if errors.Is(err, sdk.ErrUnknownLanguage) {
+		httpError(w, "Unknown language", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	archive, err := sdk.Archive(files)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="rosetta-%s-%s.zip"`, vars["language"], sdk.PackageVersion(doc)))
+	w.Write(archive)
+}
+
+// warnRemovedFields adds a Warning header when a JSON request body carries
+// fields that a newer schema version removed. Such fields are ignored, which
+// old clients would otherwise not notice.
+func warnRemovedFields(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := mux.CurrentRoute(r)
+		if route == nil || r.Body == nil || len(removedFields) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		template, _ := route.GetPathTemplate()
+		schema := latestSchema().RequestSchema(r.Method, template)
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if schema == nil || (mediaType != "application/json" && mediaType != contentTypeMergePatch) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Malformed bodies are the handler's to reject
+		var value interface{}
+		if json.Unmarshal(body, &value) == nil {
+			var found []string
+			findRemovedFields(latestSchema(), schema, value, "", &found)
+			if len(found) > 0 {
+				sort.Strings(found)
+				w.Header().Set("Warning", fmt.Sprintf(`299 rosetta "Ignored fields removed from the API: %s"`, strings.Join(found, ", ")))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func findRemovedFields(doc *openapi.Document, schema *openapi.Schema, value interface{}, prefix string, found *[]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		resolved := doc.Resolve(schema)
+		if resolved == nil {
+			return
+		}
+		for key, child := range v {
+			if property, ok := resolved.Properties[key]; ok {
+				findRemovedFields(doc, property, child, prefix+key+".", found)
+				continue
+			}
+			if version, ok := removedFields[schema.RefName()][key]; ok {
+				*found = append(*found, fmt.Sprintf("%s%s (removed in %s)", prefix, key, version))
+			}
+		}
+	case []interface{}:
+		if schema.Items == nil {
+			return
+		}
+		for _, item := range v {
+			findRemovedFields(doc, schema.Items, item, strings.TrimSuffix(prefix, ".")+"[].", found)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/event"
+
+	"rosetta/metrics"
+)
+
+var (
+	metricsRegistry = metrics.NewRegistry()
+
+	httpRequests = metricsRegistry.Counter("rosetta_http_requests_total",
+		"HTTP requests by route template, method and status.", "route", "method", "status")
+	httpDuration = metricsRegistry.Histogram("rosetta_http_request_duration_seconds",
+		"HTTP request latency by route template and method.", metrics.DefaultBuckets, "route", "method")
+	httpInFlight = metricsRegistry.Gauge("rosetta_http_requests_in_flight",
+		"HTTP requests being served.")
+	mongoDuration = metricsRegistry.Histogram("rosetta_mongo_command_duration_seconds",
+		"MongoDB command latency by command and outcome, ok or error.", metrics.DefaultBuckets, "command", "outcome")
+	s3Requests = metricsRegistry.Counter("rosetta_s3_requests_total",
+		"S3 requests, after retries, by operation and outcome, ok or error.", "operation", "outcome")
+	s3Presigns = metricsRegistry.Counter("rosetta_s3_presigns_total",
+		"Presigned S3 URLs by operation and outcome, ok or error.", "operation", "outcome")
+)
+
+// routeLabelKey holds where the router puts the template of the matched
+// route, for the instrumentation wrapped around it.
+type routeLabelKey struct{}
+
+// unmatchedRoute labels requests no route matched, so scanners probing
+// random paths don't each get series of their own.
+const unmatchedRoute = "unmatched"
+
+// otherMethod labels requests with a method HTTP doesn't define, so clients
+// can't make up series of their own.
+const otherMethod = "OTHER"
+
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions, http.MethodConnect, http.MethodTrace:
+		return method
+	}
+	return otherMethod
+}
+
+// instrumentRequests counts and times every request by route template.
+func instrumentRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		httpInFlight.Add(1)
+		defer httpInFlight.Add(-1)
+
+		route := unmatchedRoute
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), routeLabelKey{}, &route)))
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		method := methodLabel(r.Method)
+		httpRequests.Inc(route, method, strconv.Itoa(status))
+		httpDuration.Observe(time.Since(start).Seconds(), route, method)
+	})
+}
+
+// labelRoute is router middleware passing the matched route template out
+// to instrumentRequests.
+func labelRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route, ok := r.Context().Value(routeLabelKey{}).(*string); ok {
+			if template, err := mux.CurrentRoute(r).GetPathTemplate(); err == nil {
+				*route = template
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// addMongoMetrics times the commands sent to MongoDB.
+func addMongoMetrics(monitor *event.CommandMonitor) {
+	succeeded, failed := monitor.Succeeded, monitor.Failed
+	monitor.Succeeded = func(ctx context.Context, e *event.CommandSucceededEvent) {
+		if succeeded != nil {
+			succeeded(ctx, e)
+		}
+		mongoDuration.Observe(e.Duration.Seconds(), e.CommandName, "ok")
+	}
+	monitor.Failed = func(ctx context.Context, e *event.CommandFailedEvent) {
+		if failed != nil {
+			failed(ctx, e)
+		}
+		mongoDuration.Observe(e.Duration.Seconds(), e.CommandName, "error")
+	}
+}
+
+// addS3Metrics counts S3 requests and presigned URLs. Presigning only signs
+// the request, so it is counted there; sent requests once they complete.
+func addS3Metrics(handlers *request.Handlers) {
+	handlers.Sign.PushBack(func(r *request.Request) {
+		if r.IsPresigned() {
+			s3Presigns.Inc(r.Operation.Name, outcome(r.Error))
+		}
+	})
+	handlers.Complete.PushBack(func(r *request.Request) {
+		s3Requests.Inc(r.Operation.Name, outcome(r.Error))
+	})
+}
+
+func outcome(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
@@ -0,0 +1,265 @@
+// Package metrics keeps counters, gauges and histograms in memory and
+// writes them in the Prometheus text exposition format, for scraping from
+// /metrics. Only what the API records is implemented: labelled counters and
+// histograms, and plain gauges.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultBuckets are latency buckets in seconds, from 5ms to 10s.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type metric interface {
+	write(w io.Writer)
+}
+
+// Registry holds metrics in the order they were registered.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+	names   map[string]bool
+}
+
+func NewRegistry() *Registry {
+	return &Registry{names: map[string]bool{}}
+}
+
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[name] {
+		panic("metrics: " + name + " registered twice")
+	}
+	r.names[name] = true
+	r.metrics = append(r.metrics, m)
+}
+
+// Write writes every metric in the text exposition format.
+func (r *Registry) Write(w io.Writer) {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// Handler serves the metrics to a Prometheus scraper.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Write(w)
+	})
+}
+
+// series keys values by their label values, joined with a separator no
+// label value is expected to contain.
+type series[V any] struct {
+	labels []string
+	mu     sync.Mutex
+	values map[string]V
+	create func() V
+}
+
+func (s *series[V]) with(values []string) V {
+	if len(values) != len(s.labels) {
+		panic(fmt.Sprintf("metrics: %d label values for %d labels", len(values), len(s.labels)))
+	}
+	key := strings.Join(values, "\xff")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.values[key]
+	if !ok {
+		v = s.create()
+		s.values[key] = v
+	}
+	return v
+}
+
+// each calls fn for every series in label order, with the labels formatted
+// for the exposition format.
+func (s *series[V]) each(fn func(labels string, v V)) {
+	s.mu.Lock()
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	values := make(map[string]V, len(s.values))
+	for key, v := range s.values {
+		values[key] = v
+	}
+	s.mu.Unlock()
+
+	sort.Strings(keys)
+	for _, key := range keys {
+		fn(formatLabels(s.labels, strings.Split(key, "\xff")), values[key])
+	}
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + labelEscaper.Replace(values[i]) + `"`
+	}
+	return strings.Join(pairs, ",")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func writeSample(w io.Writer, name, labels string, value float64) {
+	if labels != "" {
+		name += "{" + labels + "}"
+	}
+	fmt.Fprintf(w, "%s %s\n", name, formatFloat(value))
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// atomicFloat is a float64 updated without locks.
+type atomicFloat struct {
+	bits atomic.Uint64
+}
+
+func (f *atomicFloat) add(delta float64) {
+	for {
+		old := f.bits.Load()
+		if f.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+func (f *atomicFloat) load() float64 {
+	return math.Float64frombits(f.bits.Load())
+}
+
+// Counter counts events, split by its labels.
+type Counter struct {
+	name, help string
+	series     series[*atomicFloat]
+}
+
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	c := &Counter{name: name, help: help, series: series[*atomicFloat]{
+		labels: labels,
+		values: map[string]*atomicFloat{},
+		create: func() *atomicFloat { return &atomicFloat{} },
+	}}
+	r.register(name, c)
+	return c
+}
+
+// Inc counts one event with the given label values, in label order.
+func (c *Counter) Inc(values ...string) {
+	c.series.with(values).add(1)
+}
+
+func (c *Counter) write(w io.Writer) {
+	writeHeader(w, c.name, c.help, "counter")
+	c.series.each(func(labels string, v *atomicFloat) {
+		writeSample(w, c.name, labels, v.load())
+	})
+}
+
+// Gauge is a value that goes up and down.
+type Gauge struct {
+	name, help string
+	value      atomicFloat
+}
+
+func (r *Registry) Gauge(name, help string) *Gauge {
+	g := &Gauge{name: name, help: help}
+	r.register(name, g)
+	return g
+}
+
+func (g *Gauge) Add(delta float64) {
+	g.value.add(delta)
+}
+
+func (g *Gauge) write(w io.Writer) {
+	writeHeader(w, g.name, g.help, "gauge")
+	writeSample(w, g.name, "", g.value.load())
+}
+
+// Histogram counts observations into buckets, split by its labels.
+type Histogram struct {
+	name, help string
+	buckets    []float64
+	series     series[*histogramSeries]
+}
+
+type histogramSeries struct {
+	counts []atomic.Uint64 // per bucket, not cumulative; the last is +Inf
+	count  atomic.Uint64
+	sum    atomicFloat
+}
+
+// Histogram registers a histogram with the given upper bucket bounds, in
+// increasing order.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{name: name, help: help, buckets: buckets}
+	h.series = series[*histogramSeries]{
+		labels: labels,
+		values: map[string]*histogramSeries{},
+		create: func() *histogramSeries {
+			return &histogramSeries{counts: make([]atomic.Uint64, len(buckets)+1)}
+		},
+	}
+	r.register(name, h)
+	return h
+}
+
+// Observe records v with the given label values, in label order.
+func (h *Histogram) Observe(v float64, values ...string) {
+	s := h.series.with(values)
+	s.counts[sort.SearchFloat64s(h.buckets, v)].Add(1)
+	s.count.Add(1)
+	s.sum.add(v)
+}
+
+func (h *Histogram) write(w io.Writer) {
+	writeHeader(w, h.name, h.help, "histogram")
+	h.series.each(func(labels string, s *histogramSeries) {
+		sep := ""
+		if labels != "" {
+			sep = ","
+		}
+		var cumulative uint64
+		for i := range s.counts {
+			cumulative += s.counts[i].Load()
+			bound := math.Inf(1)
+			if i < len(h.buckets) {
+				bound = h.buckets[i]
+			}
+			writeSample(w, h.name+"_bucket", labels+sep+`le="`+formatFloat(bound)+`"`, float64(cumulative))
+		}
+		writeSample(w, h.name+"_sum", labels, s.sum.load())
+		writeSample(w, h.name+"_count", labels, float64(s.count.Load()))
+	})
+}
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExpositionFormat(t *testing.T) {
+	r := NewRegistry()
+	requests := r.Counter("test_requests_total", "Requests by route and status.", "route", "status")
+	inFlight := r.Gauge("test_in_flight", "Requests being served.")
+	duration := r.Histogram("test_duration_seconds", "Request latency.", []float64{0.1, 1}, "route")
+
+	requests.Inc("/b", "200")
+	requests.Inc("/a", "500")
+	requests.Inc("/a", "500")
+	requests.Inc(`say "hi"\`+"\n", "200")
+	inFlight.Add(3)
+	inFlight.Add(-1)
+	duration.Observe(0.05, "/a")
+	duration.Observe(0.1, "/a")
+	duration.Observe(0.5, "/a")
+	duration.Observe(2, "/a")
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if got := w.Header().Get("Content-Type"); got != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("Content-Type = %q", got)
+	}
+
+	want := `# HELP test_requests_total Requests by route and status.
+# TYPE test_requests_total counter
+test_requests_total{route="/a",status="500"} 2
+test_requests_total{route="/b",status="200"} 1
+test_requests_total{route="say \"hi\"\\\n",status="200"} 1
+# HELP test_in_flight Requests being served.
+# TYPE test_in_flight gauge
+test_in_flight 2
+# HELP test_duration_seconds Request latency.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{route="/a",le="0.1"} 2
+test_duration_seconds_bucket{route="/a",le="1"} 3
+test_duration_seconds_bucket{route="/a",le="+Inf"} 4
+test_duration_seconds_sum{route="/a"} 2.65
+test_duration_seconds_count{route="/a"} 4
+`
+	if got := w.Body.String(); got != want {
+		t.Errorf("exposition:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRegisterTwicePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a name twice didn't panic")
+		}
+	}()
+	r := NewRegistry()
+	r.Gauge("test_gauge", "A gauge.")
+	r.Gauge("test_gauge", "The same gauge.")
+}
+
+func TestLabelCountMismatchPanics(t *testing.T) {
+	c := NewRegistry().Counter("test_total", "A counter.", "route")
+	defer func() {
+		if recover() == nil {
+			t.Error("wrong number of label values didn't panic")
+		}
+	}()
+	c.Inc("/a", "extra")
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestMethodLabel checks made-up methods share one series.
+func TestMethodLabel(t *testing.T) {
+	handler := instrumentRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, method := range []string{"GET", "FROBNICATE", "X-RANDOM-1"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/", nil))
+	}
+
+	var out strings.Builder
+	metricsRegistry.Write(&out)
+	if strings.Contains(out.String(), "FROBNICATE") || strings.Contains(out.String(), "X-RANDOM-1") {
+		t.Error("made-up method got a series of its own")
+	}
+	for _, label := range []string{`method="GET"`, `method="OTHER"`} {
+		if !strings.Contains(out.String(), label) {
+			t.Errorf("no series with %s", label)
+		}
+	}
+}
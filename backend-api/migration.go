@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"rosetta/models"
+)
+
+// s3MigrationBucket is the bucket media is being moved to. While it is set,
+// new media is written there, media is read from whichever bucket has it and
+// runMediaMigration copies the rest over. Once no story refers to the old
+// bucket, S3_BUCKET can be switched to the new one.
+var s3MigrationBucket string
+
+const (
+	migrationPollInterval = time.Minute
+	migrationBatchSize    = 100
+)
+
+// Counts of this instance's copier since it started
+var migrationStats struct {
+	copied  atomic.Int64
+	flipped atomic.Int64
+	failed  atomic.Int64
+}
+
+// writeBucket is the bucket new media goes to.
+func writeBucket() string {
+	if s3MigrationBucket != "" {
+		return s3MigrationBucket
+	}
+	return s3Bucket
+}
+
+// mediaBuckets lists the buckets media may be in, the one written to first.
+func mediaBuckets() []string {
+	if s3MigrationBucket != "" {
+		return []string{s3MigrationBucket, s3Bucket}
+	}
+	return []string{s3Bucket}
+}
+
+// headMedia looks key up in each media bucket and returns the first that has
+// it. Mid-migration a key may be in either.
+func headMedia(ctx context.Context, key string) (string, *s3.HeadObjectOutput, error) {
+	var err error
+	for _, bucket := range mediaBuckets() {
+		var head *s3.HeadObjectOutput
+		head, err = s3Client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+		})
+		if err == nil {
+			return bucket, head, nil
+		}
+		if !isNotFound(err) {
+			return "", nil, err
+		}
+	}
+	return "", nil, err
+}
+
+// deleteMedia deletes key from every media bucket.
+func deleteMedia(ctx context.Context, key string) error {
+	for _, bucket := range mediaBuckets() {
+		_, err := s3Client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unmigratedFilter matches stories with media still in the old bucket.
+func unmigratedFilter() bson.M {
+	prefix := primitive.Regex{Pattern: "^" + regexp.QuoteMeta(bucketMediaURL(s3Bucket, ""))}
+	return bson.M{"$or": bson.A{
+		bson.M{"segments.audio.url": prefix},
+		bson.M{"segments.audio.original_url": prefix},
+		bson.M{"segments.image.url": prefix},
+		bson.M{"audio_tracks.url": prefix},
+	}}
+}
+
+// runMediaMigration copies media from the old bucket to the migration target
+// until ctx is done. Every instance runs it; copies and reference flips are
+// idempotent, so overlapping passes only repeat work.
+func runMediaMigration(ctx context.Context) {
+	ticker := time.NewTicker(migrationPollInterval)
+	defer ticker.Stop()
+	for {
+		if err := migrateMedia(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("media migration: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// migrateMedia makes one pass over the stories with media in the old bucket.
+// Failed objects are logged and retried on the next pass.
+func migrateMedia(ctx context.Context) error {
+	after := primitive.NilObjectID
+	for ctx.Err() == nil {
+		filter := unmigratedFilter()
+		filter["_id"] = bson.M{"$gt": after}
+		opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(migrationBatchSize)
+		cursor, err := storiesCollection().Find(ctx, filter, opts)
+		if err != nil {
+			return err
+		}
+		var stories []models.Story
+		if err = cursor.All(ctx, &stories); err != nil {
+			return err
+		}
+		if len(stories) == 0 {
+			return nil
+		}
+
+		for i := range stories {
+			if err = migrateStoryMedia(ctx, &stories[i]); err != nil {
+				migrationStats.failed.Add(1)
+				log.Printf("media migration of story %s: %v", stories[i].ID.Hex(), err)
+			}
+		}
+		after = stories[len(stories)-1].ID
+	}
+	return ctx.Err()
+}
+
+func migrateStoryMedia(ctx context.Context, story *models.Story) error {
+	flipped := false
+	for _, segment := range story.Segments {
+		if segment.Audio != nil {
+			ok, err := migrateMediaURL(ctx, story.ID, "segments", segment.ID, "audio.url", segment.Audio.Url)
+			if err != nil {
+				return err
+			}
+			flipped = flipped || ok
+			if segment.Audio.OriginalUrl != "" {
+				ok, err = migrateMediaURL(ctx, story.ID, "segments", segment.ID, "audio.original_url", segment.Audio.OriginalUrl)
+				if err != nil {
+					return err
+				}
+				flipped = flipped || ok
+			}
+		}
+		if segment.Image != nil {
+			ok, err := migrateMediaURL(ctx, story.ID, "segments", segment.ID, "image.url", segment.Image.Url)
+			if err != nil {
+				return err
+			}
+			flipped = flipped || ok
+		}
+	}
+	for _, track := range story.AudioTracks {
+		ok, err := migrateMediaURL(ctx, story.ID, "audio_tracks", track.ID, "url", track.Url)
+		if err != nil {
+			return err
+		}
+		flipped = flipped || ok
+	}
+
+	// Copies of multipart uploads get new ETags, which the hash is made of
+	if flipped && story.IsPublished {
+		return refreshContentHash(ctx, story.ID)
+	}
+	return nil
+}
+
+// migrateMediaURL copies the object behind url to the migration target and,
+// once the copy checks out, points the segment or audio track at it: the
+// element of array with the given ID, at field. It reports whether the
+// reference was flipped.
+func migrateMediaURL(ctx context.Context, storyID primitive.ObjectID, array string, id primitive.ObjectID, field, url string) (bool, error) {
+	bucket, key, ok := mediaLocation(url)
+	if !ok || bucket != s3Bucket {
+		return false, nil
+	}
+	if err := copyToMigrationBucket(ctx, key); err != nil {
+		return false, fmt.Errorf("copy %s: %w", key, err)
+	}
+
+	// Only flip if the element still points at what was copied; a new
+	// upload in the meantime went to the target already
+	filter := bson.M{
+		"_id": storyID,
+		array: bson.M{"$elemMatch": bson.M{"_id": id, field: url}},
+	}
+	update := bson.M{"$set": bson.M{array + ".$." + field: bucketMediaURL(s3MigrationBucket, key)}}
+	res, err := storiesCollection().UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	migrationStats.flipped.Add(res.ModifiedCount)
+	return res.ModifiedCount > 0, nil
+}
+
+// copyToMigrationBucket copies key from the old bucket to the target unless
+// an identical copy is there already, and verifies the result.
+func copyToMigrationBucket(ctx context.Context, key string) error {
+	source, err := s3Client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s3Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return err
+	}
+
+	target, err := s3Client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s3MigrationBucket),
+		Key:    aws.String(key),
+	})
+	if err == nil && sameObject(source, target) {
+		return nil
+	}
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+
+	input := &s3.CopyObjectInput{
+		Bucket:     aws.String(s3MigrationBucket),
+		Key:        aws.String(key),
+		CopySource: aws.String(s3Bucket + "/" + key),
+	}
+	encryptCopy(input)
+	if _, err = s3Client.CopyObjectWithContext(ctx, input); err != nil {
+		return err
+	}
+
+	target, err = s3Client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s3MigrationBucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return err
+	}
+	if !sameObject(source, target) {
+		return errors.New("copy does not match the original")
+	}
+	migrationStats.copied.Add(1)
+	return nil
+}
+
+func sameObject(a, b *s3.HeadObjectOutput) bool {
+	return aws.Int64Value(a.ContentLength) == aws.Int64Value(b.ContentLength) &&
+		aws.StringValue(a.ContentType) == aws.StringValue(b.ContentType)
+}
+
+type migrationStatus struct {
+	Active           bool   `json:"active"`
+	SourceBucket     string `json:"source_bucket"`
+	TargetBucket     string `json:"target_bucket,omitempty"`
+	RemainingStories int64  `json:"remaining_stories"`
+	Copied           int64  `json:"copied"`
+	Flipped          int64  `json:"flipped"`
+	Failed           int64  `json:"failed"`
+}
+
+// getMigrationStatus reports how many stories still refer to the old bucket.
+// The counters are this instance's.
+func getMigrationStatus(w http.ResponseWriter, r *http.Request) {
+	status := migrationStatus{
+		Active:       s3MigrationBucket != "",
+		SourceBucket: s3Bucket,
+		TargetBucket: s3MigrationBucket,
+		Copied:       migrationStats.copied.Load(),
+		Flipped:      migrationStats.flipped.Load(),
+		Failed:       migrationStats.failed.Load(),
+	}
+	if status.Active {
+		remaining, err := storiesCollection().CountDocuments(r.Context(), unmigratedFilter())
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		status.RemainingStories = remaining
+	}
+
+	writeResponse(w, r, http.StatusOK, status)
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Appeal struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	StoryID   primitive.ObjectID `bson:"story_id"`
+	Message   string             `bson:"message"`
+	Status    string             `bson:"status"`
+	Note      string             `bson:"note,omitempty"`
+	CreatedAt time.Time          `bson:"created_at"`
+	DecidedAt *time.Time         `bson:"decided_at,omitempty"`
+}
+
+// Appeals start pending and are decided exactly once.
+const (
+	AppealPending    = "pending"
+	AppealUpheld     = "upheld"
+	AppealOverturned = "overturned"
+)
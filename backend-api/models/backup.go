@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DraftBackup is a user's encrypted copy of their local editor state for a
+// story. The client encrypts it with a key the server never sees, so the
+// ciphertext is stored and returned as is.
+type DraftBackup struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	UserID     primitive.ObjectID `bson:"user_id" json:"-"`
+	StoryID    primitive.ObjectID `bson:"story_id" json:"story_id"`
+	Ciphertext []byte             `bson:"ciphertext" json:"ciphertext"`
+	KeyHint    string             `bson:"key_hint,omitempty" json:"key_hint,omitempty"`
+	UpdatedAt  time.Time          `bson:"updated_at" json:"updated_at"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BlockedWord extends the built-in profanity lists. An empty Language applies
+// the word to every language.
+type BlockedWord struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Word     string             `bson:"word" json:"word"`
+	Language string             `bson:"language,omitempty" json:"language,omitempty"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MediaCleanup is a deletion of the media objects under Prefix, as queued
+// before media cleanups became jobs of the job queue. Those left over are
+// moved onto it at startup.
+type MediaCleanup struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	StoryID       primitive.ObjectID `bson:"story_id" json:"story_id"`
+	Prefix        string             `bson:"prefix" json:"prefix"`
+	Attempts      int                `bson:"attempts" json:"attempts"`
+	NextAttemptAt time.Time          `bson:"next_attempt_at" json:"next_attempt_at"`
+	LastError     string             `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	GaveUp        bool               `bson:"gave_up,omitempty" json:"gave_up,omitempty"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FeaturedStory is the daily story of a language on a date: an editorial
+// override, or the story picked on the day's first request. Picks are kept
+// per content filter, since a filter may rule out the unfiltered pick.
+type FeaturedStory struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	Date     string             `bson:"date" json:"date"`
+	Language string             `bson:"language" json:"language"`
+	StoryID  primitive.ObjectID `bson:"story_id" json:"story_id"`
+	Picked   bool               `bson:"picked,omitempty" json:"-"`
+	Filter   string             `bson:"filter,omitempty" json:"-"`
+}
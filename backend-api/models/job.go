@@ -0,0 +1,38 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Job is an operation that outlives the request that started it. Progress
+// and the result are saved as JSON as it goes, so any instance can answer
+// a poll.
+type Job struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Kind        string             `bson:"kind" json:"kind"`
+	Status      string             `bson:"status" json:"status"`
+	Progress    json.RawMessage    `bson:"progress,omitempty" json:"progress,omitempty"`
+	Result      json.RawMessage    `bson:"result,omitempty" json:"result,omitempty"`
+	Error       string             `bson:"error,omitempty" json:"error,omitempty"`
+	CallbackURL string             `bson:"callback_url,omitempty" json:"callback_url,omitempty"`
+	// Who may poll the job: the user who started it, or admins for jobs
+	// started from admin routes
+	OwnerID    primitive.ObjectID `bson:"owner_id,omitempty" json:"-"`
+	Admin      bool               `bson:"admin,omitempty" json:"-"`
+	StartedAt  time.Time          `bson:"started_at" json:"started_at"`
+	UpdatedAt  time.Time          `bson:"updated_at" json:"updated_at"`
+	FinishedAt *time.Time         `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+}
+
+const (
+	// JobQueued is waiting for a worker of the durable queue, to start or
+	// to be retried
+	JobQueued      = "queued"
+	JobRunning     = "running"
+	JobCompleted   = "completed"
+	JobFailed      = "failed"
+	JobInterrupted = "interrupted"
+)
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// EditLock is an advisory lock telling other editors who is working on a
+// story. Nothing enforces it; it lapses unless renewed before ExpiresAt.
+type EditLock struct {
+	Holder     string    `bson:"holder"`
+	AcquiredAt time.Time `bson:"acquired_at"`
+	ExpiresAt  time.Time `bson:"expires_at"`
+}
+
+// AuditEntry records an action that overrode someone else's work. Actor is
+// the signed-in user who did it; Holder and Previous are the lock holders
+// as the editors named themselves, so they only tell tabs apart.
+type AuditEntry struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	StoryID   primitive.ObjectID `bson:"story_id"`
+	Action    string             `bson:"action"`
+	Actor     string             `bson:"actor"`
+	Holder    string             `bson:"holder,omitempty"`
+	Previous  string             `bson:"previous,omitempty"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+const AuditLockTakeover = "lock.takeover"
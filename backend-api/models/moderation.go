@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ModerationAction is an entry in the append-only log of moderation
+// decisions, kept for transparency reporting.
+type ModerationAction struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	StoryID   primitive.ObjectID `bson:"story_id"`
+	Action    string             `bson:"action"`
+	Reasons   []string           `bson:"reasons,omitempty"`
+	Automated bool               `bson:"automated"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+const (
+	ModerationActionFlag  = "flag"
+	ModerationActionHide  = "hide"
+	ModerationActionClear = "clear"
+	// Appeal outcomes
+	ModerationActionUphold  = "uphold"
+	ModerationActionRestore = "restore"
+)
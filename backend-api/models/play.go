@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// StoryPlays counts the public reads of a story on one day (UTC) that came
+// from one source and, for embeds and links, one origin host.
+type StoryPlays struct {
+	ID      primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	StoryID primitive.ObjectID `bson:"story_id" json:"story_id"`
+	Day     time.Time          `bson:"day" json:"day"`
+	Source  string             `bson:"source" json:"source"`
+	Origin  string             `bson:"origin" json:"origin,omitempty"`
+	Count   int64              `bson:"count" json:"count"`
+}
+
+// Where a play came from: the app itself, a player embedded in another
+// site, a shared link followed from another site, or nowhere known.
+const (
+	PlaySourceApp    = "app"
+	PlaySourceEmbed  = "embed"
+	PlaySourceLink   = "link"
+	PlaySourceDirect = "direct"
+)
+
+var PlaySources = []string{PlaySourceApp, PlaySourceEmbed, PlaySourceLink, PlaySourceDirect}
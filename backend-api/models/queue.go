@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// QueuedJob is background work waiting in the job queue, such as deleting
+// the media of a deleted story. Unlike a Job, nobody polls it: it is retried
+// until it succeeds or runs out of attempts, when it is kept as dead for an
+// operator to look into.
+type QueuedJob struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Kind          string             `bson:"kind" json:"kind"`
+	Payload       bson.M             `bson:"payload" json:"payload"`
+	Status        string             `bson:"status" json:"status"`
+	Attempts      int                `bson:"attempts" json:"attempts"`
+	NextAttemptAt time.Time          `bson:"next_attempt_at" json:"next_attempt_at"`
+	LastError     string             `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+	DeadAt        *time.Time         `bson:"dead_at,omitempty" json:"dead_at,omitempty"`
+}
+
+const (
+	QueuedJobPending = "pending"
+	QueuedJobDead    = "dead"
+)
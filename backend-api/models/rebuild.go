@@ -0,0 +1,9 @@
+package models
+
+// RebuildProgress is how far a rebuild job got with one target.
+type RebuildProgress struct {
+	Total     int64 `bson:"total" json:"total"`
+	Processed int64 `bson:"processed" json:"processed"`
+	Updated   int64 `bson:"updated" json:"updated"`
+	Failed    int64 `bson:"failed" json:"failed"`
+}
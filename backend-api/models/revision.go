@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// StoryRevision is a snapshot of a story as of one version. Revisions are
+// the merge base for updates made against an older version.
+type StoryRevision struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	StoryID   primitive.ObjectID `bson:"story_id"`
+	Version   int64              `bson:"version"`
+	Story     Story              `bson:"story"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
@@ -7,28 +7,123 @@ import (
 )
 
 type Story struct {
-	ID          primitive.ObjectID `bson:"_id,omitempty"`
-	Title       string             `bson:"title"`
-	Segments    []Segment          `bson:"segments"`
-	CreatedAt   time.Time          `bson:"created_at"`
-	IsPublished bool               `bson:"is_published"`
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Title           string             `bson:"title" json:"title"`
+	Language        string             `bson:"language" json:"language"`
+	Segments        []Segment          `bson:"segments" json:"segments"`
+	Characters      []Character        `bson:"characters,omitempty" json:"characters,omitempty"`
+	AudioTracks     []AudioTrack       `bson:"audio_tracks,omitempty" json:"audio_tracks,omitempty"`
+	CreatedAt       time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt       time.Time          `bson:"updated_at" json:"updated_at"`
+	IsPublished     bool               `bson:"is_published" json:"is_published"`
+	Visibility      string             `bson:"visibility,omitempty" json:"visibility,omitempty"`
+	AgeRating       string             `bson:"age_rating,omitempty" json:"age_rating,omitempty"`
+	ContentWarnings []string           `bson:"content_warnings,omitempty" json:"content_warnings,omitempty"`
+	Metadata        map[string]string  `bson:"metadata,omitempty" json:"metadata,omitempty"`
+	Version         int64              `bson:"version" json:"version"`
+	OwnerID         primitive.ObjectID `bson:"owner_id,omitempty" json:"owner_id,omitempty"`
+	PublishedAt     *time.Time         `bson:"published_at,omitempty" json:"published_at,omitempty"`
+
+	ContentFingerprint string      `bson:"content_fingerprint,omitempty" json:"-"`
+	ContentHash        string      `bson:"content_hash,omitempty" json:"content_hash,omitempty"`
+	Moderation         *Moderation `bson:"moderation,omitempty" json:"-"`
+	Lock               *EditLock   `bson:"lock,omitempty" json:"-"`
+}
+
+// Moderation is set on stories awaiting review. Hidden stories are kept out
+// of feeds and public reads without telling the author.
+type Moderation struct {
+	Status    string    `bson:"status" json:"status"`
+	Score     float64   `bson:"score" json:"score"`
+	Reasons   []string  `bson:"reasons" json:"reasons"`
+	FlaggedAt time.Time `bson:"flagged_at" json:"flagged_at"`
 }
 
+const (
+	ModerationFlagged = "flagged"
+	ModerationHidden  = "hidden"
+)
+
+// Published stories are listed in feeds unless they are unlisted, in which
+// case they are only reachable by direct link. An empty visibility is public.
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+)
+
+// AgeRatings lists the accepted ratings from least to most restrictive.
+var AgeRatings = []string{"all", "7+", "13+", "16+", "18+"}
+
+var KnownContentWarnings = []string{"violence", "strong_language", "sexual_content", "drugs", "frightening", "self_harm"}
+
 type Segment struct {
-	ID     primitive.ObjectID `bson:"_id,omitempty"`
-	Audio  *Audio             `bson:"audio,omitempty"`
-	Image  *Image             `bson:"image,omitempty"`
-	Script *Script            `bson:"script,omitempty"`
+	ID      primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Audio   *Audio             `bson:"audio,omitempty" json:"audio,omitempty"`
+	Image   *Image             `bson:"image,omitempty" json:"image,omitempty"`
+	Script  *Script            `bson:"script,omitempty" json:"script,omitempty"`
+	Speaker string             `bson:"speaker,omitempty" json:"speaker,omitempty"`
+	Version int64              `bson:"version" json:"version"`
+	// Clip plays part of a shared audio track instead of audio of its own
+	Clip *AudioClip `bson:"clip,omitempty" json:"clip,omitempty"`
+}
+
+type Character struct {
+	Name  string `bson:"name" json:"name"`
+	Color string `bson:"color,omitempty" json:"color,omitempty"`
+	Voice string `bson:"voice,omitempty" json:"voice,omitempty"`
 }
 
 type Audio struct {
-	Url string `bson:"url,omitempty"`
+	Url        string `bson:"url,omitempty" json:"url,omitempty"`
+	Key        string `bson:"key,omitempty" json:"-"`
+	PendingKey string `bson:"pending_key,omitempty" json:"-"`
+
+	// Recorded when an upload completes; unset for external URLs
+	Size        int64  `bson:"size,omitempty" json:"size,omitempty"`
+	ContentType string `bson:"content_type,omitempty" json:"content_type,omitempty"`
+	DurationMs  int64  `bson:"duration_ms,omitempty" json:"duration_ms,omitempty"`
+
+	// The upload as it was, once Url plays its transcoded copy
+	OriginalUrl string `bson:"original_url,omitempty" json:"original_url,omitempty"`
+	OriginalKey string `bson:"original_key,omitempty" json:"-"`
+
+	// Problems found in the upload, see audioquality
+	Warnings []string `bson:"warnings,omitempty" json:"warnings,omitempty"`
+}
+
+// AudioTrack is a recording shared by several segments, such as narration
+// recorded in one take. Each segment plays the range of it its clip sets.
+type AudioTrack struct {
+	ID    primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Audio `bson:",inline"`
+}
+
+// AudioClip is the part of an audio track a segment plays, in milliseconds
+// from the start of the track.
+type AudioClip struct {
+	TrackID primitive.ObjectID `bson:"track_id" json:"track_id"`
+	StartMs int64              `bson:"start_ms" json:"start_ms"`
+	EndMs   int64              `bson:"end_ms" json:"end_ms"`
 }
 
 type Image struct {
-	Url string `bson:"url,omitempty"`
+	Url        string `bson:"url,omitempty" json:"url,omitempty"`
+	Key        string `bson:"key,omitempty" json:"-"`
+	PendingKey string `bson:"pending_key,omitempty" json:"-"`
 }
 
+// Script is the text of a segment in the language it was written in, with
+// any translations keyed by language tag.
 type Script struct {
-	Text string `bson:"text"`
+	Text string `bson:"text" json:"text"`
+	// Language is the original language of Text when it differs from the
+	// story's, e.g. a line of dialogue quoted in another language
+	Language     string            `bson:"language,omitempty" json:"language,omitempty"`
+	Translations map[string]string `bson:"translations,omitempty" json:"translations,omitempty"`
+}
+
+type DeletedStory struct {
+	StoryID   primitive.ObjectID `bson:"story_id" json:"story_id"`
+	OwnerID   primitive.ObjectID `bson:"owner_id,omitempty" json:"owner_id,omitempty"`
+	DeletedAt time.Time          `bson:"deleted_at" json:"deleted_at"`
 }
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/domain"
+	"rosetta/models"
+	"rosetta/spam"
+)
+
+var spamClassifier spam.Classifier = spam.Heuristics{MaxCreationsPerHour: 10, MaxLinkDensity: 0.2}
+
+// creationLog remembers when each client created stories during the last hour.
+type creationLog struct {
+	mu    sync.Mutex
+	times map[string][]time.Time
+}
+
+var recentCreations = &creationLog{times: map[string][]time.Time{}}
+
+func (c *creationLog) record(key string) int {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	kept := c.times[key][:0]
+	for _, t := range c.times[key] {
+		if now.Sub(t) < time.Hour {
+			kept = append(kept, t)
+		}
+	}
+	c.times[key] = append(kept, now)
+	return len(c.times[key])
+}
+
+func storyFingerprint(story *models.Story) string {
+	parts := []string{story.Title}
+	for _, segment := range story.Segments {
+		if segment.Script != nil {
+			parts = append(parts, segment.Script.Text)
+		}
+	}
+	return spam.Fingerprint(parts...)
+}
+
+// assessSpam scores a story the given client is about to create. Suspected
+// spam is never rejected: it is flagged for the moderation queue, and hidden
+// from feeds when the score is high enough.
+func assessSpam(ctx context.Context, clientKey string, story *models.Story) error {
+	story.ContentFingerprint = storyFingerprint(story)
+	duplicates, err := storiesCollection().CountDocuments(ctx, bson.M{"content_fingerprint": story.ContentFingerprint})
+	if err != nil {
+		return err
+	}
+
+	var text []string
+	for _, segment := range story.Segments {
+		if segment.Script != nil {
+			text = append(text, segment.Script.Text)
+		}
+	}
+
+	signals, err := spamClassifier.Classify(ctx, spam.Input{
+		Title:           story.Title,
+		Text:            strings.Join(text, "\n"),
+		RecentCreations: recentCreations.record(clientKey),
+		Duplicates:      int(duplicates),
+	})
+	if err != nil {
+		return err
+	}
+
+	score := spam.Score(signals)
+	if score < spam.FlagScore {
+		return nil
+	}
+
+	moderation := &models.Moderation{Status: models.ModerationFlagged, Score: score, FlaggedAt: time.Now()}
+	if score >= spam.HideScore {
+		moderation.Status = models.ModerationHidden
+	}
+	for _, s := range signals {
+		moderation.Reasons = append(moderation.Reasons, s.Name+": "+s.Detail)
+	}
+	story.Moderation = moderation
+	return nil
+}
+
+type moderationQueueItem struct {
+	StoryID     primitive.ObjectID `json:"story_id"`
+	Title       string             `json:"title"`
+	Status      string             `json:"status"`
+	Score       float64            `json:"score"`
+	Reasons     []string           `json:"reasons"`
+	FlaggedAt   time.Time          `json:"flagged_at"`
+	IsPublished bool               `json:"is_published"`
+}
+
+func getModerationQueue(w http.ResponseWriter, r *http.Request) {
+	filter := bson.M{"moderation.status": bson.M{"$in": bson.A{models.ModerationFlagged, models.ModerationHidden}}}
+	cursor, err := storiesCollection().Find(r.Context(), filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var stories []models.Story
+	if err = cursor.All(r.Context(), &stories); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	queue := make([]moderationQueueItem, 0, len(stories))
+	for _, story := range stories {
+		queue = append(queue, moderationQueueItem{
+			StoryID:     story.ID,
+			Title:       story.Title,
+			Status:      story.Moderation.Status,
+			Score:       story.Moderation.Score,
+			Reasons:     story.Moderation.Reasons,
+			FlaggedAt:   story.Moderation.FlaggedAt,
+			IsPublished: story.IsPublished,
+		})
+	}
+
+	writeResponse(w, r, http.StatusOK, queue)
+}
+
+// clearModerationFlag marks a queued story as reviewed and not spam.
+func clearModerationFlag(w http.ResponseWriter, r *http.Request) {
+	objectID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, "Invalid story ID", http.StatusBadRequest)
+		return
+	}
+
+	filter := bson.M{"_id": objectID, "moderation": bson.M{"$exists": true}}
+	res, err := storiesCollection().UpdateOne(r.Context(), filter, bson.M{"$unset": bson.M{"moderation": ""}})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if res.MatchedCount == 0 {
+		writeError(w, domain.New(domain.ErrNotFound, "No flagged story with this ID"))
+		return
+	}
+
+	err = recordModerationAction(r.Context(), objectID, models.ModerationActionClear, nil, false)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func isHidden(story *models.Story) bool {
+	return story.Moderation != nil && story.Moderation.Status == models.ModerationHidden
+}
+
+func recordModerationAction(ctx context.Context, storyID primitive.ObjectID, action string, reasons []string, automated bool) error {
+	_, err := moderationActionsCollection().InsertOne(ctx, models.ModerationAction{
+		StoryID:   storyID,
+		Action:    action,
+		Reasons:   reasons,
+		Automated: automated,
+		CreatedAt: time.Now(),
+	})
+	return err
+}
+
+type moderationReport struct {
+	From      time.Time      `json:"from"`
+	To        time.Time      `json:"to"`
+	Total     int            `json:"total"`
+	Automated int            `json:"automated"`
+	ByAction  map[string]int `json:"by_action"`
+	ByReason  map[string]int `json:"by_reason"`
+	Actions   []reportAction `json:"actions,omitempty"`
+}
+
+type reportAction struct {
+	StoryID   primitive.ObjectID `json:"story_id"`
+	Action    string             `json:"action"`
+	Reasons   []string           `json:"reasons"`
+	Automated bool               `json:"automated"`
+	CreatedAt time.Time          `json:"created_at"`
+}
+
+// getModerationReport summarises moderation actions taken in [from, to) for
+// transparency reporting. Pass details=true to include every action.
+func getModerationReport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	to := time.Now()
+	from := to.AddDate(0, -1, 0)
+	var err error
+	if v := query.Get("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			httpError(w, "Invalid from, expected RFC 3339", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			httpError(w, "Invalid to, expected RFC 3339", http.StatusBadRequest)
+			return
+		}
+	}
+
+	filter := bson.M{"created_at": bson.M{"$gte": from, "$lt": to}}
+	cursor, err := moderationActionsCollection().Find(r.Context(), filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var actions []models.ModerationAction
+	if err = cursor.All(r.Context(), &actions); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	report := moderationReport{
+		From:     from,
+		To:       to,
+		Total:    len(actions),
+		ByAction: map[string]int{},
+		ByReason: map[string]int{},
+	}
+	details := query.Get("details") == "true"
+	for _, action := range actions {
+		report.ByAction[action.Action]++
+		if action.Automated {
+			report.Automated++
+		}
+		for _, reason := range action.Reasons {
+			// Reasons read "signal: detail"; group by the signal only
+			name, _, _ := strings.Cut(reason, ":")
+			report.ByReason[name]++
+		}
+		if details {
+			report.Actions = append(report.Actions, reportAction{
+				StoryID:   action.StoryID,
+				Action:    action.Action,
+				Reasons:   action.Reasons,
+				Automated: action.Automated,
+				CreatedAt: action.CreatedAt,
+			})
+		}
+	}
+
+	writeResponse(w, r, http.StatusOK, report)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/api"
+	"rosetta/domain"
+	"rosetta/tts"
+)
+
+const (
+	queueKindGenerateAudio = "generate_audio"
+	maxNarrationAttempts   = 5
+)
+
+// speechSynthesizer narrates scripts for authors without a recording; nil
+// while TTS_PROVIDER is off.
+var speechSynthesizer tts.Synthesizer
+
+// narrationPayload is the payload of generate_audio jobs.
+type narrationPayload struct {
+	StoryID   primitive.ObjectID `bson:"story_id"`
+	SegmentID primitive.ObjectID `bson:"segment_id"`
+	Text      string             `bson:"text"`
+	Language  string             `bson:"language"`
+}
+
+// generateSegmentAudio narrates a segment's script with the configured TTS
+// provider. Synthesis can take a while, so it runs as a queued job; once it
+// is done the audio is the segment's, the same as an upload confirmed by
+// the client.
+func generateSegmentAudio(w http.ResponseWriter, r *http.Request) {
+	if speechSynthesizer == nil {
+		httpError(w, "Audio generation is not enabled", http.StatusNotImplemented)
+		return
+	}
+
+	vars := mux.Vars(r)
+	storyID, err := primitive.ObjectIDFromHex(vars["storyId"])
+	if err != nil {
+		httpError(w, "Invalid story ID", http.StatusBadRequest)
+		return
+	}
+	segmentID, err := primitive.ObjectIDFromHex(vars["segmentId"])
+	if err != nil {
+		httpError(w, "Invalid segment ID", http.StatusBadRequest)
+		return
+	}
+
+	story, err := findOwnStory(r.Context(), storyID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	segment := findSegment(&story, segmentID)
+	if segment == nil {
+		writeError(w, domain.New(domain.ErrNotFound, "Segment not found"))
+		return
+	}
+	if segment.Script == nil || strings.TrimSpace(segment.Script.Text) == "" {
+		writeError(w, domain.New(domain.ErrInvalid, "Segment has no script to narrate"))
+		return
+	}
+
+	queueJob(w, r, queueKindGenerateAudio, narrationPayload{
+		StoryID:   storyID,
+		SegmentID: segmentID,
+		Text:      segment.Script.Text,
+		Language:  scriptLanguage(&story, segment.Script),
+	})
+}
+
+// runNarrationJob narrates the script a generate_audio job was started
+// for and returns the story with the narration.
+func runNarrationJob(ctx context.Context, run *jobRun, payload bson.M) (interface{}, error) {
+	var job narrationPayload
+	if err := decodePayload(payload, &job); err != nil {
+		return nil, err
+	}
+	if speechSynthesizer == nil {
+		return nil, errors.New("audio generation is off on this instance")
+	}
+	input := tts.Input{Text: job.Text, Language: job.Language}
+	if err := narrateSegment(ctx, job.StoryID, job.SegmentID, input); err != nil {
+		return nil, err
+	}
+	updatedStory, err := findStory(ctx, job.StoryID)
+	if err != nil {
+		return nil, err
+	}
+	return api.StoryFromModel(&updatedStory), nil
+}
+
+// narrateSegment synthesizes input and stores it under a fresh audio key of
+// the segment, which is made pending first so the usual promotion applies:
+// a client upload started in the meantime supersedes the narration.
+func narrateSegment(ctx context.Context, storyID, segmentID primitive.ObjectID, input tts.Input) error {
+	speech, err := speechSynthesizer.Synthesize(ctx, input)
+	if errors.Is(err, tts.ErrUnsupportedLanguage) {
+		return domain.New(domain.ErrInvalid, fmt.Sprintf("No voice speaks %q", input.Language))
+	}
+	if err != nil {
+		return err
+	}
+
+	key := newAudioKey(storyID, segmentID)
+	err = updateSegment(ctx, storyID, segmentID, bson.M{"audio.pending_key": key})
+	if err != nil {
+		return err
+	}
+	putInput := &s3.PutObjectInput{
+		Bucket:      aws.String(writeBucket()),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(speech.Audio),
+		ContentType: aws.String(speech.ContentType),
+	}
+	encryptPut(putInput)
+	if _, err = s3Client.PutObjectWithContext(ctx, putInput); err != nil {
+		return err
+	}
+
+	err = promotePendingAudio(ctx, storyID, segmentID, key)
+	if errors.Is(err, domain.ErrConflict) {
+		// The bucket's upload event may have promoted it first
+		story, findErr := findStory(ctx, storyID)
+		if findErr != nil {
+			return findErr
+		}
+		if segment := findSegment(&story, segmentID); segment != nil && segment.Audio != nil && segment.Audio.Key == key {
+			return nil
+		}
+	}
+	return err
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/repository"
+	"rosetta/tts"
+)
+
+type unusedSynthesizer struct{}
+
+func (unusedSynthesizer) Synthesize(context.Context, tts.Input) (tts.Speech, error) {
+	return tts.Speech{}, errors.New("not expected to be called")
+}
+
+// TestMutationsOfMissingStory checks every endpoint changing a story
+// answers 404 with the error envelope when the story doesn't exist.
+func TestMutationsOfMissingStory(t *testing.T) {
+	savedRepo, savedSynthesizer := storyRepo, speechSynthesizer
+	storyRepo = repository.NewMemory()
+	speechSynthesizer = unusedSynthesizer{}
+	t.Cleanup(func() { storyRepo, speechSynthesizer = savedRepo, savedSynthesizer })
+
+	storyID := primitive.NewObjectID().Hex()
+	segmentID := primitive.NewObjectID().Hex()
+	trackID := primitive.NewObjectID().Hex()
+	splitJSON := `{"text":"One. Two.","language":"en"}`
+	storyJSON := `{"title":"Missing","language":"en","segments":[]}`
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		body    string
+		headers map[string]string
+	}{
+		{"update", updateStory, http.MethodPut, storyJSON, map[string]string{"If-Match": `"v1"`}},
+		{"patch", patchStory, http.MethodPatch, `{"title":"Missing"}`, map[string]string{"If-Match": `"v1"`, "Content-Type": "application/merge-patch+json"}},
+		{"delete", deleteStory, http.MethodDelete, "", nil},
+		{"publish", publishStory, http.MethodPost, "", nil},
+		{"unpublish", unpublishStory, http.MethodPost, "", nil},
+		{"audio upload", generateAudioUploadURL, http.MethodPost, `{"content_type":"audio/mpeg"}`, nil},
+		{"audio complete", completeAudioUpload, http.MethodPost, `{}`, nil},
+		{"audio credentials", generateAudioUploadCredentials, http.MethodPost, `{}`, nil},
+		{"audio generate", generateSegmentAudio, http.MethodPost, `{}`, nil},
+		{"audio trim", trimSegmentAudio, http.MethodPost, `{"start_ms":0,"end_ms":1000}`, nil},
+		{"image upload", generateImageUploadURL, http.MethodPost, `{"content_type":"image/png"}`, nil},
+		{"image confirm", confirmImageUpload, http.MethodPost, `{}`, nil},
+		{"audio track", createAudioTrack, http.MethodPost, `{"content_type":"audio/mpeg"}`, nil},
+		{"audio track complete", completeAudioTrackUpload, http.MethodPost, `{}`, nil},
+		{"split recording", splitRecording, http.MethodPost, splitJSON, nil},
+		{"put translation", putTranslation, http.MethodPut, `{"text":"Hola"}`, nil},
+		{"delete translation", deleteTranslation, http.MethodDelete, "", nil},
+		{"split segments", splitIntoSegments, http.MethodPost, splitJSON, nil},
+		{"merge segments", mergeSegments, http.MethodPost, `{}`, nil},
+		{"lock", lockStory, http.MethodPost, `{"holder":"tab-1"}`, nil},
+		{"unlock", unlockStory, http.MethodDelete, "", nil},
+		{"backup", putDraftBackup, http.MethodPut, `{"ciphertext":"c2VjcmV0"}`, nil},
+		{"appeal", submitAppeal, http.MethodPost, `{"message":"Please look again"}`, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(test.method, "/?holder=tab-1", strings.NewReader(test.body))
+			r.Header.Set("Content-Type", "application/json")
+			for k, v := range test.headers {
+				r.Header.Set(k, v)
+			}
+			r = mux.SetURLVars(r, map[string]string{
+				"id": storyID, "storyId": storyID, "segmentId": segmentID, "trackId": trackID, "lang": "es",
+			})
+			r = r.WithContext(context.WithValue(r.Context(), userKey{}, primitive.NewObjectID()))
+
+			w := httptest.NewRecorder()
+			test.handler(w, r)
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("status = %d, want 404: %s", w.Code, w.Body)
+			}
+			if !strings.Contains(w.Body.String(), `"code":"not_found"`) {
+				t.Errorf("body = %s, want the not_found error envelope", w.Body)
+			}
+		})
+	}
+}
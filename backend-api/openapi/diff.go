@@ -0,0 +1,144 @@
+package openapi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Change struct {
+	Kind        string `json:"kind"`
+	Description string `json:"description"`
+}
+
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+type Release struct {
+	Version string   `json:"version"`
+	Changes []Change `json:"changes"`
+}
+
+// Changelog lists what changed in each version against the one before it,
+// newest first. The first version has no changes to list.
+func Changelog(docs []*Document) []Release {
+	releases := make([]Release, 0, len(docs))
+	for i := len(docs) - 1; i >= 0; i-- {
+		release := Release{Version: docs[i].Info.Version, Changes: []Change{}}
+		if i > 0 {
+			release.Changes = Diff(docs[i-1], docs[i])
+		}
+		releases = append(releases, release)
+	}
+	return releases
+}
+
+// Diff lists the operations and schema fields added, removed or retyped
+// from old to new, in a stable order.
+func Diff(old, new *Document) []Change {
+	var changes []Change
+	add := func(kind, format string, args ...interface{}) {
+		changes = append(changes, Change{Kind: kind, Description: fmt.Sprintf(format, args...)})
+	}
+
+	for _, route := range unionKeys(old.Paths, new.Paths) {
+		for _, method := range unionKeys(old.Paths[route], new.Paths[route]) {
+			_, before := old.Paths[route][method]
+			_, after := new.Paths[route][method]
+			switch {
+			case !before:
+				add(ChangeAdded, "%s %s", strings.ToUpper(method), route)
+			case !after:
+				add(ChangeRemoved, "%s %s", strings.ToUpper(method), route)
+			}
+		}
+	}
+
+	for _, name := range unionKeys(old.Components.Schemas, new.Components.Schemas) {
+		before, after := old.Components.Schemas[name], new.Components.Schemas[name]
+		switch {
+		case before == nil:
+			add(ChangeAdded, "schema %s", name)
+			continue
+		case after == nil:
+			add(ChangeRemoved, "schema %s", name)
+			continue
+		}
+		for _, field := range unionKeys(before.Properties, after.Properties) {
+			b, a := before.Properties[field], after.Properties[field]
+			switch {
+			case b == nil:
+				add(ChangeAdded, "field %s.%s", name, field)
+			case a == nil:
+				add(ChangeRemoved, "field %s.%s", name, field)
+			case describe(b) != describe(a):
+				add(ChangeChanged, "field %s.%s from %s to %s", name, field, describe(b), describe(a))
+			}
+		}
+	}
+	return changes
+}
+
+// RemovedFields maps each schema of the latest version to the fields older
+// versions had and the version that dropped them.
+func RemovedFields(docs []*Document) map[string]map[string]string {
+	removed := map[string]map[string]string{}
+	if len(docs) == 0 {
+		return removed
+	}
+	latest := docs[len(docs)-1]
+	for i := 1; i < len(docs); i++ {
+		for name, before := range docs[i-1].Components.Schemas {
+			current := latest.Components.Schemas[name]
+			after := docs[i].Components.Schemas[name]
+			if current == nil {
+				continue
+			}
+			for field := range before.Properties {
+				if after != nil && after.Properties[field] != nil {
+					continue
+				}
+				// Fields that came back since are no longer removed
+				if current.Properties[field] != nil {
+					continue
+				}
+				if removed[name] == nil {
+					removed[name] = map[string]string{}
+				}
+				if _, seen := removed[name][field]; !seen {
+					removed[name][field] = docs[i].Info.Version
+				}
+			}
+		}
+	}
+	return removed
+}
+
+func describe(s *Schema) string {
+	switch {
+	case s.Ref != "":
+		return s.RefName()
+	case s.Type == "array" && s.Items != nil:
+		return "array of " + describe(s.Items)
+	case s.Format != "":
+		return s.Type + " (" + s.Format + ")"
+	}
+	return s.Type
+}
+
+func unionKeys[V any](a, b map[string]V) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
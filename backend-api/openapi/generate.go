@@ -0,0 +1,137 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/api"
+)
+
+// Route is a documented operation. Request and Response are zero values of
+// the body types, nil for none.
+type Route struct {
+	Method      string
+	Path        string
+	Status      int
+	ContentType string
+	Request     interface{}
+	Response    interface{}
+}
+
+// Routes are the operations covered by the schema: the story endpoints
+// whose bodies are the api package types.
+var Routes = []Route{
+	{Method: "GET", Path: "/stories", Status: http.StatusOK, Response: []api.StoryResponse{}},
+	{Method: "POST", Path: "/stories", Status: http.StatusCreated, Request: api.StoryRequest{}, Response: api.StoryResponse{}},
+	{Method: "GET", Path: "/stories/{id}", Status: http.StatusOK, Response: api.StoryResponse{}},
+	{Method: "PUT", Path: "/stories/{id}", Status: http.StatusOK, Request: api.StoryRequest{}, Response: api.StoryResponse{}},
+	{Method: "PATCH", Path: "/stories/{id}", Status: http.StatusOK, ContentType: "application/merge-patch+json", Request: api.StoryRequest{}, Response: api.StoryResponse{}},
+	{Method: "DELETE", Path: "/stories/{id}", Status: http.StatusNoContent},
+	{Method: "GET", Path: "/public/stories", Status: http.StatusOK, Response: []api.StoryResponse{}},
+	{Method: "GET", Path: "/public/stories/{id}", Status: http.StatusOK, Response: api.StoryResponse{}},
+}
+
+// Generate describes routes as they are in the code now, to be saved as
+// the snapshot of a new version.
+func Generate(version string, routes []Route) *Document {
+	g := generator{schemas: map[string]*Schema{}}
+	doc := &Document{
+		OpenAPI:    "3.0.3",
+		Info:       Info{Title: "Rosetta API", Version: version},
+		Paths:      map[string]map[string]Operation{},
+		Components: Components{Schemas: g.schemas},
+	}
+	for _, route := range routes {
+		op := Operation{Responses: map[string]Body{}}
+		if route.Request != nil {
+			contentType := route.ContentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+			op.RequestBody = &Body{Content: map[string]MediaType{
+				contentType: {Schema: g.schema(reflect.TypeOf(route.Request))},
+			}}
+		}
+		response := Body{Description: http.StatusText(route.Status)}
+		if route.Response != nil {
+			response.Content = map[string]MediaType{
+				"application/json": {Schema: g.schema(reflect.TypeOf(route.Response))},
+			}
+		}
+		op.Responses[strconv.Itoa(route.Status)] = response
+
+		if doc.Paths[route.Path] == nil {
+			doc.Paths[route.Path] = map[string]Operation{}
+		}
+		doc.Paths[route.Path][strings.ToLower(route.Method)] = op
+	}
+	return doc
+}
+
+type generator struct {
+	schemas map[string]*Schema
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	objectIDType = reflect.TypeOf(primitive.ObjectID{})
+	marshaler    = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshal  = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+func (g *generator) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == objectIDType:
+		return &Schema{Type: "string", Format: "objectid"}
+	case t.Implements(marshaler) || t.Implements(textMarshal):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object"}
+	case reflect.Struct:
+		name := t.Name()
+		if _, ok := g.schemas[name]; !ok {
+			// Registered first so recursive types terminate
+			s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+			g.schemas[name] = s
+			for i := 0; i < t.NumField(); i++ {
+				field := t.Field(i)
+				tag, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+				if !field.IsExported() || tag == "-" {
+					continue
+				}
+				if tag == "" {
+					tag = field.Name
+				}
+				s.Properties[tag] = g.schema(field.Type)
+			}
+		}
+		return &Schema{Ref: refPrefix + name}
+	}
+	return &Schema{}
+}
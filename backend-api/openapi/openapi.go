@@ -0,0 +1,118 @@
+// Package openapi describes the public story API as OpenAPI documents.
+// Every released schema version is kept as a snapshot; the changelog and the
+// removed-field warnings are derived by diffing consecutive snapshots.
+package openapi
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Only the subset of OpenAPI 3 the snapshots use.
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Paths      map[string]map[string]Operation `json:"paths"`
+	Components Components                      `json:"components"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type Operation struct {
+	RequestBody *Body           `json:"requestBody,omitempty"`
+	Responses   map[string]Body `json:"responses"`
+}
+
+type Body struct {
+	Description string               `json:"description,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema  *Schema     `json:"schema"`
+	Example interface{} `json:"example,omitempty"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+type Schema struct {
+	Ref        string             `json:"$ref,omitempty"`
+	Type       string             `json:"type,omitempty"`
+	Format     string             `json:"format,omitempty"`
+	Items      *Schema            `json:"items,omitempty"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+}
+
+const refPrefix = "#/components/schemas/"
+
+// RefName returns the component a $ref schema points at.
+func (s *Schema) RefName() string {
+	return strings.TrimPrefix(s.Ref, refPrefix)
+}
+
+// Resolve follows a $ref to its component.
+func (d *Document) Resolve(s *Schema) *Schema {
+	if s == nil || s.Ref == "" {
+		return s
+	}
+	return d.Components.Schemas[s.RefName()]
+}
+
+// RequestSchema returns the JSON request body schema of an operation, or nil.
+func (d *Document) RequestSchema(method, route string) *Schema {
+	op, ok := d.Paths[route][strings.ToLower(method)]
+	if !ok || op.RequestBody == nil {
+		return nil
+	}
+	for _, media := range op.RequestBody.Content {
+		return media.Schema
+	}
+	return nil
+}
+
+//go:embed snapshots/*.json
+var snapshots embed.FS
+
+// Snapshots returns the released schema versions, oldest first. Snapshot
+// files are named after their version.
+func Snapshots() ([]*Document, error) {
+	entries, err := snapshots.ReadDir("snapshots")
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make([]*Document, 0, len(entries))
+	for _, entry := range entries {
+		data, err := snapshots.ReadFile(path.Join("snapshots", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var doc Document
+		if err = json.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		if doc.Info.Version+".json" != entry.Name() {
+			return nil, fmt.Errorf("%s: holds version %q", entry.Name(), doc.Info.Version)
+		}
+		docs = append(docs, &doc)
+	}
+	sort.Slice(docs, func(i, j int) bool { return versionLess(docs[i].Info.Version, docs[j].Info.Version) })
+	return docs, nil
+}
+
+// Versions are whole numbers, compared numerically.
+func versionLess(a, b string) bool {
+	x, _ := strconv.Atoi(a)
+	y, _ := strconv.Atoi(b)
+	return x < y
+}
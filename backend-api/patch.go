@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/api"
+	"rosetta/domain"
+	"rosetta/models"
+	"rosetta/validation"
+)
+
+const contentTypeMergePatch = "application/merge-patch+json"
+
+const maxPatchSize = 1 << 20
+
+// patchStory applies a JSON Merge Patch (RFC 7386) to a story. Fields left
+// out of the patch keep their stored values, null clears a field and arrays
+// such as segments are replaced as a whole. The patch is re-applied to the
+// latest version if a concurrent write lands first, unless If-Match or
+// If-Unmodified-Since pin the version it was made against. One of the two
+// is required.
+func patchStory(w http.ResponseWriter, r *http.Request) {
+	objectID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, "Invalid story ID", http.StatusBadRequest)
+		return
+	}
+
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || (mediaType != contentTypeMergePatch && mediaType != contentTypeJSON) {
+			w.Header().Set("Accept-Patch", contentTypeMergePatch)
+			httpError(w, "Content-Type must be "+contentTypeMergePatch, http.StatusUnsupportedMediaType)
+			return
+		}
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPatchSize))
+	if err != nil {
+		httpError(w, "Patch must be at most 1 MB", http.StatusRequestEntityTooLarge)
+		return
+	}
+	var patch map[string]interface{}
+	if err = json.Unmarshal(body, &patch); err != nil {
+		httpError(w, "Patch must be a JSON object", http.StatusBadRequest)
+		return
+	}
+
+	base, ok := baseVersion(w, r)
+	if !ok {
+		return
+	}
+	since := unmodifiedSince(r)
+
+	if _, err = findOwnStory(r.Context(), objectID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	story, err := modifyStory(r.Context(), objectID, func(story *models.Story) error {
+		if base != 0 && base != story.Version {
+			return &staleVersionError{BaseVersion: base, CurrentVersion: story.Version}
+		}
+		if modifiedAfter(story, since) {
+			return errPreconditionFailed
+		}
+
+		request, err := applyMergePatch(api.StoryRequestFromModel(story), patch)
+		if err != nil {
+			return err
+		}
+		if err = validation.Story(&request); err != nil {
+			return err
+		}
+
+		patched := request.ToModel()
+		story.Title = patched.Title
+		story.Language = patched.Language
+		story.Segments = patched.Segments
+		story.Characters = patched.Characters
+		story.IsPublished = patched.IsPublished
+		story.Visibility = patched.Visibility
+		story.AgeRating = patched.AgeRating
+		story.ContentWarnings = patched.ContentWarnings
+		// Both are kept by saveStory when nil, but null in a patch clears them
+		story.Metadata = patched.Metadata
+		if story.Metadata == nil {
+			story.Metadata = map[string]string{}
+		}
+		story.AudioTracks = patched.AudioTracks
+		if story.AudioTracks == nil {
+			story.AudioTracks = []models.AudioTrack{}
+		}
+		return nil
+	})
+	if writeVersionConflict(w, err) {
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	setLastModified(w, &story)
+	w.Header().Set("ETag", storyETag(&story))
+	writeResponse(w, r, http.StatusOK, api.StoryFromModel(&story))
+}
+
+// applyMergePatch patches the JSON form of request. Unknown fields are
+// rejected rather than silently dropped.
+func applyMergePatch(request api.StoryRequest, patch map[string]interface{}) (api.StoryRequest, error) {
+	raw, err := json.Marshal(request)
+	if err != nil {
+		return request, err
+	}
+	var target map[string]interface{}
+	if err = json.Unmarshal(raw, &target); err != nil {
+		return request, err
+	}
+
+	raw, err = json.Marshal(mergePatch(target, patch))
+	if err != nil {
+		return request, err
+	}
+
+	var patched api.StoryRequest
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(&patched); err != nil {
+		return request, domain.New(domain.ErrInvalid, "Invalid patch: "+err.Error())
+	}
+	return patched, nil
+}
+
+// mergePatch implements the MergePatch algorithm of RFC 7386.
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergePatch(targetObject[key], value)
+	}
+	return targetObject
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"rosetta/models"
+)
+
+const (
+	playFlushInterval = 30 * time.Second
+	maxOriginLength   = 253
+
+	defaultStatsDays = 30
+	maxStatsDays     = 365
+	topOriginsLimit  = 20
+)
+
+type playKey struct {
+	storyID primitive.ObjectID
+	day     time.Time
+	source  string
+	origin  string
+}
+
+// playCounter buffers play counts in memory, so public reads don't wait on
+// a database write. runPlayFlush writes them out.
+type playCounter struct {
+	mu     sync.Mutex
+	counts map[playKey]int64
+}
+
+var plays = &playCounter{counts: map[playKey]int64{}}
+
+func (c *playCounter) add(key playKey) {
+	c.mu.Lock()
+	c.counts[key]++
+	c.mu.Unlock()
+}
+
+func (c *playCounter) take() map[playKey]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := c.counts
+	c.counts = map[playKey]int64{}
+	return counts
+}
+
+// recordPlay counts a public read of the story by where it came from. Apps
+// and embedded players say so with source=app or source=embed, embeds
+// optionally naming the embedding site with embed_origin; anything else
+// counts as a link when there is a Referer and as direct otherwise.
+func recordPlay(r *http.Request, storyID primitive.ObjectID) {
+	query := r.URL.Query()
+	key := playKey{storyID: storyID, day: time.Now().UTC().Truncate(24 * time.Hour)}
+
+	switch query.Get("source") {
+	case models.PlaySourceApp:
+		key.source = models.PlaySourceApp
+	case models.PlaySourceEmbed:
+		key.source = models.PlaySourceEmbed
+		key.origin = originHost(query.Get("embed_origin"))
+		if key.origin == "" {
+			key.origin = originHost(r.Referer())
+		}
+	default:
+		key.source = models.PlaySourceDirect
+		if key.origin = originHost(r.Referer()); key.origin != "" {
+			key.source = models.PlaySourceLink
+		}
+	}
+	plays.add(key)
+}
+
+// originHost reduces a URL to its host, so plays group by site rather than
+// by page, and drops anything that isn't a web URL.
+func originHost(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if len(host) > maxOriginLength {
+		return ""
+	}
+	return host
+}
+
+// runPlayFlush writes buffered play counts to the database until ctx is
+// done, then writes what is left.
+func runPlayFlush(ctx context.Context) {
+	ticker := time.NewTicker(playFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			flushPlays(flushCtx)
+			cancel()
+			return
+		case <-ticker.C:
+			flushPlays(ctx)
+		}
+	}
+}
+
+func flushPlays(ctx context.Context) {
+	counts := plays.take()
+	if len(counts) == 0 {
+		return
+	}
+
+	writes := make([]mongo.WriteModel, 0, len(counts))
+	for key, count := range counts {
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"story_id": key.storyID, "day": key.day, "source": key.source, "origin": key.origin}).
+			SetUpdate(bson.M{"$inc": bson.M{"count": count}}).
+			SetUpsert(true))
+	}
+	// Counts that fail to write are dropped; analytics can afford the loss
+	_, err := storyPlaysCollection().BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		log.Printf("failed to record %d play counts: %v", len(writes), err)
+	}
+}
+
+type playOrigin struct {
+	Source string `bson:"source" json:"source"`
+	Origin string `bson:"origin" json:"origin"`
+	Count  int64  `bson:"count" json:"count"`
+}
+
+type playStats struct {
+	StoryID    primitive.ObjectID `json:"story_id"`
+	Since      time.Time          `json:"since"`
+	Total      int64              `json:"total"`
+	BySource   map[string]int64   `json:"by_source"`
+	TopOrigins []playOrigin       `json:"top_origins"`
+}
+
+// getStoryStats shows the owner where the public reads of a story came
+// from over the last days (30 by default).
+func getStoryStats(w http.ResponseWriter, r *http.Request) {
+	storyID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, "Invalid story ID", http.StatusBadRequest)
+		return
+	}
+
+	days := defaultStatsDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxStatsDays {
+			httpError(w, fmt.Sprintf("days must be between 1 and %d", maxStatsDays), http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+
+	if _, err = findOwnStory(r.Context(), storyID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1-days)
+	stats := playStats{StoryID: storyID, Since: since, BySource: map[string]int64{}, TopOrigins: []playOrigin{}}
+	for _, source := range models.PlaySources {
+		stats.BySource[source] = 0
+	}
+
+	cursor, err := storyPlaysCollection().Aggregate(r.Context(), mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"story_id": storyID, "day": bson.M{"$gte": since}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"source": "$source", "origin": "$origin"},
+			"count": bson.M{"$sum": "$count"},
+		}}},
+		{{Key: "$project", Value: bson.M{"_id": 0, "source": "$_id.source", "origin": "$_id.origin", "count": 1}}},
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var origins []playOrigin
+	if err = cursor.All(r.Context(), &origins); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	for _, o := range origins {
+		stats.Total += o.Count
+		stats.BySource[o.Source] += o.Count
+		if o.Origin != "" {
+			stats.TopOrigins = append(stats.TopOrigins, o)
+		}
+	}
+	slices.SortFunc(stats.TopOrigins, func(a, b playOrigin) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), strings.Compare(a.Origin, b.Origin))
+	})
+	if len(stats.TopOrigins) > topOriginsLimit {
+		stats.TopOrigins = stats.TopOrigins[:topOriginsLimit]
+	}
+
+	writeResponse(w, r, http.StatusOK, stats)
+}
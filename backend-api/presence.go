@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/presence"
+)
+
+// Editors send a heartbeat every 15 seconds or so while a story is open
+var storyPresence = presence.New(45 * time.Second)
+
+// Viewers are held in memory, so what they send about themselves is bounded
+const (
+	maxPresenceNameLength   = 100
+	maxPresenceAvatarLength = 2048
+)
+
+type presenceRequest struct {
+	Name   string `json:"name"`
+	Avatar string `json:"avatar"`
+}
+
+// heartbeatPresence marks the signed-in user as viewing the story and
+// returns everyone currently viewing it, the caller included.
+func heartbeatPresence(w http.ResponseWriter, r *http.Request) {
+	storyID, ok := presenceStory(w, r)
+	if !ok {
+		return
+	}
+
+	var request presenceRequest
+	err := decodeRequest(r, &request)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if utf8.RuneCountInString(request.Name) > maxPresenceNameLength {
+		httpError(w, fmt.Sprintf("name must be at most %d characters", maxPresenceNameLength), http.StatusBadRequest)
+		return
+	}
+	if len(request.Avatar) > maxPresenceAvatarLength {
+		httpError(w, fmt.Sprintf("avatar must be at most %d bytes", maxPresenceAvatarLength), http.StatusBadRequest)
+		return
+	}
+
+	userID, _ := currentUser(r.Context())
+	storyPresence.Heartbeat(storyID.Hex(), presence.Viewer{ID: userID.Hex(), Name: request.Name, Avatar: request.Avatar})
+	writeResponse(w, r, http.StatusOK, storyPresence.Viewers(storyID.Hex()))
+}
+
+func getPresence(w http.ResponseWriter, r *http.Request) {
+	storyID, ok := presenceStory(w, r)
+	if !ok {
+		return
+	}
+
+	writeResponse(w, r, http.StatusOK, storyPresence.Viewers(storyID.Hex()))
+}
+
+// leavePresence removes the signed-in user from the story's viewers.
+func leavePresence(w http.ResponseWriter, r *http.Request) {
+	storyID, ok := presenceStory(w, r)
+	if !ok {
+		return
+	}
+
+	userID, _ := currentUser(r.Context())
+	storyPresence.Leave(storyID.Hex(), userID.Hex())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// presenceStory returns the ID of the story whose presence is asked for,
+// once the caller is known to be allowed to see the story. ok is false once
+// an error has been written.
+func presenceStory(w http.ResponseWriter, r *http.Request) (primitive.ObjectID, bool) {
+	storyID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, "Invalid story ID", http.StatusBadRequest)
+		return storyID, false
+	}
+	if _, err = findVisibleStory(r.Context(), storyID); err != nil {
+		writeError(w, err)
+		return storyID, false
+	}
+	return storyID, true
+}
@@ -0,0 +1,95 @@
+// Package presence keeps track of who is currently viewing which story,
+// based on periodic heartbeats. State lives in memory, so each API instance
+// only knows about viewers whose heartbeats it received.
+package presence
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Viewer is someone seen on a story within the tracker's TTL.
+type Viewer struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name,omitempty"`
+	Avatar   string    `json:"avatar,omitempty"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// Tracker forgets viewers that haven't sent a heartbeat within ttl.
+type Tracker struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	rooms     map[string]map[string]Viewer
+	lastSweep time.Time
+}
+
+func New(ttl time.Duration) *Tracker {
+	return &Tracker{ttl: ttl, rooms: map[string]map[string]Viewer{}}
+}
+
+// Heartbeat records viewer as present on room now.
+func (t *Tracker) Heartbeat(room string, viewer Viewer) {
+	now := time.Now()
+	viewer.LastSeen = now
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if now.Sub(t.lastSweep) > t.ttl {
+		t.sweep(now)
+	}
+
+	viewers, ok := t.rooms[room]
+	if !ok {
+		viewers = map[string]Viewer{}
+		t.rooms[room] = viewers
+	}
+	viewers[viewer.ID] = viewer
+}
+
+// Leave removes a viewer right away instead of waiting for it to expire.
+func (t *Tracker) Leave(room, viewerID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.rooms[room], viewerID)
+	if len(t.rooms[room]) == 0 {
+		delete(t.rooms, room)
+	}
+}
+
+// Viewers lists who is present on room, most recently seen first.
+func (t *Tracker) Viewers(room string) []Viewer {
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	viewers := []Viewer{}
+	for _, viewer := range t.rooms[room] {
+		if now.Sub(viewer.LastSeen) < t.ttl {
+			viewers = append(viewers, viewer)
+		}
+	}
+	sort.Slice(viewers, func(i, j int) bool {
+		return viewers[i].LastSeen.After(viewers[j].LastSeen)
+	})
+	return viewers
+}
+
+func (t *Tracker) sweep(now time.Time) {
+	for room, viewers := range t.rooms {
+		for id, viewer := range viewers {
+			if now.Sub(viewer.LastSeen) >= t.ttl {
+				delete(viewers, id)
+			}
+		}
+		if len(viewers) == 0 {
+			delete(t.rooms, room)
+		}
+	}
+	t.lastSweep = now
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/models"
+	"rosetta/presence"
+	"rosetta/repository"
+)
+
+// TestPresenceOfDraft checks only the owner can see or change who is on a
+// draft, and that viewers are who they signed in as.
+func TestPresenceOfDraft(t *testing.T) {
+	savedRepo, savedPresence := storyRepo, storyPresence
+	storyRepo = repository.NewMemory()
+	storyPresence = presence.New(time.Minute)
+	t.Cleanup(func() { storyRepo, storyPresence = savedRepo, savedPresence })
+
+	owner, stranger := primitive.NewObjectID(), primitive.NewObjectID()
+	story := models.Story{ID: primitive.NewObjectID(), Title: "Draft", Language: "en", OwnerID: owner}
+	if err := storyRepo.Create(context.Background(), &story); err != nil {
+		t.Fatal(err)
+	}
+
+	call := func(handler http.HandlerFunc, method, body string, user primitive.ObjectID) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		r = mux.SetURLVars(r, map[string]string{"id": story.ID.Hex()})
+		r = r.WithContext(context.WithValue(r.Context(), userKey{}, user))
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	w := call(heartbeatPresence, http.MethodPost, `{"name":"Ada"}`, owner)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), owner.Hex()) {
+		t.Fatalf("owner heartbeat: %d %s", w.Code, w.Body)
+	}
+	for name, handler := range map[string]http.HandlerFunc{
+		"heartbeat": heartbeatPresence, "get": getPresence, "leave": leavePresence,
+	} {
+		if w := call(handler, http.MethodPost, `{}`, stranger); w.Code != http.StatusNotFound {
+			t.Errorf("%s by a stranger: status = %d, want 404", name, w.Code)
+		}
+	}
+	if viewers := storyPresence.Viewers(story.ID.Hex()); len(viewers) != 1 || viewers[0].ID != owner.Hex() {
+		t.Errorf("viewers = %v, want only the owner", viewers)
+	}
+
+	long := `{"name":"` + strings.Repeat("a", maxPresenceNameLength+1) + `"}`
+	if w := call(heartbeatPresence, http.MethodPost, long, owner); w.Code != http.StatusBadRequest {
+		t.Errorf("overlong name: status = %d, want 400", w.Code)
+	}
+}
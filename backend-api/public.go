@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/api"
+	"rosetta/domain"
+	"rosetta/ratelimit"
+	"rosetta/repository"
+)
+
+const (
+	publicCacheMaxAge    = 300
+	publicMaxListLimit   = 100
+	publicDefaultListLen = 20
+)
+
+// registerPublicRoutes mounts the anonymous, read-only API. It only ever
+// serves published stories, and lists and searches listed ones only. It has
+// its own rate limit, so crawlers and embeds can't eat into the authoring
+// API's capacity.
+func registerPublicRoutes(r *mux.Router, perMinute int) {
+	public := r.PathPrefix("/public").Subrouter()
+	public.Use(rateLimit(ratelimit.New(perMinute, perMinute)))
+	public.HandleFunc("/stories", listPublicStories).Methods("GET")
+	public.HandleFunc("/stories/search", searchPublicStories).Methods("GET")
+	public.HandleFunc("/stories/{id}", getPublicStory).Methods("GET")
+	public.HandleFunc("/feed", getFeed).Methods("GET")
+	public.HandleFunc("/feed/daily", getDailyStory).Methods("GET")
+}
+
+func setPublicCache(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", publicCacheMaxAge))
+}
+
+func getPublicStory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	objectID, err := primitive.ObjectIDFromHex(vars["id"])
+	if err != nil {
+		httpError(w, "Invalid story ID", http.StatusBadRequest)
+		return
+	}
+
+	story, err := findStory(r.Context(), objectID)
+	if err == nil && (!story.IsPublished || isHidden(&story)) {
+		err = domain.New(domain.ErrNotFound, "Story not found")
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Edit locks are for editors only
+	story.Lock = nil
+	recordPlay(r, story.ID)
+
+	etag := storyETag(&story)
+	w.Header().Set("ETag", etag)
+	w.Header().Add("Vary", "Accept-Language")
+	setLastModified(w, &story)
+	setPublicCache(w)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	writeTranslatedStory(w, r, &story)
+}
+
+// listPublicStories pages through the listed stories with limit and
+// offset, giving the total in X-Total-Count and the next page in Link, like
+// GET /stories.
+func listPublicStories(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit := publicDefaultListLen
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > publicMaxListLimit {
+			httpError(w, fmt.Sprintf("limit must be between 1 and %d", publicMaxListLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	offset := 0
+	if v := query.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			httpError(w, "offset must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+
+	storyQuery := repository.StoryQuery{
+		Language: query.Get("lang"),
+		Listed:   true,
+		Offset:   offset,
+		Limit:    limit,
+	}
+	if err := contentQuery(&storyQuery, query); err != nil {
+		writeError(w, err)
+		return
+	}
+	metadata, err := metadataQuery(query)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	storyQuery.Metadata = metadata
+
+	// Newest first, or by title in the order readers of the locale expect
+	switch sort := query.Get("sort"); sort {
+	case "", repository.SortUpdatedAtReverse:
+		storyQuery.Sort = repository.SortUpdatedAtReverse
+	case repository.SortTitle, repository.SortTitleReverse:
+		storyQuery.Sort = sort
+		if storyQuery.Locale, err = titleLocale(query); err != nil {
+			writeError(w, err)
+			return
+		}
+	default:
+		httpError(w, "Invalid sort", http.StatusBadRequest)
+		return
+	}
+	stories, total, err := storyRepo.List(r.Context(), storyQuery)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	for i := range stories {
+		stories[i].Lock = nil
+	}
+	w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+	if int64(offset+limit) < total {
+		next := r.URL.Query()
+		next.Set("offset", strconv.Itoa(offset+limit))
+		w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, next.Encode()))
+	}
+	setPublicCache(w)
+	writeResponse(w, r, http.StatusOK, api.StoriesFromModels(stories))
+}
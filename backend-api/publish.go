@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/api"
+	"rosetta/models"
+	"rosetta/validation"
+)
+
+// publishStory publishes a story once it is complete. Incomplete stories
+// are rejected with every missing piece listed, however they get published
+// (see checkPublishable).
+func publishStory(w http.ResponseWriter, r *http.Request) {
+	setPublished(w, r, true)
+}
+
+func unpublishStory(w http.ResponseWriter, r *http.Request) {
+	setPublished(w, r, false)
+}
+
+func setPublished(w http.ResponseWriter, r *http.Request, published bool) {
+	objectID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, "Invalid story ID", http.StatusBadRequest)
+		return
+	}
+
+	story, err := modifyStory(r.Context(), objectID, func(story *models.Story) error {
+		if err := authorizeStory(r.Context(), story); err != nil {
+			return err
+		}
+		// saveStory checks the story is complete and stamps published_at
+		// when a draft gets published
+		story.IsPublished = published
+		return nil
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeResponse(w, r, http.StatusOK, api.StoryFromModel(&story))
+}
+
+// checkPublishable rejects a write that publishes an incomplete story, so
+// PUT, PATCH, sync and the rest can't publish what POST /publish wouldn't.
+// Stories already published are left to be edited freely.
+func checkPublishable(story, current *models.Story) error {
+	if !story.IsPublished || current.IsPublished {
+		return nil
+	}
+	request := api.StoryRequestFromModel(story)
+	return validation.Publishable(&request)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/models"
+	"rosetta/repository"
+)
+
+// TestUpdateCannotPublishIncompleteStory checks PUT is held to the same
+// completeness check as POST /publish.
+func TestUpdateCannotPublishIncompleteStory(t *testing.T) {
+	savedRepo := storyRepo
+	storyRepo = repository.NewMemory()
+	t.Cleanup(func() { storyRepo = savedRepo })
+
+	owner := primitive.NewObjectID()
+	story := models.Story{
+		ID:       primitive.NewObjectID(),
+		Title:    "Draft",
+		Language: "en",
+		Segments: []models.Segment{{ID: primitive.NewObjectID(), Script: &models.Script{Text: "Once upon a time"}}},
+		OwnerID:  owner,
+		Version:  1,
+	}
+	if err := storyRepo.Create(context.Background(), &story); err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"title":"Draft","language":"en","age_rating":"all","is_published":true,` +
+		`"segments":[{"id":"` + story.Segments[0].ID.Hex() + `","script":{"text":"Once upon a time"}}]}`
+	r := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("If-Match", storyETag(&story))
+	r = mux.SetURLVars(r, map[string]string{"id": story.ID.Hex()})
+	r = r.WithContext(context.WithValue(r.Context(), userKey{}, owner))
+
+	w := httptest.NewRecorder()
+	updateStory(w, r)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422: %s", w.Code, w.Body)
+	}
+	if !strings.Contains(w.Body.String(), "segments[0].audio.url") {
+		t.Errorf("body = %s, want the missing audio listed", w.Body)
+	}
+
+	stored, err := storyRepo.Get(context.Background(), story.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.IsPublished {
+		t.Error("story got published")
+	}
+}
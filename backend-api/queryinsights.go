@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"rosetta/querystats"
+)
+
+const (
+	defaultInsightsLimit = 20
+	maxInsightsLimit     = 200
+	// Plans are re-explained at most this often per shape
+	explainMaxAge = 5 * time.Minute
+)
+
+var queryStats = querystats.NewRecorder(querystats.DefaultMaxShapes)
+
+// getQueryInsights lists the query shapes the API has sent to MongoDB,
+// costliest first. explain=true also explains the listed shapes whose plan
+// is missing or stale, to show whether they use an index.
+func getQueryInsights(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit := defaultInsightsLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxInsightsLimit {
+			httpError(w, fmt.Sprintf("limit must be between 1 and %d", maxInsightsLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	if query.Get("explain") == "true" {
+		queryStats.Explain(r.Context(), client, limit, explainMaxAge)
+	}
+
+	snapshot := queryStats.Snapshot()
+	if len(snapshot.Insights) > limit {
+		snapshot.Insights = snapshot.Insights[:limit]
+	}
+	writeResponse(w, r, http.StatusOK, snapshot)
+}
+
+func resetQueryInsights(w http.ResponseWriter, r *http.Request) {
+	queryStats.Reset()
+	w.WriteHeader(http.StatusNoContent)
+}
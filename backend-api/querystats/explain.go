@@ -0,0 +1,131 @@
+package querystats
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Plan summarises how MongoDB executes a shape, from explaining its latest
+// sample with executionStats verbosity. Explain never applies writes.
+type Plan struct {
+	Stages         []string  `json:"stages"`
+	UsesIndex      bool      `json:"uses_index"`
+	CollectionScan bool      `json:"collection_scan"`
+	KeysExamined   int64     `json:"keys_examined"`
+	DocsExamined   int64     `json:"docs_examined"`
+	Returned       int64     `json:"returned"`
+	ExplainedAt    time.Time `json:"explained_at"`
+	Error          string    `json:"error,omitempty"`
+}
+
+var indexStages = map[string]bool{"IXSCAN": true, "IDHACK": true, "COUNT_SCAN": true, "DISTINCT_SCAN": true, "TEXT": true, "TEXT_MATCH": true, "EXPRESS_IXSCAN": true}
+
+// Explain plans the costliest shapes whose plan is missing or older than
+// maxAge, at most limit of them, so repeated calls sample rather than
+// re-explain everything.
+func (r *Recorder) Explain(ctx context.Context, client *mongo.Client, limit int, maxAge time.Duration) {
+	type job struct {
+		key, database string
+		sample        bson.Raw
+	}
+	var jobs []job
+
+	snapshot := r.Snapshot()
+	r.mu.Lock()
+	for _, insight := range snapshot.Insights {
+		if len(jobs) >= limit {
+			break
+		}
+		if plan := r.plans[insight.key]; plan != nil && time.Since(plan.ExplainedAt) < maxAge {
+			continue
+		}
+		if stored, ok := r.insights[insight.key]; ok && stored.sample != nil {
+			jobs = append(jobs, job{key: insight.key, database: insight.Database, sample: stored.sample})
+		}
+	}
+	r.mu.Unlock()
+
+	for _, j := range jobs {
+		plan := explain(ctx, client.Database(j.database), j.sample)
+		r.mu.Lock()
+		r.plans[j.key] = plan
+		r.mu.Unlock()
+	}
+}
+
+func explain(ctx context.Context, db *mongo.Database, sample bson.Raw) *Plan {
+	plan := &Plan{ExplainedAt: time.Now()}
+
+	// Session, cluster time and similar fields belong to the original call
+	elements, _ := sample.Elements()
+	command := bson.D{}
+	for _, element := range elements {
+		key := element.Key()
+		if strings.HasPrefix(key, "$") || key == "lsid" || key == "txnNumber" || key == "readConcern" || key == "writeConcern" {
+			continue
+		}
+		command = append(command, bson.E{Key: key, Value: element.Value()})
+	}
+
+	var result bson.M
+	err := db.RunCommand(ctx, bson.D{{Key: "explain", Value: command}, {Key: "verbosity", Value: "executionStats"}}).Decode(&result)
+	if err != nil {
+		plan.Error = err.Error()
+		return plan
+	}
+
+	walkPlan(result, plan)
+	for _, stage := range plan.Stages {
+		plan.UsesIndex = plan.UsesIndex || indexStages[stage]
+		plan.CollectionScan = plan.CollectionScan || stage == "COLLSCAN"
+	}
+	return plan
+}
+
+// walkPlan collects stage names and execution counters from anywhere in
+// the explain output, whose layout differs between commands, aggregation
+// stages and server versions. Rejected plans are skipped.
+func walkPlan(value interface{}, plan *Plan) {
+	switch v := value.(type) {
+	case bson.M:
+		if stage, ok := v["stage"].(string); ok {
+			plan.Stages = append(plan.Stages, stage)
+		}
+		if stats, ok := v["executionStats"].(bson.M); ok {
+			plan.KeysExamined += toInt64(stats["totalKeysExamined"])
+			plan.DocsExamined += toInt64(stats["totalDocsExamined"])
+			plan.Returned += toInt64(stats["nReturned"])
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			if key != "rejectedPlans" && key != "executionStats" && key != "allPlansExecution" {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			walkPlan(v[key], plan)
+		}
+	case bson.A:
+		for _, child := range v {
+			walkPlan(child, plan)
+		}
+	}
+}
+
+func toInt64(value interface{}) int64 {
+	switch n := value.(type) {
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	case float64:
+		return int64(n)
+	}
+	return 0
+}
@@ -0,0 +1,181 @@
+// Package querystats aggregates the queries the API sends to MongoDB by
+// shape, i.e. with literal values masked, and explains sampled queries to
+// show whether they are served by an index.
+package querystats
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// DefaultMaxShapes bounds the memory used by a Recorder. Queries of new
+// shapes beyond it are counted as dropped.
+const DefaultMaxShapes = 500
+
+// tracked lists the commands that read or write documents, and where their
+// filter lives.
+var tracked = map[string]string{
+	"find":          "filter",
+	"aggregate":     "pipeline",
+	"count":         "query",
+	"distinct":      "query",
+	"findAndModify": "query",
+	"update":        "updates",
+	"delete":        "deletes",
+}
+
+// Insight is the aggregate of all queries of one shape.
+type Insight struct {
+	Command     string    `json:"command"`
+	Database    string    `json:"database"`
+	Collection  string    `json:"collection"`
+	Shape       string    `json:"shape"`
+	Count       int64     `json:"count"`
+	Errors      int64     `json:"errors"`
+	TotalMillis float64   `json:"total_ms"`
+	MeanMillis  float64   `json:"mean_ms"`
+	MaxMillis   float64   `json:"max_ms"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+	Plan        *Plan     `json:"plan,omitempty"`
+
+	key    string
+	sample bson.Raw
+}
+
+type pending struct {
+	key     string
+	started time.Time
+}
+
+// Recorder collects insights from driver command events. It is safe for
+// concurrent use.
+type Recorder struct {
+	mu        sync.Mutex
+	maxShapes int
+	insights  map[string]*Insight
+	pending   map[int64]pending
+	dropped   int64
+	since     time.Time
+	plans     map[string]*Plan
+}
+
+func NewRecorder(maxShapes int) *Recorder {
+	r := &Recorder{maxShapes: maxShapes, plans: make(map[string]*Plan)}
+	r.Reset()
+	return r
+}
+
+// Reset forgets everything recorded so far, e.g. to measure a release.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.insights = make(map[string]*Insight)
+	r.pending = make(map[int64]pending)
+	r.dropped = 0
+	r.since = time.Now()
+}
+
+// Monitor returns a command monitor that feeds the recorder.
+func (r *Recorder) Monitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, e *event.CommandStartedEvent) { r.started(e) },
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			r.finished(e.RequestID, e.Duration, false)
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			r.finished(e.RequestID, e.Duration, true)
+		},
+	}
+}
+
+func (r *Recorder) started(e *event.CommandStartedEvent) {
+	field, ok := tracked[e.CommandName]
+	if !ok {
+		return
+	}
+	collection, _ := e.Command.Lookup(e.CommandName).StringValueOK()
+	shape := shapeOf(e.Command, field)
+	key := strings.Join([]string{e.DatabaseName, collection, e.CommandName, shape}, "\x00")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	insight, ok := r.insights[key]
+	if !ok {
+		if len(r.insights) >= r.maxShapes {
+			r.dropped++
+			return
+		}
+		insight = &Insight{
+			Command:    e.CommandName,
+			Database:   e.DatabaseName,
+			Collection: collection,
+			Shape:      shape,
+			FirstSeen:  time.Now(),
+			key:        key,
+		}
+		r.insights[key] = insight
+	}
+	// The latest query of a shape is kept as its sample for explain
+	insight.sample = append(bson.Raw(nil), e.Command...)
+	r.pending[e.RequestID] = pending{key: key, started: time.Now()}
+}
+
+func (r *Recorder) finished(requestID int64, duration time.Duration, failed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.pending[requestID]
+	if !ok {
+		return
+	}
+	delete(r.pending, requestID)
+
+	insight, ok := r.insights[p.key]
+	if !ok {
+		return
+	}
+	millis := float64(duration) / float64(time.Millisecond)
+	insight.Count++
+	if failed {
+		insight.Errors++
+	}
+	insight.TotalMillis += millis
+	insight.MaxMillis = max(insight.MaxMillis, millis)
+	insight.LastSeen = p.started
+}
+
+// Snapshot is a point-in-time copy of the recorder's state.
+type Snapshot struct {
+	Since    time.Time `json:"since"`
+	Dropped  int64     `json:"dropped"`
+	Insights []Insight `json:"insights"`
+}
+
+// Snapshot returns the insights ordered by total time spent, costliest
+// first, each with its last known plan.
+func (r *Recorder) Snapshot() Snapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	snapshot := Snapshot{Since: r.since, Dropped: r.dropped, Insights: make([]Insight, 0, len(r.insights))}
+	for _, insight := range r.insights {
+		copied := *insight
+		if copied.Count > 0 {
+			copied.MeanMillis = copied.TotalMillis / float64(copied.Count)
+		}
+		copied.Plan = r.plans[insight.key]
+		snapshot.Insights = append(snapshot.Insights, copied)
+	}
+	sort.Slice(snapshot.Insights, func(i, j int) bool {
+		if snapshot.Insights[i].TotalMillis != snapshot.Insights[j].TotalMillis {
+			return snapshot.Insights[i].TotalMillis > snapshot.Insights[j].TotalMillis
+		}
+		return snapshot.Insights[i].key < snapshot.Insights[j].key
+	})
+	return snapshot
+}
@@ -0,0 +1,89 @@
+package querystats
+
+import (
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+const placeholder = "?"
+
+// shapeOf renders the parts of a command that decide its plan as extended
+// JSON, with literal values replaced by "?". Sort orders are kept since
+// they select indexes too.
+func shapeOf(command bson.Raw, field string) string {
+	shape := bson.D{}
+	switch field {
+	case "pipeline":
+		value := command.Lookup("pipeline")
+		stages, _ := value.Array().Values()
+		pipeline := bson.A{}
+		for _, stage := range stages {
+			doc, ok := stage.DocumentOK()
+			if !ok {
+				continue
+			}
+			elements, _ := doc.Elements()
+			for _, element := range elements {
+				if element.Key() == "$sort" {
+					pipeline = append(pipeline, bson.D{{Key: "$sort", Value: element.Value()}})
+					continue
+				}
+				pipeline = append(pipeline, bson.D{{Key: element.Key(), Value: mask(element.Value())}})
+			}
+		}
+		shape = append(shape, bson.E{Key: "pipeline", Value: pipeline})
+	case "updates", "deletes":
+		// Bulk writes are shaped by their first statement
+		values, _ := command.Lookup(field).Array().Values()
+		if len(values) > 0 {
+			if statement, ok := values[0].DocumentOK(); ok {
+				shape = append(shape, bson.E{Key: "q", Value: mask(statement.Lookup("q"))})
+			}
+		}
+	default:
+		shape = append(shape, bson.E{Key: field, Value: mask(command.Lookup(field))})
+	}
+	if sortValue, err := command.LookupErr("sort"); err == nil {
+		shape = append(shape, bson.E{Key: "sort", Value: sortValue})
+	}
+
+	out, err := bson.MarshalExtJSON(shape, false, false)
+	if err != nil {
+		return placeholder
+	}
+	return string(out)
+}
+
+// mask keeps the keys and operators of a value, in sorted order, and
+// replaces the literals.
+// Arrays of documents, as in $or, are masked element-wise; arrays of
+// literals, as in $in, collapse to a single "?".
+func mask(value bson.RawValue) interface{} {
+	switch value.Type {
+	case bsontype.EmbeddedDocument:
+		elements, _ := value.Document().Elements()
+		doc := make(bson.D, 0, len(elements))
+		for _, element := range elements {
+			doc = append(doc, bson.E{Key: element.Key(), Value: mask(element.Value())})
+		}
+		// Filters built from Go maps list their keys in random order
+		sort.Slice(doc, func(i, j int) bool { return doc[i].Key < doc[j].Key })
+		return doc
+	case bsontype.Array:
+		values, _ := value.Array().Values()
+		masked := bson.A{}
+		for _, v := range values {
+			if v.Type != bsontype.EmbeddedDocument {
+				return placeholder
+			}
+			masked = append(masked, mask(v))
+		}
+		return masked
+	case 0:
+		return nil
+	default:
+		return placeholder
+	}
+}
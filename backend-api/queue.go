@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"rosetta/domain"
+	"rosetta/models"
+)
+
+const (
+	queuePollInterval = 5 * time.Second
+	// queueLease hides a claimed job from other workers while it runs
+	queueLease          = 5 * time.Minute
+	queueBackoff        = time.Minute
+	maxQueueBackoff     = time.Hour
+	defaultMaxAttempts  = 10
+	maxListedQueuedJobs = 100
+)
+
+// queueHandler runs the queued jobs of one kind. A job may run more than
+// once, e.g. when its instance dies halfway, so handlers must be safe to
+// repeat.
+type queueHandler struct {
+	run func(ctx context.Context, payload bson.M) error
+	// maxAttempts before the job is dead, defaultMaxAttempts if zero
+	maxAttempts int
+	// giveUp, if set, is called with the last error once the job is dead
+	giveUp func(ctx context.Context, payload bson.M, err error)
+}
+
+var queueHandlers = map[string]queueHandler{
+	queueKindMediaCleanup:   {run: runMediaCleanupJob, maxAttempts: maxCleanupAttempts},
+	queueKindTranscodeAudio: {run: runTranscodeJob, maxAttempts: maxTranscodeAttempts},
+	queueKindAnalyzeAudio:   {run: runAudioAnalysisJob, maxAttempts: maxAnalysisAttempts},
+	// Jobs clients poll, started with queueJob
+	queueKindGenerateAudio:  trackedHandler(runNarrationJob, maxNarrationAttempts),
+	queueKindSplitRecording: trackedHandler(runSplitRecordingJob, maxSplitAttempts),
+	queueKindTrimAudio:      trackedHandler(runTrimJob, maxTrimAttempts),
+}
+
+// enqueueJob queues a job of kind to run after delay. payload is stored as
+// a document and handed to the handler as such.
+func enqueueJob(ctx context.Context, kind string, payload interface{}, delay time.Duration) error {
+	doc, err := toDocument(payload)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	_, err = jobQueueCollection().InsertOne(ctx, models.QueuedJob{
+		Kind:          kind,
+		Payload:       doc,
+		Status:        models.QueuedJobPending,
+		NextAttemptAt: now.Add(delay),
+		CreatedAt:     now,
+	})
+	return err
+}
+
+func toDocument(v interface{}) (bson.M, error) {
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.M
+	err = bson.Unmarshal(data, &doc)
+	return doc, err
+}
+
+// decodePayload is the inverse of toDocument, for handlers.
+func decodePayload(payload bson.M, v interface{}) error {
+	data, err := bson.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(data, v)
+}
+
+// runQueueWorker runs due jobs one at a time until ctx is done. Every
+// instance starts QUEUE_WORKERS of them.
+func runQueueWorker(ctx context.Context) {
+	ticker := time.NewTicker(queuePollInterval)
+	defer ticker.Stop()
+	for {
+		for ctx.Err() == nil {
+			ran, err := runNextQueuedJob(ctx)
+			if err != nil {
+				log.Printf("job queue: %v", err)
+			}
+			if !ran {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runNextQueuedJob claims and runs one due job, reporting false when there
+// is none.
+func runNextQueuedJob(ctx context.Context) (bool, error) {
+	now := time.Now()
+	var job models.QueuedJob
+	err := jobQueueCollection().FindOneAndUpdate(ctx,
+		bson.M{"status": models.QueuedJobPending, "next_attempt_at": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"next_attempt_at": now.Add(queueLease)}, "$inc": bson.M{"attempts": 1}},
+		options.FindOneAndUpdate().SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).SetReturnDocument(options.After),
+	).Decode(&job)
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// An instance running an older version may not know newer kinds
+	handler, ok := queueHandlers[job.Kind]
+	if ok {
+		err = handler.run(ctx, job.Payload)
+	} else {
+		err = fmt.Errorf("no handler for %s jobs", job.Kind)
+	}
+	if err == nil {
+		_, err = jobQueueCollection().DeleteOne(ctx, bson.M{"_id": job.ID})
+		return true, err
+	}
+	if ctx.Err() != nil {
+		// Interrupted by shutdown: once the lease runs out another instance
+		// retries it, without counting the attempt against the job
+		releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err = jobQueueCollection().UpdateByID(releaseCtx, job.ID, bson.M{"$inc": bson.M{"attempts": -1}})
+		return true, err
+	}
+
+	// Retry with exponential backoff, then leave the job for an operator
+	maxAttempts := handler.maxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	set := bson.M{"last_error": err.Error()}
+	if job.Attempts >= maxAttempts {
+		set["status"] = models.QueuedJobDead
+		set["dead_at"] = time.Now()
+		log.Printf("giving up %s job %s after %d attempts: %v", job.Kind, job.ID.Hex(), job.Attempts, err)
+		if handler.giveUp != nil {
+			handler.giveUp(ctx, job.Payload, err)
+		}
+	} else {
+		set["next_attempt_at"] = time.Now().Add(min(queueBackoff<<min(job.Attempts, 16), maxQueueBackoff))
+	}
+	if _, updateErr := jobQueueCollection().UpdateByID(ctx, job.ID, bson.M{"$set": set}); updateErr != nil {
+		return true, updateErr
+	}
+	return true, fmt.Errorf("%s job %s: %w", job.Kind, job.ID.Hex(), err)
+}
+
+// listQueuedJobs lists the queued jobs that have failed, whether they are
+// still being retried or dead. The status and kind query parameters narrow
+// the list, e.g. status=pending to see jobs that haven't failed yet too.
+func listQueuedJobs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := bson.M{}
+	switch status := query.Get("status"); status {
+	case "":
+		filter["last_error"] = bson.M{"$exists": true}
+	case models.QueuedJobPending, models.QueuedJobDead:
+		filter["status"] = status
+	default:
+		httpError(w, "Status must be pending or dead", http.StatusBadRequest)
+		return
+	}
+	if kind := query.Get("kind"); kind != "" {
+		filter["kind"] = kind
+	}
+
+	cursor, err := jobQueueCollection().Find(r.Context(), filter,
+		options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(maxListedQueuedJobs))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	jobs := []models.QueuedJob{}
+	if err = cursor.All(r.Context(), &jobs); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeResponse(w, r, http.StatusOK, jobs)
+}
+
+// retryQueuedJob gives a dead job a fresh set of attempts, starting now.
+func retryQueuedJob(w http.ResponseWriter, r *http.Request) {
+	jobID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+
+	var job models.QueuedJob
+	err = jobQueueCollection().FindOneAndUpdate(r.Context(),
+		bson.M{"_id": jobID, "status": models.QueuedJobDead},
+		bson.M{
+			"$set":   bson.M{"status": models.QueuedJobPending, "attempts": 0, "next_attempt_at": time.Now()},
+			"$unset": bson.M{"dead_at": ""},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&job)
+	if err == mongo.ErrNoDocuments {
+		writeError(w, domain.New(domain.ErrNotFound, "No dead job with this ID"))
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeResponse(w, r, http.StatusOK, job)
+}
+
+// deleteQueuedJob discards a queued job, dead or not.
+func deleteQueuedJob(w http.ResponseWriter, r *http.Request) {
+	jobID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+
+	res, err := jobQueueCollection().DeleteOne(r.Context(), bson.M{"_id": jobID})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if res.DeletedCount == 0 {
+		writeError(w, domain.New(domain.ErrNotFound, "Job not found"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"rosetta/config"
+	"rosetta/ratelimit"
+)
+
+// routeLimits throttles groups of routes that are expensive or attractive
+// to abuse, each group with buckets of its own.
+type routeLimits struct {
+	auth    *ratelimit.Limiter // sign-up and login
+	writes  *ratelimit.Limiter // creating and changing stories
+	uploads *ratelimit.Limiter // presigned URLs and upload credentials
+}
+
+func newRouteLimits(cfg *config.Config) *routeLimits {
+	return &routeLimits{
+		auth:    ratelimit.New(cfg.AuthRateLimitPerMinute, cfg.AuthRateLimitPerMinute),
+		writes:  ratelimit.New(cfg.WriteRateLimitPerMinute, cfg.WriteRateLimitPerMinute),
+		uploads: ratelimit.New(cfg.UploadRateLimitPerMinute, cfg.UploadRateLimitPerMinute),
+	}
+}
+
+// rateLimit answers 429 with Retry-After once a client has used up its
+// bucket in limiter.
+func rateLimit(limiter *ratelimit.Limiter) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ok, wait := limiter.Allow(rateLimitKey(r))
+			if !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				httpError(w, "Too many requests", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// throttle is rateLimit for a single handler.
+func throttle(limiter *ratelimit.Limiter, next http.HandlerFunc) http.HandlerFunc {
+	return rateLimit(limiter)(next).ServeHTTP
+}
+
+// rateLimitKey names the client a request counts against: the signed-in
+// user, who may share an IP with a whole classroom, or else the IP.
+func rateLimitKey(r *http.Request) string {
+	if userID, ok := currentUser(r.Context()); ok {
+		return "user:" + userID.Hex()
+	}
+	return "ip:" + clientIP(r)
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
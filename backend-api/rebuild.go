@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"rosetta/domain"
+	"rosetta/models"
+	"rosetta/repository"
+)
+
+// Derived data that can be rebuilt from the stories collection.
+const (
+	rebuildFingerprints  = "fingerprints"
+	rebuildContentHashes = "content_hashes"
+	rebuildPublishedAt   = "published_at"
+	rebuildSearchIndex   = "search_index"
+)
+
+var rebuildTargets = []string{rebuildFingerprints, rebuildContentHashes, rebuildPublishedAt, rebuildSearchIndex}
+
+const jobKindRebuild = "rebuild"
+
+type rebuildRequest struct {
+	Targets []string `json:"targets"`
+}
+
+// startRebuild starts a job recomputing the requested derived data, all of
+// it when no targets are given. Its progress is reported per target. Only
+// one rebuild runs at a time.
+func startRebuild(w http.ResponseWriter, r *http.Request) {
+	var request rebuildRequest
+	if r.ContentLength != 0 {
+		if err := decodeRequest(r, &request); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	targets := request.Targets
+	if len(targets) == 0 {
+		targets = rebuildTargets
+	}
+	for _, target := range targets {
+		if !slices.Contains(rebuildTargets, target) {
+			writeError(w, domain.New(domain.ErrInvalid, fmt.Sprintf("Unknown target %q", target)))
+			return
+		}
+	}
+
+	running, err := jobRunning(r.Context(), jobKindRebuild)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if running {
+		writeError(w, domain.New(domain.ErrConflict, "A rebuild is already running"))
+		return
+	}
+
+	startJob(w, r, jobKindRebuild, func(ctx context.Context, run *jobRun) (interface{}, error) {
+		progress := map[string]*models.RebuildProgress{}
+		for _, target := range targets {
+			progress[target] = &models.RebuildProgress{}
+		}
+		run.report(ctx, progress)
+
+		for _, target := range targets {
+			if err := rebuild(ctx, run, progress, target); err != nil {
+				return nil, err
+			}
+		}
+		return progress, nil
+	})
+}
+
+// rebuild recomputes target, reporting progress as it goes.
+func rebuild(ctx context.Context, run *jobRun, all map[string]*models.RebuildProgress, target string) error {
+	progress := all[target]
+	if target == rebuildSearchIndex {
+		progress.Total = 1
+		err := rebuildSearchTextIndex(ctx)
+		if err == nil {
+			progress.Processed, progress.Updated = 1, 1
+		}
+		return err
+	}
+
+	filter := bson.M{}
+	if target == rebuildPublishedAt {
+		filter = bson.M{"is_published": true, "published_at": nil}
+	}
+	total, err := storiesCollection().CountDocuments(ctx, filter)
+	if err != nil {
+		return err
+	}
+	progress.Total = total
+
+	cursor, err := storiesCollection().Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var story models.Story
+		if err = cursor.Decode(&story); err != nil {
+			return err
+		}
+
+		set, err := rebuildStory(ctx, target, &story)
+		switch {
+		case err != nil:
+			progress.Failed++
+			log.Printf("rebuild %s of story %s: %v", target, story.ID.Hex(), err)
+		case len(set) > 0:
+			// Derived fields don't bump the version; a story changed
+			// meanwhile was re-derived by whoever saved it
+			res, err := storiesCollection().UpdateOne(ctx,
+				bson.M{"_id": story.ID, "version": repository.VersionFilter(story.Version)},
+				bson.M{"$set": set})
+			if err != nil {
+				return err
+			}
+			progress.Updated += res.ModifiedCount
+		}
+		progress.Processed++
+		run.report(ctx, all)
+	}
+	return cursor.Err()
+}
+
+// rebuildStory returns the derived fields of story that are out of date.
+func rebuildStory(ctx context.Context, target string, story *models.Story) (bson.M, error) {
+	switch target {
+	case rebuildFingerprints:
+		if fingerprint := storyFingerprint(story); fingerprint != story.ContentFingerprint {
+			return bson.M{"content_fingerprint": fingerprint}, nil
+		}
+	case rebuildContentHashes:
+		current := story.ContentHash
+		if err := stampContentHash(ctx, story); err != nil {
+			return nil, err
+		}
+		if story.ContentHash != current {
+			return bson.M{"content_hash": story.ContentHash}, nil
+		}
+	case rebuildPublishedAt:
+		// Stories published before published_at was tracked
+		return bson.M{"published_at": story.UpdatedAt}, nil
+	}
+	return nil, nil
+}
+
+// rebuildSearchTextIndex drops the text index and creates it again from
+// the stored stories.
+func rebuildSearchTextIndex(ctx context.Context) error {
+	_, err := storiesCollection().Indexes().DropOne(ctx, repository.SearchIndexName)
+	var cmdErr mongo.CommandError
+	if err != nil && !(errors.As(err, &cmdErr) && cmdErr.Name == "IndexNotFound") {
+		return err
+	}
+	_, err = storiesCollection().Indexes().CreateOne(ctx, repository.SearchIndex())
+	return err
+}
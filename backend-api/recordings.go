@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/api"
+	"rosetta/audiosplit"
+	"rosetta/domain"
+	"rosetta/models"
+	"rosetta/scripttext"
+	"rosetta/sentences"
+	"rosetta/validation"
+)
+
+// minSentencePause is the shortest silence taken for a reader pausing
+// between sentences rather than catching their breath.
+const minSentencePause = 250 * time.Millisecond
+
+const (
+	queueKindSplitRecording = "split_recording"
+	maxSplitAttempts        = 5
+)
+
+// splitRecordingPayload is the payload of split_recording jobs: the track
+// to cut into the sentences Parts, for the user who asked.
+type splitRecordingPayload struct {
+	StoryID  primitive.ObjectID `bson:"story_id"`
+	TrackID  primitive.ObjectID `bson:"track_id"`
+	UserID   primitive.ObjectID `bson:"user_id"`
+	Parts    []string           `bson:"parts"`
+	Language string             `bson:"language"`
+	Position *int               `bson:"position,omitempty"`
+}
+
+// splitRecordingProgress is reported by split_recording jobs.
+type splitRecordingProgress struct {
+	Total     int `json:"total"`
+	Processed int `json:"processed"`
+}
+
+// splitRecording turns an audio track holding a reading of a whole script
+// into segments: one per sentence of the script, each with its part of the
+// recording as audio of its own. The body is that of segments/split, and
+// the segments are inserted the same way. Cutting a long recording takes a
+// while, so it runs as a queued job; the track is left for the author to remove
+// once they have checked the segments.
+func splitRecording(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	storyID, err := primitive.ObjectIDFromHex(vars["id"])
+	if err != nil {
+		httpError(w, "Invalid story ID", http.StatusBadRequest)
+		return
+	}
+	trackID, err := primitive.ObjectIDFromHex(vars["trackId"])
+	if err != nil {
+		httpError(w, "Invalid track ID", http.StatusBadRequest)
+		return
+	}
+
+	var request splitRequest
+	err = decodeRequest(r, &request)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	request.Text = scripttext.Normalize(request.Text)
+	if strings.TrimSpace(request.Text) == "" {
+		httpError(w, "Missing text", http.StatusBadRequest)
+		return
+	}
+
+	story, err := findOwnStory(r.Context(), storyID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	track := findAudioTrack(&story, trackID)
+	if track == nil {
+		writeError(w, domain.New(domain.ErrNotFound, "Audio track not found"))
+		return
+	}
+	if track.Url == "" {
+		writeError(w, domain.New(domain.ErrConflict, "Audio track has not been uploaded yet"))
+		return
+	}
+	if _, _, ok := mediaLocation(track.Url); !ok {
+		writeError(w, domain.New(domain.ErrInvalid, "Only recordings uploaded to the story can be split"))
+		return
+	}
+
+	lang := request.Language
+	if lang == "" {
+		lang = story.Language
+	}
+	parts := sentences.Split(request.Text, lang)
+	if len(story.Segments)+len(parts) > validation.MaxSegments {
+		writeError(w, domain.New(domain.ErrInvalid, fmt.Sprintf("Story can have at most %d segments", validation.MaxSegments)))
+		return
+	}
+
+	// The job saves the segments as the user who started it
+	userID, _ := currentUser(r.Context())
+	queueJob(w, r, queueKindSplitRecording, splitRecordingPayload{
+		StoryID:  storyID,
+		TrackID:  trackID,
+		UserID:   userID,
+		Parts:    parts,
+		Language: lang,
+		Position: request.Position,
+	})
+}
+
+// runSplitRecordingJob cuts the track of a split_recording job into new
+// segments and returns the story with them. The track is looked up again,
+// as it may have changed since the job was queued.
+func runSplitRecordingJob(ctx context.Context, run *jobRun, payload bson.M) (interface{}, error) {
+	var job splitRecordingPayload
+	if err := decodePayload(payload, &job); err != nil {
+		return nil, err
+	}
+	ctx = context.WithValue(ctx, userKey{}, job.UserID)
+	storyID := job.StoryID
+
+	story, err := findOwnStory(ctx, storyID)
+	if err != nil {
+		return nil, err
+	}
+	track := findAudioTrack(&story, job.TrackID)
+	if track == nil || track.Url == "" {
+		return nil, domain.New(domain.ErrNotFound, "Audio track not found")
+	}
+	bucket, key, ok := mediaLocation(track.Url)
+	if !ok {
+		return nil, domain.New(domain.ErrInvalid, "Only recordings uploaded to the story can be split")
+	}
+
+	recording := mediaReader{ctx: ctx, bucket: bucket, key: key}
+	segments, err := cutRecording(ctx, run, storyID, recording, track.Size, job.Parts)
+	var updated models.Story
+	if err == nil {
+		for i := range segments {
+			segments[i].Script = &models.Script{Text: job.Parts[i]}
+			if job.Language != story.Language {
+				segments[i].Script.Language = job.Language
+			}
+		}
+		updated, err = modifyStory(ctx, storyID, func(story *models.Story) error {
+			if err := authorizeStory(ctx, story); err != nil {
+				return err
+			}
+			position := len(story.Segments)
+			if job.Position != nil {
+				position = *job.Position
+			}
+			if position < 0 || position > len(story.Segments) {
+				return domain.New(domain.ErrInvalid, "Position is out of range")
+			}
+			created := append([]models.Segment{}, story.Segments[:position]...)
+			created = append(created, segments...)
+			story.Segments = append(created, story.Segments[position:]...)
+			return nil
+		})
+	}
+	if err != nil {
+		// The audio cut so far has no segment to belong to
+		for _, segment := range segments {
+			scheduleMediaCleanup(ctx, storyID, segmentMediaPrefix(storyID, segment.ID))
+		}
+		return nil, err
+	}
+	for _, segment := range segments {
+		scheduleAudioProcessing(ctx, storyID, segment.ID, segment.Audio.Key)
+	}
+	return api.StoryFromModel(&updated), nil
+}
+
+// cutRecording lines parts up with the recording and stores each part's
+// audio under a fresh audio key of a new segment. On failure, it returns
+// the segments whose audio may have been stored already.
+func cutRecording(ctx context.Context, run *jobRun, storyID primitive.ObjectID, recording mediaReader, size int64, parts []string) ([]models.Segment, error) {
+	wav, err := audiosplit.OpenWAV(recording, size)
+	if errors.Is(err, audiosplit.ErrUnsupported) {
+		return nil, domain.New(domain.ErrInvalid, "Only 16-bit PCM WAV recordings can be split")
+	}
+	if err != nil {
+		return nil, err
+	}
+	pauses, err := wav.Pauses(minSentencePause)
+	if err != nil {
+		return nil, err
+	}
+	boundaries := audiosplit.Align(wav.Duration(), pauses, parts)
+
+	progress := &splitRecordingProgress{Total: len(parts)}
+	run.report(ctx, progress)
+	segments := make([]models.Segment, 0, len(parts))
+	for i := range parts {
+		segment := models.Segment{ID: primitive.NewObjectID()}
+		data, err := wav.Cut(boundaries[i], boundaries[i+1])
+		if err != nil {
+			return segments, err
+		}
+		segments = append(segments, segment)
+
+		key := newAudioKey(storyID, segment.ID)
+		input := &s3.PutObjectInput{
+			Bucket:      aws.String(writeBucket()),
+			Key:         aws.String(key),
+			Body:        bytes.NewReader(data),
+			ContentType: aws.String("audio/wav"),
+		}
+		encryptPut(input)
+		if _, err = s3Client.PutObjectWithContext(ctx, input); err != nil {
+			return segments, err
+		}
+		segments[i].Audio = &models.Audio{
+			Url:         bucketMediaURL(writeBucket(), key),
+			Key:         key,
+			Size:        int64(len(data)),
+			ContentType: "audio/wav",
+			DurationMs:  (boundaries[i+1] - boundaries[i]).Milliseconds(),
+		}
+
+		progress.Processed++
+		run.report(ctx, progress)
+	}
+	return segments, nil
+}
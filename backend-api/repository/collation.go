@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"strings"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// collationLocales are the locales MongoDB collates by, in its spelling.
+// The first is the fallback: English uses the default Unicode order, which
+// already sorts accented letters next to their base letters.
+var collationLocales = []string{
+	"en", "af", "am", "ar", "as", "az", "be", "bg", "bn", "bo", "bs", "bs_Cyrl",
+	"ca", "chr", "cs", "cy", "da", "de", "de_AT", "dsb", "dz", "ee", "el", "eo",
+	"es", "et", "fa", "fa_AF", "fi_FI", "fil", "fo", "fr", "fr_CA", "ga", "gl",
+	"gu", "ha", "haw", "he", "hi", "hr", "hsb", "hu", "hy", "id", "ig", "is",
+	"it", "ja", "ka", "kk", "kl", "km", "kn", "ko", "kok", "ky", "lb", "lkt",
+	"ln", "lo", "lt", "lv", "mk", "ml", "mn", "mr", "ms", "mt", "my", "nb", "ne",
+	"nl", "nn", "om", "or", "pa", "pl", "ps", "pt", "ro", "ru", "se", "si", "sk",
+	"sl", "smn", "sq", "sr", "sr_Latn", "sv", "sw", "ta", "te", "th", "to", "tr",
+	"ug", "uk", "ur", "vi", "wae", "yi", "yo", "zh", "zh_Hant", "zu",
+}
+
+var collationMatcher = func() language.Matcher {
+	tags := make([]language.Tag, len(collationLocales))
+	for i, locale := range collationLocales {
+		tags[i] = language.Make(strings.ReplaceAll(locale, "_", "-"))
+	}
+	return language.NewMatcher(tags)
+}()
+
+// CollationLocale returns the supported locale closest to tag, a BCP 47
+// language tag such as sv or zh-Hant, or the fallback if none is close.
+// ok is false if tag is not a language tag at all.
+func CollationLocale(tag string) (locale string, ok bool) {
+	parsed, err := language.Parse(tag)
+	if err != nil {
+		return "", false
+	}
+	_, i, confidence := collationMatcher.Match(parsed)
+	if confidence == language.No {
+		return collationLocales[0], true
+	}
+	return collationLocales[i], true
+}
+
+// TitleCollation sorts titles the way readers of locale expect, with
+// numbers compared by value so "Part 2" comes before "Part 10".
+func TitleCollation(locale string) *options.Collation {
+	if locale == "" {
+		locale = collationLocales[0]
+	}
+	return &options.Collation{Locale: locale, NumericOrdering: true}
+}
+
+// titleCollator orders titles like TitleCollation does in MongoDB.
+func titleCollator(locale string) *collate.Collator {
+	if locale == "" {
+		locale = collationLocales[0]
+	}
+	return collate.New(language.Make(strings.ReplaceAll(locale, "_", "-")), collate.Numeric)
+}
@@ -0,0 +1,246 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/domain"
+	"rosetta/models"
+)
+
+// Memory keeps stories in memory, for tests and local runs. Stories are
+// copied in and out, so callers can't change what is stored by accident.
+// Search ranks by how often the query words occur, titles counting five
+// times, which approximates the MongoDB text index.
+type Memory struct {
+	mu      sync.RWMutex
+	stories map[primitive.ObjectID]models.Story
+}
+
+func NewMemory() *Memory {
+	return &Memory{stories: make(map[primitive.ObjectID]models.Story)}
+}
+
+func (m *Memory) Create(ctx context.Context, story *models.Story) error {
+	stored, err := clone(story)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.stories[story.ID]; ok {
+		return domain.New(domain.ErrConflict, "Story already exists")
+	}
+	m.stories[story.ID] = stored
+	return nil
+}
+
+func (m *Memory) Get(ctx context.Context, id primitive.ObjectID) (models.Story, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	story, ok := m.stories[id]
+	if !ok {
+		return models.Story{}, errNotFound
+	}
+	return clone(&story)
+}
+
+func (m *Memory) List(ctx context.Context, query StoryQuery) ([]models.Story, int64, error) {
+	m.mu.RLock()
+	var matches []models.Story
+	for _, story := range m.stories {
+		if !query.UpdatedSince.IsZero() && !story.UpdatedAt.After(query.UpdatedSince) {
+			continue
+		}
+		if !query.AfterID.IsZero() && story.ID.Hex() <= query.AfterID.Hex() {
+			continue
+		}
+		if !hasMetadata(&story, query.Metadata) {
+			continue
+		}
+		if query.Language != "" && story.Language != query.Language {
+			continue
+		}
+		if query.AgeRatings != nil && !slices.Contains(query.AgeRatings, story.AgeRating) {
+			continue
+		}
+		if slices.ContainsFunc(story.ContentWarnings, func(w string) bool { return slices.Contains(query.ExcludeWarnings, w) }) {
+			continue
+		}
+		if !visible(&story, query.Listed, query.Owner) {
+			continue
+		}
+		matches = append(matches, story)
+	}
+	m.mu.RUnlock()
+
+	collator := titleCollator(query.Locale)
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		switch query.Sort {
+		case SortTitle:
+			if c := collator.CompareString(a.Title, b.Title); c != 0 {
+				return c < 0
+			}
+		case SortTitleReverse:
+			if c := collator.CompareString(a.Title, b.Title); c != 0 {
+				return c > 0
+			}
+			return a.ID.Hex() > b.ID.Hex()
+		case SortUpdatedAt:
+			if !a.UpdatedAt.Equal(b.UpdatedAt) {
+				return a.UpdatedAt.Before(b.UpdatedAt)
+			}
+		case SortUpdatedAtReverse:
+			if !a.UpdatedAt.Equal(b.UpdatedAt) {
+				return a.UpdatedAt.After(b.UpdatedAt)
+			}
+			return a.ID.Hex() > b.ID.Hex()
+		}
+		return a.ID.Hex() < b.ID.Hex()
+	})
+
+	page := paginate(matches, query.Offset, query.Limit)
+	stories := make([]models.Story, 0, len(page))
+	for i := range page {
+		story, err := clone(&page[i])
+		if err != nil {
+			return nil, 0, err
+		}
+		stories = append(stories, story)
+	}
+	return stories, int64(len(matches)), nil
+}
+
+func (m *Memory) Update(ctx context.Context, story *models.Story, version int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.stories[story.ID]
+	if !ok || stored.Version != version {
+		return false, nil
+	}
+
+	updated, err := clone(story)
+	if err != nil {
+		return false, err
+	}
+	// Only the content is replaced, as with the MongoDB update
+	updated.CreatedAt = stored.CreatedAt
+	updated.OwnerID = stored.OwnerID
+	updated.Moderation = stored.Moderation
+	updated.Lock = stored.Lock
+	m.stories[story.ID] = updated
+	return true, nil
+}
+
+func (m *Memory) Delete(ctx context.Context, id primitive.ObjectID, before time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	story, ok := m.stories[id]
+	if !ok || (!before.IsZero() && !story.UpdatedAt.Before(before)) {
+		return false, nil
+	}
+	delete(m.stories, id)
+	return true, nil
+}
+
+func (m *Memory) Search(ctx context.Context, query SearchQuery) ([]SearchHit, int64, error) {
+	words := strings.Fields(strings.ToLower(query.Text))
+
+	m.mu.RLock()
+	var hits []SearchHit
+	for _, story := range m.stories {
+		if !visible(&story, query.Listed, query.Owner) {
+			continue
+		}
+		if query.Language != "" && story.Language != query.Language {
+			continue
+		}
+		if score := textScore(&story, words); score > 0 {
+			hits = append(hits, SearchHit{Story: story, Score: score})
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID.Hex() < hits[j].ID.Hex()
+	})
+
+	page := paginate(hits, query.Offset, query.Limit)
+	results := make([]SearchHit, 0, len(page))
+	for _, hit := range page {
+		story, err := clone(&hit.Story)
+		if err != nil {
+			return nil, 0, err
+		}
+		results = append(results, SearchHit{Story: story, Score: hit.Score})
+	}
+	return results, int64(len(hits)), nil
+}
+
+func hasMetadata(story *models.Story, entries map[string]string) bool {
+	for key, value := range entries {
+		if stored, ok := story.Metadata[key]; !ok || stored != value {
+			return false
+		}
+	}
+	return true
+}
+
+func textScore(story *models.Story, words []string) float64 {
+	score := 0
+	title := strings.Fields(strings.ToLower(story.Title))
+	for _, word := range words {
+		score += 5 * count(title, word)
+		for _, segment := range story.Segments {
+			if segment.Script != nil {
+				score += count(strings.Fields(strings.ToLower(segment.Script.Text)), word)
+			}
+		}
+	}
+	return float64(score)
+}
+
+func count(fields []string, word string) int {
+	n := 0
+	for _, field := range fields {
+		if strings.Trim(field, ".,;:!?\"'()") == word {
+			n++
+		}
+	}
+	return n
+}
+
+func paginate[T any](items []T, offset, limit int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	items = items[offset:]
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
+
+// clone deep-copies a story the way MongoDB would store it, so the memory
+// repository hands out exactly what a round trip through the database does.
+func clone(story *models.Story) (models.Story, error) {
+	var copied models.Story
+	data, err := bson.Marshal(story)
+	if err != nil {
+		return copied, err
+	}
+	err = bson.Unmarshal(data, &copied)
+	return copied, err
+}
@@ -0,0 +1,12 @@
+package repository_test
+
+import (
+	"testing"
+
+	"rosetta/repository"
+	"rosetta/repository/repositorytest"
+)
+
+func TestMemory(t *testing.T) {
+	repositorytest.TestStories(t, repository.NewMemory())
+}
@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"rosetta/models"
+)
+
+const SearchIndexName = "story_text"
+
+// SearchIndex is the text index Search needs. Stories come in many
+// languages, so there is no stemming, and the override field is renamed so
+// the story's own language field is not read as one.
+func SearchIndex() mongo.IndexModel {
+	return mongo.IndexModel{
+		Keys: bson.D{{Key: "title", Value: "text"}, {Key: "segments.script.text", Value: "text"}},
+		Options: options.Index().
+			SetName(SearchIndexName).
+			SetWeights(bson.D{{Key: "title", Value: 5}, {Key: "segments.script.text", Value: 1}}).
+			SetDefaultLanguage("none").
+			SetLanguageOverride("text_search_language"),
+	}
+}
+
+// Mongo stores stories in a MongoDB collection, which needs SearchIndex for
+// Search.
+type Mongo struct {
+	collection func() *mongo.Collection
+}
+
+// NewMongo takes the collection as a function so the repository can be set
+// up before the client connects.
+func NewMongo(collection func() *mongo.Collection) *Mongo {
+	return &Mongo{collection: collection}
+}
+
+func (m *Mongo) Create(ctx context.Context, story *models.Story) error {
+	_, err := m.collection().InsertOne(ctx, story)
+	return err
+}
+
+func (m *Mongo) Get(ctx context.Context, id primitive.ObjectID) (models.Story, error) {
+	var story models.Story
+	err := m.collection().FindOne(ctx, bson.M{"_id": id}).Decode(&story)
+	if err == mongo.ErrNoDocuments {
+		return story, errNotFound
+	}
+	return story, err
+}
+
+func (m *Mongo) List(ctx context.Context, query StoryQuery) ([]models.Story, int64, error) {
+	filter := bson.M{}
+	if !query.UpdatedSince.IsZero() {
+		filter["updated_at"] = bson.M{"$gt": query.UpdatedSince}
+	}
+	if !query.AfterID.IsZero() {
+		filter["_id"] = bson.M{"$gt": query.AfterID}
+	}
+	for key, value := range query.Metadata {
+		filter["metadata."+key] = value
+	}
+	if query.Language != "" {
+		filter["language"] = query.Language
+	}
+	if query.AgeRatings != nil {
+		filter["age_rating"] = bson.M{"$in": query.AgeRatings}
+	}
+	if len(query.ExcludeWarnings) > 0 {
+		filter["content_warnings"] = bson.M{"$nin": query.ExcludeWarnings}
+	}
+	scope(filter, query.Listed, query.Owner)
+
+	sort := bson.D{{Key: "_id", Value: 1}}
+	switch query.Sort {
+	case SortUpdatedAt:
+		sort = bson.D{{Key: "updated_at", Value: 1}, {Key: "_id", Value: 1}}
+	case SortUpdatedAtReverse:
+		sort = bson.D{{Key: "updated_at", Value: -1}, {Key: "_id", Value: -1}}
+	case SortTitle:
+		sort = bson.D{{Key: "title", Value: 1}, {Key: "_id", Value: 1}}
+	case SortTitleReverse:
+		sort = bson.D{{Key: "title", Value: -1}, {Key: "_id", Value: -1}}
+	}
+	opts := options.Find().SetSort(sort).SetSkip(int64(query.Offset))
+	if query.Sort == SortTitle || query.Sort == SortTitleReverse {
+		opts.SetCollation(TitleCollation(query.Locale))
+	}
+	if query.Limit > 0 {
+		opts.SetLimit(int64(query.Limit))
+	}
+
+	return find[models.Story](ctx, m.collection(), filter, opts)
+}
+
+func (m *Mongo) Update(ctx context.Context, story *models.Story, version int64) (bool, error) {
+	filter := bson.M{"_id": story.ID, "version": VersionFilter(version)}
+	res, err := m.collection().UpdateOne(ctx, filter, bson.M{
+		"$set": bson.M{
+			"title":               story.Title,
+			"language":            story.Language,
+			"segments":            story.Segments,
+			"characters":          story.Characters,
+			"is_published":        story.IsPublished,
+			"published_at":        story.PublishedAt,
+			"visibility":          story.Visibility,
+			"age_rating":          story.AgeRating,
+			"content_warnings":    story.ContentWarnings,
+			"metadata":            story.Metadata,
+			"audio_tracks":        story.AudioTracks,
+			"content_fingerprint": story.ContentFingerprint,
+			"content_hash":        story.ContentHash,
+			"updated_at":          story.UpdatedAt,
+			"version":             story.Version,
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount == 1, nil
+}
+
+func (m *Mongo) Delete(ctx context.Context, id primitive.ObjectID, before time.Time) (bool, error) {
+	filter := bson.M{"_id": id}
+	if !before.IsZero() {
+		filter["updated_at"] = bson.M{"$lt": before}
+	}
+	res, err := m.collection().DeleteOne(ctx, filter)
+	if err != nil {
+		return false, err
+	}
+	return res.DeletedCount == 1, nil
+}
+
+func (m *Mongo) Search(ctx context.Context, query SearchQuery) ([]SearchHit, int64, error) {
+	filter := bson.M{"$text": bson.M{"$search": query.Text}}
+	scope(filter, query.Listed, query.Owner)
+	if query.Language != "" {
+		filter["language"] = query.Language
+	}
+
+	score := bson.M{"$meta": "textScore"}
+	opts := options.Find().
+		SetProjection(bson.M{"score": score}).
+		SetSort(bson.D{{Key: "score", Value: score}, {Key: "_id", Value: 1}}).
+		SetSkip(int64(query.Offset)).
+		SetLimit(int64(query.Limit))
+
+	return find[SearchHit](ctx, m.collection(), filter, opts)
+}
+
+// scope narrows filter to the stories visible per the Listed and Owner
+// fields of a query.
+func scope(filter bson.M, onlyListed bool, owner primitive.ObjectID) {
+	listed := bson.M{
+		"is_published":      true,
+		"visibility":        bson.M{"$in": bson.A{models.VisibilityPublic, "", nil}},
+		"moderation.status": bson.M{"$ne": models.ModerationHidden},
+	}
+	switch {
+	case onlyListed && !owner.IsZero():
+		filter["$or"] = bson.A{listed, bson.M{"owner_id": owner}}
+	case onlyListed:
+		for key, value := range listed {
+			filter[key] = value
+		}
+	case !owner.IsZero():
+		filter["owner_id"] = owner
+	}
+}
+
+func find[T any](ctx context.Context, collection *mongo.Collection, filter bson.M, opts *options.FindOptions) ([]T, int64, error) {
+	// A collation applies to the filter as well, so the count uses it too
+	count := options.Count()
+	if opts.Collation != nil {
+		count.SetCollation(opts.Collation)
+	}
+	total, err := collection.CountDocuments(ctx, filter, count)
+	if err != nil {
+		return nil, 0, err
+	}
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	results := []T{}
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, 0, err
+	}
+	return results, total, nil
+}
+
+// VersionFilter matches a stored version, treating stories saved before
+// versioning was introduced as version 0.
+func VersionFilter(version int64) interface{} {
+	if version == 0 {
+		return bson.M{"$in": bson.A{0, nil}}
+	}
+	return version
+}
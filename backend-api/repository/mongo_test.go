@@ -0,0 +1,47 @@
+package repository_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"rosetta/repository"
+	"rosetta/repository/repositorytest"
+)
+
+// TestMongo runs against a scratch collection in the MongoDB database at
+// DATABASE_URL, which is dropped afterwards, e.g. the story-storage service
+// in docker-compose:
+//
+//	docker compose --profile repository-check run --rm repository-check
+func TestMongo(t *testing.T) {
+	url := os.Getenv("DATABASE_URL")
+	if url == "" {
+		t.Skip("DATABASE_URL is not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(url))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+
+	name := os.Getenv("DATABASE_NAME")
+	if name == "" {
+		name = "rosetta"
+	}
+	collection := client.Database(name).Collection("repositorytest_" + primitive.NewObjectID().Hex())
+	t.Cleanup(func() { collection.Drop(context.Background()) })
+	if _, err = collection.Indexes().CreateOne(ctx, repository.SearchIndex()); err != nil {
+		t.Fatal(err)
+	}
+
+	repositorytest.TestStories(t, repository.NewMongo(func() *mongo.Collection { return collection }))
+}
@@ -0,0 +1,116 @@
+// Package repository stores stories behind an interface, so handlers don't
+// depend on MongoDB and can run against the in-memory implementation. Both
+// implementations are held to the same contract (see repositorytest).
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/domain"
+	"rosetta/models"
+)
+
+var errNotFound = domain.New(domain.ErrNotFound, "Story not found")
+
+// StoryRepository stores stories. Get returns a domain.ErrNotFound error for
+// missing stories; the other methods report a missing story by returning
+// false.
+type StoryRepository interface {
+	// Create stores a new story with the ID it already has.
+	Create(ctx context.Context, story *models.Story) error
+	Get(ctx context.Context, id primitive.ObjectID) (models.Story, error)
+	// List returns a page of the stories matching query and how many match
+	// in total.
+	List(ctx context.Context, query StoryQuery) ([]models.Story, int64, error)
+	// Update overwrites the content of the story, i.e. everything but
+	// moderation state, locks and creation time, if the stored version is
+	// still version. Stories saved before versioning count as version 0.
+	Update(ctx context.Context, story *models.Story, version int64) (bool, error)
+	// Delete removes the story. A non-zero before only deletes it if it was
+	// last updated before then.
+	Delete(ctx context.Context, id primitive.ObjectID, before time.Time) (bool, error)
+	// Search returns a page of the stories whose title or scripts match the
+	// query text, best matches first, and how many match in total.
+	Search(ctx context.Context, query SearchQuery) ([]SearchHit, int64, error)
+}
+
+// Sort orders for StoryQuery.
+const (
+	SortID               = ""
+	SortUpdatedAt        = "updated_at"
+	SortUpdatedAtReverse = "-updated_at"
+	SortTitle            = "title"
+	SortTitleReverse     = "-title"
+)
+
+type StoryQuery struct {
+	// Only stories updated after UpdatedSince, when set
+	UpdatedSince time.Time
+	// Only stories after AfterID in ID order, when set
+	AfterID primitive.ObjectID
+	// Only stories whose metadata has all these entries
+	Metadata map[string]string
+	// Only stories in Language, when set
+	Language string
+	// Only stories rated one of AgeRatings, when set
+	AgeRatings []string
+	// Only stories with none of these content warnings
+	ExcludeWarnings []string
+	// Only published stories that are listed in feeds
+	Listed bool
+	// With Listed, the stories owned by Owner match too, whatever their
+	// state; alone, only those do
+	Owner primitive.ObjectID
+	Sort  string
+	// Locale titles are sorted for, one of CollationLocale's; empty for
+	// the fallback
+	Locale string
+	Offset int
+	// Zero means no limit
+	Limit int
+}
+
+type SearchQuery struct {
+	Text string
+	// Only published stories that are listed in feeds
+	Listed bool
+	// With Listed, the stories owned by Owner match too, whatever their
+	// state; alone, only those do
+	Owner    primitive.ObjectID
+	Language string
+	Offset   int
+	Limit    int
+}
+
+type SearchHit struct {
+	models.Story `bson:",inline"`
+	Score        float64 `bson:"score"`
+}
+
+// visible reports whether story is in the scope of the Listed and Owner
+// fields of a query.
+func visible(story *models.Story, onlyListed bool, owner primitive.ObjectID) bool {
+	owned := !owner.IsZero() && story.OwnerID == owner
+	switch {
+	case onlyListed:
+		return owned || listed(story)
+	case !owner.IsZero():
+		return owned
+	}
+	return true
+}
+
+// listed reports whether a story shows up in feeds: published, not unlisted
+// and not hidden by moderation.
+func listed(story *models.Story) bool {
+	if !story.IsPublished {
+		return false
+	}
+	if story.Visibility != "" && story.Visibility != models.VisibilityPublic {
+		return false
+	}
+	return story.Moderation == nil || story.Moderation.Status != models.ModerationHidden
+}
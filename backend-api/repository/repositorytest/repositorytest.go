@@ -0,0 +1,355 @@
+// Package repositorytest checks story repositories against the contract of
+// repository.StoryRepository, in the spirit of storage/storagetest. It
+// expects an empty repository, since it checks totals and orderings. The
+// repositories' own tests run it, see repository/*_test.go.
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/domain"
+	"rosetta/models"
+	"rosetta/repository"
+)
+
+// TestStories runs every check against repo as a subtest of t, one after
+// the other. Stories it creates are deleted afterwards.
+func TestStories(t *testing.T, repo repository.StoryRepository) {
+	t.Helper()
+	ctx := context.Background()
+	checks := []struct {
+		name string
+		fn   func(context.Context, repository.StoryRepository) error
+	}{
+		{"create-get", checkCreateGet},
+		{"update", checkUpdate},
+		{"delete", checkDelete},
+		{"list", checkList},
+		{"collation", checkCollation},
+		{"search", checkSearch},
+	}
+	for _, check := range checks {
+		t.Run(check.name, func(t *testing.T) {
+			if err := check.fn(ctx, repo); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// newStory returns a story with times rounded the way MongoDB stores them.
+func newStory(title, script string, updatedAt time.Time) *models.Story {
+	updatedAt = updatedAt.Truncate(time.Millisecond).UTC()
+	return &models.Story{
+		ID:       primitive.NewObjectID(),
+		Title:    title,
+		Language: "en",
+		Segments: []models.Segment{{
+			ID:      primitive.NewObjectID(),
+			Script:  &models.Script{Text: script},
+			Version: 1,
+		}},
+		CreatedAt:   updatedAt,
+		UpdatedAt:   updatedAt,
+		IsPublished: true,
+		Version:     1,
+	}
+}
+
+func create(ctx context.Context, repo repository.StoryRepository, stories ...*models.Story) (func(), error) {
+	cleanup := func() {
+		for _, story := range stories {
+			repo.Delete(ctx, story.ID, time.Time{})
+		}
+	}
+	for _, story := range stories {
+		if err := repo.Create(ctx, story); err != nil {
+			cleanup()
+			return nil, fmt.Errorf("create %s: %w", story.Title, err)
+		}
+	}
+	return cleanup, nil
+}
+
+// checkCreateGet expects stories to come back as stored, a typed error for
+// missing ones and no change to the stored story when the caller's copy is
+// changed.
+func checkCreateGet(ctx context.Context, repo repository.StoryRepository) error {
+	story := newStory("The fox", "A quick brown fox", time.Now())
+	cleanup, err := create(ctx, repo, story)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	story.Segments[0].Script.Text = "changed after create"
+	got, err := repo.Get(ctx, story.ID)
+	if err != nil {
+		return err
+	}
+	if got.Title != story.Title || len(got.Segments) != 1 || got.Segments[0].Script.Text != "A quick brown fox" {
+		return fmt.Errorf("got %q with segments %+v", got.Title, got.Segments)
+	}
+	if !got.UpdatedAt.Equal(story.UpdatedAt) {
+		return fmt.Errorf("updated_at %v, want %v", got.UpdatedAt, story.UpdatedAt)
+	}
+
+	if _, err = repo.Get(ctx, primitive.NewObjectID()); !errors.Is(err, domain.ErrNotFound) {
+		return fmt.Errorf("get missing story: got %v, want %v", err, domain.ErrNotFound)
+	}
+	return nil
+}
+
+// checkUpdate expects version-guarded writes that leave moderation state
+// alone.
+func checkUpdate(ctx context.Context, repo repository.StoryRepository) error {
+	story := newStory("Before", "text", time.Now())
+	story.Moderation = &models.Moderation{Status: models.ModerationFlagged, Reasons: []string{"links"}}
+	legacy := newStory("Legacy", "text", time.Now())
+	legacy.Version = 0
+	cleanup, err := create(ctx, repo, story, legacy)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	update := *story
+	update.Title = "After"
+	update.Moderation = nil
+	update.Version = 2
+	saved, err := repo.Update(ctx, &update, 1)
+	if err != nil {
+		return err
+	}
+	if !saved {
+		return errors.New("update at the current version was not saved")
+	}
+	got, err := repo.Get(ctx, story.ID)
+	if err != nil {
+		return err
+	}
+	if got.Title != "After" || got.Version != 2 {
+		return fmt.Errorf("got %q at version %d, want \"After\" at 2", got.Title, got.Version)
+	}
+	if got.Moderation == nil || got.Moderation.Status != models.ModerationFlagged {
+		return fmt.Errorf("moderation %+v was not kept", got.Moderation)
+	}
+
+	update.Title = "Stale"
+	if saved, err = repo.Update(ctx, &update, 1); err != nil || saved {
+		return fmt.Errorf("update at a stale version: saved %v, error %v", saved, err)
+	}
+
+	missing := *newStory("Missing", "text", time.Now())
+	if saved, err = repo.Update(ctx, &missing, 1); err != nil || saved {
+		return fmt.Errorf("update of a missing story: saved %v, error %v", saved, err)
+	}
+
+	legacyUpdate := *legacy
+	legacyUpdate.Version = 1
+	if saved, err = repo.Update(ctx, &legacyUpdate, 0); err != nil || !saved {
+		return fmt.Errorf("update of an unversioned story: saved %v, error %v", saved, err)
+	}
+	return nil
+}
+
+// checkDelete expects the before guard to compare against updated_at.
+func checkDelete(ctx context.Context, repo repository.StoryRepository) error {
+	story := newStory("Doomed", "text", time.Now().Add(-time.Hour))
+	cleanup, err := create(ctx, repo, story)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	deleted, err := repo.Delete(ctx, story.ID, story.UpdatedAt)
+	if err != nil || deleted {
+		return fmt.Errorf("delete before updated_at: deleted %v, error %v", deleted, err)
+	}
+	deleted, err = repo.Delete(ctx, story.ID, story.UpdatedAt.Add(time.Millisecond))
+	if err != nil || !deleted {
+		return fmt.Errorf("delete after updated_at: deleted %v, error %v", deleted, err)
+	}
+	if _, err = repo.Get(ctx, story.ID); !errors.Is(err, domain.ErrNotFound) {
+		return fmt.Errorf("get deleted story: got %v, want %v", err, domain.ErrNotFound)
+	}
+	deleted, err = repo.Delete(ctx, story.ID, time.Time{})
+	if err != nil || deleted {
+		return fmt.Errorf("delete missing story: deleted %v, error %v", deleted, err)
+	}
+	return nil
+}
+
+// checkList expects each sort order, paging with a total of every match
+// and the updated_since, after-ID and metadata filters.
+func checkList(ctx context.Context, repo repository.StoryRepository) error {
+	now := time.Now()
+	// Created out of ID order, so sorting by updated_at differs from by ID
+	c := newStory("c", "text", now.Add(-3*time.Minute))
+	a := newStory("a", "text", now.Add(-1*time.Minute))
+	b := newStory("b", "text", now.Add(-2*time.Minute))
+	b.Metadata = map[string]string{"crm_id": "42", "source": "import"}
+	c.Metadata = map[string]string{"crm_id": "7", "source": "import"}
+	c.IsPublished = false
+	c.OwnerID = primitive.NewObjectID()
+	b.Visibility = models.VisibilityUnlisted
+	a.AgeRating, b.AgeRating, c.AgeRating = "7+", "all", "18+"
+	b.ContentWarnings = []string{"frightening"}
+	cleanup, err := create(ctx, repo, a, b, c)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	cases := []struct {
+		name  string
+		query repository.StoryQuery
+		want  []*models.Story
+		total int64
+	}{
+		{"by id", repository.StoryQuery{}, []*models.Story{c, a, b}, 3},
+		{"by updated_at", repository.StoryQuery{Sort: repository.SortUpdatedAt}, []*models.Story{c, b, a}, 3},
+		{"by -updated_at", repository.StoryQuery{Sort: repository.SortUpdatedAtReverse}, []*models.Story{a, b, c}, 3},
+		{"paged", repository.StoryQuery{Offset: 1, Limit: 1}, []*models.Story{a}, 3},
+		{"updated since", repository.StoryQuery{UpdatedSince: b.UpdatedAt}, []*models.Story{a}, 1},
+		{"after id", repository.StoryQuery{AfterID: c.ID}, []*models.Story{a, b}, 2},
+		{"metadata", repository.StoryQuery{Metadata: map[string]string{"source": "import", "crm_id": "42"}}, []*models.Story{b}, 1},
+		{"listed", repository.StoryQuery{Listed: true}, []*models.Story{a}, 1},
+		{"listed and own", repository.StoryQuery{Listed: true, Owner: c.OwnerID}, []*models.Story{c, a}, 2},
+		{"own", repository.StoryQuery{Owner: c.OwnerID}, []*models.Story{c}, 1},
+		{"age ratings", repository.StoryQuery{AgeRatings: []string{"all", "7+"}}, []*models.Story{a, b}, 2},
+		{"excluded warnings", repository.StoryQuery{ExcludeWarnings: []string{"violence", "frightening"}}, []*models.Story{c, a}, 2},
+	}
+	for _, tc := range cases {
+		got, total, err := repo.List(ctx, tc.query)
+		if err != nil {
+			return fmt.Errorf("%s: %w", tc.name, err)
+		}
+		if total != tc.total || !sameTitles(got, tc.want) {
+			return fmt.Errorf("%s: got %s of %d, want %s of %d", tc.name, titles(got), total, titlesOf(tc.want), tc.total)
+		}
+	}
+	return nil
+}
+
+// checkCollation expects title sorts to follow the locale: Swedish sorts Ö
+// after Z, German next to O, and numbers compare by value in both.
+func checkCollation(ctx context.Context, repo repository.StoryRepository) error {
+	now := time.Now()
+	stories := []*models.Story{
+		newStory("Zebra", "text", now),
+		newStory("Ödla", "text", now),
+		newStory("Olof", "text", now),
+		newStory("Part 10", "text", now),
+		newStory("Part 2", "text", now),
+	}
+	for _, story := range stories {
+		story.Language = "sv"
+	}
+	cleanup, err := create(ctx, repo, stories...)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	zebra, odla, olof, part10, part2 := stories[0], stories[1], stories[2], stories[3], stories[4]
+	cases := []struct {
+		name  string
+		query repository.StoryQuery
+		want  []*models.Story
+	}{
+		{"swedish", repository.StoryQuery{Sort: repository.SortTitle, Locale: "sv"}, []*models.Story{olof, part2, part10, zebra, odla}},
+		{"german", repository.StoryQuery{Sort: repository.SortTitle, Locale: "de"}, []*models.Story{odla, olof, part2, part10, zebra}},
+		{"reversed", repository.StoryQuery{Sort: repository.SortTitleReverse, Locale: "sv"}, []*models.Story{odla, zebra, part10, part2, olof}},
+		{"language", repository.StoryQuery{Sort: repository.SortTitle, Language: "en"}, nil},
+	}
+	for _, tc := range cases {
+		got, _, err := repo.List(ctx, tc.query)
+		if err != nil {
+			return fmt.Errorf("%s: %w", tc.name, err)
+		}
+		if !sameTitles(got, tc.want) {
+			return fmt.Errorf("%s: got %s, want %s", tc.name, titles(got), titlesOf(tc.want))
+		}
+	}
+	return nil
+}
+
+// checkSearch expects title matches to outrank script matches and the
+// listed, owner and language filters to apply.
+func checkSearch(ctx context.Context, repo repository.StoryRepository) error {
+	inTitle := newStory("Lighthouse keeper", "A story by the sea", time.Now())
+	inScript := newStory("By the sea", "The lighthouse was dark", time.Now())
+	draft := newStory("Lighthouse draft", "unfinished", time.Now())
+	draft.IsPublished = false
+	draft.OwnerID = primitive.NewObjectID()
+	french := newStory("Lighthouse", "Le phare", time.Now())
+	french.Language = "fr"
+	unrelated := newStory("Mountains", "Snow everywhere", time.Now())
+	cleanup, err := create(ctx, repo, inTitle, inScript, draft, french, unrelated)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	hits, total, err := repo.Search(ctx, repository.SearchQuery{Text: "lighthouse", Listed: true, Language: "en", Limit: 10})
+	if err != nil {
+		return err
+	}
+	if total != 2 || len(hits) != 2 || hits[0].ID != inTitle.ID || hits[1].ID != inScript.ID {
+		return fmt.Errorf("got %d hits of %d, want %q then %q", len(hits), total, inTitle.Title, inScript.Title)
+	}
+	if hits[0].Score <= hits[1].Score {
+		return fmt.Errorf("title match scored %v, not above script match %v", hits[0].Score, hits[1].Score)
+	}
+
+	hits, total, err = repo.Search(ctx, repository.SearchQuery{Text: "lighthouse", Listed: true, Owner: draft.OwnerID, Language: "en", Limit: 10})
+	if err != nil {
+		return err
+	}
+	if total != 3 || len(hits) != 3 {
+		return fmt.Errorf("listed and own: got %d hits of %d, want 3 of 3", len(hits), total)
+	}
+	hits, total, err = repo.Search(ctx, repository.SearchQuery{Text: "lighthouse", Owner: draft.OwnerID, Limit: 10})
+	if err != nil {
+		return err
+	}
+	if total != 1 || len(hits) != 1 || hits[0].ID != draft.ID {
+		return fmt.Errorf("own only: got %d hits of %d, want %q", len(hits), total, draft.Title)
+	}
+
+	hits, total, err = repo.Search(ctx, repository.SearchQuery{Text: "lighthouse", Offset: 1, Limit: 2})
+	if err != nil {
+		return err
+	}
+	if total != 4 || len(hits) != 2 {
+		return fmt.Errorf("unfiltered page: got %d hits of %d, want 2 of 4", len(hits), total)
+	}
+	return nil
+}
+
+func sameTitles(got []models.Story, want []*models.Story) bool {
+	return titles(got) == titlesOf(want)
+}
+
+func titles(stories []models.Story) string {
+	s := ""
+	for _, story := range stories {
+		s += "[" + story.Title + "]"
+	}
+	return s
+}
+
+func titlesOf(stories []*models.Story) string {
+	s := ""
+	for _, story := range stories {
+		s += "[" + story.Title + "]"
+	}
+	return s
+}
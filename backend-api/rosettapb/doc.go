@@ -0,0 +1,5 @@
+// Package rosettapb is the generated code of the gRPC API defined in
+// story.proto. Regenerate it after changing the definitions.
+package rosettapb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative story.proto
@@ -0,0 +1,1018 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        v5.29.3
+// source: story.proto
+
+// The gRPC API for internal services. It mirrors the story endpoints of the
+// HTTP API and shares their validation and storage.
+
+package rosettapb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Story struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Id              string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Title           string                 `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+	Language        string                 `protobuf:"bytes,3,opt,name=language,proto3" json:"language,omitempty"`
+	Segments        []*Segment             `protobuf:"bytes,4,rep,name=segments,proto3" json:"segments,omitempty"`
+	Characters      []*Character           `protobuf:"bytes,5,rep,name=characters,proto3" json:"characters,omitempty"`
+	IsPublished     bool                   `protobuf:"varint,6,opt,name=is_published,json=isPublished,proto3" json:"is_published,omitempty"`
+	Visibility      string                 `protobuf:"bytes,7,opt,name=visibility,proto3" json:"visibility,omitempty"`
+	AgeRating       string                 `protobuf:"bytes,8,opt,name=age_rating,json=ageRating,proto3" json:"age_rating,omitempty"`
+	ContentWarnings []string               `protobuf:"bytes,9,rep,name=content_warnings,json=contentWarnings,proto3" json:"content_warnings,omitempty"`
+	Version         int64                  `protobuf:"varint,10,opt,name=version,proto3" json:"version,omitempty"`
+	ContentHash     string                 `protobuf:"bytes,11,opt,name=content_hash,json=contentHash,proto3" json:"content_hash,omitempty"`
+	// Empty for stories created before accounts existed
+	OwnerId       string                 `protobuf:"bytes,12,opt,name=owner_id,json=ownerId,proto3" json:"owner_id,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,13,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,14,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	PublishedAt   *timestamppb.Timestamp `protobuf:"bytes,15,opt,name=published_at,json=publishedAt,proto3" json:"published_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Story) Reset() {
+	*x = Story{}
+	mi := &file_story_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Story) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Story) ProtoMessage() {}
+
+func (x *Story) ProtoReflect() protoreflect.Message {
+	mi := &file_story_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Story.ProtoReflect.Descriptor instead.
+func (*Story) Descriptor() ([]byte, []int) {
+	return file_story_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Story) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Story) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Story) GetLanguage() string {
+	if x != nil {
+		return x.Language
+	}
+	return ""
+}
+
+func (x *Story) GetSegments() []*Segment {
+	if x != nil {
+		return x.Segments
+	}
+	return nil
+}
+
+func (x *Story) GetCharacters() []*Character {
+	if x != nil {
+		return x.Characters
+	}
+	return nil
+}
+
+func (x *Story) GetIsPublished() bool {
+	if x != nil {
+		return x.IsPublished
+	}
+	return false
+}
+
+func (x *Story) GetVisibility() string {
+	if x != nil {
+		return x.Visibility
+	}
+	return ""
+}
+
+func (x *Story) GetAgeRating() string {
+	if x != nil {
+		return x.AgeRating
+	}
+	return ""
+}
+
+func (x *Story) GetContentWarnings() []string {
+	if x != nil {
+		return x.ContentWarnings
+	}
+	return nil
+}
+
+func (x *Story) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Story) GetContentHash() string {
+	if x != nil {
+		return x.ContentHash
+	}
+	return ""
+}
+
+func (x *Story) GetOwnerId() string {
+	if x != nil {
+		return x.OwnerId
+	}
+	return ""
+}
+
+func (x *Story) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Story) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *Story) GetPublishedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.PublishedAt
+	}
+	return nil
+}
+
+type Segment struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	AudioUrl      string                 `protobuf:"bytes,2,opt,name=audio_url,json=audioUrl,proto3" json:"audio_url,omitempty"`
+	ImageUrl      string                 `protobuf:"bytes,3,opt,name=image_url,json=imageUrl,proto3" json:"image_url,omitempty"`
+	Script        string                 `protobuf:"bytes,4,opt,name=script,proto3" json:"script,omitempty"`
+	Speaker       string                 `protobuf:"bytes,5,opt,name=speaker,proto3" json:"speaker,omitempty"`
+	Version       int64                  `protobuf:"varint,6,opt,name=version,proto3" json:"version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Segment) Reset() {
+	*x = Segment{}
+	mi := &file_story_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Segment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Segment) ProtoMessage() {}
+
+func (x *Segment) ProtoReflect() protoreflect.Message {
+	mi := &file_story_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Segment.ProtoReflect.Descriptor instead.
+func (*Segment) Descriptor() ([]byte, []int) {
+	return file_story_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Segment) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Segment) GetAudioUrl() string {
+	if x != nil {
+		return x.AudioUrl
+	}
+	return ""
+}
+
+func (x *Segment) GetImageUrl() string {
+	if x != nil {
+		return x.ImageUrl
+	}
+	return ""
+}
+
+func (x *Segment) GetScript() string {
+	if x != nil {
+		return x.Script
+	}
+	return ""
+}
+
+func (x *Segment) GetSpeaker() string {
+	if x != nil {
+		return x.Speaker
+	}
+	return ""
+}
+
+func (x *Segment) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+type Character struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Color         string                 `protobuf:"bytes,2,opt,name=color,proto3" json:"color,omitempty"`
+	Voice         string                 `protobuf:"bytes,3,opt,name=voice,proto3" json:"voice,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Character) Reset() {
+	*x = Character{}
+	mi := &file_story_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Character) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Character) ProtoMessage() {}
+
+func (x *Character) ProtoReflect() protoreflect.Message {
+	mi := &file_story_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Character.ProtoReflect.Descriptor instead.
+func (*Character) Descriptor() ([]byte, []int) {
+	return file_story_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Character) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Character) GetColor() string {
+	if x != nil {
+		return x.Color
+	}
+	return ""
+}
+
+func (x *Character) GetVoice() string {
+	if x != nil {
+		return x.Voice
+	}
+	return ""
+}
+
+// StoryInput is the writable part of a story, as in the HTTP request body.
+type StoryInput struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Title           string                 `protobuf:"bytes,1,opt,name=title,proto3" json:"title,omitempty"`
+	Language        string                 `protobuf:"bytes,2,opt,name=language,proto3" json:"language,omitempty"`
+	Segments        []*SegmentInput        `protobuf:"bytes,3,rep,name=segments,proto3" json:"segments,omitempty"`
+	Characters      []*Character           `protobuf:"bytes,4,rep,name=characters,proto3" json:"characters,omitempty"`
+	IsPublished     bool                   `protobuf:"varint,5,opt,name=is_published,json=isPublished,proto3" json:"is_published,omitempty"`
+	Visibility      string                 `protobuf:"bytes,6,opt,name=visibility,proto3" json:"visibility,omitempty"`
+	AgeRating       string                 `protobuf:"bytes,7,opt,name=age_rating,json=ageRating,proto3" json:"age_rating,omitempty"`
+	ContentWarnings []string               `protobuf:"bytes,8,rep,name=content_warnings,json=contentWarnings,proto3" json:"content_warnings,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *StoryInput) Reset() {
+	*x = StoryInput{}
+	mi := &file_story_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StoryInput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StoryInput) ProtoMessage() {}
+
+func (x *StoryInput) ProtoReflect() protoreflect.Message {
+	mi := &file_story_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StoryInput.ProtoReflect.Descriptor instead.
+func (*StoryInput) Descriptor() ([]byte, []int) {
+	return file_story_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *StoryInput) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *StoryInput) GetLanguage() string {
+	if x != nil {
+		return x.Language
+	}
+	return ""
+}
+
+func (x *StoryInput) GetSegments() []*SegmentInput {
+	if x != nil {
+		return x.Segments
+	}
+	return nil
+}
+
+func (x *StoryInput) GetCharacters() []*Character {
+	if x != nil {
+		return x.Characters
+	}
+	return nil
+}
+
+func (x *StoryInput) GetIsPublished() bool {
+	if x != nil {
+		return x.IsPublished
+	}
+	return false
+}
+
+func (x *StoryInput) GetVisibility() string {
+	if x != nil {
+		return x.Visibility
+	}
+	return ""
+}
+
+func (x *StoryInput) GetAgeRating() string {
+	if x != nil {
+		return x.AgeRating
+	}
+	return ""
+}
+
+func (x *StoryInput) GetContentWarnings() []string {
+	if x != nil {
+		return x.ContentWarnings
+	}
+	return nil
+}
+
+type SegmentInput struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Empty for new segments
+	Id            string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	AudioUrl      string `protobuf:"bytes,2,opt,name=audio_url,json=audioUrl,proto3" json:"audio_url,omitempty"`
+	ImageUrl      string `protobuf:"bytes,3,opt,name=image_url,json=imageUrl,proto3" json:"image_url,omitempty"`
+	Script        string `protobuf:"bytes,4,opt,name=script,proto3" json:"script,omitempty"`
+	Speaker       string `protobuf:"bytes,5,opt,name=speaker,proto3" json:"speaker,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SegmentInput) Reset() {
+	*x = SegmentInput{}
+	mi := &file_story_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SegmentInput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SegmentInput) ProtoMessage() {}
+
+func (x *SegmentInput) ProtoReflect() protoreflect.Message {
+	mi := &file_story_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SegmentInput.ProtoReflect.Descriptor instead.
+func (*SegmentInput) Descriptor() ([]byte, []int) {
+	return file_story_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *SegmentInput) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *SegmentInput) GetAudioUrl() string {
+	if x != nil {
+		return x.AudioUrl
+	}
+	return ""
+}
+
+func (x *SegmentInput) GetImageUrl() string {
+	if x != nil {
+		return x.ImageUrl
+	}
+	return ""
+}
+
+func (x *SegmentInput) GetScript() string {
+	if x != nil {
+		return x.Script
+	}
+	return ""
+}
+
+func (x *SegmentInput) GetSpeaker() string {
+	if x != nil {
+		return x.Speaker
+	}
+	return ""
+}
+
+type CreateStoryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Story         *StoryInput            `protobuf:"bytes,1,opt,name=story,proto3" json:"story,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateStoryRequest) Reset() {
+	*x = CreateStoryRequest{}
+	mi := &file_story_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateStoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateStoryRequest) ProtoMessage() {}
+
+func (x *CreateStoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_story_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateStoryRequest.ProtoReflect.Descriptor instead.
+func (*CreateStoryRequest) Descriptor() ([]byte, []int) {
+	return file_story_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *CreateStoryRequest) GetStory() *StoryInput {
+	if x != nil {
+		return x.Story
+	}
+	return nil
+}
+
+type GetStoryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStoryRequest) Reset() {
+	*x = GetStoryRequest{}
+	mi := &file_story_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStoryRequest) ProtoMessage() {}
+
+func (x *GetStoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_story_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStoryRequest.ProtoReflect.Descriptor instead.
+func (*GetStoryRequest) Descriptor() ([]byte, []int) {
+	return file_story_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GetStoryRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type ListStoriesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Defaults to 20, at most 100
+	PageSize      int32                  `protobuf:"varint,1,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	PageToken     string                 `protobuf:"bytes,2,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	UpdatedSince  *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=updated_since,json=updatedSince,proto3" json:"updated_since,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListStoriesRequest) Reset() {
+	*x = ListStoriesRequest{}
+	mi := &file_story_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListStoriesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListStoriesRequest) ProtoMessage() {}
+
+func (x *ListStoriesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_story_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListStoriesRequest.ProtoReflect.Descriptor instead.
+func (*ListStoriesRequest) Descriptor() ([]byte, []int) {
+	return file_story_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ListStoriesRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListStoriesRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+func (x *ListStoriesRequest) GetUpdatedSince() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedSince
+	}
+	return nil
+}
+
+type ListStoriesResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Stories []*Story               `protobuf:"bytes,1,rep,name=stories,proto3" json:"stories,omitempty"`
+	// Empty on the last page
+	NextPageToken string `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListStoriesResponse) Reset() {
+	*x = ListStoriesResponse{}
+	mi := &file_story_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListStoriesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListStoriesResponse) ProtoMessage() {}
+
+func (x *ListStoriesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_story_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListStoriesResponse.ProtoReflect.Descriptor instead.
+func (*ListStoriesResponse) Descriptor() ([]byte, []int) {
+	return file_story_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ListStoriesResponse) GetStories() []*Story {
+	if x != nil {
+		return x.Stories
+	}
+	return nil
+}
+
+func (x *ListStoriesResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+type UpdateStoryRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Story *StoryInput            `protobuf:"bytes,2,opt,name=story,proto3" json:"story,omitempty"`
+	// The version the edit started from, merged like If-Match over HTTP. Zero
+	// overwrites.
+	BaseVersion   int64 `protobuf:"varint,3,opt,name=base_version,json=baseVersion,proto3" json:"base_version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateStoryRequest) Reset() {
+	*x = UpdateStoryRequest{}
+	mi := &file_story_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateStoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateStoryRequest) ProtoMessage() {}
+
+func (x *UpdateStoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_story_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateStoryRequest.ProtoReflect.Descriptor instead.
+func (*UpdateStoryRequest) Descriptor() ([]byte, []int) {
+	return file_story_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *UpdateStoryRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *UpdateStoryRequest) GetStory() *StoryInput {
+	if x != nil {
+		return x.Story
+	}
+	return nil
+}
+
+func (x *UpdateStoryRequest) GetBaseVersion() int64 {
+	if x != nil {
+		return x.BaseVersion
+	}
+	return 0
+}
+
+type DeleteStoryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteStoryRequest) Reset() {
+	*x = DeleteStoryRequest{}
+	mi := &file_story_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteStoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteStoryRequest) ProtoMessage() {}
+
+func (x *DeleteStoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_story_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteStoryRequest.ProtoReflect.Descriptor instead.
+func (*DeleteStoryRequest) Descriptor() ([]byte, []int) {
+	return file_story_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *DeleteStoryRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type DeleteStoryResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteStoryResponse) Reset() {
+	*x = DeleteStoryResponse{}
+	mi := &file_story_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteStoryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteStoryResponse) ProtoMessage() {}
+
+func (x *DeleteStoryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_story_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteStoryResponse.ProtoReflect.Descriptor instead.
+func (*DeleteStoryResponse) Descriptor() ([]byte, []int) {
+	return file_story_proto_rawDescGZIP(), []int{11}
+}
+
+var File_story_proto protoreflect.FileDescriptor
+
+const file_story_proto_rawDesc = "" +
+	"\n" +
+	"\vstory.proto\x12\n" +
+	"rosetta.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xcb\x04\n" +
+	"\x05Story\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05title\x18\x02 \x01(\tR\x05title\x12\x1a\n" +
+	"\blanguage\x18\x03 \x01(\tR\blanguage\x12/\n" +
+	"\bsegments\x18\x04 \x03(\v2\x13.rosetta.v1.SegmentR\bsegments\x125\n" +
+	"\n" +
+	"characters\x18\x05 \x03(\v2\x15.rosetta.v1.CharacterR\n" +
+	"characters\x12!\n" +
+	"\fis_published\x18\x06 \x01(\bR\visPublished\x12\x1e\n" +
+	"\n" +
+	"visibility\x18\a \x01(\tR\n" +
+	"visibility\x12\x1d\n" +
+	"\n" +
+	"age_rating\x18\b \x01(\tR\tageRating\x12)\n" +
+	"\x10content_warnings\x18\t \x03(\tR\x0fcontentWarnings\x12\x18\n" +
+	"\aversion\x18\n" +
+	" \x01(\x03R\aversion\x12!\n" +
+	"\fcontent_hash\x18\v \x01(\tR\vcontentHash\x12\x19\n" +
+	"\bowner_id\x18\f \x01(\tR\aownerId\x129\n" +
+	"\n" +
+	"created_at\x18\r \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\x0e \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12=\n" +
+	"\fpublished_at\x18\x0f \x01(\v2\x1a.google.protobuf.TimestampR\vpublishedAt\"\x9f\x01\n" +
+	"\aSegment\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1b\n" +
+	"\taudio_url\x18\x02 \x01(\tR\baudioUrl\x12\x1b\n" +
+	"\timage_url\x18\x03 \x01(\tR\bimageUrl\x12\x16\n" +
+	"\x06script\x18\x04 \x01(\tR\x06script\x12\x18\n" +
+	"\aspeaker\x18\x05 \x01(\tR\aspeaker\x12\x18\n" +
+	"\aversion\x18\x06 \x01(\x03R\aversion\"K\n" +
+	"\tCharacter\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05color\x18\x02 \x01(\tR\x05color\x12\x14\n" +
+	"\x05voice\x18\x03 \x01(\tR\x05voice\"\xb8\x02\n" +
+	"\n" +
+	"StoryInput\x12\x14\n" +
+	"\x05title\x18\x01 \x01(\tR\x05title\x12\x1a\n" +
+	"\blanguage\x18\x02 \x01(\tR\blanguage\x124\n" +
+	"\bsegments\x18\x03 \x03(\v2\x18.rosetta.v1.SegmentInputR\bsegments\x125\n" +
+	"\n" +
+	"characters\x18\x04 \x03(\v2\x15.rosetta.v1.CharacterR\n" +
+	"characters\x12!\n" +
+	"\fis_published\x18\x05 \x01(\bR\visPublished\x12\x1e\n" +
+	"\n" +
+	"visibility\x18\x06 \x01(\tR\n" +
+	"visibility\x12\x1d\n" +
+	"\n" +
+	"age_rating\x18\a \x01(\tR\tageRating\x12)\n" +
+	"\x10content_warnings\x18\b \x03(\tR\x0fcontentWarnings\"\x8a\x01\n" +
+	"\fSegmentInput\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1b\n" +
+	"\taudio_url\x18\x02 \x01(\tR\baudioUrl\x12\x1b\n" +
+	"\timage_url\x18\x03 \x01(\tR\bimageUrl\x12\x16\n" +
+	"\x06script\x18\x04 \x01(\tR\x06script\x12\x18\n" +
+	"\aspeaker\x18\x05 \x01(\tR\aspeaker\"B\n" +
+	"\x12CreateStoryRequest\x12,\n" +
+	"\x05story\x18\x01 \x01(\v2\x16.rosetta.v1.StoryInputR\x05story\"!\n" +
+	"\x0fGetStoryRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x91\x01\n" +
+	"\x12ListStoriesRequest\x12\x1b\n" +
+	"\tpage_size\x18\x01 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x02 \x01(\tR\tpageToken\x12?\n" +
+	"\rupdated_since\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\fupdatedSince\"j\n" +
+	"\x13ListStoriesResponse\x12+\n" +
+	"\astories\x18\x01 \x03(\v2\x11.rosetta.v1.StoryR\astories\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"u\n" +
+	"\x12UpdateStoryRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12,\n" +
+	"\x05story\x18\x02 \x01(\v2\x16.rosetta.v1.StoryInputR\x05story\x12!\n" +
+	"\fbase_version\x18\x03 \x01(\x03R\vbaseVersion\"$\n" +
+	"\x12DeleteStoryRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x15\n" +
+	"\x13DeleteStoryResponse2\xee\x02\n" +
+	"\fStoryService\x12@\n" +
+	"\vCreateStory\x12\x1e.rosetta.v1.CreateStoryRequest\x1a\x11.rosetta.v1.Story\x12:\n" +
+	"\bGetStory\x12\x1b.rosetta.v1.GetStoryRequest\x1a\x11.rosetta.v1.Story\x12N\n" +
+	"\vListStories\x12\x1e.rosetta.v1.ListStoriesRequest\x1a\x1f.rosetta.v1.ListStoriesResponse\x12@\n" +
+	"\vUpdateStory\x12\x1e.rosetta.v1.UpdateStoryRequest\x1a\x11.rosetta.v1.Story\x12N\n" +
+	"\vDeleteStory\x12\x1e.rosetta.v1.DeleteStoryRequest\x1a\x1f.rosetta.v1.DeleteStoryResponseB\x13Z\x11rosetta/rosettapbb\x06proto3"
+
+var (
+	file_story_proto_rawDescOnce sync.Once
+	file_story_proto_rawDescData []byte
+)
+
+func file_story_proto_rawDescGZIP() []byte {
+	file_story_proto_rawDescOnce.Do(func() {
+		file_story_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_story_proto_rawDesc), len(file_story_proto_rawDesc)))
+	})
+	return file_story_proto_rawDescData
+}
+
+var file_story_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_story_proto_goTypes = []any{
+	(*Story)(nil),                 // 0: rosetta.v1.Story
+	(*Segment)(nil),               // 1: rosetta.v1.Segment
+	(*Character)(nil),             // 2: rosetta.v1.Character
+	(*StoryInput)(nil),            // 3: rosetta.v1.StoryInput
+	(*SegmentInput)(nil),          // 4: rosetta.v1.SegmentInput
+	(*CreateStoryRequest)(nil),    // 5: rosetta.v1.CreateStoryRequest
+	(*GetStoryRequest)(nil),       // 6: rosetta.v1.GetStoryRequest
+	(*ListStoriesRequest)(nil),    // 7: rosetta.v1.ListStoriesRequest
+	(*ListStoriesResponse)(nil),   // 8: rosetta.v1.ListStoriesResponse
+	(*UpdateStoryRequest)(nil),    // 9: rosetta.v1.UpdateStoryRequest
+	(*DeleteStoryRequest)(nil),    // 10: rosetta.v1.DeleteStoryRequest
+	(*DeleteStoryResponse)(nil),   // 11: rosetta.v1.DeleteStoryResponse
+	(*timestamppb.Timestamp)(nil), // 12: google.protobuf.Timestamp
+}
+var file_story_proto_depIdxs = []int32{
+	1,  // 0: rosetta.v1.Story.segments:type_name -> rosetta.v1.Segment
+	2,  // 1: rosetta.v1.Story.characters:type_name -> rosetta.v1.Character
+	12, // 2: rosetta.v1.Story.created_at:type_name -> google.protobuf.Timestamp
+	12, // 3: rosetta.v1.Story.updated_at:type_name -> google.protobuf.Timestamp
+	12, // 4: rosetta.v1.Story.published_at:type_name -> google.protobuf.Timestamp
+	4,  // 5: rosetta.v1.StoryInput.segments:type_name -> rosetta.v1.SegmentInput
+	2,  // 6: rosetta.v1.StoryInput.characters:type_name -> rosetta.v1.Character
+	3,  // 7: rosetta.v1.CreateStoryRequest.story:type_name -> rosetta.v1.StoryInput
+	12, // 8: rosetta.v1.ListStoriesRequest.updated_since:type_name -> google.protobuf.Timestamp
+	0,  // 9: rosetta.v1.ListStoriesResponse.stories:type_name -> rosetta.v1.Story
+	3,  // 10: rosetta.v1.UpdateStoryRequest.story:type_name -> rosetta.v1.StoryInput
+	5,  // 11: rosetta.v1.StoryService.CreateStory:input_type -> rosetta.v1.CreateStoryRequest
+	6,  // 12: rosetta.v1.StoryService.GetStory:input_type -> rosetta.v1.GetStoryRequest
+	7,  // 13: rosetta.v1.StoryService.ListStories:input_type -> rosetta.v1.ListStoriesRequest
+	9,  // 14: rosetta.v1.StoryService.UpdateStory:input_type -> rosetta.v1.UpdateStoryRequest
+	10, // 15: rosetta.v1.StoryService.DeleteStory:input_type -> rosetta.v1.DeleteStoryRequest
+	0,  // 16: rosetta.v1.StoryService.CreateStory:output_type -> rosetta.v1.Story
+	0,  // 17: rosetta.v1.StoryService.GetStory:output_type -> rosetta.v1.Story
+	8,  // 18: rosetta.v1.StoryService.ListStories:output_type -> rosetta.v1.ListStoriesResponse
+	0,  // 19: rosetta.v1.StoryService.UpdateStory:output_type -> rosetta.v1.Story
+	11, // 20: rosetta.v1.StoryService.DeleteStory:output_type -> rosetta.v1.DeleteStoryResponse
+	16, // [16:21] is the sub-list for method output_type
+	11, // [11:16] is the sub-list for method input_type
+	11, // [11:11] is the sub-list for extension type_name
+	11, // [11:11] is the sub-list for extension extendee
+	0,  // [0:11] is the sub-list for field type_name
+}
+
+func init() { file_story_proto_init() }
+func file_story_proto_init() {
+	if File_story_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_story_proto_rawDesc), len(file_story_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   12,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_story_proto_goTypes,
+		DependencyIndexes: file_story_proto_depIdxs,
+		MessageInfos:      file_story_proto_msgTypes,
+	}.Build()
+	File_story_proto = out.File
+	file_story_proto_goTypes = nil
+	file_story_proto_depIdxs = nil
+}
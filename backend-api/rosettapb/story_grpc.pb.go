@@ -0,0 +1,276 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: story.proto
+
+// The gRPC API for internal services. It mirrors the story endpoints of the
+// HTTP API and shares their validation and storage.
+
+package rosettapb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	StoryService_CreateStory_FullMethodName = "/rosetta.v1.StoryService/CreateStory"
+	StoryService_GetStory_FullMethodName    = "/rosetta.v1.StoryService/GetStory"
+	StoryService_ListStories_FullMethodName = "/rosetta.v1.StoryService/ListStories"
+	StoryService_UpdateStory_FullMethodName = "/rosetta.v1.StoryService/UpdateStory"
+	StoryService_DeleteStory_FullMethodName = "/rosetta.v1.StoryService/DeleteStory"
+)
+
+// StoryServiceClient is the client API for StoryService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type StoryServiceClient interface {
+	CreateStory(ctx context.Context, in *CreateStoryRequest, opts ...grpc.CallOption) (*Story, error)
+	GetStory(ctx context.Context, in *GetStoryRequest, opts ...grpc.CallOption) (*Story, error)
+	ListStories(ctx context.Context, in *ListStoriesRequest, opts ...grpc.CallOption) (*ListStoriesResponse, error)
+	UpdateStory(ctx context.Context, in *UpdateStoryRequest, opts ...grpc.CallOption) (*Story, error)
+	DeleteStory(ctx context.Context, in *DeleteStoryRequest, opts ...grpc.CallOption) (*DeleteStoryResponse, error)
+}
+
+type storyServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewStoryServiceClient(cc grpc.ClientConnInterface) StoryServiceClient {
+	return &storyServiceClient{cc}
+}
+
+func (c *storyServiceClient) CreateStory(ctx context.Context, in *CreateStoryRequest, opts ...grpc.CallOption) (*Story, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Story)
+	err := c.cc.Invoke(ctx, StoryService_CreateStory_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storyServiceClient) GetStory(ctx context.Context, in *GetStoryRequest, opts ...grpc.CallOption) (*Story, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Story)
+	err := c.cc.Invoke(ctx, StoryService_GetStory_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storyServiceClient) ListStories(ctx context.Context, in *ListStoriesRequest, opts ...grpc.CallOption) (*ListStoriesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListStoriesResponse)
+	err := c.cc.Invoke(ctx, StoryService_ListStories_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storyServiceClient) UpdateStory(ctx context.Context, in *UpdateStoryRequest, opts ...grpc.CallOption) (*Story, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Story)
+	err := c.cc.Invoke(ctx, StoryService_UpdateStory_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storyServiceClient) DeleteStory(ctx context.Context, in *DeleteStoryRequest, opts ...grpc.CallOption) (*DeleteStoryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteStoryResponse)
+	err := c.cc.Invoke(ctx, StoryService_DeleteStory_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// StoryServiceServer is the server API for StoryService service.
+// All implementations must embed UnimplementedStoryServiceServer
+// for forward compatibility.
+type StoryServiceServer interface {
+	CreateStory(context.Context, *CreateStoryRequest) (*Story, error)
+	GetStory(context.Context, *GetStoryRequest) (*Story, error)
+	ListStories(context.Context, *ListStoriesRequest) (*ListStoriesResponse, error)
+	UpdateStory(context.Context, *UpdateStoryRequest) (*Story, error)
+	DeleteStory(context.Context, *DeleteStoryRequest) (*DeleteStoryResponse, error)
+	mustEmbedUnimplementedStoryServiceServer()
+}
+
+// UnimplementedStoryServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedStoryServiceServer struct{}
+
+func (UnimplementedStoryServiceServer) CreateStory(context.Context, *CreateStoryRequest) (*Story, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateStory not implemented")
+}
+func (UnimplementedStoryServiceServer) GetStory(context.Context, *GetStoryRequest) (*Story, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStory not implemented")
+}
+func (UnimplementedStoryServiceServer) ListStories(context.Context, *ListStoriesRequest) (*ListStoriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListStories not implemented")
+}
+func (UnimplementedStoryServiceServer) UpdateStory(context.Context, *UpdateStoryRequest) (*Story, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateStory not implemented")
+}
+func (UnimplementedStoryServiceServer) DeleteStory(context.Context, *DeleteStoryRequest) (*DeleteStoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteStory not implemented")
+}
+func (UnimplementedStoryServiceServer) mustEmbedUnimplementedStoryServiceServer() {}
+func (UnimplementedStoryServiceServer) testEmbeddedByValue()                      {}
+
+// UnsafeStoryServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to StoryServiceServer will
+// result in compilation errors.
+type UnsafeStoryServiceServer interface {
+	mustEmbedUnimplementedStoryServiceServer()
+}
+
+func RegisterStoryServiceServer(s grpc.ServiceRegistrar, srv StoryServiceServer) {
+	// If the following call pancis, it indicates UnimplementedStoryServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&StoryService_ServiceDesc, srv)
+}
+
+func _StoryService_CreateStory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateStoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoryServiceServer).CreateStory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StoryService_CreateStory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoryServiceServer).CreateStory(ctx, req.(*CreateStoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoryService_GetStory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoryServiceServer).GetStory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StoryService_GetStory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoryServiceServer).GetStory(ctx, req.(*GetStoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoryService_ListStories_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListStoriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoryServiceServer).ListStories(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StoryService_ListStories_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoryServiceServer).ListStories(ctx, req.(*ListStoriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoryService_UpdateStory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateStoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoryServiceServer).UpdateStory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StoryService_UpdateStory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoryServiceServer).UpdateStory(ctx, req.(*UpdateStoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoryService_DeleteStory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteStoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoryServiceServer).DeleteStory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StoryService_DeleteStory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoryServiceServer).DeleteStory(ctx, req.(*DeleteStoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// StoryService_ServiceDesc is the grpc.ServiceDesc for StoryService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var StoryService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "rosetta.v1.StoryService",
+	HandlerType: (*StoryServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateStory",
+			Handler:    _StoryService_CreateStory_Handler,
+		},
+		{
+			MethodName: "GetStory",
+			Handler:    _StoryService_GetStory_Handler,
+		},
+		{
+			MethodName: "ListStories",
+			Handler:    _StoryService_ListStories_Handler,
+		},
+		{
+			MethodName: "UpdateStory",
+			Handler:    _StoryService_UpdateStory_Handler,
+		},
+		{
+			MethodName: "DeleteStory",
+			Handler:    _StoryService_DeleteStory_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "story.proto",
+}
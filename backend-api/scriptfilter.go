@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/contentfilter"
+	"rosetta/domain"
+	"rosetta/models"
+	"rosetta/scripttext"
+)
+
+// scriptFilterMode controls what happens to profanity and personal data in
+// the scripts of published stories: off, mask or reject.
+var scriptFilterMode = contentfilter.ModeOff
+
+func blockedWords(ctx context.Context, lang string) ([]string, error) {
+	filter := bson.M{"language": bson.M{"$in": bson.A{lang, "", nil}}}
+	cursor, err := blocklistCollection().Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var blocked []models.BlockedWord
+	if err = cursor.All(ctx, &blocked); err != nil {
+		return nil, err
+	}
+
+	words := make([]string, 0, len(blocked))
+	for _, b := range blocked {
+		words = append(words, b.Word)
+	}
+	return words, nil
+}
+
+// normalizeScripts normalizes the scripts of a story about to be saved and
+// their translations. Scripts are replaced rather than edited, as they may
+// be shared with the stored copy.
+func normalizeScripts(story *models.Story) {
+	for i, segment := range story.Segments {
+		if segment.Script == nil {
+			continue
+		}
+		script := *segment.Script
+		script.Text = scripttext.Normalize(script.Text)
+		script.Translations = maps.Clone(script.Translations)
+		for lang, text := range script.Translations {
+			script.Translations[lang] = scripttext.Normalize(text)
+		}
+		story.Segments[i].Script = &script
+	}
+}
+
+// filterScripts applies the script filter to a story about to be saved as
+// published, masking matches in place or rejecting the story.
+func filterScripts(ctx context.Context, story *models.Story) error {
+	if scriptFilterMode == contentfilter.ModeOff || !story.IsPublished {
+		return nil
+	}
+
+	extra := map[string][]string{}
+	check := func(text, lang string) ([]contentfilter.Match, error) {
+		words, ok := extra[lang]
+		if !ok {
+			var err error
+			if words, err = blockedWords(ctx, lang); err != nil {
+				return nil, err
+			}
+			extra[lang] = words
+		}
+		return contentfilter.Check(text, lang, words), nil
+	}
+
+	var found []string
+	for i, segment := range story.Segments {
+		if segment.Script == nil {
+			continue
+		}
+
+		script := *segment.Script
+		script.Translations = maps.Clone(script.Translations)
+		masked := false
+		filter := func(text *string, lang string) error {
+			matches, err := check(*text, lang)
+			if err != nil || len(matches) == 0 {
+				return err
+			}
+			if scriptFilterMode == contentfilter.ModeMask {
+				*text = contentfilter.Mask(*text, matches)
+				masked = true
+				return nil
+			}
+			for _, m := range matches {
+				found = append(found, fmt.Sprintf("segment %d: %s", i+1, m.Kind))
+			}
+			return nil
+		}
+
+		// Translations are checked in their own language
+		if err := filter(&script.Text, scriptLanguage(story, &script)); err != nil {
+			return err
+		}
+		for _, lang := range slices.Sorted(maps.Keys(script.Translations)) {
+			text := script.Translations[lang]
+			if err := filter(&text, lang); err != nil {
+				return err
+			}
+			script.Translations[lang] = text
+		}
+		if masked {
+			story.Segments[i].Script = &script
+		}
+	}
+
+	if len(found) > 0 {
+		return domain.New(domain.ErrInvalid, "Scripts contain blocked content ("+strings.Join(found, "; ")+")")
+	}
+	return nil
+}
+
+func listBlockedWords(w http.ResponseWriter, r *http.Request) {
+	cursor, err := blocklistCollection().Find(r.Context(), bson.M{})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	blocked := []models.BlockedWord{}
+	if err = cursor.All(r.Context(), &blocked); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeResponse(w, r, http.StatusOK, blocked)
+}
+
+func addBlockedWord(w http.ResponseWriter, r *http.Request) {
+	var blocked models.BlockedWord
+	err := decodeRequest(r, &blocked)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	blocked.Word = strings.ToLower(strings.TrimSpace(blocked.Word))
+	if blocked.Word == "" {
+		httpError(w, "Missing word", http.StatusBadRequest)
+		return
+	}
+
+	blocked.ID = primitive.NewObjectID()
+	_, err = blocklistCollection().InsertOne(r.Context(), blocked)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeResponse(w, r, http.StatusCreated, blocked)
+}
+
+func deleteBlockedWord(w http.ResponseWriter, r *http.Request) {
+	objectID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	res, err := blocklistCollection().DeleteOne(r.Context(), bson.M{"_id": objectID})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if res.DeletedCount == 0 {
+		writeError(w, domain.New(domain.ErrNotFound, "Blocked word not found"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
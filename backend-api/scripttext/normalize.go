@@ -0,0 +1,70 @@
+// Package scripttext normalizes script text before it is stored, so text to
+// speech, alignment and clients get the same characters for the same text.
+package scripttext
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/bidi"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Directional marks are invisible and only steer how neutral characters
+// such as punctuation and digits are laid out next to right-to-left text.
+const (
+	leftToRightMark  = '\u200e'
+	rightToLeftMark  = '\u200f'
+	arabicLetterMark = '\u061c'
+)
+
+// Normalize returns text in NFC with control characters, byte order marks
+// and bidi embeddings, overrides and isolates removed; the latter can make
+// text display differently from how it reads. Line and paragraph separators
+// become newlines and tabs spaces. Directional marks are kept only in text
+// with right-to-left letters, and only between other characters, one at a
+// time.
+func Normalize(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	rtl := hasRightToLeft(text)
+
+	var b strings.Builder
+	b.Grow(len(text))
+	var mark rune
+	for _, r := range text {
+		switch {
+		case r == leftToRightMark || r == rightToLeftMark || r == arabicLetterMark:
+			// The last of a run of marks is the one that takes effect
+			mark = r
+			continue
+		case r == '\n' || r == '\r' || r == '\u2028' || r == '\u2029':
+			r = '\n'
+		case r == '\t':
+			r = ' '
+		case unicode.IsControl(r) || r == '\ufeff' || isBidiControl(r):
+			continue
+		}
+		if mark != 0 && rtl && b.Len() > 0 {
+			b.WriteRune(mark)
+		}
+		mark = 0
+		b.WriteRune(r)
+	}
+	return norm.NFC.String(b.String())
+}
+
+// isBidiControl reports whether r is an embedding, override or isolate, or
+// the character closing one.
+func isBidiControl(r rune) bool {
+	return (r >= '\u202a' && r <= '\u202e') || (r >= '\u2066' && r <= '\u2069')
+}
+
+func hasRightToLeft(text string) bool {
+	for _, r := range text {
+		props, _ := bidi.LookupRune(r)
+		if class := props.Class(); class == bidi.R || class == bidi.AL {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,177 @@
+// Package sdk generates client packages for the story API from its OpenAPI
+// snapshots: a TypeScript package for the web frontends and a Swift package
+// for the iOS app. Every schema version gets packages of its own, versioned
+// after it, so clients can pin the release they were built against.
+package sdk
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"rosetta/openapi"
+)
+
+// Languages the packages are generated in.
+const (
+	TypeScript = "typescript"
+	Swift      = "swift"
+)
+
+var Languages = []string{TypeScript, Swift}
+
+var ErrUnknownLanguage = errors.New("sdk: unknown language")
+
+// File is one file of a generated package, named by its path in the package.
+type File struct {
+	Name string
+	Data []byte
+}
+
+// Generate returns the package for doc in language. The output only depends
+// on doc, so a released version always yields the same package.
+func Generate(doc *openapi.Document, language string) ([]File, error) {
+	ops := operations(doc)
+	switch language {
+	case TypeScript:
+		return typeScript(doc, ops), nil
+	case Swift:
+		return swift(doc, ops), nil
+	}
+	return nil, ErrUnknownLanguage
+}
+
+// Archive zips files. Entries carry a fixed date so the same package always
+// zips to the same bytes.
+func Archive(files []File) ([]byte, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, file := range files {
+		w, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     file.Name,
+			Method:   zip.Deflate,
+			Modified: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err = w.Write(file.Data); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// PackageVersion is the version the packages of a schema version are
+// published under.
+func PackageVersion(doc *openapi.Document) string {
+	return doc.Info.Version + ".0.0"
+}
+
+// operation is a documented route as the clients expose it.
+type operation struct {
+	Name        string // lower camel case, e.g. listPublicStories
+	Method      string // upper case
+	Path        string
+	Params      []string // path parameters, in order
+	Request     *openapi.Schema
+	ContentType string
+	Response    *openapi.Schema // nil for no body
+}
+
+// operations lists the operations of doc ordered by name. The snapshots
+// carry no operation IDs; names are made from the method and path the way
+// the handlers are named, e.g. GET /stories/{id} is getStory.
+func operations(doc *openapi.Document) []operation {
+	var ops []operation
+	for path, methods := range doc.Paths {
+		for method, op := range methods {
+			o := operation{Method: strings.ToUpper(method), Path: path}
+			var words []string
+			for _, segment := range strings.Split(strings.Trim(path, "/"), "/") {
+				if strings.HasPrefix(segment, "{") {
+					o.Params = append(o.Params, strings.Trim(segment, "{}"))
+				} else {
+					words = append(words, segment)
+				}
+			}
+			collection := !strings.HasSuffix(path, "}")
+			if !collection || o.Method == "POST" {
+				words[len(words)-1] = singular(words[len(words)-1])
+			}
+			o.Name = verb(o.Method, collection) + upperCamel(strings.Join(words, "_"))
+
+			if op.RequestBody != nil {
+				for contentType, media := range op.RequestBody.Content {
+					o.ContentType, o.Request = contentType, media.Schema
+				}
+			}
+			for _, response := range op.Responses {
+				for _, media := range response.Content {
+					o.Response = media.Schema
+				}
+			}
+			ops = append(ops, o)
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i].Name < ops[j].Name })
+	return ops
+}
+
+func verb(method string, collection bool) string {
+	switch method {
+	case "GET":
+		if collection {
+			return "list"
+		}
+		return "get"
+	case "POST":
+		return "create"
+	case "PUT":
+		return "update"
+	}
+	return strings.ToLower(method)
+}
+
+func singular(word string) string {
+	switch {
+	case strings.HasSuffix(word, "ies"):
+		return strings.TrimSuffix(word, "ies") + "y"
+	case strings.HasSuffix(word, "s"):
+		return strings.TrimSuffix(word, "s")
+	}
+	return word
+}
+
+// upperCamel turns snake_case and kebab-case into UpperCamelCase.
+func upperCamel(name string) string {
+	var b strings.Builder
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool { return r == '_' || r == '-' }) {
+		b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return b.String()
+}
+
+func lowerCamel(name string) string {
+	name = upperCamel(name)
+	if name == "" {
+		return name
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// sortedKeys returns the keys of m in order, so output is stable.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
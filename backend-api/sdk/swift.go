@@ -0,0 +1,288 @@
+package sdk
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"rosetta/openapi"
+)
+
+const swiftModule = "RosettaAPI"
+
+// Swift keywords that can't be used as property or parameter names as is.
+var swiftKeywords = []string{"case", "class", "default", "enum", "extension", "func", "import", "init", "internal", "let", "operator", "private", "protocol", "public", "return", "self", "static", "struct", "subscript", "super", "switch", "var", "where", "while"}
+
+// swift generates a Swift package with a Codable struct per schema and an
+// async URLSession client with a method per operation.
+func swift(doc *openapi.Document, ops []operation) []File {
+	header := fmt.Sprintf("// Generated from Rosetta API schema version %s. Do not edit.\n\nimport Foundation\n", doc.Info.Version)
+
+	var models strings.Builder
+	models.WriteString(header)
+	for _, name := range sortedKeys(doc.Components.Schemas) {
+		schema := doc.Components.Schemas[name]
+		fmt.Fprintf(&models, "\npublic struct %s: Codable, Hashable {\n", name)
+		properties := sortedKeys(schema.Properties)
+		for _, property := range properties {
+			fmt.Fprintf(&models, "    public var %s: %s?\n", swiftName(property), swiftType(schema.Properties[property]))
+		}
+
+		var params, assigns []string
+		for _, property := range properties {
+			params = append(params, fmt.Sprintf("%s: %s? = nil", swiftName(property), swiftType(schema.Properties[property])))
+			assigns = append(assigns, fmt.Sprintf("        self.%s = %s\n", lowerCamel(property), swiftName(property)))
+		}
+		fmt.Fprintf(&models, "\n    public init(%s) {\n%s    }\n", strings.Join(params, ", "), strings.Join(assigns, ""))
+
+		if len(properties) > 0 {
+			models.WriteString("\n    enum CodingKeys: String, CodingKey {\n")
+			for _, property := range properties {
+				fmt.Fprintf(&models, "        case %s = %q\n", swiftName(property), property)
+			}
+			models.WriteString("    }\n")
+		}
+		models.WriteString("}\n")
+	}
+
+	var client strings.Builder
+	client.WriteString(header)
+	client.WriteString(swiftRuntime)
+	for _, op := range ops {
+		var params []string
+		for _, param := range op.Params {
+			params = append(params, swiftName(param)+": String")
+		}
+		if op.Request != nil {
+			params = append(params, "body: "+swiftType(op.Request))
+		}
+		params = append(params, "options: RequestOptions = RequestOptions()")
+
+		path := op.Path
+		for _, param := range op.Params {
+			path = strings.Replace(path, "{"+param+"}", `\(escape(`+swiftName(param)+`))`, 1)
+		}
+
+		body := "Empty?.none"
+		if op.Request != nil {
+			body = "body"
+		}
+		fmt.Fprintf(&client, "\n    /// %s %s\n", op.Method, op.Path)
+		if op.Response == nil {
+			fmt.Fprintf(&client, "    public func %s(%s) async throws {\n", op.Name, strings.Join(params, ", "))
+			fmt.Fprintf(&client, "        _ = try await send(%q, \"%s\", body: %s, contentType: %q, options: options)\n", op.Method, path, body, op.ContentType)
+		} else {
+			fmt.Fprintf(&client, "    public func %s(%s) async throws -> %s {\n", op.Name, strings.Join(params, ", "), swiftType(op.Response))
+			fmt.Fprintf(&client, "        let data = try await send(%q, \"%s\", body: %s, contentType: %q, options: options)\n", op.Method, path, body, op.ContentType)
+			fmt.Fprintf(&client, "        return try decoder.decode(%s.self, from: data)\n", swiftType(op.Response))
+		}
+		client.WriteString("    }\n")
+	}
+	client.WriteString("}\n")
+
+	manifest := fmt.Sprintf(`// swift-tools-version:5.7
+// Client for the Rosetta story API, generated from schema version %s.
+import PackageDescription
+
+let package = Package(
+    name: %q,
+    platforms: [.iOS(.v15), .macOS(.v12)],
+    products: [.library(name: %q, targets: [%q])],
+    targets: [.target(name: %q)]
+)
+`, doc.Info.Version, swiftModule, swiftModule, swiftModule, swiftModule)
+
+	sources := "Sources/" + swiftModule + "/"
+	return []File{
+		{Name: "Package.swift", Data: []byte(manifest)},
+		{Name: sources + "Models.swift", Data: []byte(models.String())},
+		{Name: sources + "Client.swift", Data: []byte(client.String())},
+		{Name: sources + "JSONValue.swift", Data: []byte(header + swiftJSONValue)},
+	}
+}
+
+func swiftName(name string) string {
+	name = lowerCamel(name)
+	if slices.Contains(swiftKeywords, name) {
+		return "`" + name + "`"
+	}
+	return name
+}
+
+func swiftType(schema *openapi.Schema) string {
+	if schema.Ref != "" {
+		return schema.RefName()
+	}
+	switch schema.Type {
+	case "string":
+		if schema.Format == "date-time" {
+			return "Date"
+		}
+		return "String"
+	case "integer":
+		return "Int64"
+	case "number":
+		return "Double"
+	case "boolean":
+		return "Bool"
+	case "array":
+		return "[" + swiftType(schema.Items) + "]"
+	case "object":
+		return "[String: JSONValue]"
+	}
+	return "JSONValue"
+}
+
+// swiftRuntime is the part of the client that doesn't depend on the schema.
+// The struct is left open for the generated methods.
+const swiftRuntime = `
+public struct RequestOptions {
+    /// Extra headers, e.g. If-Match with a story's ETag.
+    public var headers: [String: String]
+    /// Query parameters, e.g. limit, cursor or metadata.<key> filters.
+    public var query: [String: String]
+
+    public init(headers: [String: String] = [:], query: [String: String] = [:]) {
+        self.headers = headers
+        self.query = query
+    }
+}
+
+/// An error answered by the API, with its stable error code.
+public struct RosettaError: Error {
+    public var status: Int
+    public var code: String
+    public var message: String
+}
+
+private struct ErrorEnvelope: Decodable {
+    struct Body: Decodable {
+        var code: String
+        var message: String
+    }
+    var error: Body
+}
+
+private struct Empty: Encodable {}
+
+public struct RosettaClient {
+    public var baseURL: URL
+    /// Sent as a bearer token: a session token or an API key.
+    public var token: String?
+    public var session: URLSession
+
+    public init(baseURL: URL, token: String? = nil, session: URLSession = .shared) {
+        self.baseURL = baseURL
+        self.token = token
+        self.session = session
+    }
+
+    private var encoder: JSONEncoder {
+        let encoder = JSONEncoder()
+        encoder.dateEncodingStrategy = .custom { date, encoder in
+            var container = encoder.singleValueContainer()
+            try container.encode(Self.dateFormatter.string(from: date))
+        }
+        return encoder
+    }
+
+    private var decoder: JSONDecoder {
+        let decoder = JSONDecoder()
+        decoder.dateDecodingStrategy = .custom { decoder in
+            let container = try decoder.singleValueContainer()
+            let value = try container.decode(String.self)
+            if let date = Self.dateFormatter.date(from: value) ?? Self.secondsFormatter.date(from: value) {
+                return date
+            }
+            throw DecodingError.dataCorruptedError(in: container, debugDescription: "Invalid date \(value)")
+        }
+        return decoder
+    }
+
+    private static let dateFormatter: ISO8601DateFormatter = {
+        let formatter = ISO8601DateFormatter()
+        formatter.formatOptions = [.withInternetDateTime, .withFractionalSeconds]
+        return formatter
+    }()
+
+    private static let secondsFormatter = ISO8601DateFormatter()
+
+    private func escape(_ segment: String) -> String {
+        segment.addingPercentEncoding(withAllowedCharacters: .urlPathAllowed.subtracting(CharacterSet(charactersIn: "/"))) ?? segment
+    }
+
+    private func send<Body: Encodable>(_ method: String, _ path: String, body: Body?, contentType: String, options: RequestOptions) async throws -> Data {
+        var components = URLComponents(url: baseURL, resolvingAgainstBaseURL: false)!
+        let basePath = components.percentEncodedPath
+        components.percentEncodedPath = (basePath.hasSuffix("/") ? String(basePath.dropLast()) : basePath) + path
+        if !options.query.isEmpty {
+            components.queryItems = options.query.sorted { $0.key < $1.key }.map { URLQueryItem(name: $0.key, value: $0.value) }
+        }
+        var request = URLRequest(url: components.url!)
+        request.httpMethod = method
+        request.setValue("application/json", forHTTPHeaderField: "Accept")
+        if let token = token {
+            request.setValue("Bearer \(token)", forHTTPHeaderField: "Authorization")
+        }
+        if let body = body {
+            request.setValue(contentType, forHTTPHeaderField: "Content-Type")
+            request.httpBody = try encoder.encode(body)
+        }
+        for (name, value) in options.headers {
+            request.setValue(value, forHTTPHeaderField: name)
+        }
+
+        let (data, response) = try await session.data(for: request)
+        let status = (response as? HTTPURLResponse)?.statusCode ?? 0
+        guard (200..<300).contains(status) else {
+            let envelope = try? JSONDecoder().decode(ErrorEnvelope.self, from: data)
+            throw RosettaError(status: status, code: envelope?.error.code ?? "", message: envelope?.error.message ?? HTTPURLResponse.localizedString(forStatusCode: status))
+        }
+        return data
+    }
+`
+
+// swiftJSONValue holds values the schema leaves untyped, such as metadata.
+const swiftJSONValue = `
+public enum JSONValue: Codable, Hashable, ExpressibleByStringLiteral {
+    case string(String)
+    case number(Double)
+    case bool(Bool)
+    case array([JSONValue])
+    case object([String: JSONValue])
+    case null
+
+    public init(stringLiteral value: String) {
+        self = .string(value)
+    }
+
+    public init(from decoder: Decoder) throws {
+        let container = try decoder.singleValueContainer()
+        if container.decodeNil() {
+            self = .null
+        } else if let value = try? container.decode(Bool.self) {
+            self = .bool(value)
+        } else if let value = try? container.decode(Double.self) {
+            self = .number(value)
+        } else if let value = try? container.decode(String.self) {
+            self = .string(value)
+        } else if let value = try? container.decode([JSONValue].self) {
+            self = .array(value)
+        } else {
+            self = .object(try container.decode([String: JSONValue].self))
+        }
+    }
+
+    public func encode(to encoder: Encoder) throws {
+        var container = encoder.singleValueContainer()
+        switch self {
+        case .string(let value): try container.encode(value)
+        case .number(let value): try container.encode(value)
+        case .bool(let value): try container.encode(value)
+        case .array(let value): try container.encode(value)
+        case .object(let value): try container.encode(value)
+        case .null: try container.encodeNil()
+        }
+    }
+}
+`
@@ -0,0 +1,162 @@
+package sdk
+
+import (
+	"fmt"
+	"strings"
+
+	"rosetta/openapi"
+)
+
+const typeScriptPackage = "@rosetta/api-client"
+
+// typeScript generates an npm package with an interface per schema and a
+// fetch-based client with a method per operation.
+func typeScript(doc *openapi.Document, ops []operation) []File {
+	var src strings.Builder
+	fmt.Fprintf(&src, "// Generated from Rosetta API schema version %s. Do not edit.\n\n", doc.Info.Version)
+
+	for _, name := range sortedKeys(doc.Components.Schemas) {
+		fmt.Fprintf(&src, "export interface %s {\n", name)
+		schema := doc.Components.Schemas[name]
+		for _, property := range sortedKeys(schema.Properties) {
+			fmt.Fprintf(&src, "  %s?: %s;\n", property, tsType(schema.Properties[property]))
+		}
+		src.WriteString("}\n\n")
+	}
+
+	src.WriteString(tsRuntime)
+
+	for _, op := range ops {
+		var params []string
+		for _, param := range op.Params {
+			params = append(params, lowerCamel(param)+": string")
+		}
+		if op.Request != nil {
+			params = append(params, "body: "+tsType(op.Request))
+		}
+		params = append(params, "options: RequestOptions = {}")
+
+		response := "void"
+		if op.Response != nil {
+			response = tsType(op.Response)
+		}
+		path := op.Path
+		for _, param := range op.Params {
+			path = strings.Replace(path, "{"+param+"}", "${encodeURIComponent("+lowerCamel(param)+")}", 1)
+		}
+
+		body := "undefined"
+		if op.Request != nil {
+			body = "body"
+		}
+		fmt.Fprintf(&src, "\n  /** %s %s */\n", op.Method, op.Path)
+		fmt.Fprintf(&src, "  %s(%s): Promise<%s> {\n", op.Name, strings.Join(params, ", "), response)
+		fmt.Fprintf(&src, "    return this.request(%q, `%s`, %s, %q, options) as Promise<%s>;\n", op.Method, path, body, op.ContentType, response)
+		src.WriteString("  }\n")
+	}
+	src.WriteString("}\n")
+
+	packageJSON := fmt.Sprintf(`{
+  "name": %q,
+  "version": %q,
+  "description": "Client for the Rosetta story API, generated from schema version %s",
+  "main": "src/index.ts",
+  "types": "src/index.ts"
+}
+`, typeScriptPackage, PackageVersion(doc), doc.Info.Version)
+
+	return []File{
+		{Name: "package.json", Data: []byte(packageJSON)},
+		{Name: "src/index.ts", Data: []byte(src.String())},
+	}
+}
+
+func tsType(schema *openapi.Schema) string {
+	if schema.Ref != "" {
+		return schema.RefName()
+	}
+	switch schema.Type {
+	case "string":
+		return "string"
+	case "integer", "number":
+		return "number"
+	case "boolean":
+		return "boolean"
+	case "array":
+		return tsType(schema.Items) + "[]"
+	case "object":
+		return "Record<string, unknown>"
+	}
+	return "unknown"
+}
+
+// tsRuntime is the part of the client that doesn't depend on the schema.
+// The class is left open for the generated methods.
+const tsRuntime = `export interface ClientOptions {
+  /** Sent as a bearer token: a session token or an API key. */
+  token?: string;
+  fetch?: typeof fetch;
+}
+
+export interface RequestOptions {
+  /** Extra headers, e.g. If-Match with a story's ETag. */
+  headers?: Record<string, string>;
+  /** Query parameters, e.g. limit, cursor or metadata.<key> filters. */
+  query?: Record<string, string>;
+  signal?: AbortSignal;
+}
+
+/** An error answered by the API, with its stable error code. */
+export class RosettaError extends Error {
+  constructor(
+    readonly status: number,
+    readonly code: string,
+    message: string,
+    readonly details?: unknown,
+  ) {
+    super(message);
+  }
+}
+
+export class RosettaClient {
+  constructor(
+    readonly baseUrl: string,
+    readonly options: ClientOptions = {},
+  ) {}
+
+  private async request(
+    method: string,
+    path: string,
+    body: unknown,
+    contentType: string,
+    options: RequestOptions,
+  ): Promise<unknown> {
+    const url = new URL(this.baseUrl.replace(/\/$/, "") + path);
+    for (const [key, value] of Object.entries(options.query ?? {})) {
+      url.searchParams.set(key, value);
+    }
+    const headers: Record<string, string> = { Accept: "application/json", ...options.headers };
+    if (this.options.token) {
+      headers.Authorization = "Bearer " + this.options.token;
+    }
+    if (body !== undefined) {
+      headers["Content-Type"] = contentType;
+    }
+
+    const response = await (this.options.fetch ?? fetch)(url, {
+      method,
+      headers,
+      body: body === undefined ? undefined : JSON.stringify(body),
+      signal: options.signal,
+    });
+    if (!response.ok) {
+      const envelope = await response.json().catch(() => undefined);
+      const error = envelope?.error ?? {};
+      throw new RosettaError(response.status, error.code ?? "", error.message ?? response.statusText, error.details);
+    }
+    if (response.status === 204) {
+      return undefined;
+    }
+    return response.json();
+  }
+`
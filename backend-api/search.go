@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"rosetta/api"
+	"rosetta/repository"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchQuery     = 200
+)
+
+type searchResult struct {
+	api.StoryResponse
+	Score float64 `json:"score"`
+}
+
+// searchStories finds stories whose title or script text matches q, best
+// matches first. Only listed stories are searched, plus the caller's own
+// when signed in, unless published=true asks for listed stories alone.
+func searchStories(w http.ResponseWriter, r *http.Request) {
+	serveSearch(w, r, false)
+}
+
+// searchPublicStories is searchStories for the public API: listed stories
+// only, whoever asks, in pages of at most publicMaxListLimit.
+func searchPublicStories(w http.ResponseWriter, r *http.Request) {
+	serveSearch(w, r, true)
+}
+
+func serveSearch(w http.ResponseWriter, r *http.Request, public bool) {
+	query := r.URL.Query()
+	maxLimit := maxListLimit
+	if public {
+		maxLimit = publicMaxListLimit
+	}
+
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" || len(q) > maxSearchQuery {
+		httpError(w, fmt.Sprintf("q is required and must be at most %d characters", maxSearchQuery), http.StatusBadRequest)
+		return
+	}
+
+	search := repository.SearchQuery{
+		Text:     q,
+		Listed:   true,
+		Language: query.Get("lang"),
+		Limit:    defaultSearchLimit,
+	}
+	if userID, ok := currentUser(r.Context()); ok && !public && query.Get("published") != "true" {
+		search.Owner = userID
+	}
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxLimit {
+			httpError(w, fmt.Sprintf("limit must be between 1 and %d", maxLimit), http.StatusBadRequest)
+			return
+		}
+		search.Limit = n
+	}
+	if v := query.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			httpError(w, "offset must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		search.Offset = n
+	}
+
+	hits, total, err := storyRepo.Search(r.Context(), search)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	results := make([]searchResult, 0, len(hits))
+	for i := range hits {
+		hits[i].Lock = nil
+		results = append(results, searchResult{StoryResponse: api.StoryFromModel(&hits[i].Story), Score: hits[i].Score})
+	}
+
+	w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+	if int64(search.Offset+search.Limit) < total {
+		next := r.URL.Query()
+		next.Set("offset", strconv.Itoa(search.Offset+search.Limit))
+		w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, next.Encode()))
+	}
+	if public {
+		setPublicCache(w)
+	}
+	writeResponse(w, r, http.StatusOK, results)
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/api"
+	"rosetta/domain"
+	"rosetta/models"
+	"rosetta/scripttext"
+	"rosetta/sentences"
+)
+
+type splitRequest struct {
+	Text     string `json:"text"`
+	Language string `json:"language"`
+	// Position is the index the new segments are inserted at; they are
+	// appended when it is omitted.
+	Position *int `json:"position"`
+}
+
+func splitIntoSegments(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	objectID, err := primitive.ObjectIDFromHex(vars["id"])
+	if err != nil {
+		httpError(w, "Invalid story ID", http.StatusBadRequest)
+		return
+	}
+
+	var request splitRequest
+	err = decodeRequest(r, &request)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Normalized before splitting, so stray controls don't end up as segments
+	request.Text = scripttext.Normalize(request.Text)
+	if strings.TrimSpace(request.Text) == "" {
+		httpError(w, "Missing text", http.StatusBadRequest)
+		return
+	}
+
+	story, err := modifyStory(r.Context(), objectID, func(story *models.Story) error {
+		if err := authorizeStory(r.Context(), story); err != nil {
+			return err
+		}
+		lang := request.Language
+		if lang == "" {
+			lang = story.Language
+		}
+
+		var created []models.Segment
+		for _, sentence := range sentences.Split(request.Text, lang) {
+			script := &models.Script{Text: sentence}
+			if lang != story.Language {
+				script.Language = lang
+			}
+			created = append(created, models.Segment{ID: primitive.NewObjectID(), Script: script})
+		}
+
+		position := len(story.Segments)
+		if request.Position != nil {
+			position = *request.Position
+		}
+		if position < 0 || position > len(story.Segments) {
+			return domain.New(domain.ErrInvalid, "Position is out of range")
+		}
+
+		segments := append([]models.Segment{}, story.Segments[:position]...)
+		segments = append(segments, created...)
+		story.Segments = append(segments, story.Segments[position:]...)
+		return nil
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeResponse(w, r, http.StatusOK, api.StoryFromModel(&story))
+}
+
+type mergeRequest struct {
+	FirstID  primitive.ObjectID `json:"first_id"`
+	SecondID primitive.ObjectID `json:"second_id"`
+}
+
+func mergeSegments(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	objectID, err := primitive.ObjectIDFromHex(vars["id"])
+	if err != nil {
+		httpError(w, "Invalid story ID", http.StatusBadRequest)
+		return
+	}
+
+	var request mergeRequest
+	err = decodeRequest(r, &request)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	story, err := modifyStory(r.Context(), objectID, func(story *models.Story) error {
+		if err := authorizeStory(r.Context(), story); err != nil {
+			return err
+		}
+		i := -1
+		for j := range story.Segments {
+			if story.Segments[j].ID == request.FirstID {
+				i = j
+				break
+			}
+		}
+		if i < 0 || i+1 >= len(story.Segments) || story.Segments[i+1].ID != request.SecondID {
+			return domain.New(domain.ErrInvalid, "Segments must exist and be adjacent, first before second")
+		}
+
+		merged := mergeSegmentPair(story.Segments[i], story.Segments[i+1])
+		story.Segments[i] = merged
+		story.Segments = append(story.Segments[:i+1], story.Segments[i+2:]...)
+		return nil
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeResponse(w, r, http.StatusOK, api.StoryFromModel(&story))
+}
+
+// mergeSegmentPair joins second onto first. Audio can't be spliced here, so
+// the merged segment keeps the first recording only when the second adds no
+// text; otherwise the audio is cleared and has to be recorded again. Clips
+// of the same track are the exception, as their ranges can be joined.
+func mergeSegmentPair(first, second models.Segment) models.Segment {
+	merged := models.Segment{ID: first.ID, Image: first.Image, Speaker: first.Speaker}
+	if merged.Image == nil {
+		merged.Image = second.Image
+	}
+
+	firstText, secondText := scriptText(first), scriptText(second)
+	if firstText != "" || secondText != "" {
+		merged.Script = &models.Script{
+			Text:         joinScripts(firstText, secondText),
+			Translations: mergeTranslations(first, second, firstText != "", secondText != ""),
+		}
+		if first.Script != nil {
+			merged.Script.Language = first.Script.Language
+		}
+	}
+
+	if secondText == "" && second.Audio == nil && second.Clip == nil {
+		merged.Audio = first.Audio
+		merged.Clip = first.Clip
+	}
+	// Clips of one track, in order, still cover both
+	if first.Clip != nil && second.Clip != nil && first.Clip.TrackID == second.Clip.TrackID && first.Clip.StartMs <= second.Clip.StartMs {
+		merged.Clip = &models.AudioClip{
+			TrackID: first.Clip.TrackID,
+			StartMs: first.Clip.StartMs,
+			EndMs:   max(first.Clip.EndMs, second.Clip.EndMs),
+		}
+	}
+	return merged
+}
+
+// mergeTranslations joins the translations of two scripts. A language only
+// one of them is translated into is dropped unless the other has no text,
+// as half a translation would read as a whole one. The result is never nil,
+// so the merged segment doesn't keep the first one's stored translations.
+func mergeTranslations(first, second models.Segment, firstHasText, secondHasText bool) map[string]string {
+	var a, b map[string]string
+	if first.Script != nil {
+		a = first.Script.Translations
+	}
+	if second.Script != nil {
+		b = second.Script.Translations
+	}
+
+	merged := map[string]string{}
+	for lang, text := range a {
+		if other, ok := b[lang]; ok {
+			merged[lang] = joinScripts(strings.TrimSpace(text), strings.TrimSpace(other))
+		} else if !secondHasText {
+			merged[lang] = text
+		}
+	}
+	for lang, text := range b {
+		if _, ok := a[lang]; !ok && !firstHasText {
+			merged[lang] = text
+		}
+	}
+	return merged
+}
+
+func scriptText(segment models.Segment) string {
+	if segment.Script == nil {
+		return ""
+	}
+	return strings.TrimSpace(segment.Script.Text)
+}
+
+// joinScripts concatenates two scripts, leaving out the space for scripts
+// that don't separate words, like Chinese, Japanese or Thai.
+func joinScripts(a, b string) string {
+	if a == "" || b == "" {
+		return a + b
+	}
+	last, _ := utf8.DecodeLastRuneInString(a)
+	cjkPunctuation := (last >= 0x3000 && last <= 0x303F) || (last >= 0xFF00 && last <= 0xFFEF)
+	if cjkPunctuation || unicode.In(last, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Thai) {
+		return a + b
+	}
+	return a + " " + b
+}
@@ -0,0 +1,143 @@
+// Package sentences splits running text into sentences using per-language
+// punctuation and abbreviation rules.
+package sentences
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Terminators that end a sentence even without following whitespace, as used
+// by scripts that don't separate words with spaces.
+var closingTerminators = map[rune]bool{
+	'。': true, '！': true, '？': true, '｡': true,
+}
+
+var terminators = map[rune]bool{
+	'.': true, '!': true, '?': true, '…': true,
+	'؟': true, '۔': true, // Arabic, Urdu
+	'।': true, '॥': true, // Devanagari
+	'։': true, // Armenian
+	'።': true, // Ethiopic
+	'။': true, // Burmese
+}
+
+// Closing quotes and brackets that belong to the sentence they follow.
+var trailers = map[rune]bool{
+	'"': true, '\'': true, ')': true, ']': true, '»': true, '”': true, '’': true,
+	'」': true, '』': true, '）': true,
+}
+
+var abbreviations = map[string][]string{
+	"en": {"mr", "mrs", "ms", "dr", "prof", "st", "jr", "sr", "vs", "etc", "e.g", "i.e", "no"},
+	"de": {"dr", "prof", "nr", "bzw", "usw", "z.b", "d.h", "ca", "str"},
+	"fr": {"m", "mme", "mlle", "dr", "etc", "p.ex", "av"},
+	"es": {"sr", "sra", "srta", "dr", "dra", "etc", "ud", "uds"},
+	"it": {"sig", "dott", "prof", "ecc"},
+	"pt": {"sr", "sra", "dr", "dra", "etc"},
+}
+
+// Split breaks text into trimmed, non-empty sentences. lang is a BCP 47 tag;
+// only its primary subtag is used to pick abbreviation rules. Blank lines
+// always end a sentence.
+func Split(text, lang string) []string {
+	abbrevs := map[string]bool{}
+	for _, a := range abbreviations[primaryLanguage(lang)] {
+		abbrevs[a] = true
+	}
+
+	var result []string
+	for _, paragraph := range paragraphs(text) {
+		result = append(result, splitParagraph(paragraph, abbrevs)...)
+	}
+	return result
+}
+
+func primaryLanguage(lang string) string {
+	lang = strings.ToLower(lang)
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	return lang
+}
+
+func paragraphs(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	var result []string
+	for _, p := range strings.Split(text, "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+func splitParagraph(text string, abbrevs map[string]bool) []string {
+	runes := []rune(text)
+	var result []string
+	start := 0
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if !terminators[r] && !closingTerminators[r] {
+			continue
+		}
+
+		// Swallow runs like "?!" or "..." and any closing quotes
+		end := i + 1
+		for end < len(runes) && (terminators[runes[end]] || closingTerminators[runes[end]] || trailers[runes[end]]) {
+			end++
+		}
+
+		if !closingTerminators[r] {
+			if end < len(runes) && !unicode.IsSpace(runes[end]) {
+				continue
+			}
+			if r == '.' && end-i == 1 && isAbbreviation(runes[start:i], abbrevs) {
+				continue
+			}
+			if r == '.' && end < len(runes) && startsLowercase(runes[end:]) {
+				continue
+			}
+		}
+
+		if sentence := strings.TrimSpace(string(runes[start:end])); sentence != "" {
+			result = append(result, sentence)
+		}
+		start = end
+		i = end - 1
+	}
+
+	if rest := strings.TrimSpace(string(runes[start:])); rest != "" {
+		result = append(result, rest)
+	}
+	return result
+}
+
+// isAbbreviation reports whether the word just before a period is a known
+// abbreviation or a single letter initial.
+func isAbbreviation(before []rune, abbrevs map[string]bool) bool {
+	j := len(before)
+	for j > 0 && !unicode.IsSpace(before[j-1]) {
+		j--
+	}
+	word := strings.TrimLeft(string(before[j:]), "\"'(«“‘")
+	if word == "" {
+		return false
+	}
+	if abbrevs[strings.ToLower(word)] {
+		return true
+	}
+	letters := []rune(word)
+	return len(letters) == 1 && unicode.IsUpper(letters[0])
+}
+
+func startsLowercase(rest []rune) bool {
+	for _, r := range rest {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		return unicode.IsLower(r)
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// serve runs server until ctx is done, then fails readiness for drainDelay
+// so load balancers stop sending traffic, stops accepting connections and
+// gives in-flight requests up to timeout to finish.
+func serve(ctx context.Context, server *http.Server, drainDelay, timeout time.Duration) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	drain.draining.Store(true)
+	if drainDelay > 0 {
+		log.Printf("draining, still serving for %s", drainDelay)
+		time.Sleep(drainDelay)
+	}
+
+	log.Printf("shutting down, waiting up to %s for in-flight requests", timeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+// Package spam scores new content for likely spam. Classifier is the
+// extension point; Heuristics is the built-in implementation.
+package spam
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Thresholds on the summed signal scores.
+const (
+	FlagScore = 0.5
+	HideScore = 1.0
+)
+
+type Input struct {
+	Title string
+	Text  string
+	// RecentCreations is how many stories the same client created in the
+	// last hour, and Duplicates how many stored stories share the content
+	// fingerprint.
+	RecentCreations int
+	Duplicates      int
+}
+
+type Signal struct {
+	Name   string  `json:"name"`
+	Score  float64 `json:"score"`
+	Detail string  `json:"detail"`
+}
+
+type Classifier interface {
+	Classify(ctx context.Context, in Input) ([]Signal, error)
+}
+
+type Heuristics struct {
+	MaxCreationsPerHour int
+	MaxLinkDensity      float64
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+
+func (h Heuristics) Classify(ctx context.Context, in Input) ([]Signal, error) {
+	var signals []Signal
+
+	if in.Duplicates > 0 {
+		signals = append(signals, Signal{
+			Name:   "duplicate_content",
+			Score:  0.6 * float64(min(in.Duplicates, 2)),
+			Detail: fmt.Sprintf("%d stories with identical content", in.Duplicates),
+		})
+	}
+
+	if in.RecentCreations > h.MaxCreationsPerHour {
+		signals = append(signals, Signal{
+			Name:   "creation_rate",
+			Score:  0.5,
+			Detail: fmt.Sprintf("%d stories created in the last hour", in.RecentCreations),
+		})
+	}
+
+	text := in.Title + " " + in.Text
+	if words := len(strings.Fields(text)); words > 0 {
+		links := len(linkPattern.FindAllString(text, -1))
+		if density := float64(links) / float64(words); links > 1 && density > h.MaxLinkDensity {
+			signals = append(signals, Signal{
+				Name:   "link_density",
+				Score:  0.5,
+				Detail: fmt.Sprintf("%d links in %d words", links, words),
+			})
+		}
+	}
+
+	return signals, nil
+}
+
+func Score(signals []Signal) float64 {
+	total := 0.0
+	for _, s := range signals {
+		total += s.Score
+	}
+	return total
+}
+
+// Fingerprint hashes content after normalising case and whitespace, so
+// trivially reformatted copies collide.
+func Fingerprint(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(strings.Join(strings.Fields(strings.ToLower(p)), " ")))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Memory keeps objects in memory, for local runs and as the reference
+// implementation of the contract. Its presigned URLs use the memory scheme
+// and cannot be uploaded to.
+type Memory struct {
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+}
+
+type memoryObject struct {
+	data []byte
+	info ObjectInfo
+}
+
+func NewMemory() *Memory {
+	return &Memory{objects: make(map[string]memoryObject)}
+}
+
+func (m *Memory) PresignPut(ctx context.Context, key, contentType string, ttl time.Duration) (string, error) {
+	u := url.URL{Scheme: "memory", Path: "/" + key}
+	u.RawQuery = url.Values{"expires": {time.Now().Add(ttl).UTC().Format(time.RFC3339)}}.Encode()
+	return u.String(), nil
+}
+
+func (m *Memory) Put(ctx context.Context, key string, body io.ReadSeeker, contentType string) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	sum := md5.Sum(data)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = memoryObject{data: data, info: ObjectInfo{
+		Key:          key,
+		Size:         int64(len(data)),
+		ContentType:  contentType,
+		ETag:         hex.EncodeToString(sum[:]),
+		LastModified: time.Now(),
+	}}
+	return nil
+}
+
+func (m *Memory) Head(ctx context.Context, key string) (ObjectInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	object, ok := m.objects[key]
+	if !ok {
+		return ObjectInfo{}, ErrNotFound
+	}
+	return object.info, nil
+}
+
+func (m *Memory) Copy(ctx context.Context, srcKey, dstKey string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	object, ok := m.objects[srcKey]
+	if !ok {
+		return ErrNotFound
+	}
+	object.info.Key = dstKey
+	object.info.LastModified = time.Now()
+	m.objects[dstKey] = object
+	return nil
+}
+
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, key)
+	return nil
+}
+
+func (m *Memory) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	objects := []ObjectInfo{}
+	for key, object := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, object.info)
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
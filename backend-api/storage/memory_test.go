@@ -0,0 +1,12 @@
+package storage_test
+
+import (
+	"testing"
+
+	"rosetta/storage"
+	"rosetta/storage/storagetest"
+)
+
+func TestMemory(t *testing.T) {
+	storagetest.TestBackend(t, storage.NewMemory())
+}
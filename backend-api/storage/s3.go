@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// S3 stores objects in a bucket of S3 or an S3-compatible service such as
+// MinIO or LocalStack.
+type S3 struct {
+	client *s3.S3
+	bucket string
+}
+
+func NewS3(client *s3.S3, bucket string) *S3 {
+	return &S3{client: client, bucket: bucket}
+}
+
+func (b *S3) PresignPut(ctx context.Context, key, contentType string, ttl time.Duration) (string, error) {
+	req, _ := b.client.PutObjectRequest(&s3.PutObjectInput{
+		Bucket:      aws.String(b.bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+	})
+	req.SetContext(ctx)
+	return req.Presign(ttl)
+}
+
+func (b *S3) Put(ctx context.Context, key string, body io.ReadSeeker, contentType string) error {
+	_, err := b.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(b.bucket),
+		Key:         aws.String(key),
+		Body:        body,
+		ContentType: aws.String(contentType),
+	})
+	return err
+}
+
+func (b *S3) Head(ctx context.Context, key string) (ObjectInfo, error) {
+	head, err := b.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return ObjectInfo{}, notFound(err)
+	}
+	return ObjectInfo{
+		Key:          key,
+		Size:         aws.Int64Value(head.ContentLength),
+		ContentType:  aws.StringValue(head.ContentType),
+		ETag:         strings.Trim(aws.StringValue(head.ETag), `"`),
+		LastModified: aws.TimeValue(head.LastModified),
+	}, nil
+}
+
+func (b *S3) Copy(ctx context.Context, srcKey, dstKey string) error {
+	_, err := b.client.CopyObjectWithContext(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(b.bucket),
+		Key:        aws.String(dstKey),
+		CopySource: aws.String(url.PathEscape(b.bucket + "/" + srcKey)),
+	})
+	return notFound(err)
+}
+
+func (b *S3) Delete(ctx context.Context, key string) error {
+	_, err := b.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+func (b *S3) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	objects := []ObjectInfo{}
+	err := b.client.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(b.bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, object := range page.Contents {
+			objects = append(objects, ObjectInfo{
+				Key:          aws.StringValue(object.Key),
+				Size:         aws.Int64Value(object.Size),
+				ETag:         strings.Trim(aws.StringValue(object.ETag), `"`),
+				LastModified: aws.TimeValue(object.LastModified),
+			})
+		}
+		return true
+	})
+	return objects, err
+}
+
+// notFound maps the S3 "no such key" errors to ErrNotFound. HEAD responses
+// have no body, so a missing object only shows as a bare 404.
+func notFound(err error) error {
+	var aerr awserr.RequestFailure
+	if errors.As(err, &aerr) && (aerr.Code() == s3.ErrCodeNoSuchKey || aerr.Code() == "NotFound" || aerr.StatusCode() == 404) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,62 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"rosetta/storage"
+	"rosetta/storage/storagetest"
+)
+
+// TestS3 runs against the S3-compatible service at S3_ENDPOINT, e.g. the
+// minio service in docker-compose:
+//
+//	docker compose --profile storage-check run --rm storage-check
+func TestS3(t *testing.T) {
+	endpoint := os.Getenv("S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("S3_ENDPOINT is not set")
+	}
+	sess, err := session.NewSession(&aws.Config{
+		Region:           aws.String(os.Getenv("AWS_REGION")),
+		Credentials:      credentials.NewStaticCredentials(os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"), ""),
+		Endpoint:         aws.String(endpoint),
+		S3ForcePathStyle: aws.Bool(true),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := s3.New(sess)
+	bucket := os.Getenv("S3_BUCKET")
+
+	// The service may still be starting, so bucket creation is retried
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	for {
+		_, err = client.CreateBucketWithContext(ctx, &s3.CreateBucketInput{Bucket: aws.String(bucket)})
+		if err == nil || bucketExists(err) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("creating bucket %s: %v", bucket, err)
+		case <-time.After(time.Second):
+		}
+	}
+
+	storagetest.TestBackend(t, storage.NewS3(client, bucket))
+}
+
+func bucketExists(err error) bool {
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && (aerr.Code() == s3.ErrCodeBucketAlreadyOwnedByYou || aerr.Code() == s3.ErrCodeBucketAlreadyExists)
+}
@@ -0,0 +1,37 @@
+// Package storage abstracts the object store that holds story media, so
+// backends other than S3 can be plugged in and checked against the same
+// contract (see storagetest).
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrNotFound is returned when an object does not exist.
+var ErrNotFound = errors.New("storage: object not found")
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	ETag         string
+	LastModified time.Time
+}
+
+// Backend is an object store. Keys are slash-separated paths; deleting a
+// missing object is not an error.
+type Backend interface {
+	// PresignPut returns a URL a client can PUT the object to until ttl
+	// elapses.
+	PresignPut(ctx context.Context, key, contentType string, ttl time.Duration) (string, error)
+	Put(ctx context.Context, key string, body io.ReadSeeker, contentType string) error
+	Head(ctx context.Context, key string) (ObjectInfo, error)
+	Copy(ctx context.Context, srcKey, dstKey string) error
+	Delete(ctx context.Context, key string) error
+	// List returns the objects whose keys start with prefix, sorted by key.
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+}
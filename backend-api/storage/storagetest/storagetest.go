@@ -0,0 +1,237 @@
+// Package storagetest checks storage backends against the contract of
+// storage.Backend, in the spirit of testing/fstest. It works on any backend
+// and only touches keys under a fresh random prefix, so it can run against
+// a shared bucket. The backends' own tests run it, see storage/*_test.go.
+package storagetest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"rosetta/storage"
+)
+
+const contentType = "audio/mpeg"
+
+// TestBackend runs every check against b as a subtest of t. Objects it
+// creates are deleted afterwards.
+func TestBackend(t *testing.T, b storage.Backend) {
+	t.Helper()
+	ctx := context.Background()
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		t.Fatal(err)
+	}
+	prefix := "storagetest-" + hex.EncodeToString(suffix) + "/"
+	t.Cleanup(func() { cleanup(ctx, b, prefix) })
+
+	checks := []struct {
+		name string
+		fn   func(context.Context, storage.Backend, string) error
+	}{
+		{"presign", checkPresign},
+		{"upload", checkUpload},
+		{"head", checkHead},
+		{"copy", checkCopy},
+		{"delete", checkDelete},
+		{"list-prefix", checkList},
+	}
+	for _, check := range checks {
+		t.Run(check.name, func(t *testing.T) {
+			if err := check.fn(ctx, b, prefix+check.name+"/"); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func cleanup(ctx context.Context, b storage.Backend, prefix string) {
+	objects, err := b.List(ctx, prefix)
+	if err != nil {
+		return
+	}
+	for _, object := range objects {
+		b.Delete(ctx, object.Key)
+	}
+}
+
+func put(ctx context.Context, b storage.Backend, key, body string) error {
+	if err := b.Put(ctx, key, strings.NewReader(body), contentType); err != nil {
+		return fmt.Errorf("put %s: %w", key, err)
+	}
+	return nil
+}
+
+// checkPresign expects an absolute URL naming the key. URLs over HTTP are
+// also uploaded to, the way a client would.
+func checkPresign(ctx context.Context, b storage.Backend, prefix string) error {
+	key := prefix + "presigned.mp3"
+	raw, err := b.PresignPut(ctx, key, contentType, time.Minute)
+	if err != nil {
+		return err
+	}
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() {
+		return fmt.Errorf("presigned URL %q is not absolute", raw)
+	}
+	if !strings.HasSuffix(u.Path, "/"+key) {
+		return fmt.Errorf("presigned URL %q does not name %s", raw, key)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil
+	}
+
+	body := []byte("presigned upload")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, raw, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("upload to presigned URL: %s", res.Status)
+	}
+
+	info, err := b.Head(ctx, key)
+	if err != nil {
+		return fmt.Errorf("head after presigned upload: %w", err)
+	}
+	if info.Size != int64(len(body)) {
+		return fmt.Errorf("presigned upload stored %d bytes, want %d", info.Size, len(body))
+	}
+	return nil
+}
+
+func checkUpload(ctx context.Context, b storage.Backend, prefix string) error {
+	key := prefix + "a.mp3"
+	if err := put(ctx, b, key, "first"); err != nil {
+		return err
+	}
+	if err := put(ctx, b, key, "second version"); err != nil {
+		return fmt.Errorf("overwrite: %w", err)
+	}
+	info, err := b.Head(ctx, key)
+	if err != nil {
+		return err
+	}
+	if info.Size != int64(len("second version")) {
+		return fmt.Errorf("overwritten object has %d bytes, want %d", info.Size, len("second version"))
+	}
+	return nil
+}
+
+func checkHead(ctx context.Context, b storage.Backend, prefix string) error {
+	if _, err := b.Head(ctx, prefix+"missing.mp3"); !errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("head of a missing object returned %v, want storage.ErrNotFound", err)
+	}
+
+	key := prefix + "a.mp3"
+	if err := put(ctx, b, key, "hello"); err != nil {
+		return err
+	}
+	info, err := b.Head(ctx, key)
+	if err != nil {
+		return err
+	}
+	switch {
+	case info.Key != key:
+		return fmt.Errorf("head key is %q, want %q", info.Key, key)
+	case info.Size != 5:
+		return fmt.Errorf("head size is %d, want 5", info.Size)
+	case info.ContentType != contentType:
+		return fmt.Errorf("head content type is %q, want %q", info.ContentType, contentType)
+	case info.ETag == "":
+		return errors.New("head has no ETag")
+	case info.LastModified.IsZero():
+		return errors.New("head has no LastModified")
+	}
+	return nil
+}
+
+func checkCopy(ctx context.Context, b storage.Backend, prefix string) error {
+	src, dst := prefix+"src.mp3", prefix+"dst.mp3"
+	if err := b.Copy(ctx, prefix+"missing.mp3", dst); !errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("copy of a missing object returned %v, want storage.ErrNotFound", err)
+	}
+
+	if err := put(ctx, b, src, "copy me"); err != nil {
+		return err
+	}
+	if err := b.Copy(ctx, src, dst); err != nil {
+		return err
+	}
+	srcInfo, err := b.Head(ctx, src)
+	if err != nil {
+		return fmt.Errorf("source gone after copy: %w", err)
+	}
+	dstInfo, err := b.Head(ctx, dst)
+	if err != nil {
+		return fmt.Errorf("head of copy: %w", err)
+	}
+	if dstInfo.Size != srcInfo.Size || dstInfo.ETag != srcInfo.ETag {
+		return fmt.Errorf("copy has size %d and ETag %q, want %d and %q", dstInfo.Size, dstInfo.ETag, srcInfo.Size, srcInfo.ETag)
+	}
+	return nil
+}
+
+func checkDelete(ctx context.Context, b storage.Backend, prefix string) error {
+	key := prefix + "a.mp3"
+	if err := put(ctx, b, key, "bye"); err != nil {
+		return err
+	}
+	if err := b.Delete(ctx, key); err != nil {
+		return err
+	}
+	if _, err := b.Head(ctx, key); !errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("head after delete returned %v, want storage.ErrNotFound", err)
+	}
+	if err := b.Delete(ctx, key); err != nil {
+		return fmt.Errorf("deleting a missing object: %w", err)
+	}
+	return nil
+}
+
+func checkList(ctx context.Context, b storage.Backend, prefix string) error {
+	keys := []string{prefix + "story/b.mp3", prefix + "story/a.mp3", prefix + "story/nested/c.mp3", prefix + "other/d.mp3"}
+	for _, key := range keys {
+		if err := put(ctx, b, key, key); err != nil {
+			return err
+		}
+	}
+
+	objects, err := b.List(ctx, prefix+"story/")
+	if err != nil {
+		return err
+	}
+	got := make([]string, 0, len(objects))
+	for _, object := range objects {
+		got = append(got, object.Key)
+	}
+	want := []string{prefix + "story/a.mp3", prefix + "story/b.mp3", prefix + "story/nested/c.mp3"}
+	if !slices.Equal(got, want) {
+		return fmt.Errorf("list returned %v, want %v", got, want)
+	}
+
+	objects, err = b.List(ctx, prefix+"none/")
+	if err != nil {
+		return err
+	}
+	if len(objects) != 0 {
+		return fmt.Errorf("list of an empty prefix returned %d objects", len(objects))
+	}
+	return nil
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/api"
+	"rosetta/domain"
+	"rosetta/models"
+	"rosetta/repository"
+	"rosetta/validation"
+)
+
+// syncChanges is a page of the changes since a sync token. While HasMore,
+// Token continues the same sync; after the last page it is the token to
+// sync from next time.
+type syncChanges struct {
+	Stories []api.StoryResponse  `json:"stories"`
+	Deleted []primitive.ObjectID `json:"deleted"`
+	Token   string               `json:"token"`
+	HasMore bool                 `json:"has_more"`
+}
+
+type syncMutation struct {
+	Op            string             `json:"op"`
+	ClientRef     string             `json:"client_ref"`
+	StoryID       primitive.ObjectID `json:"story_id"`
+	BaseUpdatedAt time.Time          `json:"base_updated_at"`
+	Story         api.StoryRequest   `json:"story"`
+}
+
+type syncResult struct {
+	ClientRef string             `json:"client_ref,omitempty"`
+	StoryID   primitive.ObjectID `json:"story_id"`
+	Status    string             `json:"status"`
+	Story     *api.StoryResponse `json:"story,omitempty"`
+}
+
+const (
+	syncApplied   = "applied"
+	syncConflict  = "conflict"
+	syncNotFound  = "not_found"
+	syncInvalid   = "invalid"
+	syncForbidden = "forbidden"
+)
+
+// syncPageSize is how many stories a page of changes holds.
+const syncPageSize = 100
+
+// syncCursor is where a sync is at: the changes after Since, from the
+// stories after AfterID in ID order. Until is when the sync started, the
+// next sync's Since.
+type syncCursor struct {
+	Since   time.Time
+	Until   time.Time
+	AfterID primitive.ObjectID
+}
+
+// encodeSyncToken encodes a cursor. A fresh sync from t is just its time,
+// as in tokens issued before paging.
+func encodeSyncToken(cursor syncCursor) string {
+	raw := strconv.FormatInt(cursor.Since.UnixMilli(), 10)
+	if !cursor.AfterID.IsZero() {
+		raw += "." + strconv.FormatInt(cursor.Until.UnixMilli(), 10) + "." + cursor.AfterID.Hex()
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeSyncToken(token string) (syncCursor, error) {
+	var cursor syncCursor
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return cursor, err
+	}
+	parts := strings.Split(string(raw), ".")
+	if len(parts) != 1 && len(parts) != 3 {
+		return cursor, errors.New("malformed sync token")
+	}
+	ms, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return cursor, err
+	}
+	cursor.Since = time.UnixMilli(ms)
+	if len(parts) == 3 {
+		if ms, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+			return cursor, err
+		}
+		cursor.Until = time.UnixMilli(ms)
+		if cursor.AfterID, err = primitive.ObjectIDFromHex(parts[2]); err != nil {
+			return cursor, err
+		}
+	}
+	return cursor, nil
+}
+
+func recordDeletion(ctx context.Context, storyID, ownerID primitive.ObjectID) error {
+	collection := deletedStoriesCollection()
+	_, err := collection.InsertOne(ctx, models.DeletedStory{StoryID: storyID, OwnerID: ownerID, DeletedAt: time.Now()})
+	return err
+}
+
+// getSyncChanges pages through the caller's stories changed since the
+// token, in ID order, and lists the ones deleted since on the first page.
+// An empty token asks for a full sync.
+func getSyncChanges(w http.ResponseWriter, r *http.Request) {
+	userID, _ := currentUser(r.Context())
+	cursor := syncCursor{Until: time.Now()}
+	if token := r.URL.Query().Get("token"); token != "" {
+		since := cursor.Until
+		var err error
+		cursor, err = decodeSyncToken(token)
+		if err != nil {
+			httpError(w, "Invalid sync token", http.StatusBadRequest)
+			return
+		}
+		if cursor.AfterID.IsZero() {
+			cursor.Until = since
+		}
+	}
+
+	ctx := r.Context()
+	changes := syncChanges{Deleted: []primitive.ObjectID{}}
+
+	stories, total, err := storyRepo.List(ctx, repository.StoryQuery{
+		UpdatedSince: cursor.Since,
+		AfterID:      cursor.AfterID,
+		Owner:        userID,
+		Limit:        syncPageSize,
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	changes.Stories = api.StoriesFromModels(stories)
+
+	if !cursor.Since.IsZero() && cursor.AfterID.IsZero() {
+		var deleted []models.DeletedStory
+		found, err := deletedStoriesCollection().Find(ctx, bson.M{"owner_id": userID, "deleted_at": bson.M{"$gt": cursor.Since}})
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if err = found.All(ctx, &deleted); err != nil {
+			writeError(w, err)
+			return
+		}
+		for _, d := range deleted {
+			changes.Deleted = append(changes.Deleted, d.StoryID)
+		}
+	}
+
+	// Stories changed while paging come again from the next sync, which
+	// starts from when this one did
+	changes.HasMore = int64(len(stories)) < total
+	if changes.HasMore {
+		changes.Token = encodeSyncToken(syncCursor{Since: cursor.Since, Until: cursor.Until, AfterID: stories[len(stories)-1].ID})
+	} else {
+		changes.Token = encodeSyncToken(syncCursor{Since: cursor.Until})
+	}
+	writeResponse(w, r, http.StatusOK, changes)
+}
+
+// applySyncMutations replays mutations queued while the client was offline.
+// A mutation only applies if the story has not changed on the server since
+// base_updated_at; otherwise the server copy wins and is returned so the
+// client can rebase its local edits.
+func applySyncMutations(w http.ResponseWriter, r *http.Request) {
+	var mutations []syncMutation
+	err := decodeRequest(r, &mutations)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	results := make([]syncResult, 0, len(mutations))
+	for _, m := range mutations {
+		result, err := applySyncMutation(ctx, clientIP(r), m)
+		if errors.Is(err, domain.ErrInvalid) {
+			result.Status = syncInvalid
+			result.Story = nil
+			err = nil
+		}
+		if errors.Is(err, domain.ErrForbidden) {
+			result.Status = syncForbidden
+			result.Story = nil
+			err = nil
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		results = append(results, result)
+	}
+
+	writeResponse(w, r, http.StatusOK, results)
+}
+
+func applySyncMutation(ctx context.Context, clientKey string, m syncMutation) (syncResult, error) {
+	result := syncResult{ClientRef: m.ClientRef, StoryID: m.StoryID, Status: syncApplied}
+
+	if m.Op == "create" || m.Op == "update" {
+		if err := validation.Story(&m.Story); err != nil {
+			return result, err
+		}
+	}
+
+	// Missing stories are reported by the guarded writes below
+	var owner primitive.ObjectID
+	if m.Op == "update" || m.Op == "delete" {
+		current, err := findOwnStory(ctx, m.StoryID)
+		if err != nil && !errors.Is(err, domain.ErrNotFound) {
+			return result, err
+		}
+		owner = current.OwnerID
+	}
+
+	switch m.Op {
+	case "create":
+		story := m.Story.ToModel()
+		story.OwnerID, _ = currentUser(ctx)
+		if err := assessSpam(ctx, clientKey, &story); err != nil {
+			return result, err
+		}
+		if err := insertStory(ctx, &story); err != nil {
+			return result, err
+		}
+		response := api.StoryFromModel(&story)
+		result.StoryID = story.ID
+		result.Story = &response
+		return result, nil
+
+	case "update":
+		current, err := findStory(ctx, m.StoryID)
+		if errors.Is(err, domain.ErrNotFound) || (err == nil && current.UpdatedAt.After(m.BaseUpdatedAt)) {
+			return syncConflictResult(ctx, result)
+		}
+		if err != nil {
+			return result, err
+		}
+
+		story := m.Story.ToModel()
+		saved, err := saveStory(ctx, &current, &story)
+		if err != nil {
+			return result, err
+		}
+		if !saved {
+			return syncConflictResult(ctx, result)
+		}
+
+	case "delete":
+		// Stored times have millisecond precision
+		before := m.BaseUpdatedAt.Truncate(time.Millisecond).Add(time.Millisecond)
+		deleted, err := removeStory(ctx, m.StoryID, owner, before)
+		if err != nil {
+			return result, err
+		}
+		if !deleted {
+			return syncConflictResult(ctx, result)
+		}
+		return result, nil
+
+	default:
+		result.Status = syncInvalid
+		return result, nil
+	}
+
+	story, err := findStory(ctx, m.StoryID)
+	if err != nil {
+		return result, err
+	}
+	response := api.StoryFromModel(&story)
+	result.Story = &response
+	return result, nil
+}
+
+// syncConflictResult reports why a guarded write matched nothing: either the
+// story is gone or it was modified after the client's base version.
+func syncConflictResult(ctx context.Context, result syncResult) (syncResult, error) {
+	story, err := findStory(ctx, result.StoryID)
+	if errors.Is(err, domain.ErrNotFound) {
+		result.Status = syncNotFound
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+	response := api.StoryFromModel(&story)
+	result.Status = syncConflict
+	result.Story = &response
+	return result, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSyncTokenRoundTrip(t *testing.T) {
+	since := time.UnixMilli(1700000000000)
+	until := since.Add(time.Hour)
+	tests := []struct {
+		name   string
+		cursor syncCursor
+	}{
+		{"fresh", syncCursor{Since: since}},
+		{"continued", syncCursor{Since: since, Until: until, AfterID: primitive.NewObjectID()}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cursor, err := decodeSyncToken(encodeSyncToken(test.cursor))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cursor.Since.Equal(test.cursor.Since) || !cursor.Until.Equal(test.cursor.Until) || cursor.AfterID != test.cursor.AfterID {
+				t.Errorf("got %+v, want %+v", cursor, test.cursor)
+			}
+		})
+	}
+}
+
+func TestDecodeSyncTokenRejectsMalformed(t *testing.T) {
+	for _, raw := range []string{"", "abc", "1.2", "1.2.not-an-id"} {
+		if _, err := decodeSyncToken(base64.RawURLEncoding.EncodeToString([]byte(raw))); err == nil {
+			t.Errorf("%q: expected an error", raw)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package textimport
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+var imageExtensions = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".webp": "image/webp",
+}
+
+const (
+	maxImageSize = 10 << 20
+	// maxImagesSize caps the images of a document together, however well
+	// they compress
+	maxImagesSize = 50 << 20
+	// maxParagraphs caps the paragraphs read, empty ones included
+	maxParagraphs = 20000
+)
+
+var (
+	ErrNotDocx = errors.New("not a Word document")
+	// ErrTooManySegments is returned once a document has more than the
+	// segments asked for.
+	ErrTooManySegments = errors.New("too many segments")
+	// ErrTooLarge is returned for documents with too many paragraphs or
+	// too much image data.
+	ErrTooLarge = errors.New("document too large")
+)
+
+// ParseDocx reads a Word document, which is also what Google Docs exports,
+// into one segment per paragraph, up to maxSegments of them. A paragraph's
+// first inline image goes with its segment; paragraphs holding only an
+// image become segments without text. Images in formats browsers can't show
+// are left out, and an image used more than once is read once. The
+// document title comes from the Title style or else the first top-level
+// heading.
+func ParseDocx(data []byte, maxSegments int) (Document, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return Document{}, ErrNotDocx
+	}
+
+	files := map[string]*zip.File{}
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+	body, ok := files["word/document.xml"]
+	if !ok {
+		return Document{}, ErrNotDocx
+	}
+
+	targets, err := relationshipTargets(files["word/_rels/document.xml.rels"])
+	if err != nil {
+		return Document{}, err
+	}
+
+	reader, err := body.Open()
+	if err != nil {
+		return Document{}, err
+	}
+	defer reader.Close()
+
+	doc := Document{Images: map[int]Media{}}
+	images := imageCache{files: files, images: map[string]*Media{}}
+	decoder := xml.NewDecoder(reader)
+	var text strings.Builder
+	var style, imageRef string
+	paragraphs := 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Document{}, ErrNotDocx
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				if paragraphs++; paragraphs > maxParagraphs {
+					return Document{}, ErrTooLarge
+				}
+				text.Reset()
+				style, imageRef = "", ""
+			case "pStyle":
+				style = attr(t, "val")
+			case "t":
+				var s string
+				if err := decoder.DecodeElement(&s, &t); err != nil {
+					return Document{}, ErrNotDocx
+				}
+				text.WriteString(s)
+			case "tab", "br":
+				text.WriteString(" ")
+			case "blip":
+				if imageRef == "" {
+					imageRef = attr(t, "embed")
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Local != "p" {
+				continue
+			}
+			paragraph := strings.Join(strings.Fields(text.String()), " ")
+			if doc.Title == "" && paragraph != "" && (style == "Title" || (style == "Heading1" && len(doc.Segments) == 0)) {
+				doc.Title = paragraph
+				continue
+			}
+
+			image, hasImage, err := images.read(targets[imageRef])
+			if err != nil {
+				return Document{}, err
+			}
+			if paragraph == "" && !hasImage {
+				continue
+			}
+			if len(doc.Segments) == maxSegments {
+				return Document{}, ErrTooManySegments
+			}
+			if hasImage {
+				doc.Images[len(doc.Segments)] = image
+			}
+			doc.Segments = append(doc.Segments, paragraph)
+		}
+	}
+	return doc, nil
+}
+
+// attr returns the attribute by local name, whatever its namespace prefix.
+func attr(element xml.StartElement, name string) string {
+	for _, a := range element.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func relationshipTargets(rels *zip.File) (map[string]string, error) {
+	targets := map[string]string{}
+	if rels == nil {
+		return targets, nil
+	}
+	reader, err := rels.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var parsed struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := xml.NewDecoder(reader).Decode(&parsed); err != nil {
+		return nil, ErrNotDocx
+	}
+	for _, rel := range parsed.Relationships {
+		// Targets are relative to the word/ directory
+		targets[rel.ID] = path.Join("word", rel.Target)
+	}
+	return targets, nil
+}
+
+// imageCache reads the images of a document once each, keeping count of
+// how much image data it has read.
+type imageCache struct {
+	files  map[string]*zip.File
+	images map[string]*Media
+	size   int64
+}
+
+// read returns the image stored under name, if it is one browsers can
+// show. Images are shared, like the archive entries they come from.
+func (c *imageCache) read(name string) (Media, bool, error) {
+	if image, ok := c.images[name]; ok {
+		if image == nil {
+			return Media{}, false, nil
+		}
+		return *image, true, nil
+	}
+
+	image, ok := readImage(c.files, name)
+	if !ok {
+		c.images[name] = nil
+		return Media{}, false, nil
+	}
+	if c.size += int64(len(image.Data)); c.size > maxImagesSize {
+		return Media{}, false, ErrTooLarge
+	}
+	c.images[name] = &image
+	return image, true, nil
+}
+
+func readImage(files map[string]*zip.File, name string) (Media, bool) {
+	contentType, ok := imageExtensions[strings.ToLower(path.Ext(name))]
+	f := files[name]
+	if !ok || f == nil || f.UncompressedSize64 > maxImageSize {
+		return Media{}, false
+	}
+	reader, err := f.Open()
+	if err != nil {
+		return Media{}, false
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(io.LimitReader(reader, maxImageSize))
+	if err != nil {
+		return Media{}, false
+	}
+	return Media{Data: data, ContentType: contentType}, true
+}
@@ -0,0 +1,71 @@
+package textimport
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// docx builds a document with a paragraph per entry of paragraphs, where
+// "[img]" stands for the one image, shared by every paragraph showing it.
+func docx(t *testing.T, paragraphs ...string) []byte {
+	t.Helper()
+	var body strings.Builder
+	body.WriteString(`<w:document xmlns:w="w" xmlns:a="a" xmlns:r="r"><w:body>`)
+	for _, p := range paragraphs {
+		body.WriteString("<w:p>")
+		if text, ok := strings.CutPrefix(p, "[img]"); ok {
+			body.WriteString(`<a:blip r:embed="rId1"/>`)
+			p = text
+		}
+		body.WriteString("<w:r><w:t>" + p + "</w:t></w:r></w:p>")
+	}
+	body.WriteString("</w:body></w:document>")
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	files := map[string]string{
+		"word/document.xml":            body.String(),
+		"word/_rels/document.xml.rels": `<Relationships><Relationship Id="rId1" Target="media/image1.png"/></Relationships>`,
+		"word/media/image1.png":        "png data",
+	}
+	for name, content := range files {
+		f, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseDocxSharesRepeatedImages(t *testing.T) {
+	doc, err := ParseDocx(docx(t, "[img]One", "Two", "[img]"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Segments) != 3 || doc.Segments[0] != "One" || doc.Segments[2] != "" {
+		t.Fatalf("segments = %q", doc.Segments)
+	}
+	first, second := doc.Images[0], doc.Images[2]
+	if len(doc.Images) != 2 || string(first.Data) != "png data" || &first.Data[0] != &second.Data[0] {
+		t.Errorf("images = %v, want the image read once for segments 0 and 2", doc.Images)
+	}
+}
+
+func TestParseDocxStopsAtMaxSegments(t *testing.T) {
+	if _, err := ParseDocx(docx(t, "One", "", "Two"), 2); err != nil {
+		t.Fatalf("empty paragraphs count against the limit: %v", err)
+	}
+	_, err := ParseDocx(docx(t, "One", "Two", "Three"), 2)
+	if !errors.Is(err, ErrTooManySegments) {
+		t.Errorf("err = %v, want ErrTooManySegments", err)
+	}
+}
@@ -0,0 +1,93 @@
+package textimport
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Scripts used by a single language among those we support.
+var scriptLanguages = []struct {
+	table *unicode.RangeTable
+	lang  string
+}{
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Hangul, "ko"},
+	{unicode.Han, "zh"},
+	{unicode.Thai, "th"},
+	{unicode.Greek, "el"},
+	{unicode.Hebrew, "he"},
+	{unicode.Arabic, "ar"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Cyrillic, "ru"},
+	{unicode.Armenian, "hy"},
+	{unicode.Georgian, "ka"},
+}
+
+// Frequent short words that tell Latin-script languages apart.
+var stopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "is", "in", "that", "it", "was", "he", "she", "you"},
+	"es": {"el", "la", "de", "que", "y", "en", "los", "se", "las", "por", "una", "es"},
+	"fr": {"le", "la", "les", "de", "et", "est", "des", "une", "que", "il", "elle", "dans"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ein", "eine", "zu", "ich", "sie", "mit"},
+	"it": {"il", "di", "che", "e", "la", "non", "un", "una", "per", "sono", "gli", "lo"},
+	"pt": {"o", "de", "que", "e", "do", "da", "em", "um", "uma", "não", "os", "é"},
+	"nl": {"de", "het", "een", "en", "van", "is", "niet", "dat", "ik", "je", "zijn", "met"},
+}
+
+// DetectLanguage guesses the BCP 47 tag of text, or returns "" when it
+// can't tell. Non-Latin scripts are recognized by their letters, with kana
+// taking precedence over Han so Japanese isn't read as Chinese; Latin-script
+// languages are told apart by their most common words.
+func DetectLanguage(text string) string {
+	counts := map[string]int{}
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for _, s := range scriptLanguages {
+			if unicode.Is(s.table, r) {
+				counts[s.lang]++
+				break
+			}
+		}
+	}
+	if letters == 0 {
+		return ""
+	}
+	if counts["ja"] > 0 && counts["ja"]*10 >= counts["zh"] {
+		return "ja"
+	}
+	best, bestCount := "", 0
+	for lang, n := range counts {
+		if n > bestCount {
+			best, bestCount = lang, n
+		}
+	}
+	if bestCount*2 >= letters {
+		return best
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	scores := map[string]int{}
+	for _, word := range words {
+		for lang, list := range stopwords {
+			for _, stopword := range list {
+				if word == stopword {
+					scores[lang]++
+				}
+			}
+		}
+	}
+	best, bestCount = "", 0
+	for lang, n := range scores {
+		if n > bestCount || (n == bestCount && lang < best) {
+			best, bestCount = lang, n
+		}
+	}
+	return best
+}
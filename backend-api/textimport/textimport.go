@@ -0,0 +1,99 @@
+// Package textimport turns plain text, Markdown or Word documents into story
+// segments.
+package textimport
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	FormatPlain    = "plain"
+	FormatMarkdown = "markdown"
+)
+
+// Document is imported text broken into segment scripts. Title is the first
+// top-level heading of a Markdown document, if any. Images and audio are
+// keyed by the index of the segment they belong to.
+type Document struct {
+	Title    string
+	Segments []string
+	Images   map[int]Media
+	Audio    map[int]Media
+}
+
+// Media is an image or recording that came with the imported text.
+type Media struct {
+	Data        []byte
+	ContentType string
+}
+
+var (
+	headingPattern = regexp.MustCompile(`^#{1,6}\s+(.*?)\s*#*\s*$`)
+	listPattern    = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s+`)
+	rulePattern    = regexp.MustCompile(`^\s*(?:[-*_]\s*){3,}$`)
+	imagePattern   = regexp.MustCompile(`!\[[^\]]*\]\([^)]*\)`)
+	linkPattern    = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	emphasis       = strings.NewReplacer("**", "", "__", "", "*", "", "`", "")
+)
+
+// Parse splits text into segments: one per paragraph, and in Markdown one
+// per heading too, so each heading opens its own segment.
+func Parse(text, format string) Document {
+	var doc Document
+	var paragraph []string
+	flush := func() {
+		if len(paragraph) > 0 {
+			doc.Segments = append(doc.Segments, strings.Join(paragraph, " "))
+			paragraph = nil
+		}
+	}
+
+	inFence := false
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if format == FormatMarkdown {
+			if strings.HasPrefix(trimmed, "```") {
+				inFence = !inFence
+				flush()
+				continue
+			}
+			if inFence || rulePattern.MatchString(trimmed) {
+				flush()
+				continue
+			}
+			if m := headingPattern.FindStringSubmatch(trimmed); m != nil {
+				flush()
+				heading := stripMarkdown(m[1])
+				if doc.Title == "" && strings.HasPrefix(trimmed, "# ") {
+					doc.Title = heading
+				} else if heading != "" {
+					doc.Segments = append(doc.Segments, heading)
+				}
+				continue
+			}
+			if listPattern.MatchString(line) {
+				// List items stand on their own
+				flush()
+				paragraph = append(paragraph, stripMarkdown(listPattern.ReplaceAllString(line, "")))
+				flush()
+				continue
+			}
+			trimmed = stripMarkdown(strings.TrimLeft(trimmed, "> "))
+		}
+
+		if trimmed == "" {
+			flush()
+			continue
+		}
+		paragraph = append(paragraph, trimmed)
+	}
+	flush()
+	return doc
+}
+
+func stripMarkdown(s string) string {
+	s = imagePattern.ReplaceAllString(s, "")
+	s = linkPattern.ReplaceAllString(s, "$1")
+	return strings.TrimSpace(emphasis.Replace(s))
+}
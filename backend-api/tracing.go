@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"rosetta/config"
+)
+
+// tracer is a no-op until setupTracing installs an exporting provider.
+var tracer = otel.Tracer("rosetta")
+
+// setupTracing exports spans over OTLP/HTTP when an endpoint is configured.
+// Requests carrying a W3C traceparent continue the caller's trace and keep
+// its sampling decision. The returned function flushes what is buffered.
+func setupTracing(ctx context.Context, cfg *config.Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if cfg.OTLPEndpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(cfg.OTLPEndpoint))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(cfg.ServiceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.TraceSampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	tracer = provider.Tracer("rosetta")
+	return provider.Shutdown, nil
+}
+
+// traceRequests starts a server span per request, continuing the trace of
+// the caller if it sent a traceparent header. Spans are named after the
+// route template once the router has matched one.
+func traceRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+				semconv.ClientAddress(clientIP(r)),
+				semconv.UserAgentOriginal(r.UserAgent()),
+			))
+		defer span.End()
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		if route, ok := r.Context().Value(routeLabelKey{}).(*string); ok && *route != unmatchedRoute {
+			span.SetName(r.Method + " " + *route)
+			span.SetAttributes(semconv.HTTPRoute(*route))
+		}
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
+
+// addMongoTracing adds a client span per MongoDB command sent on behalf of
+// a traced request. Commands of background work are left out rather than
+// each starting a trace of its own.
+func addMongoTracing(monitor *event.CommandMonitor) {
+	var spans sync.Map // request ID -> trace.Span
+
+	started, succeeded, failed := monitor.Started, monitor.Succeeded, monitor.Failed
+	monitor.Started = func(ctx context.Context, e *event.CommandStartedEvent) {
+		if started != nil {
+			started(ctx, e)
+		}
+		if !trace.SpanFromContext(ctx).SpanContext().IsValid() {
+			return
+		}
+		collection, _ := e.Command.Lookup(e.CommandName).StringValueOK()
+		_, span := tracer.Start(ctx, e.CommandName+" "+collection,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemMongoDB,
+				semconv.DBNamespace(e.DatabaseName),
+				semconv.DBCollectionName(collection),
+				semconv.DBOperationName(e.CommandName),
+			))
+		spans.Store(e.RequestID, span)
+	}
+	monitor.Succeeded = func(ctx context.Context, e *event.CommandSucceededEvent) {
+		if succeeded != nil {
+			succeeded(ctx, e)
+		}
+		if span, ok := spans.LoadAndDelete(e.RequestID); ok {
+			span.(trace.Span).End()
+		}
+	}
+	monitor.Failed = func(ctx context.Context, e *event.CommandFailedEvent) {
+		if failed != nil {
+			failed(ctx, e)
+		}
+		if span, ok := spans.LoadAndDelete(e.RequestID); ok {
+			span.(trace.Span).SetStatus(codes.Error, e.Failure)
+			span.(trace.Span).End()
+		}
+	}
+}
+
+type s3SpanKey struct{}
+
+// addS3Tracing adds a client span per S3 call made on behalf of a traced
+// request, covering its retries. Presigning makes no call and gets none.
+func addS3Tracing(handlers *request.Handlers) {
+	handlers.Validate.PushFront(func(r *request.Request) {
+		ctx := r.Context()
+		if r.IsPresigned() || !trace.SpanFromContext(ctx).SpanContext().IsValid() {
+			return
+		}
+		ctx, span := tracer.Start(ctx, "S3."+r.Operation.Name,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.RPCSystemKey.String("aws-api"),
+				semconv.RPCService("S3"),
+				semconv.RPCMethod(r.Operation.Name),
+			))
+		r.SetContext(context.WithValue(ctx, s3SpanKey{}, span))
+	})
+	handlers.Complete.PushBack(func(r *request.Request) {
+		span, ok := r.Context().Value(s3SpanKey{}).(trace.Span)
+		if !ok {
+			return
+		}
+		span.SetAttributes(attribute.Int("aws.retry_count", r.RetryCount))
+		if r.Error != nil {
+			span.RecordError(r.Error)
+			span.SetStatus(codes.Error, r.Error.Error())
+		}
+		span.End()
+	})
+}
@@ -0,0 +1,153 @@
+// Package transcode converts uploaded audio into the one format every
+// player handles. Transcoder is the extension point; FFmpeg is the
+// implementation that runs the ffmpeg command. Transcoders that can also
+// decode to WAV implement Decoder, for the analyses that need samples, and
+// those that can cut audio implement Trimmer.
+package transcode
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUnreadable is returned for input that isn't audio the transcoder can
+// read; trying again won't help.
+var ErrUnreadable = errors.New("transcode: unreadable audio")
+
+type Transcoder interface {
+	// Transcode reads audio in any format from src and writes it to dst in
+	// the canonical format, returning its content type.
+	Transcode(ctx context.Context, src io.Reader, dst io.Writer) (string, error)
+}
+
+type Decoder interface {
+	// Decode reads audio in any format from src and writes it to dst as
+	// 16-bit PCM WAV.
+	Decode(ctx context.Context, src io.Reader, dst io.Writer) error
+}
+
+type Trimmer interface {
+	// Trim reads audio in any format from src and writes the part from
+	// start to end to dst in the canonical format, returning its content
+	// type.
+	Trim(ctx context.Context, src io.Reader, dst io.Writer, start, end time.Duration) (string, error)
+}
+
+// FFmpeg encodes AAC in an MP4 container at a fixed bitrate and sample
+// rate, with the index up front so players can start before the download
+// ends. Metadata and cover art are dropped.
+type FFmpeg struct {
+	// Path of the ffmpeg binary, looked up in PATH if empty
+	Path string
+	// Bitrate as ffmpeg takes it, e.g. 128k
+	Bitrate string
+}
+
+const (
+	sampleRate = "44100"
+	// stderrTail is how much of ffmpeg's complaints goes into errors
+	stderrTail = 500
+)
+
+func (f FFmpeg) Transcode(ctx context.Context, src io.Reader, dst io.Writer) (string, error) {
+	if err := f.run(ctx, src, dst, "output.m4a", f.encodeArgs()...); err != nil {
+		return "", err
+	}
+	return "audio/mp4", nil
+}
+
+func (f FFmpeg) Trim(ctx context.Context, src io.Reader, dst io.Writer, start, end time.Duration) (string, error) {
+	args := append([]string{"-ss", seconds(start), "-to", seconds(end)}, f.encodeArgs()...)
+	if err := f.run(ctx, src, dst, "output.m4a", args...); err != nil {
+		return "", err
+	}
+	return "audio/mp4", nil
+}
+
+func (f FFmpeg) encodeArgs() []string {
+	return []string{
+		"-vn", "-map_metadata", "-1",
+		"-c:a", "aac", "-b:a", f.Bitrate, "-ar", sampleRate,
+		"-movflags", "+faststart",
+	}
+}
+
+// Decode keeps the sample rate and channels of src.
+func (f FFmpeg) Decode(ctx context.Context, src io.Reader, dst io.Writer) error {
+	return f.run(ctx, src, dst, "output.wav", "-vn", "-map_metadata", "-1", "-c:a", "pcm_s16le")
+}
+
+// run has ffmpeg convert src into dst with the output options in args,
+// output named for ffmpeg to tell the container from.
+func (f FFmpeg) run(ctx context.Context, src io.Reader, dst io.Writer, output string, args ...string) error {
+	// MP4 input may keep its index at the end, so ffmpeg reads from a file
+	// rather than a pipe, and writes to one to go back and fill in headers
+	dir, err := os.MkdirTemp("", "transcode")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	input, output := filepath.Join(dir, "input"), filepath.Join(dir, output)
+
+	if err = writeFile(input, src); err != nil {
+		return err
+	}
+
+	path := f.Path
+	if path == "" {
+		path = "ffmpeg"
+	}
+	args = append([]string{"-nostdin", "-hide_banner", "-loglevel", "error", "-i", input}, args...)
+	cmd := exec.CommandContext(ctx, path, append(args, output)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && ctx.Err() == nil {
+		return fmt.Errorf("%w: %s", ErrUnreadable, tail(stderr.String()))
+	}
+	if err != nil {
+		return fmt.Errorf("transcode: %w", err)
+	}
+
+	out, err := os.Open(output)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = io.Copy(dst, out)
+	return err
+}
+
+func writeFile(path string, src io.Reader) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(file, src); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+func tail(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) > stderrTail {
+		s = "…" + s[len(s)-stderrTail:]
+	}
+	return s
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/audioprobe"
+	"rosetta/domain"
+	"rosetta/models"
+	"rosetta/transcode"
+)
+
+const (
+	queueKindTranscodeAudio = "transcode_audio"
+	maxTranscodeAttempts    = 5
+)
+
+// audioTranscoder converts segment audio to the format every player
+// handles once it is uploaded; nil while TRANSCODER is off.
+var audioTranscoder transcode.Transcoder
+
+// errAudioReplaced stops the result of processing audio from landing on a
+// segment whose audio changed in the meantime.
+var errAudioReplaced = errors.New("audio was replaced")
+
+// segmentAudioPayload is the payload of the jobs processing the audio
+// stored under Key for a segment.
+type segmentAudioPayload struct {
+	StoryID   primitive.ObjectID `bson:"story_id"`
+	SegmentID primitive.ObjectID `bson:"segment_id"`
+	Key       string             `bson:"key"`
+}
+
+// scheduleAudioProcessing queues the jobs to run on audio stored under key,
+// which a segment has just been given.
+func scheduleAudioProcessing(ctx context.Context, storyID, segmentID primitive.ObjectID, key string) {
+	scheduleAudioAnalysis(ctx, storyID, segmentID, key)
+	scheduleTranscode(ctx, storyID, segmentID, key)
+}
+
+// scheduleTranscode queues the transcoding of the audio stored under key,
+// which a segment has just been given. Failing to queue is logged: the
+// audio plays as uploaded in the meantime.
+func scheduleTranscode(ctx context.Context, storyID, segmentID primitive.ObjectID, key string) {
+	if audioTranscoder == nil {
+		return
+	}
+	err := enqueueJob(ctx, queueKindTranscodeAudio, segmentAudioPayload{StoryID: storyID, SegmentID: segmentID, Key: key}, 0)
+	if err != nil {
+		log.Printf("failed to schedule transcoding of %s: %v", key, err)
+	}
+}
+
+// runTranscodeJob stores a transcoded copy of a segment's audio next to the
+// upload and has the segment play it, keeping the upload as its original.
+// Audio replaced or removed in the meantime is left alone, and so is audio
+// the transcoder can't read: it plays as uploaded.
+func runTranscodeJob(ctx context.Context, payload bson.M) error {
+	var job segmentAudioPayload
+	if err := decodePayload(payload, &job); err != nil {
+		return err
+	}
+	if audioTranscoder == nil {
+		return errors.New("transcoding is off on this instance")
+	}
+
+	story, err := findStory(ctx, job.StoryID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	segment := findSegment(&story, job.SegmentID)
+	if segment == nil || segment.Audio == nil || segment.Audio.Key != job.Key || segment.Audio.OriginalKey != "" {
+		return nil
+	}
+	bucket, _, ok := mediaLocation(segment.Audio.Url)
+	if !ok {
+		return nil
+	}
+
+	object, err := s3Client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(job.Key),
+	})
+	if isNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer object.Body.Close()
+
+	transcoded, err := os.CreateTemp("", "transcoded")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(transcoded.Name())
+	defer transcoded.Close()
+
+	contentType, err := audioTranscoder.Transcode(ctx, object.Body, transcoded)
+	if errors.Is(err, transcode.ErrUnreadable) {
+		log.Printf("leaving %s as uploaded: %v", job.Key, err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	size, err := transcoded.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	audio := models.Audio{
+		Key:         newAudioKey(job.StoryID, job.SegmentID),
+		Size:        size,
+		ContentType: contentType,
+		OriginalUrl: segment.Audio.Url,
+		OriginalKey: segment.Audio.Key,
+	}
+	audio.Url = bucketMediaURL(writeBucket(), audio.Key)
+	// Best effort: audio plays fine without a known duration
+	duration, err := audioprobe.Duration(transcoded, size)
+	if err != nil {
+		log.Printf("failed to probe duration of %s: %v", audio.Key, err)
+	}
+	audio.DurationMs = duration.Milliseconds()
+
+	if _, err = transcoded.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(writeBucket()),
+		Key:         aws.String(audio.Key),
+		Body:        transcoded,
+		ContentType: aws.String(contentType),
+	}
+	encryptPut(input)
+	if _, err = s3Client.PutObjectWithContext(ctx, input); err != nil {
+		return err
+	}
+
+	// Set field by field, so an upload started meanwhile stays pending
+	err = updateSegmentIf(ctx, job.StoryID, job.SegmentID, func(segment *models.Segment) error {
+		if segment.Audio == nil || segment.Audio.Key != job.Key {
+			return errAudioReplaced
+		}
+		return nil
+	}, bson.M{
+		"audio.url":          audio.Url,
+		"audio.key":          audio.Key,
+		"audio.size":         audio.Size,
+		"audio.content_type": audio.ContentType,
+		"audio.duration_ms":  audio.DurationMs,
+		"audio.original_url": audio.OriginalUrl,
+		"audio.original_key": audio.OriginalKey,
+	})
+	if errors.Is(err, errAudioReplaced) || errors.Is(err, domain.ErrNotFound) {
+		if err = deleteMedia(ctx, audio.Key); err != nil {
+			log.Printf("failed to delete unused transcoded audio %s: %v", audio.Key, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return refreshContentHash(ctx, job.StoryID)
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/text/language"
+
+	"rosetta/api"
+	"rosetta/domain"
+	"rosetta/models"
+	"rosetta/scripttext"
+	"rosetta/validation"
+)
+
+// scriptLanguage is the language a script was written in.
+func scriptLanguage(story *models.Story, script *models.Script) string {
+	if script.Language != "" {
+		return script.Language
+	}
+	return story.Language
+}
+
+// preserveTranslations keeps what the scripts of current had for clients
+// that send scripts without translations or original language, the way
+// metadata is kept for clients that don't know about it.
+func preserveTranslations(story *models.Story, current *models.Story) {
+	for i := range story.Segments {
+		script := story.Segments[i].Script
+		old := findSegment(current, story.Segments[i].ID)
+		if script == nil || old == nil || old.Script == nil {
+			continue
+		}
+		if script.Translations == nil {
+			script.Translations = old.Script.Translations
+		}
+		if script.Language == "" {
+			script.Language = old.Script.Language
+		}
+	}
+}
+
+// segmentTranslation parses the path of a segment's translation.
+func segmentTranslation(r *http.Request) (storyID, segmentID primitive.ObjectID, lang string, err error) {
+	vars := mux.Vars(r)
+	if storyID, err = primitive.ObjectIDFromHex(vars["storyId"]); err != nil {
+		return storyID, segmentID, "", domain.New(domain.ErrInvalid, "Invalid story ID")
+	}
+	if segmentID, err = primitive.ObjectIDFromHex(vars["segmentId"]); err != nil {
+		return storyID, segmentID, "", domain.New(domain.ErrInvalid, "Invalid segment ID")
+	}
+	return storyID, segmentID, vars["lang"], nil
+}
+
+// putTranslation adds or replaces the translation of one segment's script.
+func putTranslation(w http.ResponseWriter, r *http.Request) {
+	storyID, segmentID, lang, err := segmentTranslation(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var request api.TranslationRequest
+	err = decodeRequest(r, &request)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	request.Text = scripttext.Normalize(request.Text)
+	switch {
+	case strings.TrimSpace(request.Text) == "":
+		httpError(w, "Missing text", http.StatusBadRequest)
+		return
+	case utf8.RuneCountInString(request.Text) > validation.MaxScriptLength:
+		httpError(w, "Text is too long", http.StatusBadRequest)
+		return
+	}
+
+	story, err := modifyStory(r.Context(), storyID, func(story *models.Story) error {
+		if err := authorizeStory(r.Context(), story); err != nil {
+			return err
+		}
+		segment := findSegment(story, segmentID)
+		if segment == nil {
+			return domain.New(domain.ErrNotFound, "Segment not found")
+		}
+		if segment.Script == nil || strings.TrimSpace(segment.Script.Text) == "" {
+			return domain.New(domain.ErrInvalid, "Segment has no script to translate")
+		}
+		if err := validation.TranslationLanguage(lang, scriptLanguage(story, segment.Script)); err != nil {
+			return domain.New(domain.ErrInvalid, "Translation "+err.Error())
+		}
+
+		// The script is shared with the stored copy modifyStory compares to
+		script := *segment.Script
+		script.Translations = maps.Clone(script.Translations)
+		if script.Translations == nil {
+			script.Translations = map[string]string{}
+		}
+		if _, ok := script.Translations[lang]; !ok && len(script.Translations) >= validation.MaxTranslations {
+			return domain.New(domain.ErrInvalid, "Segment already has the most translations allowed")
+		}
+		script.Translations[lang] = request.Text
+		segment.Script = &script
+		return nil
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeResponse(w, r, http.StatusOK, api.StoryFromModel(&story))
+}
+
+// deleteTranslation removes the translation of one segment's script.
+func deleteTranslation(w http.ResponseWriter, r *http.Request) {
+	storyID, segmentID, lang, err := segmentTranslation(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	story, err := modifyStory(r.Context(), storyID, func(story *models.Story) error {
+		if err := authorizeStory(r.Context(), story); err != nil {
+			return err
+		}
+		segment := findSegment(story, segmentID)
+		if segment == nil {
+			return domain.New(domain.ErrNotFound, "Segment not found")
+		}
+		if segment.Script == nil {
+			return domain.New(domain.ErrNotFound, "Translation not found")
+		}
+		if _, ok := segment.Script.Translations[lang]; !ok {
+			return domain.New(domain.ErrNotFound, "Translation not found")
+		}
+
+		script := *segment.Script
+		script.Translations = maps.Clone(script.Translations)
+		delete(script.Translations, lang)
+		segment.Script = &script
+		return nil
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeResponse(w, r, http.StatusOK, api.StoryFromModel(&story))
+}
+
+// preferredTranslation picks the language a reader wants scripts in: the
+// translation query parameter, else their Accept-Language header. It
+// returns the closest language the story has translations in, or "" when
+// the reader is best served by the original scripts.
+func preferredTranslation(r *http.Request, story *models.Story) (string, error) {
+	var preferred []language.Tag
+	if tag := r.URL.Query().Get("translation"); tag != "" {
+		parsed, err := language.Parse(tag)
+		if err != nil {
+			return "", domain.New(domain.ErrInvalid, "Invalid translation, expected a language tag such as sv or pt-BR")
+		}
+		preferred = []language.Tag{parsed}
+	} else {
+		// A malformed header is no reason to fail the read
+		preferred, _, _ = language.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+	}
+	if len(preferred) == 0 {
+		return "", nil
+	}
+
+	available := map[string]bool{}
+	for _, segment := range story.Segments {
+		if segment.Script != nil {
+			for lang := range segment.Script.Translations {
+				available[lang] = true
+			}
+		}
+	}
+	if len(available) == 0 {
+		return "", nil
+	}
+
+	// The original comes first, so readers who prefer it get no translation
+	languages := append([]string{story.Language}, slices.Sorted(maps.Keys(available))...)
+	tags := make([]language.Tag, len(languages))
+	for i, lang := range languages {
+		tags[i] = language.Make(lang)
+	}
+	_, i, confidence := language.NewMatcher(tags).Match(preferred...)
+	if i == 0 || confidence == language.No {
+		return "", nil
+	}
+	return languages[i], nil
+}
+
+// translateStory fills in the translation of every segment into lang that
+// isn't already written in it.
+func translateStory(response *api.StoryResponse, story *models.Story, lang string) {
+	for i, segment := range story.Segments {
+		if segment.Script == nil || strings.EqualFold(scriptLanguage(story, segment.Script), lang) {
+			continue
+		}
+		if text, ok := segment.Script.Translations[lang]; ok {
+			response.Segments[i].Translation = &api.Translation{Language: lang, Text: text}
+		}
+	}
+}
+
+// writeTranslatedStory writes a story for a reader, with the scripts in
+// their preferred language where they have been translated into it. The
+// caller sets Vary: Accept-Language, which 304 responses need too.
+func writeTranslatedStory(w http.ResponseWriter, r *http.Request, story *models.Story) {
+	lang, err := preferredTranslation(r, story)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	response := api.StoryFromModel(story)
+	if lang != "" {
+		translateStory(&response, story, lang)
+		w.Header().Set("Content-Language", lang)
+	}
+	writeResponse(w, r, http.StatusOK, response)
+}
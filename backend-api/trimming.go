@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/api"
+	"rosetta/audioprobe"
+	"rosetta/audiosplit"
+	"rosetta/domain"
+	"rosetta/models"
+	"rosetta/transcode"
+)
+
+// minTrimmedAudio is the shortest audio a trim may leave.
+const minTrimmedAudio = 100 * time.Millisecond
+
+const (
+	queueKindTrimAudio = "trim_audio"
+	maxTrimAttempts    = 5
+)
+
+// trimPayload is the payload of trim_audio jobs: the part to keep of the
+// audio stored under Key, in milliseconds from its start.
+type trimPayload struct {
+	StoryID   primitive.ObjectID `bson:"story_id"`
+	SegmentID primitive.ObjectID `bson:"segment_id"`
+	Key       string             `bson:"key"`
+	StartMs   int64              `bson:"start_ms"`
+	EndMs     int64              `bson:"end_ms"`
+}
+
+// trimRequest is the part of a segment's audio to keep, in milliseconds from
+// its start. Without end_ms, the audio is kept to its end.
+type trimRequest struct {
+	StartMs int64  `json:"start_ms"`
+	EndMs   *int64 `json:"end_ms,omitempty"`
+}
+
+// trimSegmentAudio cuts dead air from the start and end of a segment's
+// audio. The trimmed audio is stored as a new rendition the segment plays,
+// and the upload is kept as its original, like a transcoded copy. Trimming
+// goes through the transcoder where it can cut audio; otherwise only WAV
+// can be trimmed. It runs as a queued job.
+func trimSegmentAudio(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	storyID, err := primitive.ObjectIDFromHex(vars["storyId"])
+	if err != nil {
+		httpError(w, "Invalid story ID", http.StatusBadRequest)
+		return
+	}
+	segmentID, err := primitive.ObjectIDFromHex(vars["segmentId"])
+	if err != nil {
+		httpError(w, "Invalid segment ID", http.StatusBadRequest)
+		return
+	}
+
+	var request trimRequest
+	err = decodeRequest(r, &request)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	story, err := findOwnStory(r.Context(), storyID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	segment := findSegment(&story, segmentID)
+	if segment == nil {
+		writeError(w, domain.New(domain.ErrNotFound, "Segment not found"))
+		return
+	}
+	if segment.Audio == nil || segment.Audio.Url == "" {
+		writeError(w, domain.New(domain.ErrConflict, "Segment has no audio"))
+		return
+	}
+	if segment.Audio.Key == "" {
+		writeError(w, domain.New(domain.ErrInvalid, "Only audio uploaded to the story can be trimmed"))
+		return
+	}
+	audio := *segment.Audio
+
+	start := time.Duration(request.StartMs) * time.Millisecond
+	end := time.Duration(audio.DurationMs) * time.Millisecond
+	if request.EndMs != nil {
+		end = time.Duration(*request.EndMs) * time.Millisecond
+	} else if audio.DurationMs == 0 {
+		writeError(w, domain.New(domain.ErrInvalid, "end_ms is required for audio of unknown duration"))
+		return
+	}
+	if start < 0 {
+		writeError(w, domain.New(domain.ErrInvalid, "start_ms must not be negative"))
+		return
+	}
+	if audio.DurationMs > 0 && end > time.Duration(audio.DurationMs)*time.Millisecond {
+		writeError(w, domain.New(domain.ErrInvalid, fmt.Sprintf("end_ms is past the end of the audio, at %d ms", audio.DurationMs)))
+		return
+	}
+	if end-start < minTrimmedAudio {
+		writeError(w, domain.New(domain.ErrInvalid, fmt.Sprintf("Trimmed audio must last at least %d ms", minTrimmedAudio.Milliseconds())))
+		return
+	}
+
+	queueJob(w, r, queueKindTrimAudio, trimPayload{
+		StoryID:   storyID,
+		SegmentID: segmentID,
+		Key:       audio.Key,
+		StartMs:   start.Milliseconds(),
+		EndMs:     end.Milliseconds(),
+	})
+}
+
+// runTrimJob trims the audio a trim_audio job was started for and returns
+// the story with the trimmed audio. It fails with a conflict if the
+// segment's audio changed since the job was queued.
+func runTrimJob(ctx context.Context, run *jobRun, payload bson.M) (interface{}, error) {
+	var job trimPayload
+	if err := decodePayload(payload, &job); err != nil {
+		return nil, err
+	}
+	story, err := findStory(ctx, job.StoryID)
+	if err != nil {
+		return nil, err
+	}
+	segment := findSegment(&story, job.SegmentID)
+	if segment == nil || segment.Audio == nil || segment.Audio.Key != job.Key {
+		return nil, domain.New(domain.ErrConflict, "Audio was replaced while it was being trimmed")
+	}
+
+	start := time.Duration(job.StartMs) * time.Millisecond
+	end := time.Duration(job.EndMs) * time.Millisecond
+	if err = trimAudio(ctx, job.StoryID, job.SegmentID, *segment.Audio, start, end); err != nil {
+		return nil, err
+	}
+	updatedStory, err := findStory(ctx, job.StoryID)
+	if err != nil {
+		return nil, err
+	}
+	return api.StoryFromModel(&updatedStory), nil
+}
+
+// trimAudio stores the part of audio from start to end under a fresh audio
+// key of the segment and has the segment play it. It fails with a conflict
+// if the segment's audio changed in the meantime.
+func trimAudio(ctx context.Context, storyID, segmentID primitive.ObjectID, audio models.Audio, start, end time.Duration) error {
+	bucket, head, err := headMedia(ctx, audio.Key)
+	if isNotFound(err) {
+		return domain.New(domain.ErrConflict, "Audio was replaced while it was being trimmed")
+	}
+	if err != nil {
+		return err
+	}
+
+	trimmedFile, err := os.CreateTemp("", "trimmed")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(trimmedFile.Name())
+	defer trimmedFile.Close()
+
+	recording := mediaReader{ctx: ctx, bucket: bucket, key: audio.Key}
+	contentType, err := cutAudio(ctx, recording, aws.Int64Value(head.ContentLength), trimmedFile, start, end)
+	if err != nil {
+		return err
+	}
+	size, err := trimmedFile.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	trimmed := models.Audio{
+		Key:         newAudioKey(storyID, segmentID),
+		Size:        size,
+		ContentType: contentType,
+		OriginalUrl: audio.OriginalUrl,
+		OriginalKey: audio.OriginalKey,
+	}
+	// The upload stays the original however often it is trimmed
+	if trimmed.OriginalKey == "" {
+		trimmed.OriginalUrl, trimmed.OriginalKey = audio.Url, audio.Key
+	}
+	trimmed.Url = bucketMediaURL(writeBucket(), trimmed.Key)
+	duration, err := audioprobe.Duration(trimmedFile, size)
+	if err != nil {
+		log.Printf("failed to probe duration of %s: %v", trimmed.Key, err)
+		duration = end - start
+	}
+	trimmed.DurationMs = duration.Milliseconds()
+
+	if _, err = trimmedFile.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(writeBucket()),
+		Key:         aws.String(trimmed.Key),
+		Body:        trimmedFile,
+		ContentType: aws.String(contentType),
+	}
+	encryptPut(input)
+	if _, err = s3Client.PutObjectWithContext(ctx, input); err != nil {
+		return err
+	}
+
+	// Set field by field, so an upload started meanwhile stays pending
+	err = updateSegmentIf(ctx, storyID, segmentID, func(segment *models.Segment) error {
+		if segment.Audio == nil || segment.Audio.Key != audio.Key {
+			return errAudioReplaced
+		}
+		return nil
+	}, bson.M{
+		"audio.url":          trimmed.Url,
+		"audio.key":          trimmed.Key,
+		"audio.size":         trimmed.Size,
+		"audio.content_type": trimmed.ContentType,
+		"audio.duration_ms":  trimmed.DurationMs,
+		"audio.original_url": trimmed.OriginalUrl,
+		"audio.original_key": trimmed.OriginalKey,
+	})
+	if err != nil {
+		if deleteErr := deleteMedia(ctx, trimmed.Key); deleteErr != nil {
+			log.Printf("failed to delete unused trimmed audio %s: %v", trimmed.Key, deleteErr)
+		}
+		if errors.Is(err, errAudioReplaced) {
+			return domain.New(domain.ErrConflict, "Audio was replaced while it was being trimmed")
+		}
+		return err
+	}
+
+	// Best effort: a leftover object is harmless and shows up in media reports
+	if audio.Key != trimmed.OriginalKey {
+		if err = deleteMedia(ctx, audio.Key); err != nil {
+			log.Printf("failed to delete replaced audio %s: %v", audio.Key, err)
+		}
+	}
+	return refreshContentHash(ctx, storyID)
+}
+
+// cutAudio writes the part of the size bytes of recording from start to end
+// to dst, returning its content type.
+func cutAudio(ctx context.Context, recording io.ReaderAt, size int64, dst io.Writer, start, end time.Duration) (string, error) {
+	if trimmer, ok := audioTranscoder.(transcode.Trimmer); ok {
+		contentType, err := trimmer.Trim(ctx, io.NewSectionReader(recording, 0, size), dst, start, end)
+		if errors.Is(err, transcode.ErrUnreadable) {
+			return "", domain.New(domain.ErrInvalid, "Audio could not be read")
+		}
+		return contentType, err
+	}
+
+	wav, err := audiosplit.OpenWAV(recording, size)
+	if errors.Is(err, audiosplit.ErrUnsupported) || errors.Is(err, audiosplit.ErrMalformed) {
+		return "", domain.New(domain.ErrInvalid, "Only 16-bit PCM WAV audio can be trimmed")
+	}
+	if err != nil {
+		return "", err
+	}
+	data, err := wav.Cut(start, end)
+	if err != nil {
+		return "", err
+	}
+	if _, err = dst.Write(data); err != nil {
+		return "", err
+	}
+	return "audio/wav", nil
+}
@@ -0,0 +1,159 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/polly"
+	"github.com/aws/aws-sdk-go/service/polly/pollyiface"
+	"golang.org/x/text/language"
+
+	"rosetta/sentences"
+)
+
+// Polly bills at most this many characters per request; longer texts are
+// synthesized a few sentences at a time.
+const maxPollyChars = 3000
+
+type Polly struct {
+	Client pollyiface.PollyAPI
+	// Engine is standard or neural, and limits the voices to pick from
+	Engine string
+	// Voice is used for every language when set, e.g. Joanna
+	Voice string
+
+	mu     sync.Mutex
+	voices []*polly.Voice
+}
+
+// Synthesize returns MP3 audio, which can be joined frame by frame, so a
+// text that takes several requests still comes back as one file.
+func (p *Polly) Synthesize(ctx context.Context, in Input) (Speech, error) {
+	voice := p.Voice
+	if voice == "" {
+		var err error
+		if voice, err = p.voiceFor(ctx, in.Language); err != nil {
+			return Speech{}, err
+		}
+	}
+
+	var audio bytes.Buffer
+	for _, chunk := range chunks(in.Text, in.Language) {
+		out, err := p.Client.SynthesizeSpeechWithContext(ctx, &polly.SynthesizeSpeechInput{
+			Engine:       aws.String(p.Engine),
+			OutputFormat: aws.String(polly.OutputFormatMp3),
+			Text:         aws.String(chunk),
+			TextType:     aws.String(polly.TextTypeText),
+			VoiceId:      aws.String(voice),
+		})
+		if err != nil {
+			return Speech{}, err
+		}
+		_, err = io.Copy(&audio, out.AudioStream)
+		out.AudioStream.Close()
+		if err != nil {
+			return Speech{}, err
+		}
+	}
+	return Speech{Audio: audio.Bytes(), ContentType: "audio/mpeg"}, nil
+}
+
+// voiceFor picks the first voice whose language is the closest to lang.
+func (p *Polly) voiceFor(ctx context.Context, lang string) (string, error) {
+	voices, err := p.listVoices(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(voices) == 0 {
+		return "", ErrUnsupportedLanguage
+	}
+
+	tags := make([]language.Tag, len(voices))
+	for i, voice := range voices {
+		tags[i] = language.Make(aws.StringValue(voice.LanguageCode))
+	}
+	_, i, confidence := language.NewMatcher(tags).Match(language.Make(lang))
+	if confidence == language.No {
+		return "", ErrUnsupportedLanguage
+	}
+	return aws.StringValue(voices[i].Id), nil
+}
+
+// listVoices describes the voices of the engine once; a failure is retried
+// on the next call.
+func (p *Polly) listVoices(ctx context.Context) ([]*polly.Voice, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.voices != nil {
+		return p.voices, nil
+	}
+
+	voices := []*polly.Voice{}
+	input := &polly.DescribeVoicesInput{Engine: aws.String(p.Engine)}
+	for {
+		out, err := p.Client.DescribeVoicesWithContext(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		voices = append(voices, out.Voices...)
+		if aws.StringValue(out.NextToken) == "" {
+			break
+		}
+		input.NextToken = out.NextToken
+	}
+	p.voices = voices
+	return voices, nil
+}
+
+// chunks packs whole sentences of text into pieces Polly accepts. Only a
+// sentence too long on its own is cut, at the last space that fits.
+func chunks(text, lang string) []string {
+	var result []string
+	var current strings.Builder
+	for _, sentence := range sentences.Split(text, lang) {
+		for utf8.RuneCountInString(sentence) > maxPollyChars {
+			cut := prefixLen(sentence, maxPollyChars)
+			if i := strings.LastIndex(sentence[:cut], " "); i > 0 {
+				cut = i
+			}
+			result = appendChunk(result, &current)
+			result = append(result, strings.TrimSpace(sentence[:cut]))
+			sentence = strings.TrimSpace(sentence[cut:])
+		}
+		if sentence == "" {
+			continue
+		}
+		if current.Len() > 0 && utf8.RuneCountInString(current.String())+1+utf8.RuneCountInString(sentence) > maxPollyChars {
+			result = appendChunk(result, &current)
+		}
+		if current.Len() > 0 {
+			current.WriteByte(' ')
+		}
+		current.WriteString(sentence)
+	}
+	return appendChunk(result, &current)
+}
+
+func appendChunk(result []string, current *strings.Builder) []string {
+	if current.Len() > 0 {
+		result = append(result, current.String())
+		current.Reset()
+	}
+	return result
+}
+
+// prefixLen is the length in bytes of the first n runes of s.
+func prefixLen(s string, n int) int {
+	for i := range s {
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+	return len(s)
+}
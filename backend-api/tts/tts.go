@@ -0,0 +1,26 @@
+// Package tts turns scripts into spoken audio. Synthesizer is the extension
+// point; Polly is the implementation backed by Amazon Polly.
+package tts
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrUnsupportedLanguage is returned when no voice speaks the language.
+var ErrUnsupportedLanguage = errors.New("tts: no voice for language")
+
+type Input struct {
+	Text string
+	// Language is the BCP 47 tag of the text, e.g. sv or pt-BR
+	Language string
+}
+
+type Speech struct {
+	Audio       []byte
+	ContentType string
+}
+
+type Synthesizer interface {
+	Synthesize(ctx context.Context, in Input) (Speech, error)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/domain"
+)
+
+// STS won't issue federation tokens for less than 15 minutes. An hour leaves
+// room for resumable uploads over slow connections.
+const uploadCredentialsDuration = time.Hour
+
+type uploadCredentials struct {
+	AccessKeyID     string            `json:"access_key_id"`
+	SecretAccessKey string            `json:"secret_access_key"`
+	SessionToken    string            `json:"session_token"`
+	Expiration      time.Time         `json:"expiration"`
+	Endpoint        string            `json:"endpoint"`
+	Bucket          string            `json:"bucket"`
+	Key             string            `json:"key"`
+	Headers         map[string]string `json:"upload_headers"`
+	PublicURL       string            `json:"public_url"`
+}
+
+// uploadPolicy only allows writing the one object, including the calls a
+// multipart upload needs to resume or abort.
+func uploadPolicy(key string) (string, error) {
+	policy := map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{{
+			"Effect": "Allow",
+			"Action": []string{
+				"s3:PutObject",
+				"s3:AbortMultipartUpload",
+				"s3:ListMultipartUploadParts",
+			},
+			"Resource": "arn:aws:s3:::" + writeBucket() + "/" + key,
+		}},
+	}
+	data, err := json.Marshal(policy)
+	return string(data), err
+}
+
+// generateAudioUploadCredentials is the alternative to a presigned URL for
+// clients that upload in parts: it vends temporary credentials that can only
+// write the segment's next audio object.
+func generateAudioUploadCredentials(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	storyID, err := primitive.ObjectIDFromHex(vars["storyId"])
+	if err != nil {
+		httpError(w, "Invalid story ID", http.StatusBadRequest)
+		return
+	}
+	segmentID, err := primitive.ObjectIDFromHex(vars["segmentId"])
+	if err != nil {
+		httpError(w, "Invalid segment ID", http.StatusBadRequest)
+		return
+	}
+
+	story, err := findOwnStory(r.Context(), storyID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if findSegment(&story, segmentID) == nil {
+		writeError(w, domain.New(domain.ErrNotFound, "Segment not found"))
+		return
+	}
+
+	objectName := newAudioKey(storyID, segmentID)
+	policy, err := uploadPolicy(objectName)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	out, err := stsClient.GetFederationTokenWithContext(r.Context(), &sts.GetFederationTokenInput{
+		// Federated user names are capped at 32 characters, this is 31
+		Name:            aws.String("upload-" + segmentID.Hex()),
+		Policy:          aws.String(policy),
+		DurationSeconds: aws.Int64(int64(uploadCredentialsDuration.Seconds())),
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	err = updateSegment(r.Context(), storyID, segmentID, bson.M{"audio.pending_key": objectName})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	creds := out.Credentials
+	writeResponse(w, r, http.StatusOK, uploadCredentials{
+		AccessKeyID:     aws.StringValue(creds.AccessKeyId),
+		SecretAccessKey: aws.StringValue(creds.SecretAccessKey),
+		SessionToken:    aws.StringValue(creds.SessionToken),
+		Expiration:      aws.TimeValue(creds.Expiration),
+		Endpoint:        s3PublicHost,
+		Bucket:          writeBucket(),
+		Key:             objectName,
+		Headers:         uploadHeaders(),
+		PublicURL:       mediaURL(objectName),
+	})
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/domain"
+)
+
+// s3Event is the subset of an S3 event notification we act on.
+type s3Event struct {
+	Records []struct {
+		EventName string `json:"eventName"`
+		S3        struct {
+			Object struct {
+				Key string `json:"key"`
+			} `json:"object"`
+		} `json:"s3"`
+	} `json:"Records"`
+}
+
+// snsEnvelope wraps the S3 event when the bucket publishes to an SNS topic
+// that fans out to the queue.
+type snsEnvelope struct {
+	Type    string `json:"Type"`
+	Message string `json:"Message"`
+}
+
+// consumeUploadEvents long-polls an SQS queue fed by the bucket's
+// ObjectCreated notifications and confirms audio uploads whose clients never
+// called the confirm endpoint. It runs until ctx is done.
+func consumeUploadEvents(ctx context.Context, queue *sqs.SQS, queueURL string) {
+	for ctx.Err() == nil {
+		out, err := queue.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:            aws.String(queueURL),
+			MaxNumberOfMessages: aws.Int64(10),
+			WaitTimeSeconds:     aws.Int64(20),
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("failed to receive upload events: %v", err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+
+		for _, message := range out.Messages {
+			if err := handleUploadEvent(ctx, aws.StringValue(message.Body)); err != nil {
+				// Left on the queue, it comes back after the visibility timeout
+				log.Printf("failed to handle upload event: %v", err)
+				continue
+			}
+			_, err = queue.DeleteMessageWithContext(ctx, &sqs.DeleteMessageInput{
+				QueueUrl:      aws.String(queueURL),
+				ReceiptHandle: message.ReceiptHandle,
+			})
+			if err != nil {
+				log.Printf("failed to delete upload event: %v", err)
+			}
+		}
+	}
+}
+
+// handleUploadEvent returns an error only for failures worth retrying.
+// Events for unknown keys, deleted stories or uploads that were already
+// confirmed or superseded are dropped.
+func handleUploadEvent(ctx context.Context, body string) error {
+	var envelope snsEnvelope
+	if err := json.Unmarshal([]byte(body), &envelope); err == nil && envelope.Type == "Notification" {
+		body = envelope.Message
+	}
+
+	var event s3Event
+	if err := json.Unmarshal([]byte(body), &event); err != nil {
+		log.Printf("dropping malformed upload event: %v", err)
+		return nil
+	}
+
+	for _, record := range event.Records {
+		if !strings.HasPrefix(record.EventName, "ObjectCreated:") {
+			continue
+		}
+		// Keys arrive form-encoded
+		key, err := url.QueryUnescape(record.S3.Object.Key)
+		if err != nil {
+			continue
+		}
+		if storyID, segmentID, ok := parseAudioKey(key); ok {
+			err = promotePendingAudio(ctx, storyID, segmentID, key)
+		} else if storyID, trackID, ok := parseTrackKey(key); ok {
+			err = promotePendingTrack(ctx, storyID, trackID, key)
+		} else {
+			continue
+		}
+		if errors.Is(err, domain.ErrConflict) || errors.Is(err, domain.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseAudioKey is the inverse of newAudioKey.
+func parseAudioKey(key string) (primitive.ObjectID, primitive.ObjectID, bool) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 4 || parts[2] != "audio" {
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+	storyID, err := primitive.ObjectIDFromHex(parts[0])
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+	segmentID, err := primitive.ObjectIDFromHex(parts[1])
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+	return storyID, segmentID, true
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"rosetta/domain"
+	"rosetta/models"
+	"rosetta/repository"
+	"rosetta/validation"
+)
+
+func validateStory(story *models.Story) error {
+	switch story.Visibility {
+	case "", models.VisibilityPublic, models.VisibilityUnlisted:
+	default:
+		return domain.New(domain.ErrInvalid, "Visibility must be public or unlisted")
+	}
+
+	if story.AgeRating != "" && !slices.Contains(models.AgeRatings, story.AgeRating) {
+		return domain.New(domain.ErrInvalid, fmt.Sprintf("Age rating must be one of %s", strings.Join(models.AgeRatings, ", ")))
+	}
+	for _, warning := range story.ContentWarnings {
+		if !slices.Contains(models.KnownContentWarnings, warning) {
+			return domain.New(domain.ErrInvalid, fmt.Sprintf("Unknown content warning %q", warning))
+		}
+	}
+	// Anything that can show up in public listings must be rated first
+	if story.IsPublished && story.Visibility != models.VisibilityUnlisted && story.AgeRating == "" {
+		return domain.New(domain.ErrInvalid, "Age rating is required to publish a public story")
+	}
+
+	if err := validateClips(story); err != nil {
+		return err
+	}
+	return validateCharacters(story)
+}
+
+// listedFilter narrows filter to stories that may appear in feeds and public
+// listings: published, not unlisted and not hidden by moderation.
+func listedFilter(filter bson.M) bson.M {
+	filter["is_published"] = true
+	filter["visibility"] = bson.M{"$in": bson.A{models.VisibilityPublic, "", nil}}
+	filter["moderation.status"] = bson.M{"$ne": models.ModerationHidden}
+	return filter
+}
+
+// contentFilter narrows filter using the max_age_rating and exclude_warnings
+// query parameters, e.g. for classroom use.
+func contentFilter(filter bson.M, query url.Values) error {
+	var q repository.StoryQuery
+	if err := contentQuery(&q, query); err != nil {
+		return err
+	}
+	if q.AgeRatings != nil {
+		filter["age_rating"] = bson.M{"$in": q.AgeRatings}
+	}
+	if q.ExcludeWarnings != nil {
+		filter["content_warnings"] = bson.M{"$nin": q.ExcludeWarnings}
+	}
+	return nil
+}
+
+// contentQuery is contentFilter for listings through storyRepo.
+func contentQuery(q *repository.StoryQuery, query url.Values) error {
+	if max := query.Get("max_age_rating"); max != "" {
+		i := slices.Index(models.AgeRatings, max)
+		if i < 0 {
+			return domain.New(domain.ErrInvalid, fmt.Sprintf("max_age_rating must be one of %s", strings.Join(models.AgeRatings, ", ")))
+		}
+		q.AgeRatings = models.AgeRatings[:i+1]
+	}
+
+	if exclude := query.Get("exclude_warnings"); exclude != "" {
+		q.ExcludeWarnings = strings.Split(exclude, ",")
+	}
+	return nil
+}
+
+// titleLocale returns the locale titles are sorted for: the locale query
+// parameter, else the language the listing is narrowed to by lang.
+func titleLocale(query url.Values) (string, error) {
+	if tag := query.Get("locale"); tag != "" {
+		locale, ok := repository.CollationLocale(tag)
+		if !ok {
+			return "", domain.New(domain.ErrInvalid, "Invalid locale, expected a language tag such as sv or zh-Hant")
+		}
+		return locale, nil
+	}
+	// Story languages aren't checked to be language tags
+	locale, _ := repository.CollationLocale(query.Get("lang"))
+	return locale, nil
+}
+
+// metadataQuery collects the metadata.<key>=<value> query parameters that
+// narrow a listing to stories whose metadata has all those entries.
+func metadataQuery(query url.Values) (map[string]string, error) {
+	var entries map[string]string
+	for param := range query {
+		key, ok := strings.CutPrefix(param, "metadata.")
+		if !ok {
+			continue
+		}
+		if err := validation.MetadataKey(key); err != nil {
+			return nil, domain.New(domain.ErrInvalid, fmt.Sprintf("%s: %v", param, err))
+		}
+		if entries == nil {
+			entries = map[string]string{}
+		}
+		entries[key] = query.Get(param)
+	}
+	return entries, nil
+}
@@ -0,0 +1,281 @@
+// Package validation checks the shape of story payloads before they reach
+// the domain rules: required fields, lengths, counts and URL schemes.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/api"
+	"rosetta/domain"
+)
+
+const (
+	MaxTitleLength    = 200
+	MaxLanguageLength = 35
+	MaxSegments       = 500
+	MaxScriptLength   = 5000
+	MaxSpeakerLength  = 100
+	MaxCharacters     = 50
+	MaxTranslations   = 20
+	MaxAudioTracks    = 20
+
+	MaxMetadataEntries     = 20
+	MaxMetadataKeyLength   = 64
+	MaxMetadataValueLength = 512
+)
+
+// ReservedMetadataPrefix starts metadata keys kept for the API's own use.
+const ReservedMetadataPrefix = "rosetta"
+
+// languageTag loosely matches a BCP 47 tag such as sv, pt-BR or zh-Hant.
+var languageTag = regexp.MustCompile(`^[A-Za-z]{2,8}(-[A-Za-z0-9]{1,8})*$`)
+
+// metadataKey keeps keys usable as MongoDB field names and query parameters.
+var metadataKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// AllowedURLSchemes lists the schemes accepted for media URLs.
+var AllowedURLSchemes = []string{"https", "http"}
+
+// FieldError describes one invalid field. Field is a path into the payload
+// such as segments[2].script.text.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors collects every invalid field of a payload. It counts as a
+// domain.ErrInvalid so callers that only care about validity can use
+// errors.Is.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, fe := range e {
+		messages = append(messages, fe.Field+": "+fe.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e Errors) Is(target error) bool {
+	return target == domain.ErrInvalid
+}
+
+func (e *Errors) add(field, format string, args ...interface{}) {
+	*e = append(*e, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Story returns the problems with a story payload, or nil if there are none.
+func Story(story *api.StoryRequest) error {
+	var errs Errors
+
+	title := strings.TrimSpace(story.Title)
+	switch {
+	case title == "":
+		errs.add("title", "is required")
+	case utf8.RuneCountInString(title) > MaxTitleLength:
+		errs.add("title", "must be at most %d characters", MaxTitleLength)
+	}
+
+	switch {
+	case story.Language == "":
+		errs.add("language", "is required")
+	case len(story.Language) > MaxLanguageLength:
+		errs.add("language", "must be at most %d characters", MaxLanguageLength)
+	}
+
+	if len(story.Characters) > MaxCharacters {
+		errs.add("characters", "must have at most %d entries", MaxCharacters)
+	}
+
+	if len(story.Metadata) > MaxMetadataEntries {
+		errs.add("metadata", "must have at most %d entries", MaxMetadataEntries)
+	}
+	for _, key := range slices.Sorted(maps.Keys(story.Metadata)) {
+		if err := MetadataKey(key); err != nil {
+			errs.add("metadata."+key, "%v", err)
+		} else if utf8.RuneCountInString(story.Metadata[key]) > MaxMetadataValueLength {
+			errs.add("metadata."+key, "must be at most %d characters", MaxMetadataValueLength)
+		}
+	}
+
+	if len(story.AudioTracks) > MaxAudioTracks {
+		errs.add("audio_tracks", "must have at most %d entries", MaxAudioTracks)
+	}
+	trackIDs := map[primitive.ObjectID]bool{}
+	for i, track := range story.AudioTracks {
+		field := fmt.Sprintf("audio_tracks[%d]", i)
+		if !track.ID.IsZero() && trackIDs[track.ID] {
+			errs.add(field+".id", "is used by another track")
+		}
+		trackIDs[track.ID] = true
+		if track.URL == "" {
+			errs.add(field+".url", "is required")
+		}
+		mediaURL(&errs, field+".url", track.URL)
+	}
+
+	if len(story.Segments) > MaxSegments {
+		errs.add("segments", "must have at most %d entries", MaxSegments)
+	}
+	segmentIDs := map[primitive.ObjectID]bool{}
+	for i := range story.Segments {
+		field := fmt.Sprintf("segments[%d]", i)
+		if id := story.Segments[i].ID; !id.IsZero() {
+			if segmentIDs[id] {
+				errs.add(field+".id", "is used by another segment")
+			}
+			segmentIDs[id] = true
+		}
+		segment(&errs, field, &story.Segments[i], story.Language)
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// MetadataKey returns why key can't name a metadata entry, or nil if it can.
+func MetadataKey(key string) error {
+	switch {
+	case len(key) > MaxMetadataKeyLength:
+		return fmt.Errorf("key must be at most %d characters", MaxMetadataKeyLength)
+	case !metadataKey.MatchString(key):
+		return errors.New("key must only contain letters, digits, _ and -")
+	case strings.HasPrefix(strings.ToLower(key), ReservedMetadataPrefix):
+		return fmt.Errorf("keys starting with %s are reserved", ReservedMetadataPrefix)
+	}
+	return nil
+}
+
+// Publishable returns what keeps a story from being published, or nil if it
+// is complete: it needs segments, each with script text and audio, where
+// clips count as audio once their track is uploaded.
+func Publishable(story *api.StoryRequest) error {
+	uploaded := map[primitive.ObjectID]bool{}
+	for _, track := range story.AudioTracks {
+		if track.URL != "" {
+			uploaded[track.ID] = true
+		}
+	}
+
+	var errs Errors
+	if len(story.Segments) == 0 {
+		errs.add("segments", "must have at least one entry")
+	}
+	for i, s := range story.Segments {
+		field := fmt.Sprintf("segments[%d]", i)
+		if s.Script == nil || strings.TrimSpace(s.Script.Text) == "" {
+			errs.add(field+".script.text", "is required")
+		}
+		if (s.Audio == nil || s.Audio.URL == "") && s.Clip == nil {
+			errs.add(field+".audio.url", "is required unless the segment has a clip")
+		}
+		if s.Clip != nil && !uploaded[s.Clip.TrackID] {
+			errs.add(field+".clip.track_id", "must name an uploaded audio track")
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func segment(errs *Errors, field string, s *api.SegmentRequest, storyLanguage string) {
+	if s.Script != nil {
+		script(errs, field+".script", s.Script, storyLanguage)
+	}
+	if utf8.RuneCountInString(s.Speaker) > MaxSpeakerLength {
+		errs.add(field+".speaker", "must be at most %d characters", MaxSpeakerLength)
+	}
+	if s.Audio != nil {
+		mediaURL(errs, field+".audio.url", s.Audio.URL)
+	}
+	if s.Image != nil {
+		mediaURL(errs, field+".image.url", s.Image.URL)
+	}
+	if s.Clip != nil {
+		clip(errs, field+".clip", s.Clip)
+		if s.Audio != nil {
+			errs.add(field+".clip", "can't be set together with audio")
+		}
+	}
+}
+
+func clip(errs *Errors, field string, c *api.AudioClip) {
+	if c.TrackID.IsZero() {
+		errs.add(field+".track_id", "is required")
+	}
+	if c.StartMs < 0 {
+		errs.add(field+".start_ms", "must not be negative")
+	}
+	if c.EndMs <= c.StartMs {
+		errs.add(field+".end_ms", "must be after start_ms")
+	}
+}
+
+func script(errs *Errors, field string, s *api.Script, storyLanguage string) {
+	if utf8.RuneCountInString(s.Text) > MaxScriptLength {
+		errs.add(field+".text", "must be at most %d characters", MaxScriptLength)
+	}
+	if len(s.Language) > MaxLanguageLength {
+		errs.add(field+".language", "must be at most %d characters", MaxLanguageLength)
+	}
+
+	original := s.Language
+	if original == "" {
+		original = storyLanguage
+	}
+	if len(s.Translations) > MaxTranslations {
+		errs.add(field+".translations", "must have at most %d entries", MaxTranslations)
+	}
+	for _, lang := range slices.Sorted(maps.Keys(s.Translations)) {
+		text := s.Translations[lang]
+		switch err := TranslationLanguage(lang, original); {
+		case err != nil:
+			errs.add(field+".translations."+lang, "%v", err)
+		case strings.TrimSpace(text) == "":
+			errs.add(field+".translations."+lang, "must not be empty")
+		case utf8.RuneCountInString(text) > MaxScriptLength:
+			errs.add(field+".translations."+lang, "must be at most %d characters", MaxScriptLength)
+		}
+	}
+}
+
+// TranslationLanguage returns why lang can't key a translation of a script
+// written in original, or nil if it can.
+func TranslationLanguage(lang, original string) error {
+	switch {
+	case len(lang) > MaxLanguageLength:
+		return fmt.Errorf("language must be at most %d characters", MaxLanguageLength)
+	case !languageTag.MatchString(lang):
+		return errors.New("language must be a language tag such as sv or pt-BR")
+	case strings.EqualFold(lang, original):
+		return errors.New("language must differ from the original language of the script")
+	}
+	return nil
+}
+
+func mediaURL(errs *Errors, field, raw string) {
+	if raw == "" {
+		return
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		errs.add(field, "must be an absolute URL")
+		return
+	}
+	if !slices.Contains(AllowedURLSchemes, strings.ToLower(u.Scheme)) {
+		errs.add(field, "must use one of the schemes %s", strings.Join(AllowedURLSchemes, ", "))
+	}
+}
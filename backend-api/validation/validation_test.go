@@ -0,0 +1,73 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/api"
+)
+
+func TestStoryRejectsRepeatedIDs(t *testing.T) {
+	segmentID, trackID := primitive.NewObjectID(), primitive.NewObjectID()
+	tests := []struct {
+		name  string
+		story api.StoryRequest
+		field string
+	}{
+		{
+			name: "segments",
+			story: api.StoryRequest{Title: "Fox", Language: "en", Segments: []api.SegmentRequest{
+				{ID: segmentID}, {ID: primitive.NewObjectID()}, {ID: segmentID},
+			}},
+			field: "segments[2].id",
+		},
+		{
+			name: "tracks",
+			story: api.StoryRequest{Title: "Fox", Language: "en", AudioTracks: []api.AudioTrack{
+				{ID: trackID, URL: "https://example.com/a.mp3"}, {ID: trackID, URL: "https://example.com/b.mp3"},
+			}},
+			field: "audio_tracks[1].id",
+		},
+	}
+	for _, test := range tests {
+		var errs Errors
+		if !errors.As(Story(&test.story), &errs) {
+			t.Errorf("%s: repeated ID accepted", test.name)
+			continue
+		}
+		found := false
+		for _, e := range errs {
+			found = found || e.Field == test.field
+		}
+		if !found {
+			t.Errorf("%s: no error for %s in %v", test.name, test.field, errs)
+		}
+	}
+}
+
+func TestStoryAcceptsNewSegments(t *testing.T) {
+	story := api.StoryRequest{Title: "Fox", Language: "en", Segments: []api.SegmentRequest{{}, {}}}
+	if err := Story(&story); err != nil {
+		t.Errorf("segments without IDs rejected: %v", err)
+	}
+}
+
+func TestPublishableNeedsUploadedTracks(t *testing.T) {
+	trackID := primitive.NewObjectID()
+	story := api.StoryRequest{Title: "Fox", Language: "en", Segments: []api.SegmentRequest{{
+		Script: &api.Script{Text: "The fox"},
+		Clip:   &api.AudioClip{TrackID: trackID, StartMs: 0, EndMs: 1000},
+	}}}
+
+	var errs Errors
+	if !errors.As(Publishable(&story), &errs) || errs[0].Field != "segments[0].clip.track_id" {
+		t.Fatalf("clip of a track never uploaded accepted: %v", errs)
+	}
+
+	story.AudioTracks = []api.AudioTrack{{ID: trackID, URL: "https://example.com/fox.mp3"}}
+	if err := Publishable(&story); err != nil {
+		t.Errorf("clip of an uploaded track rejected: %v", err)
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"rosetta/api"
+	"rosetta/domain"
+	"rosetta/models"
+	"rosetta/repository"
+)
+
+const maxSaveAttempts = 3
+
+var errStoryChanged = domain.New(domain.ErrConflict, "Story was modified concurrently, please retry")
+
+// storyDelta carries only the segments changed after SinceVersion. SegmentIDs
+// lists every segment in order so clients can drop removed ones and reorder.
+type storyDelta struct {
+	api.StoryResponse
+	SinceVersion int64                `json:"since_version"`
+	SegmentIDs   []primitive.ObjectID `json:"segment_ids"`
+}
+
+func newStoryDelta(story *models.Story, sinceVersion int64) storyDelta {
+	delta := storyDelta{
+		StoryResponse: api.StoryFromModel(story),
+		SinceVersion:  sinceVersion,
+		SegmentIDs:    make([]primitive.ObjectID, 0, len(story.Segments)),
+	}
+
+	all := delta.Segments
+	delta.Segments = []api.SegmentResponse{}
+	for i, segment := range story.Segments {
+		delta.SegmentIDs = append(delta.SegmentIDs, segment.ID)
+		if segment.Version > sinceVersion {
+			delta.Segments = append(delta.Segments, all[i])
+		}
+	}
+	return delta
+}
+
+func storyETag(story *models.Story) string {
+	return fmt.Sprintf(`"v%d"`, story.Version)
+}
+
+// prepareUpdate readies story to replace current. The story version is bumped
+// and segments whose content changed are stamped with the new version, so
+// delta responses can tell what moved.
+func prepareUpdate(story *models.Story, current *models.Story) {
+	version := current.Version + 1
+
+	previous := make(map[primitive.ObjectID]models.Segment, len(current.Segments))
+	for _, segment := range current.Segments {
+		previous[segment.ID] = segment
+	}
+
+	for i, segment := range story.Segments {
+		if segment.ID.IsZero() {
+			story.Segments[i].ID = primitive.NewObjectID()
+		}
+
+		old, ok := previous[segment.ID]
+		if ok {
+			preserveServerFields(&story.Segments[i], &old)
+		}
+		if ok && segmentContentEqual(old, story.Segments[i]) {
+			story.Segments[i].Version = old.Version
+		} else {
+			story.Segments[i].Version = version
+		}
+	}
+
+	// Clients that don't know about metadata leave it alone
+	if story.Metadata == nil {
+		story.Metadata = current.Metadata
+	}
+
+	// published_at marks the latest move from draft to published
+	story.PublishedAt = current.PublishedAt
+	if story.IsPublished && !current.IsPublished {
+		now := time.Now()
+		story.PublishedAt = &now
+	}
+
+	story.ID = current.ID
+	story.ContentFingerprint = storyFingerprint(story)
+	story.UpdatedAt = time.Now()
+	story.Version = version
+}
+
+// preserveServerFields carries over fields clients never send, such as the
+// object key and metadata behind the audio URL and any upload awaiting
+// confirmation.
+func preserveServerFields(segment *models.Segment, old *models.Segment) {
+	preserveImageFields(segment, old)
+	if old.Audio == nil {
+		return
+	}
+	if segment.Audio == nil {
+		if old.Audio.PendingKey != "" {
+			segment.Audio = &models.Audio{PendingKey: old.Audio.PendingKey}
+		}
+		return
+	}
+	// Clients still holding the URL from before transcoding keep it too
+	if segment.Audio.Url == old.Audio.Url || (old.Audio.OriginalUrl != "" && segment.Audio.Url == old.Audio.OriginalUrl) {
+		*segment.Audio = *old.Audio
+	}
+	segment.Audio.PendingKey = old.Audio.PendingKey
+}
+
+func preserveImageFields(segment *models.Segment, old *models.Segment) {
+	if old.Image == nil {
+		return
+	}
+	if segment.Image == nil {
+		if old.Image.PendingKey != "" {
+			segment.Image = &models.Image{PendingKey: old.Image.PendingKey}
+		}
+		return
+	}
+	if segment.Image.Url == old.Image.Url {
+		segment.Image.Key = old.Image.Key
+	}
+	segment.Image.PendingKey = old.Image.PendingKey
+}
+
+func segmentContentEqual(a, b models.Segment) bool {
+	a.Version, b.Version = 0, 0
+	return reflect.DeepEqual(a, b)
+}
+
+// saveStory writes story over current, reporting false if the stored story
+// was changed by someone else since current was read.
+func saveStory(ctx context.Context, current *models.Story, story *models.Story) (bool, error) {
+	// Before the script filter, so kept translations are filtered too
+	preserveTranslations(story, current)
+	preserveAudioTracks(story, current)
+	normalizeScripts(story)
+	if err := validateStory(story); err != nil {
+		return false, err
+	}
+	if err := checkPublishable(story, current); err != nil {
+		return false, err
+	}
+	if err := filterScripts(ctx, story); err != nil {
+		return false, err
+	}
+	if err := stampContentHash(ctx, story); err != nil {
+		return false, err
+	}
+
+	prepareUpdate(story, current)
+	saved, err := storyRepo.Update(ctx, story, current.Version)
+	if err != nil || !saved {
+		return false, err
+	}
+
+	recordRevision(ctx, story)
+	scheduleRemovedSegmentsCleanup(ctx, current, story)
+	return true, nil
+}
+
+// updateSegment applies set to a single segment, bumping the story version and
+// stamping the segment with it. Keys in set are relative to the segment, e.g.
+// "audio.key".
+func updateSegment(ctx context.Context, storyID, segmentID primitive.ObjectID, set bson.M) error {
+	return updateSegmentIf(ctx, storyID, segmentID, nil, set)
+}
+
+// updateSegmentIf is updateSegment for changes that only make sense while
+// the segment is as check expects; its error is returned as is. A nil check
+// always passes.
+func updateSegmentIf(ctx context.Context, storyID, segmentID primitive.ObjectID, check func(segment *models.Segment) error, set bson.M) error {
+	collection := storiesCollection()
+	for attempt := 0; attempt < maxSaveAttempts; attempt++ {
+		current, err := findStory(ctx, storyID)
+		if err != nil {
+			return err
+		}
+		segment := findSegment(&current, segmentID)
+		if segment == nil {
+			return domain.New(domain.ErrNotFound, "Segment not found")
+		}
+		if check != nil {
+			if err = check(segment); err != nil {
+				return err
+			}
+		}
+
+		version := current.Version + 1
+		update := bson.M{
+			"updated_at":         time.Now(),
+			"version":            version,
+			"segments.$.version": version,
+		}
+		for key, value := range set {
+			update["segments.$."+key] = value
+		}
+
+		filter := bson.M{"_id": storyID, "version": repository.VersionFilter(current.Version), "segments._id": segmentID}
+		res, err := collection.UpdateOne(ctx, filter, bson.M{"$set": update})
+		if err != nil {
+			return err
+		}
+		if res.MatchedCount == 1 {
+			if updated, err := findStory(ctx, storyID); err == nil {
+				recordRevision(ctx, &updated)
+			}
+			return nil
+		}
+	}
+	return errStoryChanged
+}
+
+// modifyStory applies fn to a copy of the stored story and saves the result,
+// re-reading and retrying if a concurrent write lands in between.
+func modifyStory(ctx context.Context, id primitive.ObjectID, fn func(story *models.Story) error) (models.Story, error) {
+	for attempt := 0; attempt < maxSaveAttempts; attempt++ {
+		current, err := findStory(ctx, id)
+		if err != nil {
+			return current, err
+		}
+
+		story := current
+		story.Segments = append([]models.Segment(nil), current.Segments...)
+		if err = fn(&story); err != nil {
+			return current, err
+		}
+
+		saved, err := saveStory(ctx, &current, &story)
+		if err != nil {
+			return current, err
+		}
+		if saved {
+			return findStory(ctx, id)
+		}
+	}
+	return models.Story{}, errStoryChanged
+}
+
+// updateStoryContent replaces the content of a story, retrying when a
+// concurrent write lands between the read and the guarded update. A non-zero
+// since rejects the update if the story changed after that time. A non-zero
+// baseVersion names the version the update was made against; if the story
+// moved on since, the update is merged with what changed in between.
+func updateStoryContent(ctx context.Context, id primitive.ObjectID, story *models.Story, since time.Time, baseVersion int64) error {
+	for attempt := 0; attempt < maxSaveAttempts; attempt++ {
+		current, err := findStory(ctx, id)
+		if err != nil {
+			return err
+		}
+		if modifiedAfter(&current, since) {
+			return errPreconditionFailed
+		}
+
+		update := *story
+		update.Segments = append([]models.Segment(nil), story.Segments...)
+		if baseVersion != 0 && baseVersion != current.Version {
+			base, err := findRevision(ctx, id, baseVersion)
+			if err != nil {
+				return err
+			}
+			merged, conflicts := mergeStory(&base, &current, story)
+			if len(conflicts) > 0 {
+				return &mergeConflictError{BaseVersion: baseVersion, CurrentVersion: current.Version, Conflicts: conflicts}
+			}
+			update = merged
+		}
+
+		saved, err := saveStory(ctx, &current, &update)
+		if err != nil {
+			return err
+		}
+		if saved {
+			return nil
+		}
+	}
+	return errStoryChanged
+}